@@ -18,7 +18,6 @@ package main
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"flag"
 	"fmt"
@@ -38,6 +37,7 @@ import (
 
 	"github.com/opsmx/oes-birger/app/agent/cfg"
 	"github.com/opsmx/oes-birger/pkg/secrets"
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"github.com/opsmx/oes-birger/pkg/updater"
 	"github.com/opsmx/oes-birger/pkg/util"
@@ -58,13 +58,16 @@ var (
 
 	hostname = getHostname()
 
-	secretsLoader secrets.SecretLoader
+	// binaryHash is the hash of our own executable, used to decide if
+	// we need to update ourselves.
+	binaryHash string
+
+	secretsLoader  secrets.SecretLoader
+	secretsUpdater secrets.SecretUpdater
 
 	endpoints []configuredEndpoint
 )
 
-type serverContext struct{}
-
 type configuredEndpoint struct {
 	Name       string   `json:"name,omitempty"`
 	Type       string   `json:"type,omitempty"`
@@ -75,7 +78,12 @@ type configuredEndpoint struct {
 }
 
 type httpRequestProcessor interface {
-	executeHTTPRequest(chan *tunnel.AgentToControllerWrapper, *tunnel.HttpRequest)
+	executeHTTPRequest(context.Context, chan *tunnel.AgentToControllerWrapper, *tunnel.HttpRequest)
+}
+
+// connectionProcessor is implemented by endpoints which forward TCP connections.
+type connectionProcessor interface {
+	openConnection(context.Context, chan *tunnel.AgentToControllerWrapper, *tunnel.OpenConnection)
 }
 
 func (e *configuredEndpoint) String() string {
@@ -96,45 +104,94 @@ func endpointsToPB(endpoints []configuredEndpoint) []*tunnel.EndpointHealth {
 	return pbEndpoints
 }
 
-func tickerPinger(stream tunnel.AgentTunnelService_EventTunnelClient) {
-	ticker := time.NewTicker(time.Duration(*tickTime) * time.Second)
+// agentSession tracks the goroutines started for one tunnel session, so
+// the session's dataflow channel can be closed once they have all exited.
+type agentSession struct {
+	sync.WaitGroup
+	dataflow chan *tunnel.AgentToControllerWrapper
+}
 
-	for ts := range ticker.C {
-		req := &tunnel.AgentToControllerWrapper{
-			Event: &tunnel.AgentToControllerWrapper_PingRequest{
-				PingRequest: &tunnel.PingRequest{Ts: uint64(ts.UnixNano())},
-			},
-		}
-		if err := stream.Send(req); err != nil {
-			log.Fatalf("Unable to send a PingRequest: %v", err)
+func (s *agentSession) run(f func()) {
+	s.Add(1)
+	go func() {
+		defer s.Done()
+		f()
+	}()
+}
+
+// runCancellable registers the cancel function for a request before
+// starting it, so a cancel which arrives before the goroutine runs is
+// not lost.
+func (s *agentSession) runCancellable(id string, f func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	registerCancelFunction(id, cancel)
+	s.run(func() {
+		defer unregisterCancelFunction(id)
+		defer cancel()
+		f(ctx)
+	})
+}
+
+func tickerPinger(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper) {
+	ticker := time.NewTicker(time.Duration(*tickTime) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ts := <-ticker.C:
+			dataflow <- &tunnel.AgentToControllerWrapper{
+				Event: &tunnel.AgentToControllerWrapper_PingRequest{
+					PingRequest: &tunnel.PingRequest{Ts: uint64(ts.UnixNano())},
+				},
+			}
 		}
 	}
 }
 
-func dataflowHandler(dataflow chan *tunnel.AgentToControllerWrapper, stream tunnel.AgentTunnelService_EventTunnelClient) {
+// dataflowHandler sends everything on the dataflow channel to the controller
+// until the channel is closed.  If a send fails, the session is cancelled,
+// and further messages are discarded.
+func dataflowHandler(cancel context.CancelFunc, dataflow chan *tunnel.AgentToControllerWrapper, stream tunnel.AgentTunnelService_EventTunnelClient) {
+	failed := false
 	for ew := range dataflow {
+		if failed {
+			continue
+		}
 		if err := stream.Send(ew); err != nil {
-			log.Fatalf("Unable to respond over GRPC: %v", err)
+			log.Printf("Unable to respond over GRPC: %v", err)
+			failed = true
+			cancel()
 		}
 	}
-
 }
 
-func runTunnel(wg *sync.WaitGroup, sa *serverContext, conn *grpc.ClientConn, endpoints []configuredEndpoint) {
-	defer wg.Done()
+// runTunnel runs one session with the controller, returning when the
+// connection fails.  In-flight requests are cancelled before it returns.
+func runTunnel(conn *grpc.ClientConn, endpoints []configuredEndpoint) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	client := tunnel.NewAgentTunnelServiceClient(conn)
-	ctx := context.Background()
 
 	stream, err := client.EventTunnel(ctx)
 	if err != nil {
-		log.Fatalf("%v.EventTunnel(_) = _, %v", client, err)
+		return fmt.Errorf("%v.EventTunnel(_) = _, %v", client, err)
 	}
-	pbEndpoints := endpointsToPB(endpoints)
+	pbEndpoints := append(endpointsToPB(endpoints), commandHostsToPB()...)
+	pbEndpoints = append(pbEndpoints, localCommandsToPB()...)
 	helloMsg := &tunnel.AgentHello{
-		Version:   version.String(),
-		Endpoints: pbEndpoints,
-		Hostname:  hostname,
+		Version:    version.String(),
+		Endpoints:  pbEndpoints,
+		Hostname:   hostname,
+		Os:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		BinaryHash: binaryHash,
+
+		StreamsRequestBodies: true,
+		TunnelsUpgrades:      true,
+		PacesCommandInput:    true,
 	}
 	hello := &tunnel.AgentToControllerWrapper{
 		Event: &tunnel.AgentToControllerWrapper_AgentHello{
@@ -142,67 +199,188 @@ func runTunnel(wg *sync.WaitGroup, sa *serverContext, conn *grpc.ClientConn, end
 		},
 	}
 	if err = stream.Send(hello); err != nil {
-		log.Fatalf("Unable to send hello packet: %v", err)
+		return fmt.Errorf("unable to send hello packet: %v", err)
 	}
 
-	dataflow := make(chan *tunnel.AgentToControllerWrapper, 20)
-
-	go tickerPinger(stream)
-	go dataflowHandler(dataflow, stream)
+	session := &agentSession{
+		dataflow: make(chan *tunnel.AgentToControllerWrapper, 20),
+	}
 
-	waitc := make(chan struct{})
+	sendDone := make(chan struct{})
 	go func() {
-		for {
-			in, err := stream.Recv()
-			if err == io.EOF {
-				// Server has closed the connection.
-				close(waitc)
-				return
+		dataflowHandler(cancel, session.dataflow, stream)
+		close(sendDone)
+	}()
+	session.run(func() { tickerPinger(ctx, session.dataflow) })
+	session.run(func() { certificateRenewer(ctx, session.dataflow) })
+
+	err = receiveLoop(stream, session, endpoints)
+	abortAgentUpdate()
+	clientCertificate.endRenewal()
+
+	// Stop everything started by this session.  Responses they send
+	// while shutting down are discarded by the dataflowHandler.
+	cancel()
+	cancelAllFunctions()
+	session.Wait()
+	close(session.dataflow)
+	<-sendDone
+	return err
+}
+
+func receiveLoop(stream tunnel.AgentTunnelService_EventTunnelClient, session *agentSession, endpoints []configuredEndpoint) error {
+	dataflow := session.dataflow
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("controller closed the connection")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to receive a message: %T: %v", err, err)
+		}
+		switch x := in.Event.(type) {
+		case *tunnel.ControllerToAgentWrapper_PingResponse:
+			continue
+		case *tunnel.ControllerToAgentWrapper_CancelRequest:
+			req := in.GetCancelRequest()
+			callCancelFunction(req.Id)
+		case *tunnel.ControllerToAgentWrapper_HttpRequest:
+			req := in.GetHttpRequest()
+			found := false
+			for _, endpoint := range endpoints {
+				if endpoint.Configured && endpoint.Type == req.Type && endpoint.Name == req.Name {
+					instance := endpoint.instance
+					if req.BodyStreamed || req.Upgrade {
+						registerRequestBody(req.Id, dataflow)
+					}
+					session.runCancellable(req.Id, func(ctx context.Context) { instance.executeHTTPRequest(ctx, dataflow, req) })
+					found = true
+					break
+				}
 			}
-			if err != nil {
-				log.Fatalf("Failed to receive a message: %T: %v", err, err)
+			if !found {
+				log.Printf("Request for unsupported HTTP tunnel type=%s name=%s", req.Type, req.Name)
+				dataflow <- makeBadGatewayResponse(req.Id)
 			}
-			switch x := in.Event.(type) {
-			case *tunnel.ControllerToAgentWrapper_PingResponse:
-				continue
-			case *tunnel.ControllerToAgentWrapper_CancelRequest:
-				req := in.GetCancelRequest()
-				callCancelFunction(req.Id)
-			case *tunnel.ControllerToAgentWrapper_HttpRequest:
-				req := in.GetHttpRequest()
-				found := false
-				for _, endpoint := range endpoints {
-					if endpoint.Configured && endpoint.Type == req.Type && endpoint.Name == req.Name {
-						go endpoint.instance.executeHTTPRequest(dataflow, req)
+		case *tunnel.ControllerToAgentWrapper_OpenConnection:
+			req := in.GetOpenConnection()
+			found := false
+			for _, endpoint := range endpoints {
+				if endpoint.Configured && endpoint.Type == req.Type && endpoint.Name == req.Name {
+					if instance, ok := endpoint.instance.(connectionProcessor); ok {
+						registerRequestBody(req.Id, dataflow)
+						session.runCancellable(req.Id, func(ctx context.Context) { instance.openConnection(ctx, dataflow, req) })
 						found = true
-						break
 					}
+					break
 				}
-				if !found {
-					log.Printf("Request for unsupported HTTP tunnel type=%s name=%s", req.Type, req.Name)
-					dataflow <- makeBadGatewayResponse(req.Id)
-				}
-			case *tunnel.ControllerToAgentWrapper_CommandRequest:
-				req := in.GetCommandRequest()
-				log.Printf("Got cmd request: %s %v %v", req.Name, req.Arguments, req.Environment)
-				switch req.Name {
-				case "sh":
-					log.Printf("Running 'sh'")
-					go runCommand(dataflow, req)
-				default:
-					log.Printf("Unknown command %s", req.Name)
-					dataflow <- makeCommandFailed(req, nil, "Agent: Unknown command")
+			}
+			if !found {
+				log.Printf("Connection for unsupported endpoint type=%s name=%s", req.Type, req.Name)
+				dataflow <- makeConnectionOpened(req.Id, "no such endpoint")
+			}
+		case *tunnel.ControllerToAgentWrapper_CommandRequest:
+			req := in.GetCommandRequest()
+			log.Printf("Got cmd request: %s %v %v", req.Name, req.Arguments, req.Environment)
+			if host, found := commandHosts[req.Name]; found {
+				var input *commandInput
+				if req.Stdin {
+					input = registerCommandInput(req.Id, dataflow)
 				}
-			case nil:
+				session.runCancellable(req.Id, func(ctx context.Context) {
+					traceCommand(ctx, req, func() { host.runCommand(ctx, dataflow, req, input) })
+				})
 				continue
-			default:
-				log.Printf("Received unknown message: %T", x)
 			}
+			command, found := localCommands[req.Name]
+			if !found {
+				log.Printf("Unknown command %s", req.Name)
+				dataflow <- makeCommandFailed(req, nil, "Agent: Unknown command")
+				continue
+			}
+			if err := command.checkRequest(req); err != nil {
+				log.Printf("Rejected command request: %v", err)
+				dataflow <- makeCommandFailed(req, nil, fmt.Sprintf("Agent: %v", err))
+				continue
+			}
+			var input *commandInput
+			if req.Stdin {
+				input = registerCommandInput(req.Id, dataflow)
+			}
+			session.runCancellable(req.Id, func(ctx context.Context) {
+				traceCommand(ctx, req, func() { runCommand(ctx, dataflow, command, req, input) })
+			})
+		case *tunnel.ControllerToAgentWrapper_CommandData:
+			deliverCommandInput(in.GetCommandData())
+		case *tunnel.ControllerToAgentWrapper_CommandResize:
+			resizeTerminal(in.GetCommandResize())
+		case *tunnel.ControllerToAgentWrapper_WindowUpdate:
+			updateSendWindow(in.GetWindowUpdate())
+		case *tunnel.ControllerToAgentWrapper_HttpRequestBody:
+			deliverRequestBody(in.GetHttpRequestBody())
+		case *tunnel.ControllerToAgentWrapper_ConnectionData:
+			deliverConnectionData(in.GetConnectionData())
+		case *tunnel.ControllerToAgentWrapper_AgentBinaryInfo:
+			handleAgentBinaryInfo(dataflow, in.GetAgentBinaryInfo())
+		case *tunnel.ControllerToAgentWrapper_AgentBinaryChunk:
+			handleAgentBinaryChunk(in.GetAgentBinaryChunk())
+		case *tunnel.ControllerToAgentWrapper_AgentCertificate:
+			msg := in.GetAgentCertificate()
+			session.run(func() { handleAgentCertificate(msg) })
+		case *tunnel.ControllerToAgentWrapper_AgentTrustBundle:
+			msg := in.GetAgentTrustBundle()
+			session.run(func() { handleTrustBundle(msg) })
+		case nil:
+			continue
+		default:
+			log.Printf("Received unknown message: %T", x)
 		}
-	}()
-	<-waitc
-	close(dataflow)
-	_ = stream.CloseSend()
+	}
+}
+
+// dialOptions returns the options for connecting to the controller, using
+// our current certificate and trusted CAs.
+func dialOptions() []grpc.DialOption {
+	ta := credentials.NewTLS(&tls.Config{
+		GetClientCertificate: clientCertificate.GetClientCertificate,
+		RootCAs:              trustedCAs.get(),
+	})
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(ta),
+		grpc.WithBlock(),
+	}
+}
+
+// connectAndRunTunnel dials the controller and runs a single tunnel session.
+func connectAndRunTunnel() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, config.ControllerHostname, dialOptions()...)
+	if err != nil {
+		return fmt.Errorf("could not connect: %v", err)
+	}
+	defer conn.Close()
+
+	log.Printf("Starting GRPC tunnel.")
+	return runTunnel(conn, endpoints)
+}
+
+// runForever keeps a tunnel session to the controller running, waiting a
+// little longer after each failure.  Once a session has been up for a while,
+// the delay is reset.
+func runForever() {
+	delay := newBackoff(minReconnectDelay, maxReconnectDelay)
+	for {
+		started := time.Now()
+		err := connectAndRunTunnel()
+		if time.Since(started) > stableSessionTime {
+			delay.reset()
+		}
+		wait := delay.next()
+		log.Printf("Tunnel session ended: %v, reconnecting in %v", err, wait)
+		time.Sleep(wait)
+	}
 }
 
 func loadCert() []byte {
@@ -237,6 +415,8 @@ func configureEndpoints(secretsLoader secrets.SecretLoader) {
 				instance, configured, err = MakeKubernetesEndpoint(service.Name, config)
 			case "aws":
 				instance, configured, err = MakeAwsEndpoint(service.Name, config, secretsLoader)
+			case "tcp":
+				instance, configured, err = MakeTCPEndpoint(service.Name, config)
 			default:
 				instance, configured, err = MakeGenericEndpoint(service.Type, service.Name, config, secretsLoader)
 			}
@@ -286,12 +466,12 @@ func main() {
 
 	var err error
 
-	arg0hash, err := updater.HashSelf()
+	binaryHash, err = updater.HashSelf()
 	if err != nil {
 		log.Printf("Could not hash self: %v", err)
-		arg0hash = "unknown"
+		binaryHash = "unknown"
 	}
-	log.Printf("Binary hash: %s\n", arg0hash)
+	log.Printf("Binary hash: %s\n", binaryHash)
 
 	flag.Parse()
 
@@ -301,10 +481,12 @@ func main() {
 	if !ok {
 		log.Fatalf("envar POD_NAMESPACE not set to the pod's namespace")
 	}
-	secretsLoader, err = secrets.MakeKubernetesSecretLoader(namespace)
+	kubernetesSecrets, err := secrets.MakeKubernetesSecretLoader(namespace)
 	if err != nil {
 		log.Fatal(err)
 	}
+	secretsLoader = kubernetesSecrets
+	secretsUpdater = kubernetesSecrets
 
 	c, err := cfg.Load(*configFile)
 	if err != nil {
@@ -313,6 +495,14 @@ func main() {
 	config = c
 	log.Printf("controller hostname: %s", config.ControllerHostname)
 
+	shutdownTracing, err := tracing.Setup(context.Background(), "agent", config.Tracing)
+	if err != nil {
+		log.Fatalf("Unable to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	go runPrometheusHTTPServer(config.PrometheusListenPort)
+
 	uc, err := cfg.LoadServiceConfig(config.ServicesConfigPath)
 	if err != nil {
 		log.Fatalf("Error loading services config: %v", err)
@@ -320,45 +510,20 @@ func main() {
 	agentServiceConfig = uc
 
 	configureEndpoints(secretsLoader)
+	configureCommandHosts()
+	configureLocalCommands()
 
 	// load client cert/key, cacert
 	clcert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
 	if err != nil {
 		log.Fatalf("Unable to load agent certificate or key: %v", err)
 	}
-	caCertPool := x509.NewCertPool()
-	srvcert := loadCert()
-	if ok := caCertPool.AppendCertsFromPEM(srvcert); !ok {
-		log.Fatalf("Unable to append certificate to pool: %v", err)
-	}
-
-	ta := credentials.NewTLS(&tls.Config{
-		Certificates: []tls.Certificate{clcert},
-		RootCAs:      caCertPool,
-	})
-
-	sa := &serverContext{}
-
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(ta),
-		grpc.WithBlock(),
+	if err := clientCertificate.set(clcert); err != nil {
+		log.Fatal(err)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	conn, err := grpc.DialContext(ctx, config.ControllerHostname, opts...)
-	if err != nil {
-		log.Fatalf("Could not connect: %v", err)
+	if err := trustedCAs.set(loadCert()); err != nil {
+		log.Fatalf("Unable to load CA certificate: %v", err)
 	}
-	defer conn.Close()
-
-	var wg sync.WaitGroup
-
-	log.Printf("Starting GRPC tunnel.")
-	wg.Add(1)
-	go runTunnel(&wg, sa, conn, endpoints)
 
-	wg.Wait()
-	log.Printf("Done.")
+	runForever()
 }
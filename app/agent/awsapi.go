@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -110,7 +111,8 @@ func MakeAwsEndpoint(name string, configBytes []byte, secretsLoader secrets.Secr
 	return k, true, nil
 }
 
-func (a *AwsEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+func (a *AwsEndpoint) executeHTTPRequest(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+	defer unregisterRequestBody(req.Id)
 	log.Printf("Running request %v", req)
 	tlsConfig := &tls.Config{
 		MinVersion: tls.VersionTLS12,
@@ -142,14 +144,27 @@ func (a *AwsEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToController
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	registerCancelFunction(req.Id, cancel)
-	defer unregisterCancelFunction(req.Id)
+	ctx, span := startRequestSpan(ctx, req)
+	defer span.End()
 
 	baseURL := fmt.Sprintf("https://%s:%s", host, port)
 	actualurl := fmt.Sprintf("https://%s:%s%s", host, port, req.URI)
 
-	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, actualurl, bytes.NewBuffer(req.Body))
+	// The signature covers a hash of the body, so a streamed body is
+	// copied to a file which can be read once to sign and again to send.
+	var body io.ReadSeeker = bytes.NewReader(req.Body)
+	if req.BodyStreamed {
+		spooled, err := spoolRequestBody(ctx, req)
+		if err != nil {
+			log.Printf("Failed to receive request body for %s to %s: %v", req.Method, actualurl, err)
+			dataflow <- makeBadGatewayResponse(req.Id)
+			return
+		}
+		defer spooled.Close()
+		body = spooled
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, actualurl, body)
 	if err != nil {
 		log.Printf("Failed to build request for %s to %s: %v", req.Method, actualurl, err)
 		dataflow <- makeBadGatewayResponse(req.Id)
@@ -166,8 +181,10 @@ func (a *AwsEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToController
 		}
 	}
 
-	bodyBuffer := bytes.NewReader(req.Body)
-	_, err = a.signer.Sign(httpRequest, bodyBuffer, signerService, signingRegion, ts)
+	if spooled, ok := body.(*spooledBody); ok {
+		httpRequest.ContentLength = spooled.size
+	}
+	_, err = a.signer.Sign(httpRequest, body, signerService, signingRegion, ts)
 	if err != nil {
 		log.Printf("Failed to sign AWS request: %v", err)
 		dataflow <- makeBadGatewayResponse(req.Id)
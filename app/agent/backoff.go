@@ -0,0 +1,66 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	minReconnectDelay = 1 * time.Second
+	maxReconnectDelay = 2 * time.Minute
+
+	// A session which lasts this long is considered to have worked,
+	// and the reconnect delay starts over.
+	stableSessionTime = 5 * time.Minute
+)
+
+// backoff computes jittered, exponentially increasing delays.  Each delay
+// is chosen at random between half and all of the current limit, so many
+// agents which lose their controller at the same time do not all
+// reconnect at once.
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+	rnd     *rand.Rand
+}
+
+func newBackoff(min time.Duration, max time.Duration) *backoff {
+	return &backoff{
+		min:     min,
+		max:     max,
+		current: min,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// next returns the delay to use, and doubles the limit for next time.
+func (b *backoff) next() time.Duration {
+	half := b.current / 2
+	delay := half + time.Duration(b.rnd.Int63n(int64(half)+1))
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
+	}
+	return delay
+}
+
+func (b *backoff) reset() {
+	b.current = b.min
+}
@@ -0,0 +1,46 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Second, 10*time.Second)
+
+	limits := []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+	for i, limit := range limits {
+		got := b.next()
+		if got < limit/2 || got > limit {
+			t.Errorf("next() #%d = %v, want between %v and %v", i, got, limit/2, limit)
+		}
+	}
+
+	b.reset()
+	if got := b.next(); got < 500*time.Millisecond || got > time.Second {
+		t.Errorf("next() after reset = %v, want between 500ms and 1s", got)
+	}
+}
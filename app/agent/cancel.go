@@ -43,7 +43,20 @@ func callCancelFunction(id string) {
 	cancelRegistry.Lock()
 	defer cancelRegistry.Unlock()
 	cancel, ok := cancelRegistry.m[id]
-	if ok {
+	if !ok {
+		unknownIDDropsCounter.WithLabelValues("cancel").Inc()
+		return
+	}
+	cancel()
+	log.Printf("Cancelling request %s", id)
+}
+
+// cancelAllFunctions cancels every outstanding request, such as when the
+// session they arrived on has ended.
+func cancelAllFunctions() {
+	cancelRegistry.Lock()
+	defer cancelRegistry.Unlock()
+	for id, cancel := range cancelRegistry.m {
 		cancel()
 		log.Printf("Cancelling request %s", id)
 	}
@@ -0,0 +1,54 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAgentSession_runCancellable_earlyCancel(t *testing.T) {
+	session := &agentSession{}
+	start := make(chan struct{})
+	var err error
+	session.runCancellable("early-cancel", func(ctx context.Context) {
+		<-start
+		err = ctx.Err()
+	})
+
+	// the cancel arrives before the request's goroutine has done anything.
+	callCancelFunction("early-cancel")
+	close(start)
+	session.Wait()
+
+	if err != context.Canceled {
+		t.Errorf("context error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestAgentSession_runCancellable_unregisters(t *testing.T) {
+	session := &agentSession{}
+	session.runCancellable("finished", func(ctx context.Context) {})
+	session.Wait()
+
+	cancelRegistry.Lock()
+	_, found := cancelRegistry.m["finished"]
+	cancelRegistry.Unlock()
+	if found {
+		t.Error("cancel function still registered after the request finished")
+	}
+}
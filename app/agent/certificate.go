@@ -0,0 +1,286 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// certificateCheckInterval is how often we check if our certificate
+// needs to be renewed, in addition to the check when a session starts.
+const certificateCheckInterval = time.Hour
+
+// agentCertificate holds our client certificate, which is replaced when
+// the controller sends us a renewed one.  New connections to the
+// controller use the current certificate.  The key for a renewal is
+// generated here and only its certificate request is sent, so it never
+// leaves the agent.
+type agentCertificate struct {
+	sync.Mutex
+	cert       *tls.Certificate
+	leaf       *x509.Certificate
+	pending    bool
+	pendingKey crypto.Signer
+}
+
+var clientCertificate = &agentCertificate{}
+
+// controllerTrust holds the CA certificates we trust for the controller's
+// server certificate.  The controller sends us a new set before it
+// switches to a new CA, and new connections use the current set.
+type controllerTrust struct {
+	sync.Mutex
+	pool *x509.CertPool
+}
+
+var trustedCAs = &controllerTrust{}
+
+// set replaces the trusted CAs with the PEM encoded certificates.
+func (t *controllerTrust) set(certs []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certs) {
+		return fmt.Errorf("no CA certificates found")
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.pool = pool
+	return nil
+}
+
+func (t *controllerTrust) get() *x509.CertPool {
+	t.Lock()
+	defer t.Unlock()
+	return t.pool
+}
+
+func (c *agentCertificate) set(cert tls.Certificate) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse agent certificate: %v", err)
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.cert = &cert
+	c.leaf = leaf
+	return nil
+}
+
+// GetClientCertificate is used as the tls.Config callback, so each new
+// connection picks up the current certificate.
+func (c *agentCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.cert, nil
+}
+
+// startRenewal returns true, and marks a renewal as in progress, if two
+// thirds of the certificate's validity period has passed and we have not
+// already asked for a new one.
+func (c *agentCertificate) startRenewal(now time.Time) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.pending || c.leaf == nil {
+		return false
+	}
+	lifetime := c.leaf.NotAfter.Sub(c.leaf.NotBefore)
+	if now.Before(c.leaf.NotBefore.Add(lifetime / 3 * 2)) {
+		return false
+	}
+	c.pending = true
+	return true
+}
+
+// endRenewal allows another renewal to be started.  It is called when the
+// controller replies, or the session ends before it does.
+func (c *agentCertificate) endRenewal() {
+	c.Lock()
+	defer c.Unlock()
+	c.pending = false
+	c.pendingKey = nil
+}
+
+// makeRequest generates a new key of the same type and size as our
+// current one, and returns a PEM encoded certificate signing request
+// for it.  The key is kept until the controller replies.
+func (c *agentCertificate) makeRequest() ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	key, err := generateKeyLike(c.leaf.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.CertificateRequest{RawSubject: c.leaf.RawSubject}
+	der, err := x509.CreateCertificateRequest(crand.Reader, template, key)
+	if err != nil {
+		return nil, err
+	}
+	c.pendingKey = key
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// renewed pairs the certificate the controller sent with the key we
+// generated for it, returning the certificate and the PEM encoded key.
+func (c *agentCertificate) renewed(certPEM []byte) (tls.Certificate, []byte, error) {
+	c.Lock()
+	key := c.pendingKey
+	c.Unlock()
+	if key == nil {
+		return tls.Certificate{}, nil, fmt.Errorf("no certificate request is pending")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	return cert, keyPEM, nil
+}
+
+// generateKeyLike makes a new private key of the same type and size as pub.
+func generateKeyLike(pub crypto.PublicKey) (crypto.Signer, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(crand.Reader, key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(key.Curve, crand.Reader)
+	case ed25519.PublicKey:
+		_, priv, err := ed25519.GenerateKey(crand.Reader)
+		return priv, err
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func (c *agentCertificate) notAfter() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.leaf.NotAfter
+}
+
+func makeAgentCertificateRequest(csr []byte) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_AgentCertificateRequest{
+			AgentCertificateRequest: &tunnel.AgentCertificateRequest{
+				Csr: csr,
+			},
+		},
+	}
+}
+
+// certificateRenewer asks the controller for a new certificate once ours
+// is close to expiry.
+func certificateRenewer(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper) {
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if clientCertificate.startRenewal(time.Now()) {
+			log.Printf("Agent certificate expires %s, requesting a new one",
+				clientCertificate.notAfter().Format(time.RFC3339))
+			csr, err := clientCertificate.makeRequest()
+			if err != nil {
+				log.Printf("Unable to make a certificate request: %v", err)
+				clientCertificate.endRenewal()
+			} else {
+				dataflow <- makeAgentCertificateRequest(csr)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleAgentCertificate switches to the renewed certificate, which will
+// be used when we next connect, and saves it to our Kubernetes secret so
+// it is also used after a restart.
+func handleAgentCertificate(msg *tunnel.AgentCertificate) {
+	defer clientCertificate.endRenewal()
+
+	if len(msg.Error) > 0 {
+		log.Printf("Controller could not renew our certificate: %s", msg.Error)
+		return
+	}
+	cert, keyPEM, err := clientCertificate.renewed(msg.Certificate)
+	if err != nil {
+		log.Printf("Unable to use renewed certificate: %v", err)
+		return
+	}
+	if err := clientCertificate.set(cert); err != nil {
+		log.Printf("Unable to use renewed certificate: %v", err)
+		return
+	}
+	log.Printf("Renewed agent certificate, valid until %s",
+		clientCertificate.notAfter().Format(time.RFC3339))
+
+	if len(config.CertificateSecretName) == 0 {
+		log.Printf("WARNING: certificateSecretName is not set, renewed certificate will be lost on restart")
+		return
+	}
+	err = secretsUpdater.UpdateSecret(config.CertificateSecretName, map[string][]byte{
+		filepath.Base(config.CertFile): msg.Certificate,
+		filepath.Base(config.KeyFile):  keyPEM,
+	})
+	if err != nil {
+		log.Printf("Unable to save renewed certificate to secret %s: %v", config.CertificateSecretName, err)
+		return
+	}
+	log.Printf("Saved renewed certificate to secret %s", config.CertificateSecretName)
+}
+
+// handleTrustBundle switches to the CA certificates the controller sent,
+// which will be used when we next connect, and saves them to our
+// Kubernetes secret, using the base name of caCertFile as the key.
+func handleTrustBundle(msg *tunnel.AgentTrustBundle) {
+	if err := trustedCAs.set(msg.CaCertificates); err != nil {
+		log.Printf("Unable to use trust bundle from the controller: %v", err)
+		return
+	}
+	log.Printf("Updated the trusted controller CA certificates")
+
+	if len(config.CertificateSecretName) == 0 {
+		return
+	}
+	err := secretsUpdater.UpdateSecret(config.CertificateSecretName, map[string][]byte{
+		filepath.Base(*caCertFile): msg.CaCertificates,
+	})
+	if err != nil {
+		log.Printf("Unable to save trust bundle to secret %s: %v", config.CertificateSecretName, err)
+		return
+	}
+	log.Printf("Saved trust bundle to secret %s", config.CertificateSecretName)
+}
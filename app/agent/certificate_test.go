@@ -0,0 +1,132 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+)
+
+func TestAgentCertificate_startRenewal(t *testing.T) {
+	notBefore := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &agentCertificate{
+		leaf: &x509.Certificate{
+			NotBefore: notBefore,
+			NotAfter:  notBefore.Add(300 * time.Hour),
+		},
+	}
+
+	if c.startRenewal(notBefore.Add(199 * time.Hour)) {
+		t.Errorf("startRenewal() = true before two thirds of the lifetime")
+	}
+	if !c.startRenewal(notBefore.Add(200 * time.Hour)) {
+		t.Errorf("startRenewal() = false at two thirds of the lifetime")
+	}
+	if c.startRenewal(notBefore.Add(201 * time.Hour)) {
+		t.Errorf("startRenewal() = true while a renewal is pending")
+	}
+	c.endRenewal()
+	if !c.startRenewal(notBefore.Add(202 * time.Hour)) {
+		t.Errorf("startRenewal() = false after the pending renewal ended")
+	}
+}
+
+func TestAgentCertificate_renewal(t *testing.T) {
+	caCert, caKey, err := ca.MakeCertificateAuthority(ca.KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authority, err := ca.MakeCAFromData(caCert, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := ca.CertificateName{Agent: "smith", Purpose: ca.CertificatePurposeAgent, KeyType: ca.KeyTypeECDSA, KeyBits: 384}
+	_, cert64, key64, err := authority.GenerateCertificate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _ := base64.StdEncoding.DecodeString(cert64)
+	keyPEM, _ := base64.StdEncoding.DecodeString(key64)
+	original, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &agentCertificate{}
+	if err := c.set(original); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := c.renewed(certPEM); err == nil {
+		t.Errorf("renewed() expected an error with no request pending")
+	}
+
+	csr, err := c.makeRequest()
+	if err != nil {
+		t.Fatalf("makeRequest() = %v", err)
+	}
+	signed, err := authority.SignCertificateRequest(name, csr)
+	if err != nil {
+		t.Fatalf("SignCertificateRequest() = %v", err)
+	}
+	renewed, _, err := c.renewed(signed)
+	if err != nil {
+		t.Fatalf("renewed() = %v", err)
+	}
+	key, ok := renewed.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok || key.Curve.Params().BitSize != 384 {
+		t.Errorf("renewed key is %T, want a P-384 ECDSA key", renewed.PrivateKey)
+	}
+	if key.Equal(original.PrivateKey) {
+		t.Errorf("renewed certificate reuses the old key")
+	}
+
+	c.endRenewal()
+	if _, _, err := c.renewed(signed); err == nil {
+		t.Errorf("renewed() expected an error after the renewal ended")
+	}
+}
+
+func TestControllerTrust_set(t *testing.T) {
+	oldCA, _ := makeTestCertificate(t, "old-ca")
+	newCA, _ := makeTestCertificate(t, "new-ca")
+	trust := &controllerTrust{}
+
+	if err := trust.set(oldCA); err != nil {
+		t.Fatalf("set() = %v", err)
+	}
+	first := trust.get()
+
+	if err := trust.set([]byte("not a certificate")); err == nil {
+		t.Errorf("set() expected an error for a bundle with no certificates")
+	}
+	if trust.get() != first {
+		t.Errorf("trusted CAs replaced by an invalid bundle")
+	}
+
+	if err := trust.set(append(oldCA, newCA...)); err != nil {
+		t.Fatalf("set() = %v", err)
+	}
+	if trust.get() == first {
+		t.Errorf("trusted CAs not replaced")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"io/ioutil"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/opsmx/oes-birger/pkg/tracing"
 )
 
 const (
@@ -31,12 +33,33 @@ const (
 // AgentConfig holds all the configuration for the agent.  The
 // configuration file is loaded from disk first, and then any
 // environment variables are applied.
+//
+// If AutoUpdate is set, the agent will replace its own binary with the
+// one offered by the controller, and restart.
+//
+// CertificateSecretName is the Kubernetes secret CertFile and KeyFile are
+// mounted from.  When the controller renews our certificate, the new one
+// is saved there, using the base names of CertFile and KeyFile as keys.
+// The CA certificates the controller sends are saved there too, using the
+// base name of the caCertFile flag, so pointing that flag at the secret
+// keeps them after a restart.
+//
+// PrometheusListenPort is where metrics are served, at /metrics.
+//
+// Tracing configures exporting OpenTelemetry traces.
 type AgentConfig struct {
 	ControllerHostname string  `yaml:"controllerHostname,omitempty"`
 	CACert64           *string `yaml:"caCert64,omitempty"`
 	CertFile           string  `yaml:"certFile,omitempty"`
 	KeyFile            string  `yaml:"keyFile,omitempty"`
 	ServicesConfigPath string  `yaml:"servicesConfigPath,omitempty"`
+	AutoUpdate         bool    `yaml:"autoUpdate,omitempty"`
+
+	CertificateSecretName string `yaml:"certificateSecretName,omitempty"`
+
+	PrometheusListenPort uint16 `yaml:"prometheusListenPort,omitempty"`
+
+	Tracing tracing.Config `yaml:"tracing,omitempty"`
 }
 
 func (c *AgentConfig) applyDefaults() {
@@ -55,6 +78,10 @@ func (c *AgentConfig) applyDefaults() {
 	if len(c.ServicesConfigPath) == 0 {
 		c.ServicesConfigPath = defaultUserconfigPath
 	}
+
+	if c.PrometheusListenPort == 0 {
+		c.PrometheusListenPort = 9102
+	}
 }
 
 // Load will load YAML configuration from the provided filename, and then apply
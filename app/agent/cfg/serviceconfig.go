@@ -28,9 +28,12 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// CommandConfig defines a remote host we can run commands on.
+// CommandConfig defines a remote host we can run commands on over SSH.
 // Each host has a `Name`, which can be targeted from Spinnaker.
 // There are no environment overrides for these.
+//
+// The host key is verified using `KnownHosts` unless `InsecureIgnoreHostKey`
+// is set.  At least one of `UserKeyPath` or `PasswordPath` must be set.
 type CommandConfig struct {
 	Enabled               bool   `yaml:"enabled"`
 	Name                  string `yaml:"name"`
@@ -42,6 +45,28 @@ type CommandConfig struct {
 	PasswordPath          string `yaml:"passwordPath"`
 }
 
+// LocalCommandConfig defines a command which may be run on the agent
+// itself.  Requests are only allowed if every argument fully matches
+// at least one of the `AllowedArguments` regular expressions, and every
+// environment variable passed in is named in `AllowedEnvironment`.
+// The `Environment` entries are always set.
+//
+// If `UID` or `GID` are not set, the command runs as 65534 (nobody).
+// A `TimeoutSeconds` of 0 means the command may run forever.
+type LocalCommandConfig struct {
+	Enabled            bool     `yaml:"enabled"`
+	Name               string   `yaml:"name"`
+	Path               string   `yaml:"path"`
+	AllowedArguments   []string `yaml:"allowedArguments,omitempty"`
+	Environment        []string `yaml:"environment,omitempty"`
+	AllowedEnvironment []string `yaml:"allowedEnvironment,omitempty"`
+	UID                *uint32  `yaml:"uid,omitempty"`
+	GID                *uint32  `yaml:"gid,omitempty"`
+	WorkingDirectory   string   `yaml:"workingDirectory,omitempty"`
+	TimeoutSeconds     int      `yaml:"timeoutSeconds,omitempty"`
+	AllowTTY           bool     `yaml:"allowTTY,omitempty"`
+}
+
 //
 // ServiceConfig holds configuration for a service, like a Jenkins endpoint.
 //
@@ -60,8 +85,9 @@ type serviceNamespace struct {
 
 // AgentServiceConfig defines a service level configuration top-level list.
 type AgentServiceConfig struct {
-	Commands []CommandConfig `yaml:"commands,omitempty"`
-	Services []ServiceConfig `yaml:"services,omitempty"`
+	Commands      []CommandConfig      `yaml:"commands,omitempty"`
+	LocalCommands []LocalCommandConfig `yaml:"localCommands,omitempty"`
+	Services      []ServiceConfig      `yaml:"services,omitempty"`
 }
 
 // LoadServiceConfig loads a service configuration YAML file.
@@ -21,12 +21,254 @@ import (
 	"io"
 	"log"
 	"os/exec"
+	"sync"
 	"syscall"
 
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"golang.org/x/net/context"
 )
 
+// maxPendingCommandInput is the most stdin data which will be held for a
+// command which is not reading it.  A controller which paces input sends
+// no more than tunnel.RequestBodyWindow ahead of the command, so only
+// older controllers can reach this.  The command is then stopped, rather
+// than being given a false end of input.
+const maxPendingCommandInput = 1024 * 1024
+
+// commandInputDropped is the failure reported for a command stopped
+// because its input had to be dropped.
+const commandInputDropped = "Agent: command stopped, as it was not reading its input and input was dropped"
+
+// commandInputRegistry holds the stdin queue for each running command
+// which accepts input, by request ID.
+var commandInputRegistry = struct {
+	sync.Mutex
+	m map[string]*commandInput
+}{m: make(map[string]*commandInput)}
+
+//
+// commandInput queues stdin data for a command.  Adding to it never blocks,
+// so a command which does not read its input cannot stall the tunnel, and
+// credit is returned to the controller as the command is given the input.
+//
+type commandInput struct {
+	sync.Mutex
+	id       string
+	dataflow chan *tunnel.AgentToControllerWrapper
+	pending  []*tunnel.CommandData
+	size     int
+	overflow bool
+	done     bool
+	wake     chan struct{}
+	dropped  chan struct{}
+	finished chan struct{}
+	unacked  int64
+	sending  sync.WaitGroup
+}
+
+func newCommandInput(id string, dataflow chan *tunnel.AgentToControllerWrapper) *commandInput {
+	return &commandInput{
+		id:       id,
+		dataflow: dataflow,
+		wake:     make(chan struct{}, 1),
+		dropped:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+func (c *commandInput) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *commandInput) push(data *tunnel.CommandData) {
+	c.Lock()
+	if c.done || c.overflow {
+		c.Unlock()
+		return
+	}
+	c.size += len(data.Body)
+	if c.size > maxPendingCommandInput {
+		log.Printf("Command %s is not reading its input, stopping it", data.Id)
+		c.overflow = true
+		c.pending = nil
+		c.size = 0
+		close(c.dropped)
+		c.Unlock()
+		return
+	}
+	c.pending = append(c.pending, data)
+	c.Unlock()
+	c.signal()
+}
+
+//
+// close stops the queue once it is drained.  It waits for any window
+// update being sent, so the session cannot close the dataflow channel
+// under it.
+//
+func (c *commandInput) close() {
+	c.Lock()
+	if c.done {
+		c.Unlock()
+		return
+	}
+	c.done = true
+	close(c.finished)
+	c.Unlock()
+	c.signal()
+	c.sending.Wait()
+}
+
+// next returns the next queued input, waiting for one if needed.  It returns
+// false once the queue is closed and drained.
+func (c *commandInput) next() (*tunnel.CommandData, bool) {
+	for {
+		c.Lock()
+		if len(c.pending) > 0 {
+			data := c.pending[0]
+			c.pending[0] = nil
+			c.pending = c.pending[1:]
+			c.size -= len(data.Body)
+			c.Unlock()
+			return data, true
+		}
+		if c.done {
+			c.Unlock()
+			return nil, false
+		}
+		c.Unlock()
+		<-c.wake
+	}
+}
+
+// consumed returns credit to the controller once enough input has been
+// given to the command, or discarded after it closed its input.
+func (c *commandInput) consumed(n int) {
+	c.Lock()
+	if c.done || n == 0 {
+		c.Unlock()
+		return
+	}
+	c.unacked += int64(n)
+	if c.unacked < requestBodyCreditThreshold {
+		c.Unlock()
+		return
+	}
+	update := makeWindowUpdate(c.id, c.unacked)
+	c.unacked = 0
+	c.sending.Add(1)
+	c.Unlock()
+
+	defer c.sending.Done()
+	select {
+	case c.dataflow <- update:
+	case <-c.finished:
+	}
+}
+
+//
+// watch returns a context which is cancelled if input has to be dropped,
+// so the command is stopped.  It may be called on a nil commandInput.
+//
+func (c *commandInput) watch(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if c != nil {
+		go func() {
+			select {
+			case <-c.dropped:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
+
+// wasDropped returns true if input was dropped because the command was
+// not reading it.  It may be called on a nil commandInput.
+func (c *commandInput) wasDropped() bool {
+	if c == nil {
+		return false
+	}
+	select {
+	case <-c.dropped:
+		return true
+	default:
+		return false
+	}
+}
+
+// registerCommandInput must be called before any CommandData for the request
+// can arrive, so it is called before the command's goroutine is started.
+func registerCommandInput(id string, dataflow chan *tunnel.AgentToControllerWrapper) *commandInput {
+	commandInputRegistry.Lock()
+	defer commandInputRegistry.Unlock()
+	c := newCommandInput(id, dataflow)
+	commandInputRegistry.m[id] = c
+	return c
+}
+
+func unregisterCommandInput(id string) {
+	commandInputRegistry.Lock()
+	c, found := commandInputRegistry.m[id]
+	delete(commandInputRegistry.m, id)
+	commandInputRegistry.Unlock()
+	if found {
+		c.close()
+	}
+}
+
+// deliverCommandInput is called from the tunnel's receive loop, and so
+// must not block.
+func deliverCommandInput(data *tunnel.CommandData) {
+	commandInputRegistry.Lock()
+	c, found := commandInputRegistry.m[data.Id]
+	commandInputRegistry.Unlock()
+	if !found {
+		log.Printf("Got command data for unknown or finished command %s", data.Id)
+		unknownIDDropsCounter.WithLabelValues("command").Inc()
+		return
+	}
+	c.push(data)
+}
+
+// inputReceiver writes stdin data to the command until it is closed.  If the
+// command stops reading, further input is discarded until the command
+// is unregistered.
+func inputReceiver(c *commandInput, out io.WriteCloser) {
+	for {
+		data, ok := c.next()
+		if !ok {
+			break
+		}
+		if out == nil {
+			c.consumed(len(data.Body))
+			continue
+		}
+		if len(data.Body) > 0 {
+			_, err := out.Write(data.Body)
+			c.consumed(len(data.Body))
+			if err != nil {
+				log.Printf("Got %v in stdin write", err)
+				out.Close()
+				out = nil
+				continue
+			}
+		}
+		if data.Closed {
+			out.Close()
+			out = nil
+		}
+	}
+	if out != nil {
+		out.Close()
+	}
+}
+
 func outputSender(channel tunnel.ChannelDirection, c chan *outputMessage, in io.Reader) {
 	buffer := make([]byte, 10240)
 	for {
@@ -108,45 +350,91 @@ func makeCommandDataClosed(req *tunnel.CommandRequest, channel tunnel.ChannelDir
 	}
 }
 
-func runCommand(dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.CommandRequest) {
-	ctx, cancel := context.WithCancel(context.Background())
-	registerCancelFunction(req.Id, cancel)
-	defer unregisterCancelFunction(req.Id)
+// traceCommand runs a command in a span continuing the controller's trace.
+func traceCommand(ctx context.Context, req *tunnel.CommandRequest, run func()) {
+	_, span := tracing.Start(tracing.Extract(ctx, req.TraceContext), "runCommand", tracing.SpanKindConsumer,
+		tracing.String("command.name", req.Name),
+		tracing.String("transaction.id", req.Id))
+	defer span.End()
+	run()
+}
+
+func runCommand(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, command *localCommand, req *tunnel.CommandRequest, input *commandInput) {
+	if command.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, command.timeout)
+		defer cancel()
+	}
+	if input != nil {
+		defer unregisterCommandInput(req.Id)
+	}
+	ctx, cancel := input.watch(ctx)
+	defer cancel()
+	window := registerSendWindow(req.Id, req.Window)
+	defer unregisterSendWindow(req.Id)
 
 	log.Printf("Got command request: %v", req)
 
 	// aggregation channel, for stdout and stderr to be send through.
 	agg := make(chan *outputMessage)
 
-	cmd := exec.CommandContext(ctx, req.Name, req.Arguments...)
-	cmd.Env = req.Environment
+	cmd := exec.CommandContext(ctx, command.path, req.Arguments...)
+	cmd.Env = command.makeEnvironment(req)
+	cmd.Dir = command.workingDirectory
 	cmd.SysProcAttr = &syscall.SysProcAttr{}
-	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 65534, Gid: 65534}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: command.uid, Gid: command.gid}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		dataflow <- makeCommandFailed(req, err, "StdoutPipe()")
-		return
-	}
+	activeCount := 0
+	if req.Tty {
+		terminal, err := startTerminal(cmd, req.TerminalSize)
+		if err != nil {
+			dataflow <- makeCommandFailed(req, err, "StartTerminal()")
+			return
+		}
+		defer terminal.Close()
+		registerTerminal(req.Id, ptyResizer(terminal))
+		defer unregisterTerminal(req.Id)
+		if input != nil {
+			go inputReceiver(input, &terminalInput{terminal})
+		}
+		// A terminal has only one output stream, which we send as stdout.
+		go outputSender(tunnel.ChannelDirection_STDOUT, agg, terminal)
+		activeCount = 1
+	} else {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			dataflow <- makeCommandFailed(req, err, "StdoutPipe()")
+			return
+		}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		dataflow <- makeCommandFailed(req, err, "StderrPipe()")
-		return
-	}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			dataflow <- makeCommandFailed(req, err, "StderrPipe()")
+			return
+		}
+
+		if input != nil {
+			stdin, err := cmd.StdinPipe()
+			if err != nil {
+				dataflow <- makeCommandFailed(req, err, "StdinPipe()")
+				return
+			}
+			go inputReceiver(input, stdin)
+		}
 
-	go outputSender(tunnel.ChannelDirection_STDOUT, agg, stdout)
-	go outputSender(tunnel.ChannelDirection_STDERR, agg, stderr)
+		go outputSender(tunnel.ChannelDirection_STDOUT, agg, stdout)
+		go outputSender(tunnel.ChannelDirection_STDERR, agg, stderr)
 
-	err = cmd.Start()
-	if err != nil {
-		// this path will occur if the command can't be found.  Other errors
-		// are possible, but this is most likely.
-		dataflow <- makeCommandFailed(req, err, "Start()")
-		return
+		err = cmd.Start()
+		if err != nil {
+			// this path will occur if the command can't be found.  Other errors
+			// are possible, but this is most likely.
+			dataflow <- makeCommandFailed(req, err, "Start()")
+			return
+		}
+		activeCount = 2
 	}
 
-	activeCount := 2
 	for msg := range agg {
 		if msg.closed {
 			log.Printf("Channel %d closed", msg.channel)
@@ -156,13 +444,27 @@ func runCommand(dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.Comm
 				break
 			}
 		} else {
+			// Once cancelled, output is discarded so the readers can finish.
+			if _, err := window.Wait(ctx); err != nil {
+				continue
+			}
+			window.Consume(len(msg.value))
 			dataflow <- makeCommandData(req, msg.channel, msg.value)
 		}
 	}
 
-	log.Printf("Command closed both stdin and stdout.")
+	log.Printf("Command closed all output streams.")
 
-	if err := cmd.Wait(); err != nil {
+	err := cmd.Wait()
+	if input.wasDropped() {
+		dataflow <- makeCommandFailed(req, nil, commandInputDropped)
+		return
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		dataflow <- makeCommandFailed(req, nil, fmt.Sprintf("Agent: command %s timed out after %v", command.name, command.timeout))
+		return
+	}
+	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
 			log.Printf("exited with code != 0")
 			// The program has exited with an exit code != 0
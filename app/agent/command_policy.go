@@ -0,0 +1,179 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/agent/cfg"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+const nobodyID = 65534
+
+// localCommand is a command from the catalogue which may be run on the
+// agent, along with the policy requests for it must follow.
+type localCommand struct {
+	name               string
+	path               string
+	allowedArguments   []*regexp.Regexp
+	environment        []string
+	allowedEnvironment map[string]bool
+	uid                uint32
+	gid                uint32
+	workingDirectory   string
+	timeout            time.Duration
+	allowTTY           bool
+}
+
+// localCommands holds the configured local commands, by name.
+var localCommands = map[string]*localCommand{}
+
+func configureLocalCommands() {
+	localCommands = map[string]*localCommand{}
+	for _, command := range agentServiceConfig.LocalCommands {
+		if !command.Enabled {
+			continue
+		}
+		if _, found := commandHosts[command.Name]; found {
+			log.Fatalf("Local command %s: name is already used by a command host", command.Name)
+		}
+		c, err := makeLocalCommand(command)
+		if err != nil {
+			log.Fatalf("Local command %s: %v", command.Name, err)
+		}
+		log.Printf("Adding local command %s, path %s", c.name, c.path)
+		localCommands[c.name] = c
+	}
+}
+
+// localCommandsToPB returns the configured local commands as endpoints,
+// so the controller knows they can be targeted by remote-command.
+func localCommandsToPB() []*tunnel.EndpointHealth {
+	pbEndpoints := []*tunnel.EndpointHealth{}
+	for name := range localCommands {
+		pbEndpoints = append(pbEndpoints, &tunnel.EndpointHealth{
+			Name:       name,
+			Type:       "remote-command",
+			Configured: true,
+		})
+	}
+	return pbEndpoints
+}
+
+func makeLocalCommand(c cfg.LocalCommandConfig) (*localCommand, error) {
+	if len(c.Name) == 0 {
+		return nil, fmt.Errorf("name must be set")
+	}
+	if len(c.Path) == 0 {
+		return nil, fmt.Errorf("path must be set")
+	}
+	if c.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("timeoutSeconds must not be negative")
+	}
+
+	ret := &localCommand{
+		name:               c.Name,
+		path:               c.Path,
+		environment:        c.Environment,
+		allowedEnvironment: map[string]bool{},
+		uid:                nobodyID,
+		gid:                nobodyID,
+		workingDirectory:   c.WorkingDirectory,
+		timeout:            time.Duration(c.TimeoutSeconds) * time.Second,
+		allowTTY:           c.AllowTTY,
+	}
+
+	for _, pattern := range c.AllowedArguments {
+		// Patterns must match the entire argument.
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("allowedArguments: %v", err)
+		}
+		ret.allowedArguments = append(ret.allowedArguments, re)
+	}
+
+	for _, env := range c.Environment {
+		if !strings.Contains(env, "=") {
+			return nil, fmt.Errorf("environment: %s is not in NAME=value format", env)
+		}
+	}
+
+	for _, name := range c.AllowedEnvironment {
+		ret.allowedEnvironment[name] = true
+	}
+
+	if c.UID != nil {
+		ret.uid = *c.UID
+	}
+	if c.GID != nil {
+		ret.gid = *c.GID
+	}
+
+	return ret, nil
+}
+
+func (c *localCommand) argumentAllowed(arg string) bool {
+	for _, re := range c.allowedArguments {
+		if re.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequest returns an error describing why the request is not
+// allowed by the command's policy, or nil if it is.
+func (c *localCommand) checkRequest(req *tunnel.CommandRequest) error {
+	if req.Tty && !c.allowTTY {
+		return fmt.Errorf("command %s may not be run on a terminal", c.name)
+	}
+	for i, arg := range req.Arguments {
+		if !c.argumentAllowed(arg) {
+			return fmt.Errorf("argument %d (%q) is not allowed for command %s", i+1, arg, c.name)
+		}
+	}
+	for _, env := range req.Environment {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !c.allowedEnvironment[name] {
+			return fmt.Errorf("environment variable %s is not allowed for command %s", name, c.name)
+		}
+	}
+	return nil
+}
+
+// makeEnvironment returns the command's fixed environment, followed by
+// any variables from the request.  The request must already have been
+// checked, and the fixed environment wins if both set the same name.
+func (c *localCommand) makeEnvironment(req *tunnel.CommandRequest) []string {
+	ret := []string{}
+	fixed := map[string]bool{}
+	for _, env := range c.environment {
+		fixed[strings.SplitN(env, "=", 2)[0]] = true
+		ret = append(ret, env)
+	}
+	for _, env := range req.Environment {
+		if !fixed[strings.SplitN(env, "=", 2)[0]] {
+			ret = append(ret, env)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,131 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/agent/cfg"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+func u32p(v uint32) *uint32 {
+	return &v
+}
+
+func TestMakeLocalCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  cfg.LocalCommandConfig
+		want    *localCommand
+		wantErr bool
+	}{
+		{
+			"defaults",
+			cfg.LocalCommandConfig{Name: "ls", Path: "/bin/ls"},
+			&localCommand{name: "ls", path: "/bin/ls", allowedEnvironment: map[string]bool{}, uid: nobodyID, gid: nobodyID},
+			false,
+		},
+		{
+			"all settings",
+			cfg.LocalCommandConfig{
+				Name:               "ls",
+				Path:               "/bin/ls",
+				Environment:        []string{"A=b"},
+				AllowedEnvironment: []string{"C"},
+				UID:                u32p(1000),
+				GID:                u32p(1001),
+				WorkingDirectory:   "/tmp",
+				TimeoutSeconds:     30,
+				AllowTTY:           true,
+			},
+			&localCommand{
+				name:               "ls",
+				path:               "/bin/ls",
+				environment:        []string{"A=b"},
+				allowedEnvironment: map[string]bool{"C": true},
+				uid:                1000,
+				gid:                1001,
+				workingDirectory:   "/tmp",
+				timeout:            30 * time.Second,
+				allowTTY:           true,
+			},
+			false,
+		},
+		{"missing name", cfg.LocalCommandConfig{Path: "/bin/ls"}, nil, true},
+		{"missing path", cfg.LocalCommandConfig{Name: "ls"}, nil, true},
+		{"negative timeout", cfg.LocalCommandConfig{Name: "ls", Path: "/bin/ls", TimeoutSeconds: -1}, nil, true},
+		{"bad pattern", cfg.LocalCommandConfig{Name: "ls", Path: "/bin/ls", AllowedArguments: []string{"("}}, nil, true},
+		{"bad environment", cfg.LocalCommandConfig{Name: "ls", Path: "/bin/ls", Environment: []string{"A"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeLocalCommand(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("makeLocalCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("makeLocalCommand() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalCommand_checkRequest(t *testing.T) {
+	c, err := makeLocalCommand(cfg.LocalCommandConfig{
+		Name:               "kubectl",
+		Path:               "/usr/bin/kubectl",
+		AllowedArguments:   []string{"get|describe", "pods?", "-n", "[a-z0-9-]+"},
+		AllowedEnvironment: []string{"KUBECONFIG"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *tunnel.CommandRequest
+		wantErr bool
+	}{
+		{"no arguments", &tunnel.CommandRequest{}, false},
+		{"allowed arguments", &tunnel.CommandRequest{Arguments: []string{"get", "pods", "-n", "default"}}, false},
+		{"disallowed argument", &tunnel.CommandRequest{Arguments: []string{"get", "pods", "--output=json"}}, true},
+		{"partial match is not allowed", &tunnel.CommandRequest{Arguments: []string{"get", "pods; rm"}}, true},
+		{"allowed environment", &tunnel.CommandRequest{Environment: []string{"KUBECONFIG=/tmp/k"}}, false},
+		{"disallowed environment", &tunnel.CommandRequest{Environment: []string{"LD_PRELOAD=/tmp/x"}}, true},
+		{"tty not allowed", &tunnel.CommandRequest{Tty: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.checkRequest(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("checkRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalCommand_makeEnvironment(t *testing.T) {
+	c := &localCommand{environment: []string{"A=fixed", "B=fixed"}}
+	req := &tunnel.CommandRequest{Environment: []string{"A=request", "C=request"}}
+	want := []string{"A=fixed", "B=fixed", "C=request"}
+	if got := c.makeEnvironment(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("makeEnvironment() = %v, want %v", got, want)
+	}
+}
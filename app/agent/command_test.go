@@ -0,0 +1,149 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestCommandInput_delivers(t *testing.T) {
+	input := registerCommandInput("cmd-deliver", make(chan *tunnel.AgentToControllerWrapper, 10))
+	out := &closeBuffer{}
+	finished := make(chan struct{})
+	go func() {
+		inputReceiver(input, out)
+		close(finished)
+	}()
+
+	deliverCommandInput(&tunnel.CommandData{Id: "cmd-deliver", Body: []byte("hello, ")})
+	deliverCommandInput(&tunnel.CommandData{Id: "cmd-deliver", Body: []byte("world"), Closed: true})
+	unregisterCommandInput("cmd-deliver")
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("inputReceiver did not finish")
+	}
+	if got := out.String(); got != "hello, world" {
+		t.Errorf("stdin = %q, want %q", got, "hello, world")
+	}
+	if !out.closed {
+		t.Error("stdin was not closed")
+	}
+}
+
+func TestCommandInput_doesNotBlockWhenUnread(t *testing.T) {
+	input := registerCommandInput("cmd-unread", make(chan *tunnel.AgentToControllerWrapper, 10))
+	defer unregisterCommandInput("cmd-unread")
+	ctx, cancel := input.watch(context.Background())
+	defer cancel()
+
+	chunk := make([]byte, 64*1024)
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*maxPendingCommandInput/len(chunk); i++ {
+			deliverCommandInput(&tunnel.CommandData{Id: "cmd-unread", Body: chunk})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deliverCommandInput blocked on a command which is not reading")
+	}
+
+	// once over the limit, the input is dropped and the command stopped,
+	// rather than its stdin being closed.
+	if len(input.pending) != 0 {
+		t.Errorf("%d chunks still queued after overflow", len(input.pending))
+	}
+	if !input.wasDropped() {
+		t.Error("expected input to be dropped after overflow")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("command context was not cancelled after overflow")
+	}
+}
+
+func TestCommandInput_returnsCredit(t *testing.T) {
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	input := registerCommandInput("cmd-credit", dataflow)
+	out := &closeBuffer{}
+	finished := make(chan struct{})
+	go func() {
+		inputReceiver(input, out)
+		close(finished)
+	}()
+
+	chunk := make([]byte, requestBodyCreditThreshold/2)
+	for i := 0; i < 3; i++ {
+		deliverCommandInput(&tunnel.CommandData{Id: "cmd-credit", Body: chunk})
+	}
+
+	select {
+	case msg := <-dataflow:
+		update := msg.GetWindowUpdate()
+		if update == nil || update.Id != "cmd-credit" || update.Bytes != requestBodyCreditThreshold {
+			t.Errorf("got %v, want a window update for %d bytes", msg, requestBodyCreditThreshold)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no WindowUpdate sent")
+	}
+
+	unregisterCommandInput("cmd-credit")
+	<-finished
+	if out.Len() != 3*len(chunk) {
+		t.Errorf("command got %d bytes, want %d", out.Len(), 3*len(chunk))
+	}
+	if input.wasDropped() {
+		t.Error("input was dropped for a command which was reading it")
+	}
+}
+
+func TestCommandInput_watchNil(t *testing.T) {
+	var input *commandInput
+	ctx, cancel := input.watch(context.Background())
+	if ctx.Err() != nil {
+		t.Error("context cancelled without a command input")
+	}
+	cancel()
+	if input.wasDropped() {
+		t.Error("nil input reported as dropped")
+	}
+}
+
+func TestCommandInput_unknownID(t *testing.T) {
+	// must not block or panic.
+	deliverCommandInput(&tunnel.CommandData{Id: "cmd-unknown", Body: []byte("x")})
+}
@@ -0,0 +1,118 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// closeWriter is a connection which can be half closed, as *net.TCPConn
+// and *tls.Conn can.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+//
+// pipeConnection passes bytes between the controller and conn until
+// either end closes.  Data from the client arrives as the request's
+// streamed body, and data from conn is sent in ConnectionData messages as
+// window allows.  Unless ctx is done first, the last message sent is a
+// closed ConnectionData.
+//
+func pipeConnection(ctx context.Context, id string, conn io.ReadWriteCloser, fromClient *streamedRequestBody, dataflow chan *tunnel.AgentToControllerWrapper, window *tunnel.SendWindow, metrics *requestMetrics) {
+	// When the client closes, conn is closed for writing only if it can
+	// be, so the reply can still be read below.  Otherwise it is closed,
+	// and the read ends.
+	go func() {
+		_, err := io.Copy(conn, &countingBody{ReadCloser: fromClient, count: metrics.addRequestBytes})
+		if err != nil {
+			log.Printf("Got error on connection write: %v", err)
+		}
+		if cw, ok := conn.(closeWriter); ok && err == nil {
+			if err = cw.CloseWrite(); err == nil {
+				return
+			}
+		}
+		conn.Close()
+	}()
+
+	// A read from conn does not stop when ctx is done.
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stopped:
+		}
+	}()
+
+	for {
+		available, err := window.Wait(ctx)
+		if err != nil {
+			log.Printf("Context cancelled, request ID %s", id)
+			metrics.cancelled()
+			return
+		}
+		buf := make([]byte, 10240)
+		if available < int64(len(buf)) {
+			buf = buf[:available]
+		}
+		n, err := conn.Read(buf)
+		if n > 0 {
+			metrics.addResponseBytes(n)
+			window.Consume(n)
+			dataflow <- makeConnectionData(id, buf[:n], false)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("Context cancelled, request ID %s", id)
+				metrics.cancelled()
+				return
+			}
+			dataflow <- makeConnectionData(id, emptyBytes, true)
+			return
+		}
+	}
+}
+
+func makeConnectionData(id string, data []byte, closed bool) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_ConnectionData{
+			ConnectionData: &tunnel.ConnectionData{
+				Id:     id,
+				Data:   data,
+				Closed: closed,
+			},
+		},
+	}
+}
+
+func makeConnectionOpened(id string, errorMessage string) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_ConnectionOpened{
+			ConnectionOpened: &tunnel.ConnectionOpened{
+				Id:    id,
+				Error: errorMessage,
+			},
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"sync"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// windowRegistry holds the send window for each response being sent, by
+// request ID.
+var windowRegistry = struct {
+	sync.Mutex
+	m map[string]*tunnel.SendWindow
+}{m: make(map[string]*tunnel.SendWindow)}
+
+// registerSendWindow returns the window for a request, or nil if the
+// controller did not ask for flow control.
+func registerSendWindow(id string, size int64) *tunnel.SendWindow {
+	if size <= 0 {
+		return nil
+	}
+	w := tunnel.NewSendWindow(size)
+	windowRegistry.Lock()
+	defer windowRegistry.Unlock()
+	windowRegistry.m[id] = w
+	return w
+}
+
+func unregisterSendWindow(id string) {
+	windowRegistry.Lock()
+	defer windowRegistry.Unlock()
+	delete(windowRegistry.m, id)
+}
+
+// updateSendWindow adds credit from the controller.  Updates for requests
+// which have already finished are expected, and ignored.
+func updateSendWindow(update *tunnel.WindowUpdate) {
+	windowRegistry.Lock()
+	w, found := windowRegistry.m[update.Id]
+	windowRegistry.Unlock()
+	if !found {
+		return
+	}
+	w.Grant(update.Bytes)
+}
@@ -0,0 +1,186 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+func TestSendWindow_disabled(t *testing.T) {
+	w := registerSendWindow("disabled", 0)
+	if w != nil {
+		t.Fatalf("registerSendWindow() with no window = %v, want nil", w)
+	}
+	w.Consume(1 << 30)
+	if _, err := w.Wait(context.Background()); err != nil {
+		t.Errorf("wait() on a nil window = %v", err)
+	}
+}
+
+func TestSendWindow_waitsForCredit(t *testing.T) {
+	w := registerSendWindow("paced", 100)
+	defer unregisterSendWindow("paced")
+
+	available, err := w.Wait(context.Background())
+	if err != nil || available != 100 {
+		t.Fatalf("wait() = %d, %v, want 100", available, err)
+	}
+	w.Consume(150)
+
+	got := make(chan int64)
+	go func() {
+		available, _ := w.Wait(context.Background())
+		got <- available
+	}()
+
+	select {
+	case <-got:
+		t.Fatalf("wait() returned with no credit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	updateSendWindow(&tunnel.WindowUpdate{Id: "paced", Bytes: 40})
+	updateSendWindow(&tunnel.WindowUpdate{Id: "paced", Bytes: 20})
+	select {
+	case available := <-got:
+		if available != 10 {
+			t.Errorf("wait() = %d, want 10", available)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wait() did not return after credit was granted")
+	}
+}
+
+func TestSendWindow_cancel(t *testing.T) {
+	w := registerSendWindow("cancelled", 10)
+	defer unregisterSendWindow("cancelled")
+	w.Consume(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.Wait(ctx); err == nil {
+		t.Errorf("wait() expected an error once cancelled")
+	}
+
+	// Updates for unknown or finished requests are ignored.
+	updateSendWindow(&tunnel.WindowUpdate{Id: "unknown", Bytes: 10})
+}
+
+func TestSendWindow_exhaustionAndRefill(t *testing.T) {
+	type step struct {
+		consume int
+		grant   int64
+		blocked bool  // whether wait() should block after the step
+		want    int64 // what wait() should return, once unblocked
+	}
+	tests := []struct {
+		name  string
+		size  int64
+		steps []step
+	}{
+		{
+			"exact exhaustion",
+			100,
+			[]step{
+				{consume: 60, want: 40},
+				{consume: 40, blocked: true},
+				{grant: 30, want: 30},
+			},
+		},
+		{
+			"overshoot needs more than the overshoot back",
+			100,
+			[]step{
+				{consume: 150, blocked: true},
+				{grant: 50, blocked: true},
+				{grant: 1, want: 1},
+			},
+		},
+		{
+			"refill beyond the initial size",
+			10,
+			[]step{
+				{consume: 10, blocked: true},
+				{grant: 100, want: 100},
+				{consume: 100, blocked: true},
+				{grant: 5, want: 5},
+			},
+		},
+		{
+			"invalid grants are ignored",
+			10,
+			[]step{
+				{consume: 10, blocked: true},
+				{grant: -5, blocked: true},
+				{grant: 5, want: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := "refill " + tt.name
+			w := registerSendWindow(id, tt.size)
+			defer unregisterSendWindow(id)
+
+			for i, s := range tt.steps {
+				w.Consume(s.consume)
+				updateSendWindow(&tunnel.WindowUpdate{Id: id, Bytes: s.grant})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+				available, err := w.Wait(ctx)
+				cancel()
+				if s.blocked {
+					if err == nil {
+						t.Errorf("step %d: wait() = %d, want it to block", i, available)
+					}
+					continue
+				}
+				if err != nil || available != s.want {
+					t.Errorf("step %d: wait() = %d, %v, want %d", i, available, err, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSendWindow_cancelWhileBlocked(t *testing.T) {
+	w := registerSendWindow("blocked", 10)
+	defer unregisterSendWindow("blocked")
+	w.Consume(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+	go func() {
+		_, err := w.Wait(ctx)
+		result <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Errorf("wait() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wait() did not return once cancelled")
+	}
+}
@@ -17,8 +17,8 @@ package main
  */
 
 import (
-	"bytes"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -28,25 +28,63 @@ import (
 	"github.com/opsmx/oes-birger/pkg/secrets"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 	"gopkg.in/yaml.v3"
 )
 
+//
+// genericEndpointCredentials are added to each request.  For the "oauth2"
+// type, access tokens are fetched from TokenURL with the client
+// credentials grant, using the clientId and clientSecret items of the
+// Kubernetes secret.
+//
 type genericEndpointCredentials struct {
-	Type       string `yaml:"type,omitempty"`
-	Username   string `yaml:"username,omitempty"`
-	Password   string `yaml:"password,omitempty"`
-	Token      string `yaml:"token,omitempty"`
+	Type       string   `yaml:"type,omitempty"`
+	Username   string   `yaml:"username,omitempty"`
+	Password   string   `yaml:"password,omitempty"`
+	Token      string   `yaml:"token,omitempty"`
+	SecretName string   `yaml:"secretName,omitempty"`
+	TokenURL   string   `yaml:"tokenURL,omitempty"`
+	Scopes     []string `yaml:"scopes,omitempty"`
+
+	rawUsername     string `yaml:"-"`
+	rawPassword     string `yaml:"-"`
+	rawToken        string `yaml:"-"`
+	rawClientID     string `yaml:"-"`
+	rawClientSecret string `yaml:"-"`
+}
+
+//
+// genericEndpointTLS configures the connection to the service.  The
+// secret may hold a client certificate and key as "tls.crt" and "tls.key",
+// and a CA bundle as "ca.crt", which replaces the system's trusted CAs.
+// ServerName overrides the name sent in SNI and checked against the
+// service's certificate.
+//
+type genericEndpointTLS struct {
 	SecretName string `yaml:"secretName,omitempty"`
+	ServerName string `yaml:"serverName,omitempty"`
+}
 
-	rawUsername string `yaml:"-"`
-	rawPassword string `yaml:"-"`
-	rawToken    string `yaml:"-"`
+//
+// genericEndpointHeader is added to every request, replacing any value
+// the client sent.  The value is either set here, or read from the
+// secretKey item of a Kubernetes secret.
+//
+type genericEndpointHeader struct {
+	Name       string `yaml:"name"`
+	Value      string `yaml:"value,omitempty"`
+	SecretName string `yaml:"secretName,omitempty"`
+	SecretKey  string `yaml:"secretKey,omitempty"`
 }
 
 type genericEndpointConfig struct {
 	URL         string                     `yaml:"url,omitempty"`
 	Insecure    bool                       `yaml:"insecure,omitempty"`
 	Credentials genericEndpointCredentials `yaml:"credentials,omitempty"`
+	TLS         genericEndpointTLS         `yaml:"tls,omitempty"`
+	Headers     []genericEndpointHeader    `yaml:"headers,omitempty"`
 }
 
 // GenericEndpoint defines the state (config and credentials) for a generic HTTP
@@ -55,6 +93,9 @@ type GenericEndpoint struct {
 	endpointType string
 	endpointName string
 	config       genericEndpointConfig
+	tlsConfig    *tls.Config
+	headers      http.Header
+	tokenSource  *oauth2TokenSource
 }
 
 func (ep *GenericEndpoint) loadSecrets(secretsLoader secrets.SecretLoader) error {
@@ -98,6 +139,8 @@ func (ep *GenericEndpoint) loadBase64Secrets() error {
 		}
 		ep.config.Credentials.rawToken = string(rawToken)
 		return nil
+	case "oauth2":
+		return fmt.Errorf("oauth2: secretName must be set")
 	default:
 		return fmt.Errorf("unknown credential type %s", ep.config.Credentials.Type)
 	}
@@ -121,6 +164,8 @@ func (ep *GenericEndpoint) loadKubernetesSecrets(secretsLoader secrets.SecretLoa
 	token, hasToken := getItem(secret, "token")
 	username, hasUsername := getItem(secret, "username")
 	password, hasPassword := getItem(secret, "password")
+	clientID, hasClientID := getItem(secret, "clientId")
+	clientSecret, hasClientSecret := getItem(secret, "clientSecret")
 
 	switch ep.config.Credentials.Type {
 	case "basic":
@@ -140,11 +185,121 @@ func (ep *GenericEndpoint) loadKubernetesSecrets(secretsLoader secrets.SecretLoa
 		}
 		ep.config.Credentials.rawToken = string(token)
 		return nil
+	case "oauth2":
+		if ep.config.Credentials.TokenURL == "" {
+			return fmt.Errorf("oauth2: tokenURL must be set")
+		}
+		if !hasClientID {
+			return fmt.Errorf("oauth2: clientId missing in secret")
+		}
+		if !hasClientSecret {
+			return fmt.Errorf("oauth2: clientSecret missing in secret")
+		}
+		ep.config.Credentials.rawClientID = string(clientID)
+		ep.config.Credentials.rawClientSecret = string(clientSecret)
+		return nil
 	default:
 		return fmt.Errorf("unknown or unsupported credential type %s", ep.config.Credentials.Type)
 	}
 }
 
+func (ep *GenericEndpoint) loadTLS(secretsLoader secrets.SecretLoader) error {
+	ep.tlsConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         ep.config.TLS.ServerName,
+		InsecureSkipVerify: ep.config.Insecure,
+	}
+	if ep.config.TLS.SecretName == "" {
+		return nil
+	}
+
+	secret, err := secretsLoader.GetSecret(ep.config.TLS.SecretName)
+	if err != nil {
+		return err
+	}
+
+	cert, hasCert := getItem(secret, "tls.crt")
+	key, hasKey := getItem(secret, "tls.key")
+	caBundle, hasCABundle := getItem(secret, "ca.crt")
+
+	if hasCert != hasKey {
+		return fmt.Errorf("tls: secret must have both tls.crt and tls.key, or neither")
+	}
+	if !hasCert && !hasCABundle {
+		return fmt.Errorf("tls: secret has no tls.crt, tls.key or ca.crt")
+	}
+	if hasCert {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return fmt.Errorf("tls: %v", err)
+		}
+		ep.tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	if hasCABundle {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return fmt.Errorf("tls: no certificates found in ca.crt")
+		}
+		ep.tlsConfig.RootCAs = pool
+	}
+	return nil
+}
+
+func (ep *GenericEndpoint) loadHeaders(secretsLoader secrets.SecretLoader) error {
+	ep.headers = http.Header{}
+	for _, header := range ep.config.Headers {
+		if header.Name == "" {
+			return fmt.Errorf("headers: name missing")
+		}
+		if header.SecretName == "" {
+			if header.Value == "" {
+				return fmt.Errorf("headers: %s: value or secretName must be set", header.Name)
+			}
+			ep.headers.Set(header.Name, header.Value)
+			continue
+		}
+
+		if header.Value != "" {
+			return fmt.Errorf("headers: %s: value and secretName cannot both be set", header.Name)
+		}
+		if header.SecretKey == "" {
+			return fmt.Errorf("headers: %s: secretKey missing", header.Name)
+		}
+		secret, err := secretsLoader.GetSecret(header.SecretName)
+		if err != nil {
+			return err
+		}
+		value, found := getItem(secret, header.SecretKey)
+		if !found {
+			return fmt.Errorf("headers: %s: %s missing in secret", header.Name, header.SecretKey)
+		}
+		ep.headers.Set(header.Name, string(value))
+	}
+	return nil
+}
+
+//
+// makeTokenSource sets up fetching of OAuth2 access tokens.  The token
+// endpoint is reached with the same TLS settings as the service.
+//
+func (ep *GenericEndpoint) makeTokenSource() {
+	creds := ep.config.Credentials
+	if creds.Type != "oauth2" {
+		return
+	}
+	config := &clientcredentials.Config{
+		ClientID:     creds.rawClientID,
+		ClientSecret: creds.rawClientSecret,
+		TokenURL:     creds.TokenURL,
+		Scopes:       creds.Scopes,
+		AuthStyle:    oauth2.AuthStyleInHeader,
+	}
+	ep.tokenSource = newOAuth2TokenSource(config, &http.Client{
+		Transport: &http.Transport{TLSClientConfig: ep.tlsConfig.Clone()},
+		Timeout:   30 * time.Second,
+	})
+}
+
 // MakeGenericEndpoint returns a generic HTTP endpoint which allows calling a HTTP service.
 func MakeGenericEndpoint(endpointType string, endpointName string, configBytes []byte, secretsLoader secrets.SecretLoader) (*GenericEndpoint, bool, error) {
 	ep := &GenericEndpoint{
@@ -165,6 +320,20 @@ func MakeGenericEndpoint(endpointType string, endpointName string, configBytes [
 		return nil, false, nil
 	}
 
+	err = ep.loadTLS(secretsLoader)
+	if err != nil {
+		log.Printf("Unable to load TLS configuration for %s/%s: %v", endpointType, endpointName, err)
+		return nil, false, nil
+	}
+
+	err = ep.loadHeaders(secretsLoader)
+	if err != nil {
+		log.Printf("Unable to load headers for %s/%s: %v", endpointType, endpointName, err)
+		return nil, false, nil
+	}
+
+	ep.makeTokenSource()
+
 	if ep.config.URL == "" {
 		log.Printf("url not set for %s/%s", endpointType, endpointName)
 		return nil, false, nil
@@ -173,34 +342,32 @@ func MakeGenericEndpoint(endpointType string, endpointName string, configBytes [
 	return ep, true, nil
 }
 
-func (ep *GenericEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+func (ep *GenericEndpoint) executeHTTPRequest(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+	defer unregisterRequestBody(req.Id)
 	log.Printf("Running request %v", req)
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-	}
 	tr := &http.Transport{
 		MaxIdleConns:       10,
 		IdleConnTimeout:    30 * time.Second,
 		DisableCompression: true,
-		TLSClientConfig:    tlsConfig,
-	}
-	if ep.config.Insecure {
-		tr.TLSClientConfig.InsecureSkipVerify = true
+		TLSClientConfig:    ep.tlsConfig.Clone(),
 	}
 	client := &http.Client{
 		Transport: tr,
 	}
+	if ep.tokenSource != nil {
+		client.Transport = &oauth2Transport{base: tr, source: ep.tokenSource}
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	registerCancelFunction(req.Id, cancel)
-	defer unregisterCancelFunction(req.Id)
+	ctx, span := startRequestSpan(ctx, req)
+	defer span.End()
 
-	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, ep.config.URL+req.URI, bytes.NewBuffer(req.Body))
+	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, ep.config.URL+req.URI, makeRequestBody(ctx, req))
 	if err != nil {
 		log.Printf("Failed to build request for %s to %s: %v", req.Method, ep.config.URL+req.URI, err)
 		dataflow <- makeBadGatewayResponse(req.Id)
 		return
 	}
+	setContentLength(req, httpRequest)
 
 	copyHeaders(req, httpRequest)
 
@@ -213,6 +380,9 @@ func (ep *GenericEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToContr
 	case "token":
 		httpRequest.Header.Set("Authorization", "Token "+creds.rawToken)
 	}
+	for name, values := range ep.headers {
+		httpRequest.Header[name] = values
+	}
 
 	runHTTPRequest(client, req, httpRequest, dataflow, ep.config.URL)
 }
@@ -17,9 +17,23 @@ package main
  */
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
 )
 
 func makeMap(username *string, password *string, token *string) *map[string][]byte {
@@ -57,6 +71,10 @@ var (
 		"uX_": makeMap(sp("foo"), sp(""), nil),
 		"__X": makeMap(nil, nil, sp("")),
 		"___": makeMap(nil, nil, nil),
+		"oauth2": {
+			"clientId":     []byte("client"),
+			"clientSecret": []byte("s3cret"),
+		},
 	}
 )
 
@@ -161,6 +179,14 @@ func TestGenericEndpoint_loadBase64Secrets(t *testing.T) {
 			"", "", "",
 			true,
 		},
+
+		// Type 'oauth2'
+		{
+			"credential type oauth2, no secretName",
+			genericEndpointCredentials{Type: "oauth2", TokenURL: "https://example.com/token"},
+			"", "", "",
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -254,6 +280,26 @@ func TestGenericEndpoint_loadKubernetesSecrets(t *testing.T) {
 			"", "", "",
 			"bearer: token missing in secret",
 		},
+
+		// Type 'oauth2'
+		{
+			"credential type oauth2, secretName has client credentials",
+			genericEndpointCredentials{Type: "oauth2", SecretName: "oauth2", TokenURL: "https://example.com/token"},
+			"", "", "",
+			"",
+		},
+		{
+			"credential type oauth2, tokenURL missing",
+			genericEndpointCredentials{Type: "oauth2", SecretName: "oauth2"},
+			"", "", "",
+			"oauth2: tokenURL must be set",
+		},
+		{
+			"credential type oauth2, secretName has no client credentials",
+			genericEndpointCredentials{Type: "oauth2", SecretName: "upt", TokenURL: "https://example.com/token"},
+			"", "", "",
+			"oauth2: clientId missing in secret",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -284,3 +330,181 @@ func TestGenericEndpoint_loadKubernetesSecrets(t *testing.T) {
 		})
 	}
 }
+
+type mapSecretLoader map[string]map[string][]byte
+
+func (m mapSecretLoader) GetSecret(name string) (*map[string][]byte, error) {
+	if secret, found := m[name]; found {
+		return &secret, nil
+	}
+	return nil, fmt.Errorf("secret key not found")
+}
+
+// makeTestCertificate returns a self-signed certificate for dnsName, which
+// can be used as a CA, a server certificate, and a client certificate.
+func makeTestCertificate(t *testing.T, dnsName string) (certPEM []byte, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: dnsName},
+		DNSNames:              []string{dnsName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() = %v", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM
+}
+
+func TestGenericEndpoint_loadTLS(t *testing.T) {
+	certPEM, keyPEM := makeTestCertificate(t, "service.internal")
+	loader := mapSecretLoader{
+		"full":    {"tls.crt": certPEM, "tls.key": keyPEM, "ca.crt": certPEM},
+		"ca-only": {"ca.crt": certPEM},
+		"no-key":  {"tls.crt": certPEM},
+		"bad-ca":  {"ca.crt": []byte("junk")},
+		"empty":   {},
+	}
+
+	tests := []struct {
+		name       string
+		tls        genericEndpointTLS
+		wantCert   bool
+		wantRootCA bool
+		wantErr    bool
+	}{
+		{"nothing set", genericEndpointTLS{}, false, false, false},
+		{"cert, key and ca", genericEndpointTLS{SecretName: "full"}, true, true, false},
+		{"ca only", genericEndpointTLS{SecretName: "ca-only"}, false, true, false},
+		{"cert without key", genericEndpointTLS{SecretName: "no-key"}, false, false, true},
+		{"ca junk", genericEndpointTLS{SecretName: "bad-ca"}, false, false, true},
+		{"empty secret", genericEndpointTLS{SecretName: "empty"}, false, false, true},
+		{"missing secret", genericEndpointTLS{SecretName: "missing"}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := &GenericEndpoint{config: genericEndpointConfig{TLS: tt.tls}}
+			err := ep.loadTLS(loader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenericEndpoint.loadTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := len(ep.tlsConfig.Certificates) > 0; got != tt.wantCert {
+				t.Errorf("client certificate set = %v, want %v", got, tt.wantCert)
+			}
+			if got := ep.tlsConfig.RootCAs != nil; got != tt.wantRootCA {
+				t.Errorf("RootCAs set = %v, want %v", got, tt.wantRootCA)
+			}
+		})
+	}
+}
+
+func TestGenericEndpoint_loadHeaders(t *testing.T) {
+	loader := mapSecretLoader{
+		"artifactory": {"apikey": []byte("secret-key")},
+	}
+
+	tests := []struct {
+		name    string
+		headers []genericEndpointHeader
+		want    http.Header
+		wantErr bool
+	}{
+		{"none", nil, http.Header{}, false},
+		{
+			"static and secret",
+			[]genericEndpointHeader{
+				{Name: "x-team", Value: "platform"},
+				{Name: "X-JFrog-Art-Api", SecretName: "artifactory", SecretKey: "apikey"},
+			},
+			http.Header{"X-Team": {"platform"}, "X-Jfrog-Art-Api": {"secret-key"}},
+			false,
+		},
+		{"no name", []genericEndpointHeader{{Value: "x"}}, nil, true},
+		{"no value", []genericEndpointHeader{{Name: "X-Team"}}, nil, true},
+		{"value and secret", []genericEndpointHeader{{Name: "X-Team", Value: "x", SecretName: "artifactory", SecretKey: "apikey"}}, nil, true},
+		{"no secret key", []genericEndpointHeader{{Name: "X-Team", SecretName: "artifactory"}}, nil, true},
+		{"key missing in secret", []genericEndpointHeader{{Name: "X-Team", SecretName: "artifactory", SecretKey: "other"}}, nil, true},
+		{"missing secret", []genericEndpointHeader{{Name: "X-Team", SecretName: "missing", SecretKey: "apikey"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep := &GenericEndpoint{config: genericEndpointConfig{Headers: tt.headers}}
+			err := ep.loadHeaders(loader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenericEndpoint.loadHeaders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && fmt.Sprint(ep.headers) != fmt.Sprint(tt.want) {
+				t.Errorf("headers = %v, want %v", ep.headers, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenericEndpoint_executeHTTPRequest_mutualTLS(t *testing.T) {
+	certPEM, keyPEM := makeTestCertificate(t, "service.internal")
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair() = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "key" || r.TLS.ServerName != "service.internal" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	loader := mapSecretLoader{
+		"client-tls": {"tls.crt": certPEM, "tls.key": keyPEM, "ca.crt": certPEM},
+	}
+	configBytes := []byte(fmt.Sprintf(`
+url: %s
+tls:
+  secretName: client-tls
+  serverName: service.internal
+headers:
+  - name: X-Api-Key
+    value: key
+`, server.URL))
+	ep, configured, err := MakeGenericEndpoint("vault", "v1", configBytes, loader)
+	if err != nil || !configured {
+		t.Fatalf("MakeGenericEndpoint() = %v, %v", configured, err)
+	}
+
+	req := &tunnel.HttpRequest{Id: "mtls", Type: "vault", Name: "v1", Method: "GET", URI: "/v1/sys/health"}
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	ep.executeHTTPRequest(context.Background(), dataflow, req)
+
+	resp := nextMessage(t, dataflow).GetHttpResponse()
+	if resp == nil || resp.Status != http.StatusNoContent {
+		t.Fatalf("response = %v, want 204", resp)
+	}
+}
@@ -18,10 +18,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 )
 
@@ -79,24 +81,87 @@ func makeResponse(id string, response *http.Response) *tunnel.AgentToControllerW
 	}
 }
 
+// countingBody counts the bytes of a streamed request body as they are sent.
+type countingBody struct {
+	io.ReadCloser
+	count func(int)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.count(n)
+	return n, err
+}
+
+// startRequestSpan continues the controller's trace for a request to an
+// endpoint.
+func startRequestSpan(ctx context.Context, req *tunnel.HttpRequest) (context.Context, *tracing.Span) {
+	return tracing.Start(tracing.Extract(ctx, req.TraceContext), "executeHTTPRequest", tracing.SpanKindConsumer,
+		tracing.String("endpoint.type", req.Type),
+		tracing.String("endpoint.name", req.Name),
+		tracing.String("transaction.id", req.Id))
+}
+
 func runHTTPRequest(client *http.Client, req *tunnel.HttpRequest, httpRequest *http.Request, dataflow chan *tunnel.AgentToControllerWrapper, baseURL string) {
+	metrics := startRequestMetrics(req.Type, req.Name)
+	metrics.addRequestBytes(len(req.Body))
+	if req.BodyStreamed && httpRequest.Body != nil {
+		httpRequest.Body = &countingBody{ReadCloser: httpRequest.Body, count: metrics.addRequestBytes}
+	}
+	ctx, span := tracing.Start(httpRequest.Context(), "runHTTPRequest", tracing.SpanKindClient,
+		tracing.String("http.method", req.Method),
+		tracing.String("http.url", baseURL+req.URI))
+	httpRequest = httpRequest.WithContext(ctx)
+	tracing.InjectHTTP(ctx, httpRequest.Header)
+	status := http.StatusBadGateway
+	defer func() {
+		metrics.finish(status)
+		span.SetAttributes(tracing.Int("http.status_code", status))
+		span.End()
+	}()
+
 	log.Printf("Sending HTTP request: %s to %v", req.Method, baseURL+req.URI)
 	httpResponse, err := client.Do(httpRequest)
 	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			metrics.cancelled()
+		}
 		log.Printf("Failed to execute request for %s to %s: %v", req.Method, baseURL+req.URI, err)
+		span.SetError(err.Error())
 		dataflow <- makeBadGatewayResponse(req.Id)
 		return
 	}
+	status = httpResponse.StatusCode
+
+	window := registerSendWindow(req.Id, req.Window)
+	defer unregisterSendWindow(req.Id)
+
+	if req.Upgrade && httpResponse.StatusCode == http.StatusSwitchingProtocols {
+		runUpgradedConnection(ctx, req, httpResponse, dataflow, window, metrics)
+		return
+	}
 
 	// First, send the headers.
 	resp := makeResponse(req.Id, httpResponse)
 	dataflow <- resp
 
-	// Now, send one or more data packet.
+	// Now, send one or more data packet, only as fast as the controller
+	// passes them on.
 	for {
+		available, err := window.Wait(ctx)
+		if err != nil {
+			log.Printf("Context cancelled, request ID %s", req.Id)
+			metrics.cancelled()
+			return
+		}
 		buf := make([]byte, 10240)
+		if available < int64(len(buf)) {
+			buf = buf[:available]
+		}
 		n, err := httpResponse.Body.Read(buf)
 		if n > 0 {
+			metrics.addResponseBytes(n)
+			window.Consume(n)
 			resp := makeChunkedResponse(req.Id, buf[:n])
 			dataflow <- resp
 		}
@@ -107,6 +172,7 @@ func runHTTPRequest(client *http.Client, req *tunnel.HttpRequest, httpRequest *h
 		}
 		if err == context.Canceled {
 			log.Printf("Context cancelled, request ID %s", req.Id)
+			metrics.cancelled()
 			return
 		}
 		if err != nil {
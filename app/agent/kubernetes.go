@@ -205,7 +205,8 @@ func (ke *KubernetesEndpoint) loadServiceAccount() (*kubeContext, error) {
 	}, nil
 }
 
-func (ke *KubernetesEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+func (ke *KubernetesEndpoint) executeHTTPRequest(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+	defer unregisterRequestBody(req.Id)
 	c := ke.makeServerContextFields()
 
 	// TODO: A ServerCA is technically optional, but we might want to fail if it's not present...
@@ -233,16 +234,16 @@ func (ke *KubernetesEndpoint) executeHTTPRequest(dataflow chan *tunnel.AgentToCo
 		Transport: tr,
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	registerCancelFunction(req.Id, cancel)
-	defer unregisterCancelFunction(req.Id)
+	ctx, span := startRequestSpan(ctx, req)
+	defer span.End()
 
-	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, c.serverURL+req.URI, bytes.NewBuffer(req.Body))
+	httpRequest, err := http.NewRequestWithContext(ctx, req.Method, c.serverURL+req.URI, makeRequestBody(ctx, req))
 	if err != nil {
 		log.Printf("Failed to build request for %s to %s: %v", req.Method, c.serverURL+req.URI, err)
 		dataflow <- makeBadGatewayResponse(req.Id)
 		return
 	}
+	setContentLength(req, httpRequest)
 
 	copyHeaders(req, httpRequest)
 	if len(c.token) > 0 {
@@ -0,0 +1,112 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/opsmx/oes-birger/pkg/util"
+)
+
+var (
+	endpointLabels = []string{"endpoint_type", "endpoint_name"}
+
+	requestsInFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agent_requests_in_flight",
+		Help: "The number of HTTP requests currently running",
+	}, endpointLabels)
+	requestDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "agent_request_duration_seconds",
+		Help:    "How long HTTP requests to endpoints took, by status class (2xx, 4xx, ...)",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, append(endpointLabels, "status_class"))
+	requestBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_request_bytes_total",
+		Help: "Bytes sent to endpoints in HTTP request bodies",
+	}, endpointLabels)
+	responseBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_response_bytes_total",
+		Help: "Bytes received from endpoints in HTTP response bodies",
+	}, endpointLabels)
+	requestsCancelledCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_requests_cancelled_total",
+		Help: "HTTP requests cancelled by the controller before they finished",
+	}, endpointLabels)
+	unknownIDDropsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "agent_unknown_id_drops_total",
+		Help: "Messages from the controller dropped because the request they belong to was not found",
+	}, []string{"kind"})
+)
+
+// requestMetrics tracks one HTTP request.  It counts as in flight from
+// when it is made until finish is called.
+type requestMetrics struct {
+	labels []string
+	start  time.Time
+}
+
+func startRequestMetrics(endpointType string, endpointName string) *requestMetrics {
+	m := &requestMetrics{
+		labels: []string{endpointType, endpointName},
+		start:  time.Now(),
+	}
+	requestsInFlightGauge.WithLabelValues(m.labels...).Inc()
+	return m
+}
+
+func (m *requestMetrics) addRequestBytes(n int) {
+	if n > 0 {
+		requestBytesCounter.WithLabelValues(m.labels...).Add(float64(n))
+	}
+}
+
+func (m *requestMetrics) addResponseBytes(n int) {
+	if n > 0 {
+		responseBytesCounter.WithLabelValues(m.labels...).Add(float64(n))
+	}
+}
+
+func (m *requestMetrics) cancelled() {
+	requestsCancelledCounter.WithLabelValues(m.labels...).Inc()
+}
+
+// finish must be called exactly once.
+func (m *requestMetrics) finish(status int) {
+	requestsInFlightGauge.WithLabelValues(m.labels...).Dec()
+	labels := append(append([]string{}, m.labels...), util.HTTPStatusClass(status))
+	requestDurationHistogram.WithLabelValues(labels...).Observe(time.Since(m.start).Seconds())
+}
+
+func runPrometheusHTTPServer(port uint16) {
+	log.Printf("Running HTTP listener for Prometheus on port %d", port)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,131 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenRefreshMargin is how long before a token expires it is replaced.
+const tokenRefreshMargin = 60 * time.Second
+
+//
+// oauth2TokenSource fetches access tokens with the client credentials
+// grant, and caches each one until shortly before it expires.  A token
+// the service has rejected can be discarded early.
+//
+type oauth2TokenSource struct {
+	config *clientcredentials.Config
+	ctx    context.Context
+
+	sync.Mutex
+	source oauth2.TokenSource
+}
+
+//
+// newOAuth2TokenSource returns a token source for config, which reaches
+// the token endpoint with client.
+//
+func newOAuth2TokenSource(config *clientcredentials.Config, client *http.Client) *oauth2TokenSource {
+	s := &oauth2TokenSource{
+		config: config,
+		ctx:    context.WithValue(context.Background(), oauth2.HTTPClient, client),
+	}
+	s.reset()
+	return s
+}
+
+func (s *oauth2TokenSource) reset() {
+	s.source = oauth2.ReuseTokenSourceWithExpiry(nil, s.config.TokenSource(s.ctx), tokenRefreshMargin)
+}
+
+// Token returns a cached access token, or fetches a new one.
+func (s *oauth2TokenSource) Token() (*oauth2.Token, error) {
+	s.Lock()
+	source := s.source
+	s.Unlock()
+	return source.Token()
+}
+
+// invalidate discards token if it is still cached, so the next call to
+// Token fetches a new one.
+func (s *oauth2TokenSource) invalidate(token *oauth2.Token) {
+	s.Lock()
+	defer s.Unlock()
+	current, err := s.source.Token()
+	if err == nil && current.AccessToken == token.AccessToken {
+		s.reset()
+	}
+}
+
+//
+// oauth2Transport adds a bearer token to each request.  If the endpoint
+// rejects the token with a 401, the token is discarded and the request is
+// sent once more with a new one.  Streamed request bodies cannot be sent
+// twice, so those requests are not retried.
+//
+type oauth2Transport struct {
+	base   http.RoundTripper
+	source *oauth2TokenSource
+}
+
+func (t *oauth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token()
+	if err != nil {
+		// A RoundTripper must close the body, even when it fails.
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.source.invalidate(token)
+	token, err = t.source.Token()
+	if err != nil {
+		return resp, nil
+	}
+	retry := req
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry = req.Clone(req.Context())
+		retry.Body = body
+	}
+	resp.Body.Close()
+	return t.send(retry, token)
+}
+
+// send makes the request with token.
+func (t *oauth2Transport) send(req *http.Request, token *oauth2.Token) (*http.Response, error) {
+	transport := &oauth2.Transport{Source: oauth2.StaticTokenSource(token), Base: t.base}
+	return transport.RoundTrip(req)
+}
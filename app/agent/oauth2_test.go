@@ -0,0 +1,197 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenServer issues numbered tokens, and counts how many it has issued.
+func tokenServer(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+			t.Errorf("form = %v, want grant_type=client_credentials", r.PostForm)
+		}
+		n := atomic.AddInt32(issued, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+}
+
+func makeTestTokenSource(tokenURL string, clientSecret string) *oauth2TokenSource {
+	config := &clientcredentials.Config{
+		ClientID:     "client",
+		ClientSecret: clientSecret,
+		TokenURL:     tokenURL,
+		AuthStyle:    oauth2.AuthStyleInHeader,
+	}
+	return newOAuth2TokenSource(config, http.DefaultClient)
+}
+
+func TestOAuth2TokenSource_caches(t *testing.T) {
+	var issued int32
+	server := tokenServer(t, 3600, &issued)
+	defer server.Close()
+
+	source := makeTestTokenSource(server.URL, "s3cret")
+	var first *oauth2.Token
+	for i := 0; i < 3; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token() = %v", err)
+		}
+		if token.AccessToken != "token-1" {
+			t.Errorf("Token() = %s, want token-1", token.AccessToken)
+		}
+		first = token
+	}
+
+	source.invalidate(first)
+	if token, _ := source.Token(); token.AccessToken != "token-2" {
+		t.Errorf("Token() after invalidate = %s, want token-2", token.AccessToken)
+	}
+
+	// A token which has already been replaced does not discard its replacement.
+	source.invalidate(first)
+	if token, _ := source.Token(); token.AccessToken != "token-2" {
+		t.Errorf("Token() after a stale invalidate = %s, want token-2", token.AccessToken)
+	}
+}
+
+func TestOAuth2TokenSource_refreshesBeforeExpiry(t *testing.T) {
+	var issued int32
+	server := tokenServer(t, 2, &issued)
+	defer server.Close()
+
+	// With a two second lifetime, tokens are replaced at once, as they
+	// expire within tokenRefreshMargin.
+	source := makeTestTokenSource(server.URL, "s3cret")
+	source.Token()
+	if token, _ := source.Token(); token.AccessToken != "token-2" {
+		t.Errorf("Token() = %s, want token-2", token.AccessToken)
+	}
+}
+
+func TestOAuth2TokenSource_error(t *testing.T) {
+	var issued int32
+	server := tokenServer(t, 3600, &issued)
+	defer server.Close()
+
+	source := makeTestTokenSource(server.URL, "wrong")
+	_, err := source.Token()
+	if err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Token() error = %v, want invalid_client", err)
+	}
+}
+
+func TestOAuth2Transport_retriesOnce(t *testing.T) {
+	var issued int32
+	tokens := tokenServer(t, 3600, &issued)
+	defer tokens.Close()
+
+	// The service accepts only the second token issued.
+	var calls int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer service.Close()
+
+	client := &http.Client{Transport: &oauth2Transport{
+		base:   http.DefaultTransport,
+		source: makeTestTokenSource(tokens.URL, "s3cret"),
+	}}
+
+	resp, err := client.Post(service.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Post() = %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Errorf("response = %d %q, want 200 hello", resp.StatusCode, body)
+	}
+	if calls != 2 {
+		t.Errorf("service called %d times, want 2", calls)
+	}
+
+	// A body which cannot be sent again is not retried.
+	atomic.StoreInt32(&calls, 0)
+	source := client.Transport.(*oauth2Transport).source
+	token, _ := source.Token()
+	source.invalidate(token)
+	req, _ := http.NewRequest("POST", service.URL, ioutil.NopCloser(strings.NewReader("hello")))
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || calls != 1 {
+		t.Errorf("response = %d after %d calls, want 401 after 1", resp.StatusCode, calls)
+	}
+}
+
+// closeRecorder records whether a request body was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestOAuth2Transport_closesBodyWithoutToken(t *testing.T) {
+	var issued int32
+	tokens := tokenServer(t, 3600, &issued)
+	defer tokens.Close()
+
+	transport := &oauth2Transport{
+		base:   http.DefaultTransport,
+		source: makeTestTokenSource(tokens.URL, "wrong"),
+	}
+	body := &closeRecorder{Reader: strings.NewReader("hello")}
+	req, _ := http.NewRequest("POST", "http://service.invalid/", body)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatalf("RoundTrip() succeeded without a token")
+	}
+	if !body.closed {
+		t.Errorf("request body was not closed")
+	}
+}
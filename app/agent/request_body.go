@@ -0,0 +1,261 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// requestBodyCreditThreshold is how much of a request body must be read
+// before credit is returned to the controller.
+const requestBodyCreditThreshold = tunnel.RequestBodyWindow / 4
+
+var errRequestBodyClosed = errors.New("request body closed")
+
+//
+// streamedRequestBody is a request body arriving from the controller in
+// HttpRequestBody messages.  Chunks are queued as they arrive, so the
+// tunnel is never held up by a slow endpoint, and credit is returned to
+// the controller as they are read.  The controller's send window limits
+// how much can be queued.
+//
+type streamedRequestBody struct {
+	sync.Mutex
+	id       string
+	dataflow chan *tunnel.AgentToControllerWrapper
+	chunks   [][]byte
+	eof      bool
+	closed   bool
+	arrived  chan struct{}
+	done     chan struct{}
+	unacked  int64
+	sending  sync.WaitGroup
+}
+
+var requestBodyRegistry = struct {
+	sync.Mutex
+	m map[string]*streamedRequestBody
+}{m: make(map[string]*streamedRequestBody)}
+
+// registerRequestBody must be called before any HttpRequestBody or
+// ConnectionData for the request can arrive, so it is called before the
+// request's goroutine is started.
+func registerRequestBody(id string, dataflow chan *tunnel.AgentToControllerWrapper) {
+	requestBodyRegistry.Lock()
+	defer requestBodyRegistry.Unlock()
+	requestBodyRegistry.m[id] = &streamedRequestBody{
+		id:       id,
+		dataflow: dataflow,
+		arrived:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// unregisterRequestBody closes the body, so an endpoint still reading it
+// gets an error.  It is safe to call for requests without a streamed body.
+func unregisterRequestBody(id string) {
+	requestBodyRegistry.Lock()
+	b, found := requestBodyRegistry.m[id]
+	delete(requestBodyRegistry.m, id)
+	requestBodyRegistry.Unlock()
+	if found {
+		b.Close()
+	}
+}
+
+func deliverRequestBody(chunk *tunnel.HttpRequestBody) {
+	deliver(chunk.Id, chunk.Body, len(chunk.Body) == 0)
+}
+
+// deliverConnectionData passes data from the client of an upgraded or
+// forwarded connection on, as the request's body.
+func deliverConnectionData(data *tunnel.ConnectionData) {
+	deliver(data.Id, data.Data, data.Closed)
+}
+
+func deliver(id string, data []byte, eof bool) {
+	b := findRequestBody(id)
+	if b == nil {
+		unknownIDDropsCounter.WithLabelValues("http").Inc()
+		return
+	}
+	b.Lock()
+	if len(data) > 0 {
+		b.chunks = append(b.chunks, data)
+	}
+	if eof {
+		b.eof = true
+	}
+	b.Unlock()
+	b.signal()
+}
+
+func findRequestBody(id string) *streamedRequestBody {
+	requestBodyRegistry.Lock()
+	defer requestBodyRegistry.Unlock()
+	return requestBodyRegistry.m[id]
+}
+
+//
+// makeRequestBody returns the body for an endpoint's request, which is
+// either sent with the request or streamed after it.  A streamed body
+// is closed if ctx is done before it is unregistered.
+//
+func makeRequestBody(ctx context.Context, req *tunnel.HttpRequest) io.Reader {
+	if !req.BodyStreamed {
+		return bytes.NewReader(req.Body)
+	}
+	b := findRequestBody(req.Id)
+	if b == nil {
+		return bytes.NewReader(nil)
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.Close()
+		case <-b.done:
+		}
+	}()
+	return b
+}
+
+// setContentLength sets the length of a streamed body, if it is known.
+func setContentLength(req *tunnel.HttpRequest, httpRequest *http.Request) {
+	if req.BodyStreamed && req.ContentLength >= 0 {
+		httpRequest.ContentLength = req.ContentLength
+	}
+}
+
+//
+// spoolRequestBody copies the request body to a temporary file, for
+// endpoints which need to read it more than once.  The file is removed
+// when closed.
+//
+func spoolRequestBody(ctx context.Context, req *tunnel.HttpRequest) (*spooledBody, error) {
+	f, err := ioutil.TempFile("", "request-body-*")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+	size, err := io.Copy(f, makeRequestBody(ctx, req))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &spooledBody{File: f, size: size}, nil
+}
+
+// spooledBody is a request body copied to an unlinked temporary file.
+type spooledBody struct {
+	*os.File
+	size int64
+}
+
+func (b *streamedRequestBody) signal() {
+	select {
+	case b.arrived <- struct{}{}:
+	default:
+	}
+}
+
+func (b *streamedRequestBody) Read(p []byte) (int, error) {
+	for {
+		b.Lock()
+		if b.closed {
+			b.Unlock()
+			return 0, errRequestBodyClosed
+		}
+		if len(b.chunks) > 0 {
+			n := copy(p, b.chunks[0])
+			if n == len(b.chunks[0]) {
+				b.chunks = b.chunks[1:]
+			} else {
+				b.chunks[0] = b.chunks[0][n:]
+			}
+			b.unacked += int64(n)
+			var update *tunnel.AgentToControllerWrapper
+			if b.unacked >= requestBodyCreditThreshold {
+				update = makeWindowUpdate(b.id, b.unacked)
+				b.unacked = 0
+				b.sending.Add(1)
+			}
+			b.Unlock()
+			if update != nil {
+				b.sendUpdate(update)
+			}
+			return n, nil
+		}
+		if b.eof {
+			b.Unlock()
+			return 0, io.EOF
+		}
+		b.Unlock()
+		<-b.arrived
+	}
+}
+
+//
+// sendUpdate returns credit to the controller.  It gives up if the body is
+// closed, which it is when the request's context is done, so a full
+// dataflow channel cannot hold up the reader.
+//
+func (b *streamedRequestBody) sendUpdate(update *tunnel.AgentToControllerWrapper) {
+	defer b.sending.Done()
+	select {
+	case b.dataflow <- update:
+	case <-b.done:
+	}
+}
+
+//
+// Close discards the rest of the body.  It waits for any window update
+// being sent, so the session cannot close the dataflow channel under it.
+//
+func (b *streamedRequestBody) Close() error {
+	b.Lock()
+	if b.closed {
+		b.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.chunks = nil
+	close(b.done)
+	b.Unlock()
+	b.signal()
+	b.sending.Wait()
+	return nil
+}
+
+func makeWindowUpdate(id string, n int64) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_WindowUpdate{
+			WindowUpdate: &tunnel.WindowUpdate{Id: id, Bytes: n},
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+func TestStreamedRequestBody_read(t *testing.T) {
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody("streamed", dataflow)
+	defer unregisterRequestBody("streamed")
+
+	req := &tunnel.HttpRequest{Id: "streamed", BodyStreamed: true}
+	body := makeRequestBody(context.Background(), req)
+
+	go func() {
+		deliverRequestBody(&tunnel.HttpRequestBody{Id: "streamed", Body: []byte("hello, ")})
+		deliverRequestBody(&tunnel.HttpRequestBody{Id: "streamed", Body: []byte("world")})
+		deliverRequestBody(&tunnel.HttpRequestBody{Id: "streamed", Body: []byte{}})
+	}()
+
+	got, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	}
+	if string(got) != "hello, world" {
+		t.Errorf("body = %q, want %q", got, "hello, world")
+	}
+}
+
+func TestStreamedRequestBody_returnsCredit(t *testing.T) {
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody("credit", dataflow)
+	defer unregisterRequestBody("credit")
+
+	req := &tunnel.HttpRequest{Id: "credit", BodyStreamed: true}
+	body := makeRequestBody(context.Background(), req)
+
+	deliverRequestBody(&tunnel.HttpRequestBody{Id: "credit", Body: make([]byte, requestBodyCreditThreshold)})
+	deliverRequestBody(&tunnel.HttpRequestBody{Id: "credit", Body: []byte{}})
+	if _, err := io.Copy(ioutil.Discard, body); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+
+	select {
+	case msg := <-dataflow:
+		update := msg.GetWindowUpdate()
+		if update == nil || update.Id != "credit" || update.Bytes != requestBodyCreditThreshold {
+			t.Errorf("update = %v, want %d bytes for credit", update, requestBodyCreditThreshold)
+		}
+	default:
+		t.Errorf("no WindowUpdate sent")
+	}
+}
+
+func TestStreamedRequestBody_cancelled(t *testing.T) {
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody("cancelled", dataflow)
+	defer unregisterRequestBody("cancelled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := &tunnel.HttpRequest{Id: "cancelled", BodyStreamed: true}
+	body := makeRequestBody(ctx, req)
+
+	errs := make(chan error)
+	go func() {
+		_, err := body.Read(make([]byte, 10))
+		errs <- err
+	}()
+	cancel()
+	if err := <-errs; err != errRequestBodyClosed {
+		t.Errorf("Read() = %v, want %v", err, errRequestBodyClosed)
+	}
+}
+
+func TestStreamedRequestBody_creditSentWithoutLock(t *testing.T) {
+	// The session's dataflow channel is full, so credit cannot be sent.
+	dataflow := make(chan *tunnel.AgentToControllerWrapper)
+	registerRequestBody("blocked", dataflow)
+	defer unregisterRequestBody("blocked")
+
+	req := &tunnel.HttpRequest{Id: "blocked", BodyStreamed: true}
+	body := makeRequestBody(context.Background(), req)
+	deliverRequestBody(&tunnel.HttpRequestBody{Id: "blocked", Body: make([]byte, requestBodyCreditThreshold)})
+
+	errs := make(chan error)
+	go func() {
+		_, err := body.Read(make([]byte, requestBodyCreditThreshold))
+		errs <- err
+	}()
+
+	// Wait for the reader to take the chunk, and so be sending credit.
+	b := findRequestBody("blocked")
+	for taken := false; !taken; time.Sleep(time.Millisecond) {
+		b.Lock()
+		taken = len(b.chunks) == 0
+		b.Unlock()
+	}
+
+	// More of the body can still arrive while the reader waits to send.
+	delivered := make(chan struct{})
+	go func() {
+		deliverRequestBody(&tunnel.HttpRequestBody{Id: "blocked", Body: []byte("more")})
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatalf("deliverRequestBody() blocked while credit was being sent")
+	}
+
+	// Closing the body stops the reader waiting.
+	unregisterRequestBody("blocked")
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Errorf("Read() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Read() did not return once the body was closed")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/agent/cfg"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultSSHPort  = "22"
+	sshDialTimeout  = 30 * time.Second
+	defaultTermType = "xterm"
+)
+
+// sshCommandHost is a remote host, reached over SSH, which commands
+// can be run on.
+type sshCommandHost struct {
+	name    string
+	address string
+	config  *ssh.ClientConfig
+}
+
+// commandHosts holds the configured SSH command hosts, by name.
+var commandHosts = map[string]*sshCommandHost{}
+
+func configureCommandHosts() {
+	commandHosts = map[string]*sshCommandHost{}
+	for _, command := range agentServiceConfig.Commands {
+		if !command.Enabled {
+			continue
+		}
+		host, err := makeSSHCommandHost(command)
+		if err != nil {
+			log.Fatalf("Command host %s: %v", command.Name, err)
+		}
+		log.Printf("Adding command host %s, address %s, username %s", host.name, host.address, command.Username)
+		commandHosts[host.name] = host
+	}
+}
+
+// commandHostsToPB returns the configured command hosts as endpoints,
+// so the controller knows they can be targeted by remote-command.
+func commandHostsToPB() []*tunnel.EndpointHealth {
+	pbEndpoints := []*tunnel.EndpointHealth{}
+	for name := range commandHosts {
+		pbEndpoints = append(pbEndpoints, &tunnel.EndpointHealth{
+			Name:       name,
+			Type:       "remote-command",
+			Configured: true,
+		})
+	}
+	return pbEndpoints
+}
+
+func makeSSHCommandHost(c cfg.CommandConfig) (*sshCommandHost, error) {
+	if len(c.Name) == 0 {
+		return nil, fmt.Errorf("name must be set")
+	}
+	if len(c.Host) == 0 {
+		return nil, fmt.Errorf("host must be set")
+	}
+	if len(c.Username) == 0 {
+		return nil, fmt.Errorf("username must be set")
+	}
+
+	address := c.Host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultSSHPort)
+	}
+
+	hostKeyCallback, err := makeHostKeyCallback(c)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := makeSSHAuthMethods(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sshCommandHost{
+		name:    c.Name,
+		address: address,
+		config: &ssh.ClientConfig{
+			User:            c.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sshDialTimeout,
+		},
+	}, nil
+}
+
+func makeHostKeyCallback(c cfg.CommandConfig) (ssh.HostKeyCallback, error) {
+	if c.InsecureIgnoreHostKey {
+		log.Printf("WARNING: command host %s will not verify the host key", c.Name)
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	if len(c.KnownHosts) == 0 {
+		return nil, fmt.Errorf("knownHostsPath must be set unless insecureIgnoreHostKey is true")
+	}
+	callback, err := knownhosts.New(c.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("loading known hosts: %v", err)
+	}
+	return callback, nil
+}
+
+// makeSSHAuthMethods returns the key and password authentication methods
+// configured.  If both are set, the key is tried first.
+func makeSSHAuthMethods(c cfg.CommandConfig) ([]ssh.AuthMethod, error) {
+	auth := []ssh.AuthMethod{}
+	if len(c.UserKeyPath) > 0 {
+		key, err := ioutil.ReadFile(c.UserKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading user key: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parsing user key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if len(c.PasswordPath) > 0 {
+		password, err := ioutil.ReadFile(c.PasswordPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading password: %v", err)
+		}
+		auth = append(auth, ssh.Password(strings.TrimRight(string(password), "\r\n")))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("one of userKeyPath or passwordPath must be set")
+	}
+	return auth, nil
+}
+
+// shellQuote quotes each argument so the remote shell will see it
+// as a single word, and joins them into a command line.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// getTermType returns the terminal type from the request's environment,
+// if present.
+func getTermType(environment []string) string {
+	for _, env := range environment {
+		if strings.HasPrefix(env, "TERM=") {
+			return strings.TrimPrefix(env, "TERM=")
+		}
+	}
+	return defaultTermType
+}
+
+// runCommand runs the request's arguments as a command line on the
+// remote host.  If there are no arguments and a terminal is requested,
+// the user's login shell is started instead.
+func (h *sshCommandHost) runCommand(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.CommandRequest, input *commandInput) {
+	if input != nil {
+		defer unregisterCommandInput(req.Id)
+	}
+	ctx, cancel := input.watch(ctx)
+	defer cancel()
+	window := registerSendWindow(req.Id, req.Window)
+	defer unregisterSendWindow(req.Id)
+
+	log.Printf("Got SSH command request for %s: %v", h.name, req)
+
+	if !req.Tty && len(req.Arguments) == 0 {
+		dataflow <- makeCommandFailed(req, nil, "Agent: no command given for SSH host")
+		return
+	}
+
+	client, err := ssh.Dial("tcp", h.address, h.config)
+	if err != nil {
+		dataflow <- makeCommandFailed(req, err, "Dial()")
+		return
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		dataflow <- makeCommandFailed(req, err, "NewSession()")
+		return
+	}
+	defer session.Close()
+
+	// Closing the session will cause any blocked reads or Wait() to return.
+	go func() {
+		<-ctx.Done()
+		session.Close()
+	}()
+
+	for _, env := range req.Environment {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if err := session.Setenv(parts[0], parts[1]); err != nil {
+			// Most SSH servers only accept a few environment variables.
+			log.Printf("SSH host %s refused environment variable %s", h.name, parts[0])
+		}
+	}
+
+	if req.Tty {
+		rows, columns := 24, 80
+		if validTerminalSize(req.TerminalSize) {
+			rows, columns = int(req.TerminalSize.Rows), int(req.TerminalSize.Columns)
+		}
+		if err := session.RequestPty(getTermType(req.Environment), rows, columns, ssh.TerminalModes{}); err != nil {
+			dataflow <- makeCommandFailed(req, err, "RequestPty()")
+			return
+		}
+		registerTerminal(req.Id, func(size *tunnel.TerminalSize) error {
+			return session.WindowChange(int(size.Rows), int(size.Columns))
+		})
+		defer unregisterTerminal(req.Id)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		dataflow <- makeCommandFailed(req, err, "StdoutPipe()")
+		return
+	}
+
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		dataflow <- makeCommandFailed(req, err, "StderrPipe()")
+		return
+	}
+
+	if input != nil {
+		stdin, err := session.StdinPipe()
+		if err != nil {
+			dataflow <- makeCommandFailed(req, err, "StdinPipe()")
+			return
+		}
+		go inputReceiver(input, stdin)
+	}
+
+	if req.Tty && len(req.Arguments) == 0 {
+		err = session.Shell()
+	} else {
+		err = session.Start(shellQuote(req.Arguments))
+	}
+	if err != nil {
+		dataflow <- makeCommandFailed(req, err, "Start()")
+		return
+	}
+
+	// aggregation channel, for stdout and stderr to be send through.
+	agg := make(chan *outputMessage)
+	go outputSender(tunnel.ChannelDirection_STDOUT, agg, stdout)
+	go outputSender(tunnel.ChannelDirection_STDERR, agg, stderr)
+
+	activeCount := 2
+	for msg := range agg {
+		if msg.closed {
+			dataflow <- makeCommandDataClosed(req, msg.channel)
+			activeCount--
+			if activeCount == 0 {
+				break
+			}
+		} else {
+			// Output after a cancel is dropped, so outputSender can exit.
+			if _, err := window.Wait(ctx); err != nil {
+				continue
+			}
+			window.Consume(len(msg.value))
+			dataflow <- makeCommandData(req, msg.channel, msg.value)
+		}
+	}
+
+	if err := session.Wait(); err != nil {
+		if input.wasDropped() {
+			dataflow <- makeCommandFailed(req, nil, commandInputDropped)
+			return
+		}
+		if exiterr, ok := err.(*ssh.ExitError); ok {
+			log.Printf("SSH command on %s exited with code %d", h.name, exiterr.ExitStatus())
+			dataflow <- makeCommandTermination(req, exiterr.ExitStatus())
+			return
+		}
+		dataflow <- makeCommandFailed(req, err, "Wait()")
+		return
+	}
+
+	dataflow <- makeCommandTermination(req, 0)
+}
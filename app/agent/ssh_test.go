@@ -0,0 +1,142 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/opsmx/oes-birger/app/agent/cfg"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"empty", []string{}, ""},
+		{"simple", []string{"ls", "-l"}, `'ls' '-l'`},
+		{"spaces", []string{"echo", "a b"}, `'echo' 'a b'`},
+		{"quotes", []string{"echo", "it's"}, `'echo' 'it'\''s'`},
+		{"metacharacters", []string{"echo", "$HOME; rm -rf /"}, `'echo' '$HOME; rm -rf /'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shellQuote(tt.args); got != tt.want {
+				t.Errorf("shellQuote() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTermType(t *testing.T) {
+	if got := getTermType([]string{"FOO=bar", "TERM=vt100"}); got != "vt100" {
+		t.Errorf("getTermType() = %v, want vt100", got)
+	}
+	if got := getTermType([]string{"FOO=bar"}); got != defaultTermType {
+		t.Errorf("getTermType() = %v, want %v", got, defaultTermType)
+	}
+}
+
+func TestMakeSSHCommandHost(t *testing.T) {
+	dir := t.TempDir()
+	passwordPath := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(passwordPath, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	knownHostsPath := filepath.Join(dir, "known_hosts")
+	if err := ioutil.WriteFile(knownHostsPath, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	missingPath := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name        string
+		config      cfg.CommandConfig
+		wantAddress string
+		wantErr     bool
+	}{
+		{
+			"default port",
+			cfg.CommandConfig{Name: "a", Host: "example.com", Username: "u", KnownHosts: knownHostsPath, PasswordPath: passwordPath},
+			"example.com:22",
+			false,
+		},
+		{
+			"explicit port",
+			cfg.CommandConfig{Name: "a", Host: "example.com:2222", Username: "u", InsecureIgnoreHostKey: true, PasswordPath: passwordPath},
+			"example.com:2222",
+			false,
+		},
+		{
+			"missing name",
+			cfg.CommandConfig{Host: "example.com", Username: "u", InsecureIgnoreHostKey: true, PasswordPath: passwordPath},
+			"",
+			true,
+		},
+		{
+			"missing username",
+			cfg.CommandConfig{Name: "a", Host: "example.com", InsecureIgnoreHostKey: true, PasswordPath: passwordPath},
+			"",
+			true,
+		},
+		{
+			"no host key verification configured",
+			cfg.CommandConfig{Name: "a", Host: "example.com", Username: "u", PasswordPath: passwordPath},
+			"",
+			true,
+		},
+		{
+			"missing known hosts file",
+			cfg.CommandConfig{Name: "a", Host: "example.com", Username: "u", KnownHosts: missingPath, PasswordPath: passwordPath},
+			"",
+			true,
+		},
+		{
+			"no auth",
+			cfg.CommandConfig{Name: "a", Host: "example.com", Username: "u", InsecureIgnoreHostKey: true},
+			"",
+			true,
+		},
+		{
+			"missing user key",
+			cfg.CommandConfig{Name: "a", Host: "example.com", Username: "u", InsecureIgnoreHostKey: true, UserKeyPath: missingPath},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeSSHCommandHost(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("makeSSHCommandHost() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.address != tt.wantAddress {
+				t.Errorf("makeSSHCommandHost() address = %v, want %v", got.address, tt.wantAddress)
+			}
+			if len(got.config.Auth) != 1 {
+				t.Errorf("makeSSHCommandHost() auth methods = %d, want 1", len(got.config.Auth))
+			}
+		})
+	}
+}
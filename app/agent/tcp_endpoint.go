@@ -0,0 +1,168 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tracing"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"github.com/opsmx/oes-birger/pkg/util"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v3"
+)
+
+//
+// tcpEndpointConfig lists the host:port addresses a "tcp" endpoint may
+// connect to.  Address is used when the controller does not name one,
+// and is always allowed.  Allowed addresses may use "*.example.com" or
+// "*" as the host, and "*" as the port, so that the endpoint can serve
+// the controller's forward proxy.
+//
+type tcpEndpointConfig struct {
+	Address            string   `yaml:"address,omitempty"`
+	AllowedAddresses   []string `yaml:"allowedAddresses,omitempty"`
+	DialTimeoutSeconds int      `yaml:"dialTimeoutSeconds,omitempty"`
+}
+
+// TCPEndpoint forwards TCP connections from the controller, such as to a
+// database or SSH bastion.
+type TCPEndpoint struct {
+	endpointName string
+	config       tcpEndpointConfig
+}
+
+// MakeTCPEndpoint returns a TCP endpoint, or an error if the configuration is invalid.
+func MakeTCPEndpoint(name string, configBytes []byte) (*TCPEndpoint, bool, error) {
+	ep := &TCPEndpoint{endpointName: name}
+
+	err := yaml.Unmarshal(configBytes, &ep.config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	addresses := ep.config.AllowedAddresses
+	if len(ep.config.Address) > 0 {
+		addresses = append(addresses, ep.config.Address)
+	}
+	for _, address := range addresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, false, fmt.Errorf("tcp endpoint %s: %v", name, err)
+		}
+	}
+	if strings.Contains(ep.config.Address, "*") {
+		return nil, false, fmt.Errorf("tcp endpoint %s: address must not contain wildcards", name)
+	}
+	if ep.config.DialTimeoutSeconds < 0 {
+		return nil, false, fmt.Errorf("tcp endpoint %s: dialTimeoutSeconds must not be negative", name)
+	}
+	if ep.config.DialTimeoutSeconds == 0 {
+		ep.config.DialTimeoutSeconds = 10
+	}
+
+	if len(addresses) == 0 {
+		log.Printf("no addresses set for tcp/%s", name)
+		return ep, false, nil
+	}
+	return ep, true, nil
+}
+
+// allows returns true if the endpoint may connect to address.
+func (ep *TCPEndpoint) allows(address string) bool {
+	if strings.EqualFold(address, ep.config.Address) {
+		return true
+	}
+	for _, allowed := range ep.config.AllowedAddresses {
+		if addressMatches(allowed, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressMatches returns true if the host:port address matches pattern.
+func addressMatches(pattern string, address string) bool {
+	patternHost, patternPort, err := net.SplitHostPort(pattern)
+	if err != nil {
+		return false
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if patternPort != "*" && patternPort != port {
+		return false
+	}
+	return util.HostMatches(patternHost, host)
+}
+
+func (ep *TCPEndpoint) executeHTTPRequest(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.HttpRequest) {
+	defer unregisterRequestBody(req.Id)
+	log.Printf("HTTP request for tcp endpoint %s refused", ep.endpointName)
+	dataflow <- makeBadGatewayResponse(req.Id)
+}
+
+func (ep *TCPEndpoint) openConnection(ctx context.Context, dataflow chan *tunnel.AgentToControllerWrapper, req *tunnel.OpenConnection) {
+	defer unregisterRequestBody(req.Id)
+	address := req.Address
+	if len(address) == 0 {
+		address = ep.config.Address
+	}
+
+	metrics := startRequestMetrics(req.Type, req.Name)
+	ctx, span := tracing.Start(tracing.Extract(ctx, req.TraceContext), "openConnection", tracing.SpanKindClient,
+		tracing.String("endpoint.type", req.Type),
+		tracing.String("endpoint.name", req.Name),
+		tracing.String("transaction.id", req.Id),
+		tracing.String("net.peer.name", address))
+	status := http.StatusBadGateway
+	defer func() {
+		metrics.finish(status)
+		span.End()
+	}()
+
+	if !ep.allows(address) {
+		err := fmt.Sprintf("address %q is not allowed for tcp endpoint %s", address, ep.endpointName)
+		log.Printf("Refused connection %s: %s", req.Id, err)
+		span.SetError(err)
+		dataflow <- makeConnectionOpened(req.Id, err)
+		return
+	}
+
+	log.Printf("Opening connection %s to %s", req.Id, address)
+	dialer := &net.Dialer{Timeout: time.Duration(ep.config.DialTimeoutSeconds) * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		log.Printf("Failed to connect to %s: %v", address, err)
+		span.SetError(err.Error())
+		dataflow <- makeConnectionOpened(req.Id, err.Error())
+		return
+	}
+	defer conn.Close()
+	status = http.StatusOK
+
+	window := registerSendWindow(req.Id, req.Window)
+	defer unregisterSendWindow(req.Id)
+
+	dataflow <- makeConnectionOpened(req.Id, "")
+	pipeConnection(ctx, req.Id, conn, findRequestBody(req.Id), dataflow, window, metrics)
+}
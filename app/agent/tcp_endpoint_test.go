@@ -0,0 +1,196 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+func TestMakeTCPEndpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		wantConfigured bool
+		wantErr        bool
+	}{
+		{"address", "address: db:5432", true, false},
+		{"allowed", "allowedAddresses: [db:5432, replica:5432]", true, false},
+		{"nothing", "{}", false, false},
+		{"no port", "address: db", false, true},
+		{"wildcard allowed", "allowedAddresses: ['*.example.com:443']", true, false},
+		{"wildcard address", "address: '*.example.com:443'", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, configured, err := MakeTCPEndpoint("tcp1", []byte(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MakeTCPEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if configured != tt.wantConfigured {
+				t.Errorf("MakeTCPEndpoint() configured = %v, want %v", configured, tt.wantConfigured)
+			}
+		})
+	}
+}
+
+func TestTCPEndpoint_allows(t *testing.T) {
+	ep, _, err := MakeTCPEndpoint("tcp1", []byte("address: db:5432\nallowedAddresses: [Replica:5432]"))
+	if err != nil {
+		t.Fatalf("MakeTCPEndpoint() = %v", err)
+	}
+	for address, want := range map[string]bool{
+		"db:5432":      true,
+		"replica:5432": true,
+		"db:22":        false,
+		"other:5432":   false,
+	} {
+		if got := ep.allows(address); got != want {
+			t.Errorf("allows(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestTCPEndpoint_allowsWildcards(t *testing.T) {
+	ep, _, err := MakeTCPEndpoint("tcp1", []byte("allowedAddresses: ['*.example.com:443', 'db.internal:*']"))
+	if err != nil {
+		t.Fatalf("MakeTCPEndpoint() = %v", err)
+	}
+	for address, want := range map[string]bool{
+		"api.example.com:443": true,
+		"API.example.com:443": true,
+		"api.example.com:80":  false,
+		"example.com:443":     false,
+		"db.internal:5432":    true,
+		"db.internal:22":      true,
+		"db.other:22":         false,
+		"db.internal":         false,
+	} {
+		if got := ep.allows(address); got != want {
+			t.Errorf("allows(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestTCPEndpoint_openConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	ep, _, err := MakeTCPEndpoint("tcp1", []byte("address: "+listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("MakeTCPEndpoint() = %v", err)
+	}
+	req := &tunnel.OpenConnection{Id: "tcp", Type: "tcp", Name: "tcp1"}
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody(req.Id, dataflow)
+	done := make(chan struct{})
+	go func() {
+		ep.openConnection(context.Background(), dataflow, req)
+		close(done)
+	}()
+
+	opened := nextMessage(t, dataflow).GetConnectionOpened()
+	if opened == nil || len(opened.Error) > 0 {
+		t.Fatalf("opened = %v, want no error", opened)
+	}
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Data: []byte("ping")})
+	data := nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || string(data.Data) != "ping" {
+		t.Fatalf("data = %v, want ping echoed", data)
+	}
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Closed: true})
+	data = nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || !data.Closed {
+		t.Fatalf("data = %v, want closed", data)
+	}
+	<-done
+}
+
+func TestTCPEndpoint_halfClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() = %v", err)
+	}
+	defer listener.Close()
+	// The server answers only once the client has finished sending.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request, _ := ioutil.ReadAll(conn)
+		conn.Write(append([]byte("got "), request...))
+	}()
+
+	ep, _, err := MakeTCPEndpoint("tcp1", []byte("address: "+listener.Addr().String()))
+	if err != nil {
+		t.Fatalf("MakeTCPEndpoint() = %v", err)
+	}
+	req := &tunnel.OpenConnection{Id: "half-close", Type: "tcp", Name: "tcp1"}
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody(req.Id, dataflow)
+	go ep.openConnection(context.Background(), dataflow, req)
+
+	opened := nextMessage(t, dataflow).GetConnectionOpened()
+	if opened == nil || len(opened.Error) > 0 {
+		t.Fatalf("opened = %v, want no error", opened)
+	}
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Data: []byte("request")})
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Closed: true})
+
+	data := nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || string(data.Data) != "got request" {
+		t.Fatalf("data = %v, want the reply sent after the client closed", data)
+	}
+	data = nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || !data.Closed {
+		t.Fatalf("data = %v, want closed", data)
+	}
+}
+
+func TestTCPEndpoint_refusesAddress(t *testing.T) {
+	ep, _, err := MakeTCPEndpoint("tcp1", []byte("address: db:5432"))
+	if err != nil {
+		t.Fatalf("MakeTCPEndpoint() = %v", err)
+	}
+	req := &tunnel.OpenConnection{Id: "refused", Type: "tcp", Name: "tcp1", Address: "other:22"}
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody(req.Id, dataflow)
+	ep.openConnection(context.Background(), dataflow, req)
+
+	opened := nextMessage(t, dataflow).GetConnectionOpened()
+	if opened == nil || len(opened.Error) == 0 {
+		t.Fatalf("opened = %v, want an error", opened)
+	}
+}
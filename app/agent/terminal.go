@@ -0,0 +1,112 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/creack/pty"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// resizeFunc changes the size of a running command's terminal.
+type resizeFunc func(size *tunnel.TerminalSize) error
+
+// terminalRegistry holds the resize function for each command running on
+// a terminal, by request ID.
+var terminalRegistry = struct {
+	sync.Mutex
+	m map[string]resizeFunc
+}{m: make(map[string]resizeFunc)}
+
+func registerTerminal(id string, resize resizeFunc) {
+	terminalRegistry.Lock()
+	defer terminalRegistry.Unlock()
+	terminalRegistry.m[id] = resize
+}
+
+func unregisterTerminal(id string) {
+	terminalRegistry.Lock()
+	defer terminalRegistry.Unlock()
+	delete(terminalRegistry.m, id)
+}
+
+func resizeTerminal(req *tunnel.CommandResize) {
+	terminalRegistry.Lock()
+	defer terminalRegistry.Unlock()
+	resize, found := terminalRegistry.m[req.Id]
+	if !found {
+		log.Printf("Got resize for unknown or finished command %s", req.Id)
+		unknownIDDropsCounter.WithLabelValues("command").Inc()
+		return
+	}
+	if !validTerminalSize(req.TerminalSize) {
+		return
+	}
+	if err := resize(req.TerminalSize); err != nil {
+		log.Printf("Unable to resize terminal for %s: %v", req.Id, err)
+	}
+}
+
+func validTerminalSize(size *tunnel.TerminalSize) bool {
+	return size != nil && size.Rows > 0 && size.Columns > 0
+}
+
+func makeWinsize(size *tunnel.TerminalSize) *pty.Winsize {
+	if !validTerminalSize(size) {
+		return nil
+	}
+	return &pty.Winsize{
+		Rows: uint16(size.Rows),
+		Cols: uint16(size.Columns),
+	}
+}
+
+// startTerminal starts the command on a new pseudo-terminal, and returns the
+// controlling side of it.  Any credentials already set on the command are kept.
+func startTerminal(cmd *exec.Cmd, size *tunnel.TerminalSize) (*os.File, error) {
+	attrs := cmd.SysProcAttr
+	attrs.Setsid = true
+	attrs.Setctty = true
+	return pty.StartWithAttrs(cmd, makeWinsize(size), attrs)
+}
+
+// ptyResizer returns a resizeFunc for a local pseudo-terminal.
+func ptyResizer(terminal *os.File) resizeFunc {
+	return func(size *tunnel.TerminalSize) error {
+		return pty.Setsize(terminal, makeWinsize(size))
+	}
+}
+
+// terminalInput writes stdin data to a terminal.  Closing it sends an
+// end-of-file character rather than closing the terminal, as the terminal
+// is also used for output.
+type terminalInput struct {
+	terminal *os.File
+}
+
+func (t *terminalInput) Write(p []byte) (int, error) {
+	return t.terminal.Write(p)
+}
+
+func (t *terminalInput) Close() error {
+	_, err := t.terminal.Write([]byte{4})
+	return err
+}
@@ -0,0 +1,122 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"log"
+	"os"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"github.com/opsmx/oes-birger/pkg/updater"
+)
+
+// agentUpdate is a download of a new agent binary in progress.
+type agentUpdate struct {
+	hash *updater.Hash
+	file *os.File
+}
+
+// currentUpdate is only used from the tunnel's receive loop, so
+// it needs no locking.
+var currentUpdate *agentUpdate
+
+func makeAgentBinaryRequest(hash string) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_AgentBinaryRequest{
+			AgentBinaryRequest: &tunnel.AgentBinaryRequest{
+				Hash: hash,
+			},
+		},
+	}
+}
+
+// handleAgentBinaryInfo starts downloading the controller's agent binary
+// if it differs from ours.
+func handleAgentBinaryInfo(dataflow chan *tunnel.AgentToControllerWrapper, info *tunnel.AgentBinaryInfo) {
+	if info.Hash == binaryHash || currentUpdate != nil {
+		return
+	}
+	if !config.AutoUpdate {
+		log.Printf("Controller has agent binary %s, but autoUpdate is disabled", info.Hash)
+		return
+	}
+
+	hash, err := updater.ParseHash(info.Hash)
+	if err != nil {
+		log.Printf("Unable to update: %v", err)
+		return
+	}
+	f, err := updater.TempFileForSelf()
+	if err != nil {
+		log.Printf("Unable to update: %v", err)
+		return
+	}
+
+	log.Printf("Downloading agent binary %s (%d bytes)", info.Hash, info.Size)
+	currentUpdate = &agentUpdate{hash: hash, file: f}
+	dataflow <- makeAgentBinaryRequest(info.Hash)
+}
+
+// handleAgentBinaryChunk writes the downloaded binary, and once it is
+// complete, verifies it and restarts using it.
+func handleAgentBinaryChunk(chunk *tunnel.AgentBinaryChunk) {
+	if currentUpdate == nil {
+		log.Printf("Got agent binary data, but no update is in progress")
+		return
+	}
+	if len(chunk.Error) > 0 {
+		log.Printf("Controller could not send agent binary: %s", chunk.Error)
+		abortAgentUpdate()
+		return
+	}
+	if len(chunk.Body) > 0 {
+		if _, err := currentUpdate.file.Write(chunk.Body); err != nil {
+			log.Printf("Unable to write agent binary: %v", err)
+			abortAgentUpdate()
+		}
+		return
+	}
+
+	path := currentUpdate.file.Name()
+	if err := currentUpdate.file.Close(); err != nil {
+		log.Printf("Unable to write agent binary: %v", err)
+		abortAgentUpdate()
+		return
+	}
+	if err := updater.ReplaceSelf(path, currentUpdate.hash); err != nil {
+		log.Printf("Unable to replace agent binary: %v", err)
+		abortAgentUpdate()
+		return
+	}
+	currentUpdate = nil
+
+	log.Printf("Agent binary updated, restarting")
+	cancelAllFunctions()
+	if err := updater.RestartSelf(); err != nil {
+		log.Fatalf("Unable to restart after update: %v", err)
+	}
+}
+
+// abortAgentUpdate discards any partially downloaded binary.
+func abortAgentUpdate() {
+	if currentUpdate == nil {
+		return
+	}
+	currentUpdate.file.Close()
+	os.Remove(currentUpdate.file.Name())
+	currentUpdate = nil
+}
@@ -0,0 +1,48 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// runUpgradedConnection passes bytes between the controller and an
+// endpoint which has switched protocols, until either end closes.
+func runUpgradedConnection(ctx context.Context, req *tunnel.HttpRequest, httpResponse *http.Response, dataflow chan *tunnel.AgentToControllerWrapper, window *tunnel.SendWindow, metrics *requestMetrics) {
+	conn, ok := httpResponse.Body.(io.ReadWriteCloser)
+	fromClient := findRequestBody(req.Id)
+	if !ok || fromClient == nil {
+		log.Printf("Unable to use upgraded connection for request ID %s", req.Id)
+		httpResponse.Body.Close()
+		dataflow <- makeBadGatewayResponse(req.Id)
+		return
+	}
+	defer conn.Close()
+
+	// The length of an upgraded connection is unknown, and zero would
+	// end the request.
+	resp := makeResponse(req.Id, httpResponse)
+	resp.GetHttpResponse().ContentLength = -1
+	dataflow <- resp
+
+	pipeConnection(ctx, req.Id, conn, fromClient, dataflow, window, metrics)
+}
@@ -0,0 +1,88 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// echoUpgradeHandler switches protocols and echoes what it reads.
+func echoUpgradeHandler(w http.ResponseWriter, r *http.Request) {
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	brw.Flush()
+	io.Copy(conn, brw.Reader)
+}
+
+func nextMessage(t *testing.T, dataflow chan *tunnel.AgentToControllerWrapper) *tunnel.AgentToControllerWrapper {
+	select {
+	case msg := <-dataflow:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message from the agent")
+		return nil
+	}
+}
+
+func TestRunHTTPRequest_upgrade(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echoUpgradeHandler))
+	defer server.Close()
+
+	req := &tunnel.HttpRequest{Id: "upgrade", Method: "GET", URI: "/", Upgrade: true}
+	dataflow := make(chan *tunnel.AgentToControllerWrapper, 10)
+	registerRequestBody(req.Id, dataflow)
+	defer unregisterRequestBody(req.Id)
+
+	httpRequest, _ := http.NewRequestWithContext(context.Background(), "GET", server.URL, nil)
+	httpRequest.Header.Set("Connection", "Upgrade")
+	httpRequest.Header.Set("Upgrade", "echo")
+	done := make(chan struct{})
+	go func() {
+		runHTTPRequest(server.Client(), req, httpRequest, dataflow, server.URL)
+		close(done)
+	}()
+
+	resp := nextMessage(t, dataflow).GetHttpResponse()
+	if resp == nil || resp.Status != http.StatusSwitchingProtocols || resp.ContentLength != -1 {
+		t.Fatalf("response = %v, want 101 with unknown length", resp)
+	}
+
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Data: []byte("hello")})
+	data := nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || string(data.Data) != "hello" {
+		t.Fatalf("data = %v, want hello echoed", data)
+	}
+
+	// When the client closes, the agent closes the connection too.
+	deliverConnectionData(&tunnel.ConnectionData{Id: req.Id, Closed: true})
+	data = nextMessage(t, dataflow).GetConnectionData()
+	if data == nil || !data.Closed {
+		t.Fatalf("data = %v, want closed", data)
+	}
+	<-done
+}
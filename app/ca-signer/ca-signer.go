@@ -0,0 +1,113 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//
+// ca-signer holds the CA private key in a separate process from the
+// controller, and signs certificates for it over a Unix socket.  It is
+// intended to run as a sidecar, sharing only the socket's directory with
+// the controller, which is configured with a signer of type "remote".
+//
+// This is a reference implementation which reads the key from a file.
+// Signers backed by a PKCS#11 token or a KMS can serve the same protocol,
+// or be added to the controller directly with ca.RegisterSignerType.
+//
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+)
+
+var (
+	keyFile    = flag.String("keyFile", "/app/secrets/ca/tls.key", "The file containing the CA private key")
+	socketPath = flag.String("socket", "/app/signer/signer.sock", "The Unix socket to listen on")
+)
+
+func main() {
+	flag.Parse()
+
+	keyPEM, err := ioutil.ReadFile(*keyFile)
+	if err != nil {
+		log.Fatalf("Unable to read CA key: %v", err)
+	}
+	signer, err := ca.ParsePrivateKey(keyPEM)
+	if err != nil {
+		log.Fatalf("Unable to parse CA key: %v", err)
+	}
+
+	handler, err := ca.NewRemoteSignerHandler(signer)
+	if err != nil {
+		log.Fatalf("Unable to serve key: %v", err)
+	}
+
+	// A socket left behind by a previous run would prevent us listening.
+	if err := os.Remove(*socketPath); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Unable to remove old socket: %v", err)
+	}
+	listener, err := listenPrivate(*socketPath)
+	if err != nil {
+		log.Fatalf("Unable to listen: %v", err)
+	}
+
+	log.Printf("Signing with key %s on %s", *keyFile, *socketPath)
+	server := &http.Server{
+		Handler: handler,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return ca.WithRemoteSignerPeer(ctx, describePeer(c))
+		},
+	}
+	log.Fatal(server.Serve(listener))
+}
+
+// listenPrivate creates the socket with a umask which leaves it usable only
+// by our user, so there is no window in which others may connect.
+func listenPrivate(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
+
+// describePeer returns the credentials of the process connected to the
+// socket, for logging.
+func describePeer(c net.Conn) string {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return c.RemoteAddr().String()
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "unknown peer"
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || credErr != nil {
+		return "unknown peer"
+	}
+	return fmt.Sprintf("pid %d uid %d gid %d", cred.Pid, cred.Uid, cred.Gid)
+}
@@ -28,6 +28,13 @@ type Search struct {
 	EndpointType string // the endpoint type, eg "jenkins", "kubernetes", "remote-command"
 	EndpointName string // the endpoint name, eg "jenkins1" or "kubernetes1"
 	Session      string // the session ID for a specific agent, used to cancel.
+
+	// StreamedRequestBody limits the search to agents which accept
+	// request bodies streamed after the request.
+	StreamedRequestBody bool
+
+	// Upgrade limits the search to agents which tunnel upgraded connections.
+	Upgrade bool
 }
 
 func (a Search) String() string {
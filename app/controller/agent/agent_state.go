@@ -20,7 +20,10 @@
 //
 package agent
 
-import "fmt"
+import (
+	"fmt"
+	"sync/atomic"
+)
 
 // DirectlyConnectedAgent holds all the magic needed to implement a directly connected agent.
 type DirectlyConnectedAgent struct {
@@ -29,11 +32,37 @@ type DirectlyConnectedAgent struct {
 	Endpoints       []Endpoint
 	Version         string
 	Hostname        string
+	OS              string
+	Arch            string
+	BinaryHash      string
 	InRequest       chan interface{}
 	InCancelRequest chan string
 	ConnectedAt     uint64
 	LastPing        uint64
 	LastUse         uint64
+
+	// CertificateSerial is the serial number of the client certificate
+	// the agent authenticated with.
+	CertificateSerial string
+
+	// StreamsRequestBodies is set if the agent accepts HttpRequestBody messages.
+	StreamsRequestBodies bool
+
+	// TunnelsUpgrades is set if the agent handles HttpRequest.Upgrade.
+	TunnelsUpgrades bool
+
+	// PacesCommandInput is set if the agent returns WindowUpdates for
+	// command input.
+	PacesCommandInput bool
+
+	// Disconnected is closed when the agent's tunnel should be shut down.
+	Disconnected chan struct{}
+	disconnected int32
+
+	// Closed is closed when the agent is removed, after which messages
+	// are no longer accepted.
+	Closed chan struct{}
+	closed int32
 }
 
 // GetSession returns the randomly assigned session ID.  This is assigned each time
@@ -53,29 +82,68 @@ func (s *DirectlyConnectedAgent) GetEndpoints() []Endpoint {
 	return s.Endpoints
 }
 
+// CanStreamRequestBodies returns true if the agent accepts streamed request bodies.
+func (s *DirectlyConnectedAgent) CanStreamRequestBodies() bool {
+	return s.StreamsRequestBodies
+}
+
+// CanTunnelUpgrades returns true if the agent tunnels upgraded connections.
+func (s *DirectlyConnectedAgent) CanTunnelUpgrades() bool {
+	return s.TunnelsUpgrades
+}
+
+// CanPaceCommandInput returns true if the agent returns credit for command input.
+func (s *DirectlyConnectedAgent) CanPaceCommandInput() bool {
+	return s.PacesCommandInput
+}
+
 func (s DirectlyConnectedAgent) String() string {
 	return fmt.Sprintf("(name=%s, session=%s)", s.Name, s.Session)
 }
 
-// Close will shut down an agent's requests channels.
+// Close stops the agent accepting messages.  It is safe to call more than once.
 func (s *DirectlyConnectedAgent) Close() {
-	close(s.InRequest)
-	close(s.InCancelRequest)
+	if s.Closed != nil && atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		close(s.Closed)
+	}
 }
 
 //
-// Send sends a message to a specific Agent
+// Disconnect signals the agent's tunnel to close.  It is safe to call
+// more than once.
 //
-func (s *DirectlyConnectedAgent) Send(message interface{}) string {
-	s.InRequest <- message
-	return s.Session
+func (s *DirectlyConnectedAgent) Disconnect() {
+	if s.Disconnected != nil && atomic.CompareAndSwapInt32(&s.disconnected, 0, 1) {
+		close(s.Disconnected)
+	}
+}
+
+//
+// Send sends a message to a specific Agent.  It returns false if the
+// agent has been closed.
+//
+func (s *DirectlyConnectedAgent) Send(message interface{}) (string, bool) {
+	select {
+	case <-s.Closed:
+		return "", false
+	default:
+	}
+	select {
+	case s.InRequest <- message:
+		return s.Session, true
+	case <-s.Closed:
+		return "", false
+	}
 }
 
 //
 // Cancel cancels a specific stream
 //
 func (s *DirectlyConnectedAgent) Cancel(id string) {
-	s.InCancelRequest <- id
+	select {
+	case s.InCancelRequest <- id:
+	case <-s.Closed:
+	}
 }
 
 //
@@ -98,6 +166,9 @@ type DirectlyConnectedAgentStatistics struct {
 	ConnectedAt uint64 `json:"connectedAt"`
 	LastPing    uint64 `json:"lastPing"`
 	LastUse     uint64 `json:"lastUse"`
+	OS          string `json:"os,omitempty"`
+	Arch        string `json:"arch,omitempty"`
+	BinaryHash  string `json:"binaryHash,omitempty"`
 }
 
 //
@@ -108,6 +179,9 @@ func (s *DirectlyConnectedAgent) GetStatistics() interface{} {
 		ConnectedAt: s.ConnectedAt,
 		LastPing:    s.LastPing,
 		LastUse:     s.LastUse,
+		OS:          s.OS,
+		Arch:        s.Arch,
+		BinaryHash:  s.BinaryHash,
 	}
 	ret.Name = s.Name
 	ret.Session = s.Session
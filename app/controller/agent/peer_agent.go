@@ -0,0 +1,172 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import "fmt"
+
+//
+// PeerRequest wraps a message destined for an agent which is connected
+// to another controller.  The agent's name and session are included so
+// the peer controller can route it to the correct agent connection.
+//
+type PeerRequest struct {
+	Name    string
+	Session string
+	Message interface{}
+}
+
+//
+// PeerCancelRequest asks the peer controller to cancel a specific request
+// running on one of its directly connected agents.
+//
+type PeerCancelRequest struct {
+	Name    string
+	Session string
+	ID      string
+}
+
+// PeerConnectedAgent is an agent which is directly connected to another
+// controller, and which we reach by forwarding requests through that peer.
+// All agents advertised by the same peer share that peer's request channels,
+// and Done is closed when the connection to the peer ends.
+type PeerConnectedAgent struct {
+	Name            string
+	Session         string
+	Endpoints       []Endpoint
+	Version         string
+	Hostname        string
+	ControllerID    string
+	InRequest       chan *PeerRequest
+	InCancelRequest chan *PeerCancelRequest
+	Done            <-chan struct{}
+	ConnectedAt     uint64
+
+	// StreamsRequestBodies is set if the agent accepts streamed request
+	// bodies.  The peer passes them through unchanged.
+	StreamsRequestBodies bool
+
+	// TunnelsUpgrades is set if the agent tunnels upgraded connections.
+	TunnelsUpgrades bool
+
+	// PacesCommandInput is set if the agent returns credit for command
+	// input.  The peer passes it through unchanged.
+	PacesCommandInput bool
+}
+
+// GetSession returns the session ID assigned by the peer controller.
+func (s *PeerConnectedAgent) GetSession() string {
+	return s.Session
+}
+
+// GetName returns the agent name.
+func (s *PeerConnectedAgent) GetName() string {
+	return s.Name
+}
+
+// GetEndpoints returns the list of endpoints.
+func (s *PeerConnectedAgent) GetEndpoints() []Endpoint {
+	return s.Endpoints
+}
+
+// CanStreamRequestBodies returns true if the agent accepts streamed request bodies.
+func (s *PeerConnectedAgent) CanStreamRequestBodies() bool {
+	return s.StreamsRequestBodies
+}
+
+// CanTunnelUpgrades returns true if the agent tunnels upgraded connections.
+func (s *PeerConnectedAgent) CanTunnelUpgrades() bool {
+	return s.TunnelsUpgrades
+}
+
+// CanPaceCommandInput returns true if the agent returns credit for command input.
+func (s *PeerConnectedAgent) CanPaceCommandInput() bool {
+	return s.PacesCommandInput
+}
+
+func (s PeerConnectedAgent) String() string {
+	return fmt.Sprintf("(name=%s, session=%s, controller=%s)", s.Name, s.Session, s.ControllerID)
+}
+
+// Close does nothing, as the request channels belong to the peer connection.
+func (s *PeerConnectedAgent) Close() {}
+
+//
+// Send sends a message to the peer controller, for delivery to the agent.
+// It returns false if the connection to the peer has ended.
+//
+func (s *PeerConnectedAgent) Send(message interface{}) (string, bool) {
+	select {
+	case <-s.Done:
+		return "", false
+	default:
+	}
+	select {
+	case s.InRequest <- &PeerRequest{Name: s.Name, Session: s.Session, Message: message}:
+		return s.Session, true
+	case <-s.Done:
+		return "", false
+	}
+}
+
+//
+// Cancel cancels a specific stream
+//
+func (s *PeerConnectedAgent) Cancel(id string) {
+	select {
+	case s.InCancelRequest <- &PeerCancelRequest{Name: s.Name, Session: s.Session, ID: id}:
+	case <-s.Done:
+	}
+}
+
+//
+// HasEndpoint returns true if the endpoint is presend and configured.
+//
+func (s *PeerConnectedAgent) HasEndpoint(endpointType string, endpointName string) bool {
+	for _, ep := range s.Endpoints {
+		if ep.Type == endpointType && ep.Name == endpointName {
+			return ep.Configured
+		}
+	}
+	return false
+}
+
+//
+// PeerConnectedAgentStatistics describes statistics for an agent connected
+// to a peer controller.
+//
+type PeerConnectedAgentStatistics struct {
+	BaseStatistics
+	ControllerID string `json:"controllerId"`
+	ConnectedAt  uint64 `json:"connectedAt"`
+}
+
+//
+// GetStatistics returns a set of stats for the agent.
+//
+func (s *PeerConnectedAgent) GetStatistics() interface{} {
+	ret := &PeerConnectedAgentStatistics{
+		ControllerID: s.ControllerID,
+		ConnectedAt:  s.ConnectedAt,
+	}
+	ret.Name = s.Name
+	ret.Session = s.Session
+	ret.ConnectionType = "peer"
+	ret.Endpoints = s.Endpoints
+	ret.Version = s.Version
+	ret.Hostname = s.Hostname
+	return ret
+}
@@ -21,11 +21,6 @@ import (
 	"log"
 	"math/rand"
 	"sync"
-	"time"
-)
-
-var (
-	rnd = rand.New(rand.NewSource(time.Now().UnixNano())) // not used for crypto
 )
 
 //
@@ -48,12 +43,15 @@ type BaseStatistics struct {
 //
 type Agent interface {
 	Close()
-	Send(interface{}) string
+	Send(interface{}) (string, bool)
 	Cancel(string)
 	HasEndpoint(string, string) bool
 	GetSession() string
 	GetName() string
 	GetEndpoints() []Endpoint
+	CanStreamRequestBodies() bool
+	CanTunnelUpgrades() bool
+	CanPaceCommandInput() bool
 
 	GetStatistics() interface{}
 }
@@ -63,7 +61,8 @@ type Agent interface {
 //
 type ConnectedAgents struct {
 	sync.RWMutex
-	m map[string][]Agent
+	m         map[string][]Agent
+	listeners []chan struct{}
 }
 
 //
@@ -119,6 +118,7 @@ func (s *ConnectedAgents) AddAgent(state Agent) {
 		log.Printf("  agent %s, endpoint: %s", state, &endpoint)
 	}
 	connectedAgentsGauge.WithLabelValues(state.GetName()).Inc()
+	s.notifyListeners()
 }
 
 //
@@ -149,41 +149,188 @@ func (s *ConnectedAgents) RemoveAgent(state Agent) error {
 	s.m[state.GetName()] = agentList
 	connectedAgentsGauge.WithLabelValues(state.GetName()).Dec()
 	log.Printf("agent %s removed, now at %d paths", state, len(agentList))
+	s.notifyListeners()
 	return nil
 }
 
-func (s *ConnectedAgents) findService(ep Search) (Agent, error) {
+//
+// Subscribe returns a channel which will receive a value whenever an agent
+// is added or removed.  Notifications are coalesced, so a slow reader
+// will see only one pending notification.  Call Unsubscribe when done.
+//
+func (s *ConnectedAgents) Subscribe() chan struct{} {
+	s.Lock()
+	defer s.Unlock()
+	c := make(chan struct{}, 1)
+	s.listeners = append(s.listeners, c)
+	return c
+}
+
+//
+// Unsubscribe removes a channel returned by Subscribe.
+//
+func (s *ConnectedAgents) Unsubscribe(c chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+	i := sliceIndex(len(s.listeners), func(i int) bool { return s.listeners[i] == c })
+	if i == -1 {
+		return
+	}
+	s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+}
+
+// notifyListeners must be called with the lock held.
+func (s *ConnectedAgents) notifyListeners() {
+	for _, c := range s.listeners {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//
+// GetDirectlyConnectedAgents returns all agents whose connection terminates
+// on this controller.  These are the agents we advertise to our peers.
+//
+func (s *ConnectedAgents) GetDirectlyConnectedAgents() []*DirectlyConnectedAgent {
+	s.RLock()
+	defer s.RUnlock()
+	ret := []*DirectlyConnectedAgent{}
+	for _, agentList := range s.m {
+		for _, a := range agentList {
+			if direct, ok := a.(*DirectlyConnectedAgent); ok {
+				ret = append(ret, direct)
+			}
+		}
+	}
+	return ret
+}
+
+//
+// DisconnectByCertificateSerial disconnects every directly connected agent
+// which authenticated with the certificate serial number, and returns how
+// many were found.  Agents connected to peer controllers are disconnected
+// by those controllers, once the revocation reaches them.
+//
+func (s *ConnectedAgents) DisconnectByCertificateSerial(serial string) int {
+	count := 0
+	for _, direct := range s.GetDirectlyConnectedAgents() {
+		if direct.CertificateSerial == serial {
+			log.Printf("Disconnecting agent %s, certificate serial %s", direct, serial)
+			direct.Disconnect()
+			count++
+		}
+	}
+	return count
+}
+
+// candidates returns the agents a request for ep may be sent to.
+func (s *ConnectedAgents) candidates(ep Search) ([]Agent, error) {
 	agentList, ok := s.m[ep.Name]
 	if !ok || len(agentList) == 0 {
 		return nil, fmt.Errorf("no agents connected for %s", ep)
 	}
-	possibleAgents := []int{}
+	// Directly connected agents are preferred, and we only use agents
+	// connected to a peer controller if there are none.
+	directAgents := []int{}
+	peerAgents := []int{}
 	for i, a := range agentList {
-		if a.HasEndpoint(ep.EndpointType, ep.EndpointName) {
-			possibleAgents = append(possibleAgents, i)
+		if !ep.MatchesAgent(a) || !a.HasEndpoint(ep.EndpointType, ep.EndpointName) {
+			continue
+		}
+		if ep.StreamedRequestBody && !a.CanStreamRequestBodies() {
+			continue
+		}
+		if ep.Upgrade && !a.CanTunnelUpgrades() {
+			continue
+		}
+		if _, viaPeer := a.(*PeerConnectedAgent); viaPeer {
+			peerAgents = append(peerAgents, i)
+		} else {
+			directAgents = append(directAgents, i)
 		}
 	}
+	possibleAgents := directAgents
+	if len(possibleAgents) == 0 {
+		possibleAgents = peerAgents
+	}
 	if len(possibleAgents) == 0 {
 		return nil, fmt.Errorf("request for %s, no such path exists or all are unconfigured", ep)
 	}
-	selected := possibleAgents[rnd.Intn(len(possibleAgents))]
-	return agentList[selected], nil
+	ret := make([]Agent, len(possibleAgents))
+	for i, index := range possibleAgents {
+		ret[i] = agentList[index]
+	}
+	return ret, nil
+}
+
+func (s *ConnectedAgents) findService(ep Search) (Agent, error) {
+	possibleAgents, err := s.candidates(ep)
+	if err != nil {
+		return nil, err
+	}
+	// The top-level rand functions are safe for concurrent use, which
+	// they need to be, as this is called under the read lock.
+	return possibleAgents[rand.Intn(len(possibleAgents))], nil
+}
+
+//
+// CanStreamRequestBodies returns true if every agent a request for ep
+// could be sent to accepts streamed request bodies.  It returns false if
+// there are no such agents.
+//
+func (s *ConnectedAgents) CanStreamRequestBodies(ep Search) bool {
+	return s.allCandidates(ep, Agent.CanStreamRequestBodies)
+}
+
+//
+// CanTunnelUpgrades returns true if every agent a request for ep could be
+// sent to tunnels upgraded connections.  It returns false if there are no
+// such agents.
+//
+func (s *ConnectedAgents) CanTunnelUpgrades(ep Search) bool {
+	return s.allCandidates(ep, Agent.CanTunnelUpgrades)
+}
+
+//
+// CanPaceCommandInput returns true if every agent a request for ep could
+// be sent to returns credit for command input.  It returns false if there
+// are no such agents.
+//
+func (s *ConnectedAgents) CanPaceCommandInput(ep Search) bool {
+	return s.allCandidates(ep, Agent.CanPaceCommandInput)
+}
+
+func (s *ConnectedAgents) allCandidates(ep Search, f func(Agent) bool) bool {
+	s.RLock()
+	defer s.RUnlock()
+	possibleAgents, err := s.candidates(ep)
+	if err != nil {
+		return false
+	}
+	for _, a := range possibleAgents {
+		if !f(a) {
+			return false
+		}
+	}
+	return true
 }
 
 //
 // Send will search for the specific agent and endpoint. send a message to an agent, and return true if an agent
-// was found.
+// was found.  The agent is sent the message without holding the lock, so
+// one which is slow to accept it does not hold up the others.
 //
 func (s *ConnectedAgents) Send(ep Search, message interface{}) (string, bool) {
 	s.RLock()
-	defer s.RUnlock()
 	agent, err := s.findService(ep)
+	s.RUnlock()
 	if err != nil {
 		log.Printf("%v", err)
 		return "", false
 	}
-	session := agent.Send(message)
-	return session, true
+	return agent.Send(message)
 }
 
 //
@@ -196,18 +343,24 @@ func (s *ConnectedAgents) Cancel(ep Search, id string) error {
 	}
 
 	s.RLock()
-	defer s.RUnlock()
 	agentList, ok := s.m[ep.Name]
 	if !ok || len(agentList) == 0 {
+		s.RUnlock()
 		return fmt.Errorf("no agents connected for: %s (likely coding error)", ep)
 	}
 
+	var found Agent
 	for _, a := range agentList {
 		if ep.MatchesAgent(a) {
-			a.Cancel(id)
-			return nil
+			found = a
+			break
 		}
 	}
+	s.RUnlock()
 
-	return fmt.Errorf("no agents with specific session exist for %s (likely coding error)", ep)
+	if found == nil {
+		return fmt.Errorf("no agents with specific session exist for %s (likely coding error)", ep)
+	}
+	found.Cancel(id)
+	return nil
 }
@@ -62,13 +62,17 @@ type FakeAgent struct {
 
 	lastCancelled string
 	lastMessage   int
+
+	streamsRequestBodies bool
+	tunnelsUpgrades      bool
+	pacesCommandInput    bool
 }
 
 func (a *FakeAgent) Close() {}
 
-func (a *FakeAgent) Send(m interface{}) string {
+func (a *FakeAgent) Send(m interface{}) (string, bool) {
 	a.lastMessage = m.(int)
-	return a.session
+	return a.session, true
 }
 
 func (a *FakeAgent) Cancel(id string) {
@@ -101,6 +105,18 @@ func (a *FakeAgent) GetEndpoints() []Endpoint {
 	return a.endpoints
 }
 
+func (a *FakeAgent) CanStreamRequestBodies() bool {
+	return a.streamsRequestBodies
+}
+
+func (a *FakeAgent) CanTunnelUpgrades() bool {
+	return a.tunnelsUpgrades
+}
+
+func (a *FakeAgent) CanPaceCommandInput() bool {
+	return a.pacesCommandInput
+}
+
 func (s *MySuite) TestConnectedAgents(c *C) {
 	agents := MakeAgents()
 
@@ -203,3 +219,166 @@ func (s *MySuite) TestConnectedAgents_sliceIndex(c *C) {
 	c.Assert(sliceIndex(len(ints), func(i int) bool { return ints[i] == 8 }), Equals, 1)
 	c.Assert(sliceIndex(len(ints), func(i int) bool { return ints[i] == -99 }), Equals, -1)
 }
+
+func (s *MySuite) TestConnectedAgents_PrefersDirect(c *C) {
+	agents := MakeAgents()
+
+	endpoints := []Endpoint{{Name: "ep1", Type: "type1", Configured: true}}
+	peerAgent := &PeerConnectedAgent{
+		Name:            "agent2",
+		Session:         "agent2.peer",
+		Endpoints:       endpoints,
+		InRequest:       make(chan *PeerRequest, 1),
+		InCancelRequest: make(chan *PeerCancelRequest, 1),
+	}
+	directAgent := &DirectlyConnectedAgent{
+		Name:      "agent2",
+		Session:   "agent2.direct",
+		Endpoints: endpoints,
+		InRequest: make(chan interface{}, 10),
+	}
+
+	// Only the peer agent is known, so it must be used.
+	agents.AddAgent(peerAgent)
+	session, found := agents.Send(Search{Name: "agent2", EndpointType: "type1", EndpointName: "ep1"}, 7)
+	c.Assert(found, Equals, true)
+	c.Assert(session, Equals, "agent2.peer")
+	msg := <-peerAgent.InRequest
+	c.Assert(msg.Name, Equals, "agent2")
+	c.Assert(msg.Session, Equals, "agent2.peer")
+	c.Assert(msg.Message, Equals, 7)
+
+	// Once a direct agent appears, it is always preferred.
+	agents.AddAgent(directAgent)
+	for i := 0; i < 10; i++ {
+		session, found = agents.Send(Search{Name: "agent2", EndpointType: "type1", EndpointName: "ep1"}, 8)
+		c.Assert(found, Equals, true)
+		c.Assert(session, Equals, "agent2.direct")
+	}
+
+	// A specific session can still be targeted.
+	session, found = agents.Send(Search{Name: "agent2", Session: "agent2.peer", EndpointType: "type1", EndpointName: "ep1"}, 9)
+	c.Assert(found, Equals, true)
+	c.Assert(session, Equals, "agent2.peer")
+	<-peerAgent.InRequest
+
+	direct := agents.GetDirectlyConnectedAgents()
+	c.Assert(direct, HasLen, 1)
+	c.Assert(direct[0], Equals, directAgent)
+}
+
+func (s *MySuite) TestConnectedAgents_StreamedRequestBody(c *C) {
+	agents := MakeAgents()
+
+	endpoints := []Endpoint{{Name: "ep1", Type: "type1", Configured: true}}
+	oldAgent := &FakeAgent{name: "agent3", session: "agent3.old", endpoints: endpoints}
+	newAgent := &FakeAgent{name: "agent3", session: "agent3.new", endpoints: endpoints, streamsRequestBodies: true}
+	ep := Search{Name: "agent3", EndpointType: "type1", EndpointName: "ep1"}
+
+	c.Assert(agents.CanStreamRequestBodies(ep), Equals, false)
+
+	agents.AddAgent(newAgent)
+	c.Assert(agents.CanStreamRequestBodies(ep), Equals, true)
+
+	// With an agent which cannot stream, bodies must be sent whole.
+	agents.AddAgent(oldAgent)
+	c.Assert(agents.CanStreamRequestBodies(ep), Equals, false)
+
+	streamed := ep
+	streamed.StreamedRequestBody = true
+	for i := 0; i < 10; i++ {
+		session, found := agents.Send(streamed, 1)
+		c.Assert(found, Equals, true)
+		c.Assert(session, Equals, "agent3.new")
+	}
+}
+
+func (s *MySuite) TestConnectedAgents_Upgrade(c *C) {
+	agents := MakeAgents()
+
+	endpoints := []Endpoint{{Name: "ep1", Type: "type1", Configured: true}}
+	oldAgent := &FakeAgent{name: "agent3", session: "agent3.old", endpoints: endpoints}
+	newAgent := &FakeAgent{name: "agent3", session: "agent3.new", endpoints: endpoints, tunnelsUpgrades: true}
+	ep := Search{Name: "agent3", EndpointType: "type1", EndpointName: "ep1"}
+
+	agents.AddAgent(newAgent)
+	c.Assert(agents.CanTunnelUpgrades(ep), Equals, true)
+	agents.AddAgent(oldAgent)
+	c.Assert(agents.CanTunnelUpgrades(ep), Equals, false)
+
+	upgrade := ep
+	upgrade.Upgrade = true
+	for i := 0; i < 10; i++ {
+		session, found := agents.Send(upgrade, 1)
+		c.Assert(found, Equals, true)
+		c.Assert(session, Equals, "agent3.new")
+	}
+}
+
+func (s *MySuite) TestConnectedAgents_PacesCommandInput(c *C) {
+	agents := MakeAgents()
+
+	endpoints := []Endpoint{{Name: "ep1", Type: "type1", Configured: true}}
+	agents.AddAgent(&FakeAgent{name: "agent3", session: "agent3.old", endpoints: endpoints})
+	agents.AddAgent(&FakeAgent{name: "agent3", session: "agent3.new", endpoints: endpoints, pacesCommandInput: true})
+	ep := Search{Name: "agent3", EndpointType: "type1", EndpointName: "ep1"}
+
+	c.Assert(agents.CanPaceCommandInput(ep), Equals, false)
+
+	// Once a command is sent, only the session it went to matters.
+	ep.Session = "agent3.new"
+	c.Assert(agents.CanPaceCommandInput(ep), Equals, true)
+	ep.Session = "agent3.old"
+	c.Assert(agents.CanPaceCommandInput(ep), Equals, false)
+}
+
+func (s *MySuite) TestConnectedAgents_Subscribe(c *C) {
+	agents := MakeAgents()
+	notify := agents.Subscribe()
+
+	agents.AddAgent(bogusagent)
+	agents.AddAgent(agent1Session1)
+	c.Assert(notify, HasLen, 1)
+	<-notify
+
+	err := agents.RemoveAgent(bogusagent)
+	c.Assert(err, IsNil)
+	c.Assert(notify, HasLen, 1)
+	<-notify
+
+	agents.Unsubscribe(notify)
+	agents.AddAgent(bogusagent)
+	c.Assert(notify, HasLen, 0)
+}
+
+func (s *MySuite) TestConnectedAgents_DisconnectByCertificateSerial(c *C) {
+	agents := MakeAgents()
+
+	revoked := &DirectlyConnectedAgent{
+		Name:              "agent3",
+		Session:           "agent3.session1",
+		CertificateSerial: "42",
+		Disconnected:      make(chan struct{}),
+	}
+	other := &DirectlyConnectedAgent{
+		Name:              "agent3",
+		Session:           "agent3.session2",
+		CertificateSerial: "43",
+		Disconnected:      make(chan struct{}),
+	}
+	agents.AddAgent(revoked)
+	agents.AddAgent(other)
+	agents.AddAgent(agent1Session1)
+
+	c.Assert(agents.DisconnectByCertificateSerial("42"), Equals, 1)
+	c.Assert(agents.DisconnectByCertificateSerial("42"), Equals, 1) // safe to repeat
+	c.Assert(agents.DisconnectByCertificateSerial("99"), Equals, 0)
+
+	_, open := <-revoked.Disconnected
+	c.Assert(open, Equals, false)
+	select {
+	case <-other.Disconnected:
+		c.Error("agent with a different certificate was disconnected")
+	default:
+	}
+}
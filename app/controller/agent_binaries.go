@@ -0,0 +1,163 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"github.com/opsmx/oes-birger/pkg/updater"
+)
+
+const agentBinaryChunkSize = 64 * 1024
+
+// agentBinaryChunkInterval paces sending agent binaries, so an update does
+// not crowd out the agent's other traffic.
+const agentBinaryChunkInterval = 5 * time.Millisecond
+
+// agentBinary is an agent executable which we will send to agents
+// so they can update themselves.
+type agentBinary struct {
+	path string
+	hash string
+	size int64
+}
+
+// agentBinaries holds the available agent binaries, by "os/arch".
+var agentBinaries = map[string]*agentBinary{}
+
+// agentBinaryMessage carries agent binary information or one chunk of
+// a binary being sent to the agent.
+type agentBinaryMessage struct {
+	event *tunnel.ControllerToAgentWrapper
+}
+
+// loadAgentBinaries finds and hashes the agent binaries in dir.  These
+// are named agent.<arch>.latest, and are built for Linux.
+func loadAgentBinaries(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "agent.*.latest"))
+	if err != nil {
+		log.Printf("Unable to search for agent binaries in %s: %v", dir, err)
+		return
+	}
+	for _, path := range paths {
+		arch := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "agent."), ".latest")
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Printf("Unable to read agent binary %s: %v", path, err)
+			continue
+		}
+		hash, err := updater.HashFile(path)
+		if err != nil {
+			log.Printf("Unable to hash agent binary %s: %v", path, err)
+			continue
+		}
+		key := "linux/" + arch
+		agentBinaries[key] = &agentBinary{path: path, hash: hash.String(), size: stat.Size()}
+		log.Printf("Agent binary for %s: %s", key, hash)
+	}
+}
+
+func findAgentBinary(goos string, goarch string) *agentBinary {
+	return agentBinaries[goos+"/"+goarch]
+}
+
+func findAgentBinaryByHash(hash string) *agentBinary {
+	for _, binary := range agentBinaries {
+		if binary.hash == hash {
+			return binary
+		}
+	}
+	return nil
+}
+
+func makeAgentBinaryInfo(binary *agentBinary) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_AgentBinaryInfo{
+			AgentBinaryInfo: &tunnel.AgentBinaryInfo{
+				Hash: binary.hash,
+				Size: binary.size,
+			},
+		},
+	}
+}
+
+func makeAgentBinaryChunk(body []byte, message string) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_AgentBinaryChunk{
+			AgentBinaryChunk: &tunnel.AgentBinaryChunk{
+				Body:  body,
+				Error: message,
+			},
+		},
+	}
+}
+
+// sendAgentBinary sends the requested binary to the agent in chunks,
+// followed by an empty chunk.  It is run in its own goroutine, and queues
+// each chunk in turn to be sent along with the agent's other messages.
+func sendAgentBinary(state *agent.DirectlyConnectedAgent, hash string) {
+	send := func(event *tunnel.ControllerToAgentWrapper) bool {
+		_, ok := state.Send(&agentBinaryMessage{event: event})
+		return ok
+	}
+
+	binary := findAgentBinaryByHash(hash)
+	if binary == nil {
+		log.Printf("Agent %s requested unknown binary %s", state, hash)
+		send(makeAgentBinaryChunk(nil, "unknown agent binary"))
+		return
+	}
+
+	f, err := os.Open(binary.path)
+	if err != nil {
+		log.Printf("Unable to open agent binary %s: %v", binary.path, err)
+		send(makeAgentBinaryChunk(nil, "unable to read agent binary"))
+		return
+	}
+	defer f.Close()
+
+	log.Printf("Sending agent binary %s to agent %s", binary.path, state)
+	ticker := time.NewTicker(agentBinaryChunkInterval)
+	defer ticker.Stop()
+	for {
+		buffer := make([]byte, agentBinaryChunkSize)
+		n, err := io.ReadFull(f, buffer)
+		if n > 0 {
+			if !send(makeAgentBinaryChunk(buffer[:n], "")) {
+				log.Printf("Agent %s went away while sending agent binary", state)
+				return
+			}
+			<-ticker.C
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Unable to read agent binary %s: %v", binary.path, err)
+			send(makeAgentBinaryChunk(nil, "unable to read agent binary"))
+			return
+		}
+	}
+	send(makeAgentBinaryChunk(nil, ""))
+}
@@ -0,0 +1,117 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+)
+
+func testAgentState() *agent.DirectlyConnectedAgent {
+	return &agent.DirectlyConnectedAgent{
+		Name:      "smith",
+		Session:   "session",
+		InRequest: make(chan interface{}),
+		Closed:    make(chan struct{}),
+	}
+}
+
+// nextAgentBinaryChunk returns the next chunk queued for the agent.
+func nextAgentBinaryChunk(t *testing.T, state *agent.DirectlyConnectedAgent) []byte {
+	t.Helper()
+	select {
+	case m := <-state.InRequest:
+		msg, ok := m.(*agentBinaryMessage)
+		if !ok {
+			t.Fatalf("got %T, want *agentBinaryMessage", m)
+		}
+		chunk := msg.event.GetAgentBinaryChunk()
+		if chunk == nil {
+			t.Fatalf("got %T, want an agent binary chunk", msg.event.Event)
+		}
+		if chunk.Error != "" {
+			t.Fatalf("got error %q", chunk.Error)
+		}
+		return chunk.Body
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an agent binary chunk")
+	}
+	return nil
+}
+
+func TestSendAgentBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.amd64.latest")
+	contents := bytes.Repeat([]byte("0123456789"), agentBinaryChunkSize/4)
+	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	agentBinaries = map[string]*agentBinary{}
+	defer func() { agentBinaries = map[string]*agentBinary{} }()
+	loadAgentBinaries(filepath.Dir(path))
+	binary := findAgentBinary("linux", "amd64")
+	if binary == nil {
+		t.Fatal("agent binary not loaded")
+	}
+
+	state := testAgentState()
+	go sendAgentBinary(state, binary.hash)
+
+	var got []byte
+	for {
+		body := nextAgentBinaryChunk(t, state)
+		if len(body) == 0 {
+			break
+		}
+		if len(body) > agentBinaryChunkSize {
+			t.Errorf("chunk of %d bytes is larger than %d", len(body), agentBinaryChunkSize)
+		}
+		got = append(got, body...)
+	}
+	if !bytes.Equal(got, contents) {
+		t.Errorf("got %d bytes, want %d bytes", len(got), len(contents))
+	}
+}
+
+func TestSendAgentBinary_agentGone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.amd64.latest")
+	if err := ioutil.WriteFile(path, make([]byte, 4*agentBinaryChunkSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	agentBinaries = map[string]*agentBinary{}
+	defer func() { agentBinaries = map[string]*agentBinary{} }()
+	loadAgentBinaries(filepath.Dir(path))
+
+	state := testAgentState()
+	done := make(chan struct{})
+	go func() {
+		sendAgentBinary(state, findAgentBinary("linux", "amd64").hash)
+		close(done)
+	}()
+	nextAgentBinaryChunk(t, state)
+	state.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sendAgentBinary did not stop when the agent went away")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// agentCertificateRenewalInterval is the shortest time allowed between
+// certificates issued to agents with the same name.
+const agentCertificateRenewalInterval = 10 * time.Minute
+
+// agentCertificateMessage carries a renewed certificate, or the reason
+// one was not issued, to be sent to an agent.
+type agentCertificateMessage struct {
+	cert    []byte
+	message string
+}
+
+// renewalLimiter limits how often certificates are issued for each
+// agent name, so a misbehaving agent cannot keep the CA busy.
+type renewalLimiter struct {
+	sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+var agentRenewals = newRenewalLimiter(agentCertificateRenewalInterval)
+
+func newRenewalLimiter(interval time.Duration) *renewalLimiter {
+	return &renewalLimiter{
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// allow returns true, and records the time, unless name was allowed
+// within the interval.
+func (l *renewalLimiter) allow(name string, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+	for n, t := range l.last {
+		if now.Sub(t) >= l.interval {
+			delete(l.last, n)
+		}
+	}
+	if _, found := l.last[name]; found {
+		return false
+	}
+	l.last[name] = now
+	return true
+}
+
+// agentTrustBundleMessage carries the CA certificates agents should trust
+// for our server certificate.
+type agentTrustBundleMessage struct {
+	bundle []byte
+}
+
+func makeAgentTrustBundle(bundle []byte) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_AgentTrustBundle{
+			AgentTrustBundle: &tunnel.AgentTrustBundle{
+				CaCertificates: bundle,
+			},
+		},
+	}
+}
+
+// currentTrustBundle returns our CA certificates and trust bundle, PEM encoded.
+func currentTrustBundle() ([]byte, error) {
+	ca64, err := authority.GetCACert()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(ca64)
+}
+
+// sendTrustBundle queues the current trust bundle to be sent to an agent.
+// It is run in its own goroutine.
+func sendTrustBundle(state *agent.DirectlyConnectedAgent) {
+	bundle, err := currentTrustBundle()
+	if err != nil {
+		log.Printf("Unable to send trust bundle to agent %s: %v", state, err)
+		return
+	}
+	state.Send(&agentTrustBundleMessage{bundle: bundle})
+}
+
+// sendTrustBundleToAgents sends the current trust bundle to every
+// directly connected agent, and returns how many there were.
+func sendTrustBundleToAgents() int {
+	direct := agents.GetDirectlyConnectedAgents()
+	for _, state := range direct {
+		go sendTrustBundle(state)
+	}
+	return len(direct)
+}
+
+func makeAgentCertificate(cert []byte, message string) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_AgentCertificate{
+			AgentCertificate: &tunnel.AgentCertificate{
+				Certificate: cert,
+				Error:       message,
+			},
+		},
+	}
+}
+
+// signAgentCertificate signs the agent's certificate request with the same
+// name the agent's manifest certificate has, and queues the certificate or
+// an error to be sent to it.  It is run in its own goroutine, so signing
+// does not hold up the agent's receive loop.
+func signAgentCertificate(state *agent.DirectlyConnectedAgent, csr []byte) {
+	if !agentRenewals.allow(state.Name, time.Now()) {
+		log.Printf("Agent %s asked for a certificate too soon after the last one", state)
+		state.Send(&agentCertificateMessage{message: "certificate renewal rate limited, try again later"})
+		return
+	}
+	name := ca.CertificateName{
+		Agent:   state.Name,
+		Purpose: ca.CertificatePurposeAgent,
+	}
+	cert, err := authority.SignCertificateRequest(name, csr)
+	if err != nil {
+		log.Printf("Unable to issue a certificate for agent %s: %v", state, err)
+		state.Send(&agentCertificateMessage{message: fmt.Sprintf("unable to issue certificate: %v", err)})
+		return
+	}
+	log.Printf("Issued a renewed certificate for agent %s", state)
+	state.Send(&agentCertificateMessage{cert: cert})
+}
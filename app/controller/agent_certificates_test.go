@@ -0,0 +1,113 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+)
+
+func TestRenewalLimiter_allow(t *testing.T) {
+	l := newRenewalLimiter(10 * time.Minute)
+	now := time.Now()
+
+	if !l.allow("smith", now) {
+		t.Errorf("allow() = false for the first request")
+	}
+	if !l.allow("jones", now) {
+		t.Errorf("allow() = false for another agent")
+	}
+	if l.allow("smith", now.Add(9*time.Minute)) {
+		t.Errorf("allow() = true within the interval")
+	}
+	if !l.allow("smith", now.Add(10*time.Minute)) {
+		t.Errorf("allow() = false after the interval")
+	}
+}
+
+// nextAgentCertificate returns the next certificate message queued for the agent.
+func nextAgentCertificate(t *testing.T, state *agent.DirectlyConnectedAgent) *agentCertificateMessage {
+	t.Helper()
+	select {
+	case m := <-state.InRequest:
+		msg, ok := m.(*agentCertificateMessage)
+		if !ok {
+			t.Fatalf("got %T, want *agentCertificateMessage", m)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for a certificate")
+	}
+	return nil
+}
+
+func TestSignAgentCertificate(t *testing.T) {
+	useFileCA(t)
+	saved := agentRenewals
+	t.Cleanup(func() { agentRenewals = saved })
+	agentRenewals = newRenewalLimiter(time.Hour)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(crand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	state := testAgentState()
+
+	go signAgentCertificate(state, csr)
+	msg := nextAgentCertificate(t, state)
+	if msg.message != "" {
+		t.Fatalf("got error %q", msg.message)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if _, err := tls.X509KeyPair(msg.cert, keyPEM); err != nil {
+		t.Errorf("certificate does not match the requested key: %v", err)
+	}
+
+	go signAgentCertificate(state, csr)
+	msg = nextAgentCertificate(t, state)
+	if msg.message == "" || msg.cert != nil {
+		t.Errorf("second request was not rate limited")
+	}
+}
+
+func TestSignAgentCertificate_badRequest(t *testing.T) {
+	useFileCA(t)
+	saved := agentRenewals
+	t.Cleanup(func() { agentRenewals = saved })
+	agentRenewals = newRenewalLimiter(time.Hour)
+	state := testAgentState()
+
+	go signAgentCertificate(state, nil)
+	msg := nextAgentCertificate(t, state)
+	if msg.message == "" || msg.cert != nil {
+		t.Errorf("got a certificate for an empty request")
+	}
+}
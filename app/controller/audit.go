@@ -0,0 +1,155 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/tracing"
+)
+
+//
+// commandAudit collects the audit record, metrics, and trace span for a
+// remote command.  The command's output and exit arrive on a different goroutine
+// than its input, so the record is locked, and it is logged only once.
+//
+type commandAudit struct {
+	sync.Mutex
+	record  *audit.Record
+	metrics *requestMetrics
+	ctx     context.Context
+	span    *tracing.Span
+	logged  bool
+}
+
+func newCommandAudit(ctx context.Context, identity audit.Identity, agentName string, commandName string, transactionID string) *commandAudit {
+	ctx, span := tracing.Start(ctx, "remote-command", tracing.SpanKindServer,
+		tracing.String("agent", agentName),
+		tracing.String("command.name", commandName),
+		tracing.String("transaction.id", transactionID))
+	return &commandAudit{
+		metrics: startRequestMetrics(agentName, "remote-command", commandName),
+		ctx:     ctx,
+		span:    span,
+		record: &audit.Record{
+			Time:          time.Now().UTC(),
+			Kind:          audit.KindCommand,
+			TransactionID: transactionID,
+			Identity:      identity,
+			Agent:         agentName,
+			EndpointType:  "remote-command",
+		},
+	}
+}
+
+// traceContext returns the trace context to send with the command.
+func (a *commandAudit) traceContext() map[string]string {
+	return tracing.Inject(a.ctx)
+}
+
+// started records the command and its arguments.
+func (a *commandAudit) started(name string, arguments []string) {
+	a.Lock()
+	defer a.Unlock()
+	a.record.Time = time.Now().UTC()
+	a.record.EndpointName = name
+	a.record.Arguments = arguments
+}
+
+// sentTo records the agent session the command was sent to.
+func (a *commandAudit) sentTo(session string) {
+	a.Lock()
+	defer a.Unlock()
+	a.record.Session = session
+}
+
+func (a *commandAudit) addRequestBytes(n int) {
+	a.Lock()
+	defer a.Unlock()
+	a.record.RequestBytes += int64(n)
+	a.metrics.addRequestBytes(n)
+}
+
+func (a *commandAudit) addResponseBytes(n int) {
+	a.Lock()
+	defer a.Unlock()
+	a.record.ResponseBytes += int64(n)
+	a.metrics.addResponseBytes(n)
+}
+
+// exited logs the record with the command's exit code.
+func (a *commandAudit) exited(exitCode int, message string) {
+	a.Lock()
+	defer a.Unlock()
+	a.record.SetExitCode(exitCode)
+	if len(message) > 0 {
+		a.record.Error = message
+	}
+	a.log()
+}
+
+//
+// finish logs the record if the command has not already exited.  The
+// reason is recorded as the error, as the exit code is not known.
+//
+func (a *commandAudit) finish(reason string) {
+	a.Lock()
+	defer a.Unlock()
+	if a.logged {
+		return
+	}
+	if len(a.record.Error) == 0 {
+		a.record.Error = reason
+	}
+	a.log()
+}
+
+// cancelled logs the record if the client went away before the command
+// exited.
+func (a *commandAudit) cancelled(reason string) {
+	a.Lock()
+	defer a.Unlock()
+	if a.logged {
+		return
+	}
+	a.metrics.cancelled()
+	a.record.Error = reason
+	a.log()
+}
+
+// log must be called with the lock held.
+func (a *commandAudit) log() {
+	if a.logged {
+		return
+	}
+	a.logged = true
+	a.record.Finish(time.Now())
+	auditLog.Log(a.record)
+	if a.record.ExitCode != nil {
+		a.metrics.finish(commandStatusClass(*a.record.ExitCode))
+		a.span.SetAttributes(tracing.Int("command.exit_code", *a.record.ExitCode))
+	} else {
+		a.metrics.finish(commandError)
+	}
+	if len(a.record.Error) > 0 {
+		a.span.SetError(a.record.Error)
+	}
+	a.span.End()
+}
@@ -0,0 +1,79 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"log"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+)
+
+//
+// certificateCheckInterval is how often the CA files are checked for
+// changes, and our own certificates for upcoming expiry.
+//
+const certificateCheckInterval = time.Hour
+
+//
+// certificateRenewer renews our certificates when they are close to expiry,
+// or when the CA changes.  Agents only trust the CA certificates they were
+// given, so when the CA changes, the new trust bundle is sent to them first,
+// and our certificates are renewed by the new CA at the following check.
+//
+type certificateRenewer struct {
+	certs     []*ca.RenewableCertificate
+	caChanged bool
+}
+
+//
+// check reloads the CA, and renews the certificates if needed.  Errors
+// are logged, and the current certificates are kept.
+//
+func (r *certificateRenewer) check(now time.Time) {
+	changed, err := authority.Reload()
+	if err != nil {
+		log.Printf("CA not reloaded: %v", err)
+	}
+	renewAll := r.caChanged
+	r.caChanged = false
+	if changed {
+		count := sendTrustBundleToAgents()
+		log.Printf("CA certificate or trust bundle changed, sent it to %d agents, renewing certificates in %v", count, certificateCheckInterval)
+		r.caChanged = true
+	}
+
+	for _, cert := range r.certs {
+		if !renewAll && !cert.NeedsRenewal(now) {
+			continue
+		}
+		if err := cert.Renew(); err != nil {
+			log.Printf("Unable to renew certificate expiring %s: %v", cert.NotAfter().Format(time.RFC3339), err)
+			continue
+		}
+		log.Printf("Renewed certificate, now valid until %s", cert.NotAfter().Format(time.RFC3339))
+	}
+}
+
+func watchCertificates(certs []*ca.RenewableCertificate) {
+	r := &certificateRenewer{certs: certs}
+	t := time.NewTicker(certificateCheckInterval)
+	defer t.Stop()
+	for range t.C {
+		r.check(time.Now())
+	}
+}
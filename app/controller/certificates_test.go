@@ -0,0 +1,116 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+)
+
+// useFileCA replaces the authority with one loaded from files in a
+// temporary directory, for the duration of the test.
+func useFileCA(t *testing.T) ca.Config {
+	dir := t.TempDir()
+	config := ca.Config{
+		CACertFile:      filepath.Join(dir, "tls.crt"),
+		CAKeyFile:       filepath.Join(dir, "tls.key"),
+		TrustBundleFile: filepath.Join(dir, "bundle.pem"),
+	}
+	certPEM, keyPEM, err := ca.MakeCertificateAuthority(ca.KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range map[string][]byte{config.CACertFile: certPEM, config.CAKeyFile: keyPEM, config.TrustBundleFile: {}} {
+		if err := ioutil.WriteFile(file, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := authority
+	t.Cleanup(func() { authority = saved })
+	if authority, err = ca.LoadCAFromFile(config); err != nil {
+		t.Fatalf("LoadCAFromFile() = %v", err)
+	}
+	return config
+}
+
+func TestCertificateRenewer_waitsForAgentsAfterCAChange(t *testing.T) {
+	config := useFileCA(t)
+	issued := 0
+	cert, err := ca.NewRenewableCertificate(func() (*tls.Certificate, error) {
+		issued++
+		return authority.MakeServerCert([]string{"controller.local"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &certificateRenewer{certs: []*ca.RenewableCertificate{cert}}
+	now := time.Now()
+
+	r.check(now)
+	if issued != 1 {
+		t.Errorf("renewed with no CA change, issued %d", issued)
+	}
+
+	newCA, _, err := ca.MakeCertificateAuthority(ca.KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config.TrustBundleFile, newCA, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r.check(now)
+	if issued != 1 {
+		t.Errorf("renewed before agents were sent the new trust bundle, issued %d", issued)
+	}
+	r.check(now)
+	if issued != 2 {
+		t.Errorf("not renewed at the check after the CA changed, issued %d", issued)
+	}
+	r.check(now)
+	if issued != 2 {
+		t.Errorf("renewed again with no CA change, issued %d", issued)
+	}
+}
+
+func TestSendTrustBundle(t *testing.T) {
+	useFileCA(t)
+	want, err := currentTrustBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := testAgentState()
+	go sendTrustBundle(state)
+	select {
+	case m := <-state.InRequest:
+		msg, ok := m.(*agentTrustBundleMessage)
+		if !ok {
+			t.Fatalf("got %T, want *agentTrustBundleMessage", m)
+		}
+		if len(msg.bundle) == 0 || !bytes.Equal(msg.bundle, want) {
+			t.Errorf("got bundle %q, want %q", msg.bundle, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("trust bundle not sent")
+	}
+}
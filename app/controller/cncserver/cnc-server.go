@@ -23,10 +23,15 @@ package cncserver
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"log"
+	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/oklog/ulid/v2"
@@ -38,7 +43,7 @@ import (
 
 type cncCertificateAuthority interface {
 	ca.CertificateIssuer
-	ca.CertPoolGenerator
+	ca.CertificateRevoker
 }
 
 type cncConfig interface {
@@ -46,6 +51,8 @@ type cncConfig interface {
 	GetAgentAdvertisePort() uint16
 	GetServiceURL() string
 	GetControlURL() string
+	GetRemoteCommandAddress() string
+	GetServiceTokenLifetime() time.Duration
 	GetControlListenPort() uint16
 }
 
@@ -53,13 +60,22 @@ type cncAgentStatsReporter interface {
 	GetStatistics() interface{}
 }
 
+type cncServiceKeys interface {
+	CurrentKey() (jwk.Key, error)
+}
+
+type cncTokenDenylist interface {
+	Revoke(id string, expiresAt time.Time) error
+	List() []jwtutil.DeniedToken
+}
+
 // CNCServer holds the context for a specific instance of a command and control http server.
 type CNCServer struct {
 	cfg           cncConfig
 	authority     cncCertificateAuthority
 	agentReporter cncAgentStatsReporter
-	jwkKeyset     jwk.Set
-	jwtCurrentKey string
+	serviceKeys   cncServiceKeys
+	jwtDenylist   cncTokenDenylist
 	version       string
 }
 
@@ -70,16 +86,16 @@ func MakeCNCServer(
 	config cncConfig,
 	authority cncCertificateAuthority,
 	agents cncAgentStatsReporter,
-	jwkset jwk.Set,
-	currentKey string,
+	keys cncServiceKeys,
+	denylist cncTokenDenylist,
 	vers string,
 ) *CNCServer {
 	return &CNCServer{
 		cfg:           config,
 		authority:     authority,
 		agentReporter: agents,
-		jwkKeyset:     jwkset,
-		jwtCurrentKey: currentKey,
+		serviceKeys:   keys,
+		jwtDenylist:   denylist,
 		version:       vers,
 	}
 }
@@ -107,6 +123,15 @@ func (s *CNCServer) authenticate(method string, h http.HandlerFunc) http.Handler
 	}
 }
 
+// applyCertificateOptions copies the caller's choices for the key and
+// lifetime to the certificate request.  The CA rejects a lifetime longer
+// than its configured maximum.
+func applyCertificateOptions(name *ca.CertificateName, opts fwdapi.CertificateOptions) {
+	name.KeyType = opts.KeyType
+	name.KeyBits = opts.KeyBits
+	name.Lifetime = time.Duration(opts.LifetimeSeconds) * time.Second
+}
+
 func (s *CNCServer) generateKubectlComponents() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("content-type", "application/json")
@@ -130,6 +155,7 @@ func (s *CNCServer) generateKubectlComponents() http.HandlerFunc {
 			Agent:   req.AgentName,
 			Purpose: ca.CertificatePurposeService,
 		}
+		applyCertificateOptions(&name, req.CertificateOptions)
 		ca64, user64, key64, err := s.authority.GenerateCertificate(name)
 		if err != nil {
 			util.FailRequest(w, err, http.StatusBadRequest)
@@ -181,6 +207,7 @@ func (s *CNCServer) generateAgentManifestComponents() http.HandlerFunc {
 			Agent:   req.AgentName,
 			Purpose: ca.CertificatePurposeAgent,
 		}
+		applyCertificateOptions(&name, req.CertificateOptions)
 		ca64, user64, key64, err := s.authority.GenerateCertificate(name)
 		if err != nil {
 			util.FailRequest(w, err, http.StatusBadRequest)
@@ -228,15 +255,41 @@ func (s *CNCServer) generateServiceCredentials() http.HandlerFunc {
 			return
 		}
 
-		var key jwk.Key
-		var ok bool
-		if key, ok = s.jwkKeyset.LookupKeyID(s.jwtCurrentKey); !ok {
-			err := fmt.Errorf("unable to find service key '%s'", s.jwtCurrentKey)
+		key, err := s.serviceKeys.CurrentKey()
+		if err != nil {
 			util.FailRequest(w, err, http.StatusBadRequest)
 			return
 		}
 
-		token, err := jwtutil.MakeJWT(key, req.Type, req.Name, req.AgentName)
+		lifetime := s.cfg.GetServiceTokenLifetime()
+		if req.LifetimeSeconds > 0 {
+			requested := time.Duration(req.LifetimeSeconds) * time.Second
+			if requested > lifetime {
+				err := fmt.Errorf("'lifetimeSeconds' may not be more than %d", int64(lifetime/time.Second))
+				util.FailRequest(w, err, http.StatusBadRequest)
+				return
+			}
+			lifetime = requested
+		}
+
+		tokenID, err := jwtutil.NewTokenID()
+		if err != nil {
+			util.FailRequest(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		claims := &jwtutil.ServiceClaims{
+			Type:       req.Type,
+			Name:       req.Name,
+			Agent:      req.AgentName,
+			ID:         tokenID,
+			IssuedAt:   now,
+			Expiry:     now.Add(lifetime),
+			Methods:    req.Methods,
+			PathPrefix: req.PathPrefix,
+		}
+		token, err := jwtutil.MakeServiceJWT(key, claims)
 		if err != nil {
 			util.FailRequest(w, err, http.StatusBadRequest)
 			return
@@ -254,6 +307,8 @@ func (s *CNCServer) generateServiceCredentials() http.HandlerFunc {
 			Type:      req.Type,
 			URL:       s.cfg.GetServiceURL(),
 			CACert:    cacert,
+			TokenID:   tokenID,
+			ExpiresAt: claims.Expiry.Unix(),
 		}
 
 		username := fmt.Sprintf("%s.%s", req.Name, req.AgentName)
@@ -291,6 +346,82 @@ func (s *CNCServer) generateServiceCredentials() http.HandlerFunc {
 	}
 }
 
+func (s *CNCServer) revokeServiceToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+
+		var req fwdapi.RevokeServiceTokenRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = req.Validate()
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		// The token was issued no later than now, so expires no later
+		// than the longest lifetime from now.
+		err = s.jwtDenylist.Revoke(req.TokenID, time.Now().Add(s.cfg.GetServiceTokenLifetime()))
+		if err != nil {
+			util.FailRequest(w, err, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Revoked service token ID %s", req.TokenID)
+
+		ret := fwdapi.RevokeServiceTokenResponse{
+			TokenID: req.TokenID,
+		}
+		json, err := json.Marshal(ret)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+		n, err := w.Write(json)
+		if err != nil {
+			log.Printf("revokeServiceToken: error while writing: %v", err)
+			return
+		}
+		if n != len(json) {
+			log.Printf("revokeServiceToken: failed to write entire message: %d of %d written", n, len(json))
+			return
+		}
+	}
+}
+
+func (s *CNCServer) getRevokedServiceTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+
+		ret := fwdapi.RevokedServiceTokensResponse{
+			Tokens: []fwdapi.RevokedServiceToken{},
+		}
+		for _, item := range s.jwtDenylist.List() {
+			ret.Tokens = append(ret.Tokens, fwdapi.RevokedServiceToken{
+				TokenID:   item.ID,
+				RevokedAt: item.RevokedAt,
+			})
+		}
+		json, err := json.Marshal(ret)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+		n, err := w.Write(json)
+		if err != nil {
+			log.Printf("getRevokedServiceTokens: error while writing: %v", err)
+			return
+		}
+		if n != len(json) {
+			log.Printf("getRevokedServiceTokens: failed to write entire message: %d of %d written", n, len(json))
+			return
+		}
+	}
+}
+
 func (s *CNCServer) generateControlCredentials() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("content-type", "application/json")
@@ -312,6 +443,7 @@ func (s *CNCServer) generateControlCredentials() http.HandlerFunc {
 			Name:    req.Name,
 			Purpose: ca.CertificatePurposeAgent,
 		}
+		applyCertificateOptions(&name, req.CertificateOptions)
 		ca64, user64, key64, err := s.authority.GenerateCertificate(name)
 		if err != nil {
 			util.FailRequest(w, err, http.StatusBadRequest)
@@ -341,6 +473,147 @@ func (s *CNCServer) generateControlCredentials() http.HandlerFunc {
 	}
 }
 
+func (s *CNCServer) generateRemoteCommandCredentials() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+
+		var req fwdapi.RemoteCommandRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = req.Validate()
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		name := ca.CertificateName{
+			Name:    req.Name,
+			Type:    "remote-command",
+			Agent:   req.AgentName,
+			Purpose: ca.CertificatePurposeRemoteCommand,
+		}
+		applyCertificateOptions(&name, req.CertificateOptions)
+		ca64, user64, key64, err := s.authority.GenerateCertificate(name)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+		ret := fwdapi.RemoteCommandResponse{
+			AgentName:       req.AgentName,
+			Name:            req.Name,
+			ServerAddress:   s.cfg.GetRemoteCommandAddress(),
+			UserCertificate: user64,
+			UserKey:         key64,
+			CACert:          ca64,
+		}
+		json, err := json.Marshal(ret)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+		n, err := w.Write(json)
+		if err != nil {
+			log.Printf("generateRemoteCommandCredentials: error while writing: %v", err)
+			return
+		}
+		if n != len(json) {
+			log.Printf("generateRemoteCommandCredentials: failed to write entire message: %d of %d written", n, len(json))
+			return
+		}
+	}
+}
+
+// parseRevokeRequest returns the serial number to revoke, and if the
+// certificate itself was provided, the name it was issued for.
+func parseRevokeRequest(req fwdapi.RevokeCertificateRequest) (*big.Int, *ca.CertificateName, error) {
+	if len(req.SerialNumber) > 0 {
+		serial, ok := new(big.Int).SetString(req.SerialNumber, 0)
+		if !ok || serial.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("'serialNumber' is invalid")
+		}
+		return serial, nil, nil
+	}
+
+	certPEM, err := base64.StdEncoding.DecodeString(req.Certificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'certificate' is not base64 encoded: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("'certificate' is not a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("'certificate' is invalid: %v", err)
+	}
+	name, err := ca.GetCertificateNameFromCert(cert)
+	if err != nil {
+		// Certificates without our name, such as server certificates, can still be revoked.
+		name = nil
+	}
+	return cert.SerialNumber, name, nil
+}
+
+func (s *CNCServer) revokeCertificate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+
+		var req fwdapi.RevokeCertificateRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = req.Validate()
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		serial, name, err := parseRevokeRequest(req)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+
+		err = s.authority.RevokeCertificate(serial, name)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		ret := fwdapi.RevokeCertificateResponse{
+			SerialNumber: serial.String(),
+		}
+		if name != nil {
+			ret.Purpose = name.Purpose
+			ret.AgentName = name.Agent
+			ret.Name = name.Name
+		}
+		log.Printf("Revoked certificate serial %s (purpose %q, agent %q, name %q)",
+			ret.SerialNumber, ret.Purpose, ret.AgentName, ret.Name)
+		json, err := json.Marshal(ret)
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadRequest)
+			return
+		}
+		n, err := w.Write(json)
+		if err != nil {
+			log.Printf("revokeCertificate: error while writing: %v", err)
+			return
+		}
+		if n != len(json) {
+			log.Printf("revokeCertificate: failed to write entire message: %d of %d written", n, len(json))
+			return
+		}
+	}
+}
+
 func (s *CNCServer) getStatistics() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("content-type", "application/json")
@@ -380,28 +653,31 @@ func (s *CNCServer) routes(mux *http.ServeMux) {
 	mux.HandleFunc(fwdapi.ControlEndpoint,
 		s.authenticate("POST", s.generateControlCredentials()))
 
+	mux.HandleFunc(fwdapi.CommandEndpoint,
+		s.authenticate("POST", s.generateRemoteCommandCredentials()))
+
+	mux.HandleFunc(fwdapi.RevokeServiceTokenEndpoint,
+		s.authenticate("POST", s.revokeServiceToken()))
+
+	mux.HandleFunc(fwdapi.RevokedServiceTokensEndpoint,
+		s.authenticate("GET", s.getRevokedServiceTokens()))
+
 	mux.HandleFunc(fwdapi.StatisticsEndpoint,
 		s.authenticate("GET", s.getStatistics()))
 
+	mux.HandleFunc(fwdapi.RevokeEndpoint,
+		s.authenticate("POST", s.revokeCertificate()))
+
 }
 
-// RunServer will start the HTTPS server and serve requests.
-func (s *CNCServer) RunServer(serverCert tls.Certificate) {
+//
+// RunServer will start the HTTPS server and serve requests.  The tlsConfig
+// must require and verify client certificates.
+//
+func (s *CNCServer) RunServer(tlsConfig *tls.Config) {
 	log.Printf("Running Command and Control API HTTPS listener on port %d",
 		s.cfg.GetControlListenPort())
 
-	certPool, err := s.authority.MakeCertPool()
-	if err != nil {
-		log.Fatalf("While making certpool: %v", err)
-	}
-
-	tlsConfig := &tls.Config{
-		ClientCAs:    certPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{serverCert},
-		MinVersion:   tls.VersionTLS12,
-	}
-
 	mux := http.NewServeMux()
 
 	s.routes(mux)
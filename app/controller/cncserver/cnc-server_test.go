@@ -18,20 +18,28 @@ package cncserver
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/opsmx/oes-birger/pkg/ca"
 	"github.com/opsmx/oes-birger/pkg/fwdapi"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
 )
 
 type handlerTracker struct {
@@ -54,9 +62,16 @@ func (*mockConfig) GetControlURL() string { return "https://control.local" }
 
 func (*mockConfig) GetServiceURL() string { return "https://service.local" }
 
+func (*mockConfig) GetRemoteCommandAddress() string { return "command.local:9004" }
+
+func (*mockConfig) GetServiceTokenLifetime() time.Duration { return time.Hour }
+
 func (*mockConfig) GetAgentHostname() string { return "agent.local" }
 
-type mockAuthority struct{}
+type mockAuthority struct {
+	revokedSerial *big.Int
+	revokedName   *ca.CertificateName
+}
 
 func (*mockAuthority) GenerateCertificate(name ca.CertificateName) (string, string, string, error) {
 	return "a", "b", "c", nil
@@ -66,8 +81,31 @@ func (*mockAuthority) GetCACert() (string, error) {
 	return "base64-cacert", nil
 }
 
-func (*mockAuthority) MakeCertPool() (*x509.CertPool, error) {
-	return nil, nil
+func (m *mockAuthority) RevokeCertificate(serial *big.Int, name *ca.CertificateName) error {
+	m.revokedSerial = serial
+	m.revokedName = name
+	return nil
+}
+
+func (*mockAuthority) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return nil
+}
+
+type mockDenylist struct {
+	revoked []string
+}
+
+func (m *mockDenylist) Revoke(id string, expiresAt time.Time) error {
+	m.revoked = append(m.revoked, id)
+	return nil
+}
+
+func (m *mockDenylist) List() []jwtutil.DeniedToken {
+	ret := []jwtutil.DeniedToken{}
+	for _, id := range m.revoked {
+		ret = append(ret, jwtutil.DeniedToken{ID: id, RevokedAt: time.Unix(1600000000, 0).UTC()})
+	}
+	return ret
 }
 
 type mockAgents struct{}
@@ -151,7 +189,7 @@ func TestCNCServer_authenticate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := MakeCNCServer(nil, nil, nil, nil, "", "")
+			c := MakeCNCServer(nil, nil, nil, nil, nil, "")
 			h := handlerTracker{}
 			r := httptest.NewRequest("GET", "https://localhost/statistics", nil)
 			r.TLS.PeerCertificates = []*x509.Certificate{tt.cert}
@@ -209,7 +247,7 @@ func TestCNCServer_generateKubectlComponents(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, "", "")
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, nil, "")
 
 			body, err := json.Marshal(tt.request)
 			if err != nil {
@@ -279,10 +317,41 @@ func TestCNCServer_generateAgentManifestComponents(t *testing.T) {
 			checkFunc,
 			http.StatusOK,
 		},
+		{
+			"badKeyType",
+			fwdapi.ManifestRequest{
+				AgentName:          "agent smith",
+				CertificateOptions: fwdapi.CertificateOptions{KeyType: "dsa"},
+			},
+			requireError("'keyType' is invalid"),
+			http.StatusBadRequest,
+		},
+		{
+			"keyBitsWithoutType",
+			fwdapi.ManifestRequest{
+				AgentName:          "agent smith",
+				CertificateOptions: fwdapi.CertificateOptions{KeyBits: 384},
+			},
+			requireError("'keyBits' is invalid"),
+			http.StatusBadRequest,
+		},
+		{
+			"workingWithOptions",
+			fwdapi.ManifestRequest{
+				AgentName: "agent smith",
+				CertificateOptions: fwdapi.CertificateOptions{
+					KeyType:         "ecdsa",
+					KeyBits:         384,
+					LifetimeSeconds: 86400,
+				},
+			},
+			checkFunc,
+			http.StatusOK,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, "", "")
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, nil, "")
 
 			body, err := json.Marshal(tt.request)
 			if err != nil {
@@ -326,6 +395,12 @@ func MakeServiceCheckFunc() func(*testing.T, []byte) {
 		stringEquals(t, "URL", response.URL, "https://service.local")
 		stringEquals(t, "CACert", response.CACert, "base64-cacert")
 		stringEquals(t, "CredentialType", response.CredentialType, "basic")
+		if response.TokenID == "" {
+			t.Errorf("Expected TokenID to be set")
+		}
+		if expires := time.Unix(response.ExpiresAt, 0); expires.Before(time.Now()) || expires.After(time.Now().Add(time.Hour+time.Second)) {
+			t.Errorf("Expected ExpiresAt within an hour, got %v", expires)
+		}
 		creds := response.Credential.(map[string]interface{})
 		if len(creds) != 2 {
 			t.Errorf("Unexpected keys: %#v", creds)
@@ -412,6 +487,44 @@ func TestCNCServer_generateServiceCredentials(t *testing.T) {
 			requireError("unable to find service key"),
 			http.StatusBadRequest,
 		},
+		{
+			"lifetime-too-long",
+			fwdapi.ServiceCredentialRequest{
+				AgentName:       "agent smith",
+				Type:            "jenkins",
+				Name:            "service smith",
+				LifetimeSeconds: 7200,
+			},
+			"key1",
+			requireError("'lifetimeSeconds' may not be more than 3600"),
+			http.StatusBadRequest,
+		},
+		{
+			"restricted",
+			fwdapi.ServiceCredentialRequest{
+				AgentName:       "agent smith",
+				Type:            "jenkins",
+				Name:            "service smith",
+				LifetimeSeconds: 60,
+				Methods:         []string{"GET"},
+				PathPrefix:      "/job",
+			},
+			"key1",
+			serviceCheckFunc,
+			http.StatusOK,
+		},
+		{
+			"bad-method",
+			fwdapi.ServiceCredentialRequest{
+				AgentName: "agent smith",
+				Type:      "jenkins",
+				Name:      "service smith",
+				Methods:   []string{"get it"},
+			},
+			"key1",
+			requireError("'methods' is invalid"),
+			http.StatusBadRequest,
+		},
 		{
 			"aws",
 			fwdapi.ServiceCredentialRequest{
@@ -438,9 +551,14 @@ func TestCNCServer_generateServiceCredentials(t *testing.T) {
 			if err != nil {
 				panic(err)
 			}
-			keys := jwk.NewSet()
-			keys.Add(key1)
-			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, keys, tt.jwkKey, "")
+			keys := jwtutil.NewKeyring()
+			if tt.jwkKey == "key1" {
+				err = keys.Update(map[string]jwk.Key{"key1": key1}, tt.jwkKey, time.Hour, time.Now())
+				if err != nil {
+					panic(err)
+				}
+			}
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, keys, nil, "")
 
 			body, err := json.Marshal(tt.request)
 			if err != nil {
@@ -471,6 +589,90 @@ func TestCNCServer_generateServiceCredentials(t *testing.T) {
 	}
 }
 
+func TestCNCServer_revokeServiceToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      interface{}
+		validateBody verifierFunc
+		wantStatus   int
+		wantRevoked  []string
+	}{
+		{
+			"badJSON",
+			"badjson",
+			requireError("json: cannot unmarshal"),
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"missingTokenID",
+			fwdapi.RevokeServiceTokenRequest{},
+			requireError("'tokenId' is invalid"),
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"working",
+			fwdapi.RevokeServiceTokenRequest{TokenID: "abc123"},
+			func(t *testing.T, body []byte) {
+				stringEquals(t, "body", string(body), `{"tokenId":"abc123"}`)
+			},
+			http.StatusOK,
+			[]string{"abc123"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denylist := &mockDenylist{}
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, denylist, "")
+
+			body, err := json.Marshal(tt.request)
+			if err != nil {
+				panic(err)
+			}
+
+			r := httptest.NewRequest("POST", "https://localhost/foo", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			h := c.revokeServiceToken()
+			h.ServeHTTP(w, r)
+
+			if w.Result().StatusCode != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			resultBody, err := ioutil.ReadAll(w.Result().Body)
+			if err != nil {
+				panic(err)
+			}
+
+			tt.validateBody(t, resultBody)
+
+			if strings.Join(denylist.revoked, ",") != strings.Join(tt.wantRevoked, ",") {
+				t.Errorf("Expected %v to be revoked, got %v", tt.wantRevoked, denylist.revoked)
+			}
+		})
+	}
+}
+
+func TestCNCServer_getRevokedServiceTokens(t *testing.T) {
+	c := MakeCNCServer(nil, nil, nil, nil, &mockDenylist{revoked: []string{"abc123"}}, "")
+
+	r := httptest.NewRequest("GET", "https://localhost/foo", nil)
+	w := httptest.NewRecorder()
+	h := c.getRevokedServiceTokens()
+	h.ServeHTTP(w, r)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	resultBody, err := ioutil.ReadAll(w.Result().Body)
+	if err != nil {
+		panic(err)
+	}
+	stringEquals(t, "body", string(resultBody), `{"tokens":[{"tokenId":"abc123","revokedAt":"2020-09-13T12:26:40Z"}]}`)
+}
+
 func TestCNCServer_generateControlCredentials(t *testing.T) {
 	checkFunc := func(t *testing.T, body []byte) {
 		var response fwdapi.ControlCredentialsResponse
@@ -512,7 +714,7 @@ func TestCNCServer_generateControlCredentials(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, "", "")
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, nil, "")
 
 			body, err := json.Marshal(tt.request)
 			if err != nil {
@@ -543,9 +745,88 @@ func TestCNCServer_generateControlCredentials(t *testing.T) {
 	}
 }
 
+func TestCNCServer_generateRemoteCommandCredentials(t *testing.T) {
+	checkFunc := func(t *testing.T, body []byte) {
+		var response fwdapi.RemoteCommandResponse
+		err := json.Unmarshal(body, &response)
+		if err != nil {
+			panic(err)
+		}
+		stringEquals(t, "AgentName", response.AgentName, "agent smith")
+		stringEquals(t, "Name", response.Name, "shell")
+		stringEquals(t, "ServerAddress", response.ServerAddress, "command.local:9004")
+		stringEquals(t, "UserCertificate", response.UserCertificate, "b")
+		stringEquals(t, "UserKey", response.UserKey, "c")
+		stringEquals(t, "CACert", response.CACert, "a")
+	}
+
+	tests := []struct {
+		name         string
+		request      interface{}
+		validateBody verifierFunc
+		wantStatus   int
+	}{
+		{
+			"badJSON",
+			"badjson",
+			requireError("json: cannot unmarshal"),
+			http.StatusBadRequest,
+		},
+		{
+			"missingAgentName",
+			fwdapi.RemoteCommandRequest{Name: "shell"},
+			requireError("'agentName' is invalid"),
+			http.StatusBadRequest,
+		},
+		{
+			"missingName",
+			fwdapi.RemoteCommandRequest{AgentName: "agent smith"},
+			requireError("'name' is invalid"),
+			http.StatusBadRequest,
+		},
+		{
+			"working",
+			fwdapi.RemoteCommandRequest{AgentName: "agent smith", Name: "shell"},
+			checkFunc,
+			http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MakeCNCServer(&mockConfig{}, &mockAuthority{}, nil, nil, nil, "")
+
+			body, err := json.Marshal(tt.request)
+			if err != nil {
+				panic(err)
+			}
+
+			r := httptest.NewRequest("POST", "https://localhost/foo", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			h := c.generateRemoteCommandCredentials()
+			h.ServeHTTP(w, r)
+
+			if w.Result().StatusCode != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			ct := w.Result().Header.Get("content-type")
+			if ct != "application/json" {
+				t.Errorf("Expected content-type to be application/json, not %s", ct)
+			}
+
+			resultBody, err := ioutil.ReadAll(w.Result().Body)
+			if err != nil {
+				panic(err)
+			}
+
+			tt.validateBody(t, resultBody)
+		})
+	}
+}
+
 func TestCNCServer_getStatistics(t *testing.T) {
 	t.Run("getCredentials", func(t *testing.T) {
-		c := MakeCNCServer(nil, nil, &mockAgents{}, nil, "", "")
+		c := MakeCNCServer(nil, nil, &mockAgents{}, nil, nil, "")
 
 		r := httptest.NewRequest("GET", "https://localhost/foo", nil)
 		w := httptest.NewRecorder()
@@ -570,3 +851,142 @@ func TestCNCServer_getStatistics(t *testing.T) {
 		}
 	})
 }
+
+func makeRevokeTestCertificate(serial int64, name string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{
+					Type:  []int{2, 5, 4, ca.OpsMxOIDValue},
+					Value: name,
+				},
+			},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	p := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return base64.StdEncoding.EncodeToString(p)
+}
+
+func TestCNCServer_revokeCertificate(t *testing.T) {
+	agentCert := makeRevokeTestCertificate(12345, `{"agent":"agent smith","purpose":"agent"}`)
+
+	checkFunc := func(serial string, purpose string, agentName string) verifierFunc {
+		return func(t *testing.T, body []byte) {
+			var response fwdapi.RevokeCertificateResponse
+			err := json.Unmarshal(body, &response)
+			if err != nil {
+				panic(err)
+			}
+			stringEquals(t, "SerialNumber", response.SerialNumber, serial)
+			stringEquals(t, "Purpose", response.Purpose, purpose)
+			stringEquals(t, "AgentName", response.AgentName, agentName)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		request      interface{}
+		validateBody verifierFunc
+		wantStatus   int
+		wantSerial   string
+	}{
+		{
+			"badJSON",
+			"badjson",
+			requireError("json: cannot unmarshal"),
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"missing",
+			fwdapi.RevokeCertificateRequest{},
+			requireError("exactly one of"),
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"both",
+			fwdapi.RevokeCertificateRequest{SerialNumber: "1", Certificate: agentCert},
+			requireError("exactly one of"),
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"badSerial",
+			fwdapi.RevokeCertificateRequest{SerialNumber: "xyzzy"},
+			requireError("'serialNumber' is invalid"),
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"badCertificate",
+			fwdapi.RevokeCertificateRequest{Certificate: base64.StdEncoding.EncodeToString([]byte("not a cert"))},
+			requireError("not a PEM encoded certificate"),
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"decimalSerial",
+			fwdapi.RevokeCertificateRequest{SerialNumber: "12345"},
+			checkFunc("12345", "", ""),
+			http.StatusOK,
+			"12345",
+		},
+		{
+			"hexSerial",
+			fwdapi.RevokeCertificateRequest{SerialNumber: "0x3039"},
+			checkFunc("12345", "", ""),
+			http.StatusOK,
+			"12345",
+		},
+		{
+			"certificate",
+			fwdapi.RevokeCertificateRequest{Certificate: agentCert},
+			checkFunc("12345", "agent", "agent smith"),
+			http.StatusOK,
+			"12345",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authority := &mockAuthority{}
+			c := MakeCNCServer(&mockConfig{}, authority, nil, nil, nil, "")
+
+			body, err := json.Marshal(tt.request)
+			if err != nil {
+				panic(err)
+			}
+
+			r := httptest.NewRequest("POST", "https://localhost/foo", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			h := c.revokeCertificate()
+			h.ServeHTTP(w, r)
+
+			if w.Result().StatusCode != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			resultBody, err := ioutil.ReadAll(w.Result().Body)
+			if err != nil {
+				panic(err)
+			}
+
+			tt.validateBody(t, resultBody)
+
+			gotSerial := ""
+			if authority.revokedSerial != nil {
+				gotSerial = authority.revokedSerial.String()
+			}
+			stringEquals(t, "revoked serial", gotSerial, tt.wantSerial)
+		})
+	}
+}
@@ -21,10 +21,16 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/opsmx/oes-birger/pkg/audit"
 	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tracing"
+	"github.com/opsmx/oes-birger/pkg/util"
 )
 
 // ControllerConfig holds all the configuration for the controller.  The
@@ -46,14 +52,188 @@ type ControllerConfig struct {
 	AgentAdvertisePort      uint16                  `yaml:"agentAdvertisePort"`
 	RemoteCommandHostname   *string                 `yaml:"remoteCommandHostname"`
 	RemoteCommandListenPort uint16                  `yaml:"remoteCommandListenPort"`
+	PeerListenPort          uint16                  `yaml:"peerListenPort"`
+	Peers                   []string                `yaml:"peers,omitempty"`
+	AgentBinariesPath       string                  `yaml:"agentBinariesPath,omitempty"`
+	Audit                   audit.Config            `yaml:"audit,omitempty"`
+	Tracing                 tracing.Config          `yaml:"tracing,omitempty"`
+	TCPForwards             []tcpForwardConfig      `yaml:"tcpForwards,omitempty"`
+	ForwardProxy            forwardProxyConfig      `yaml:"forwardProxy,omitempty"`
 }
 
 type agentConfig struct {
 	Name string `yaml:"name,omitempty"`
 }
 
+//
+// tcpForwardConfig is a TCP listener on the controller whose connections
+// are forwarded through an agent's "tcp" endpoint, such as to reach a
+// database behind the agent's firewall.
+//
+type tcpForwardConfig struct {
+	// ListenAddress is where the controller listens, such as
+	// "127.0.0.1:15432".
+	ListenAddress string `yaml:"listenAddress"`
+
+	Agent        string `yaml:"agent"`
+	EndpointName string `yaml:"endpointName"`
+
+	// Address is the host:port the agent connects to.  If empty, the
+	// endpoint's default address is used.
+	Address string `yaml:"address,omitempty"`
+
+	// RequireClientCertificate makes the listener use TLS, and accept
+	// only clients with a service certificate for the endpoint.
+	// Otherwise, anyone who can reach the listener can connect, so
+	// ListenAddress must be a loopback address.
+	RequireClientCertificate bool `yaml:"requireClientCertificate,omitempty"`
+}
+
+func (c tcpForwardConfig) String() string {
+	return fmt.Sprintf("(agent=%s, endpointName=%s, address=%s)", c.Agent, c.EndpointName, c.Address)
+}
+
+func (c *tcpForwardConfig) validate() error {
+	host, _, err := net.SplitHostPort(c.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("tcpForwards: listenAddress: %v", err)
+	}
+	if !c.RequireClientCertificate && !isLoopbackHost(host) {
+		return fmt.Errorf("tcpForwards: listenAddress %s is not a loopback address, so requireClientCertificate must be set", c.ListenAddress)
+	}
+	if len(c.Agent) == 0 || len(c.EndpointName) == 0 {
+		return fmt.Errorf("tcpForwards: agent and endpointName must be set for %s", c.ListenAddress)
+	}
+	if len(c.Address) > 0 {
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("tcpForwards: address: %v", err)
+		}
+	}
+	return nil
+}
+
+// isLoopbackHost returns true if host only accepts local connections.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+//
+// forwardProxyConfig lets the service listener act as an HTTP proxy.
+// CONNECT requests are tunnelled through an agent's "tcp" endpoint, and
+// requests with an absolute URI are sent to any other endpoint type.
+// The destination host selects the endpoint from Hosts, and the first
+// match wins.
+//
+type forwardProxyConfig struct {
+	Enabled bool              `yaml:"enabled,omitempty"`
+	Hosts   []proxyHostConfig `yaml:"hosts,omitempty"`
+}
+
+//
+// proxyHostConfig maps a destination host, such as "api.example.com" or
+// "*.example.com", to an agent endpoint.  The port is not matched.
+//
+type proxyHostConfig struct {
+	Host         string `yaml:"host"`
+	Agent        string `yaml:"agent"`
+	EndpointType string `yaml:"endpointType"`
+	EndpointName string `yaml:"endpointName"`
+}
+
+func (c proxyHostConfig) String() string {
+	return fmt.Sprintf("(host=%s, agent=%s, endpointType=%s, endpointName=%s)",
+		c.Host, c.Agent, c.EndpointType, c.EndpointName)
+}
+
+func (c *forwardProxyConfig) validate() error {
+	for _, h := range c.Hosts {
+		if len(h.Host) == 0 || len(h.Agent) == 0 || len(h.EndpointType) == 0 || len(h.EndpointName) == 0 {
+			return fmt.Errorf("forwardProxy: host, agent, endpointType and endpointName must be set for %s", h)
+		}
+		if strings.Contains(h.Host, ":") {
+			return fmt.Errorf("forwardProxy: host %s must not include a port", h.Host)
+		}
+	}
+	return nil
+}
+
+// route returns the endpoint for the destination host, without a port.
+func (c *forwardProxyConfig) route(host string) (proxyHostConfig, bool) {
+	for _, h := range c.Hosts {
+		if util.HostMatches(h.Host, host) {
+			return h, true
+		}
+	}
+	return proxyHostConfig{}, false
+}
+
 type serviceAuthConfig struct {
 	CurrentKeyName string `yaml:"currentKeyName,omitempty"`
+
+	// TokenLifetimeSeconds is how long service tokens are valid for.
+	// Requests may ask for a shorter lifetime.
+	TokenLifetimeSeconds int64 `yaml:"tokenLifetimeSeconds,omitempty"`
+
+	// TokenDenylistFile is where revoked token IDs are stored.  If empty,
+	// revocations are lost when the controller restarts.
+	TokenDenylistFile string `yaml:"tokenDenylistFile,omitempty"`
+
+	// KeyOverlapSeconds is how long a key removed from the serviceAuth
+	// directory can still be used to verify tokens.
+	KeyOverlapSeconds int64 `yaml:"keyOverlapSeconds,omitempty"`
+
+	// RequireExpiringTokens rejects service tokens without an expiry or a
+	// token ID, which were issued before those were added and are accepted
+	// forever otherwise.  Set it once such tokens have been replaced, to
+	// finish migrating to expiring tokens.
+	RequireExpiringTokens bool `yaml:"requireExpiringTokens,omitempty"`
+}
+
+func (c *serviceAuthConfig) applyDefaults() error {
+	if c.TokenLifetimeSeconds < 0 {
+		return fmt.Errorf("serviceAuth.tokenLifetimeSeconds must not be negative")
+	}
+	if c.TokenLifetimeSeconds == 0 {
+		c.TokenLifetimeSeconds = 365 * 24 * 60 * 60
+	}
+	if c.KeyOverlapSeconds < 0 {
+		return fmt.Errorf("serviceAuth.keyOverlapSeconds must not be negative")
+	}
+	if c.KeyOverlapSeconds == 0 {
+		c.KeyOverlapSeconds = 24 * 60 * 60
+	}
+	return nil
+}
+
+func (c *serviceAuthConfig) keyOverlap() time.Duration {
+	return time.Duration(c.KeyOverlapSeconds) * time.Second
+}
+
+// loadServiceAuthConfig loads only the serviceAuth section of the config,
+// so it can be checked for changes while running.
+func loadServiceAuthConfig(f io.Reader) (*serviceAuthConfig, error) {
+	buf, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		ServiceAuth serviceAuthConfig `yaml:"serviceAuth,omitempty"`
+	}
+	err = yaml.Unmarshal(buf, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.ServiceAuth.applyDefaults()
+	if err != nil {
+		return nil, err
+	}
+	return &config.ServiceAuth, nil
 }
 
 // LoadConfig will load YAML configuration from the provided filename,
@@ -102,10 +282,32 @@ func LoadConfig(f io.Reader) (*ControllerConfig, error) {
 		return nil, fmt.Errorf("remoteCommandHostname not set")
 	}
 
+	if config.PeerListenPort == 0 {
+		config.PeerListenPort = 9005
+	}
+
+	if len(config.AgentBinariesPath) == 0 {
+		config.AgentBinariesPath = "/app/agent-binaries"
+	}
+
+	err = config.ServiceAuth.applyDefaults()
+	if err != nil {
+		return nil, err
+	}
+
 	if config.PrometheusListenPort == 0 {
 		config.PrometheusListenPort = 9102
 	}
 
+	for i := range config.TCPForwards {
+		if err := config.TCPForwards[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.ForwardProxy.validate(); err != nil {
+		return nil, err
+	}
+
 	config.addAllHostnames()
 
 	return config, nil
@@ -144,6 +346,16 @@ func (c *ControllerConfig) GetControlURL() string {
 	return fmt.Sprintf("https://%s:%d", *c.ControlHostname, c.ControlListenPort)
 }
 
+// GetRemoteCommandAddress returns the host:port remote-command clients connect to.
+func (c *ControllerConfig) GetRemoteCommandAddress() string {
+	return fmt.Sprintf("%s:%d", *c.RemoteCommandHostname, c.RemoteCommandListenPort)
+}
+
+// GetServiceTokenLifetime returns the longest lifetime a service token may have.
+func (c *ControllerConfig) GetServiceTokenLifetime() time.Duration {
+	return time.Duration(c.ServiceAuth.TokenLifetimeSeconds) * time.Second
+}
+
 // GetAgentAdvertisePort returns the port the CNC server will use to advertise agent
 // connections in manifests.
 func (c *ControllerConfig) GetAgentAdvertisePort() uint16 {
@@ -179,4 +391,51 @@ func (c *ControllerConfig) Dump() {
 		*c.ControlHostname, c.ControlListenPort)
 	log.Printf("RemoteCommand hostname: %s, port %d",
 		*c.RemoteCommandHostname, c.RemoteCommandListenPort)
+	log.Printf("Service token lifetime: %v, removed key overlap: %v",
+		c.GetServiceTokenLifetime(), c.ServiceAuth.keyOverlap())
+	if len(c.ServiceAuth.TokenDenylistFile) > 0 {
+		log.Printf("Service token denylist: %s", c.ServiceAuth.TokenDenylistFile)
+	} else {
+		log.Printf("WARNING: serviceAuth.tokenDenylistFile is not set, token revocations will not survive a restart")
+	}
+	if !c.ServiceAuth.RequireExpiringTokens {
+		log.Printf("Service tokens without an expiry are accepted; set serviceAuth.requireExpiringTokens once they are replaced")
+	}
+	log.Printf("Agent binaries path: %s", c.AgentBinariesPath)
+	if len(c.CAConfig.RevocationListFile) > 0 {
+		log.Printf("Certificate revocation list: %s", c.CAConfig.RevocationListFile)
+	} else {
+		log.Printf("WARNING: caConfig.revocationListFile is not set, revocations will not survive a restart")
+	}
+	if len(c.CAConfig.TrustBundleFile) > 0 {
+		log.Printf("Additional trusted CAs: %s", c.CAConfig.TrustBundleFile)
+	}
+	if len(c.CAConfig.Signer.Type) > 0 && c.CAConfig.Signer.Type != ca.SignerTypeFile {
+		log.Printf("CA signer: %s at %s", c.CAConfig.Signer.Type, c.CAConfig.Signer.Address)
+	}
+	if len(c.Audit.Filename) > 0 {
+		log.Printf("Audit log: %s", c.Audit.Filename)
+	}
+	if len(c.Audit.Webhook) > 0 {
+		log.Printf("Audit webhook: %s", c.Audit.Webhook)
+	}
+	if len(c.Tracing.Endpoint) > 0 {
+		log.Printf("Trace collector: %s", c.Tracing.Endpoint)
+	}
+	for _, fwd := range c.TCPForwards {
+		log.Printf("TCP forwarder on %s for %s", fwd.ListenAddress, fwd)
+		if !fwd.RequireClientCertificate {
+			log.Printf("WARNING: TCP forwarder on %s does not authenticate its local clients", fwd.ListenAddress)
+		}
+	}
+	if c.ForwardProxy.Enabled {
+		log.Printf("Forward proxy enabled on the service listener")
+		for _, h := range c.ForwardProxy.Hosts {
+			log.Printf("  proxy host: %s", h)
+		}
+	}
+	log.Printf("Peer controller port %d", c.PeerListenPort)
+	for _, p := range c.Peers {
+		log.Printf("  peer: %s", p)
+	}
 }
@@ -0,0 +1,104 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// connectionDataMessage carries bytes from the client of an upgraded or
+// forwarded connection to the agent.
+type connectionDataMessage struct {
+	data *tunnel.ConnectionData
+}
+
+//
+// pipeConnection copies bytes between a client's connection and the agent
+// until either end closes.  Data from the client is read from r, and data
+// from the agent arrives on in and is written to w.  The agent always ends
+// the connection with a closed ConnectionData, even when the client closed
+// first.  It returns false if the connection failed before then, so the
+// agent should be told to cancel the request.
+//
+func pipeConnection(ep agent.Search, id string, w io.Writer, r io.Reader, in <-chan *tunnel.AgentToControllerWrapper, window *receiveWindow, record *audit.Record, metrics *requestMetrics, countRequest func(int)) bool {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	sendWindow := tunnel.NewSendWindow(tunnel.RequestBodyWindow)
+	clientDone := make(chan error, 1)
+	clientRead := clientDone
+	go func() {
+		clientDone <- streamToAgent(ctx, r, sendWindow, countRequest, func(data []byte, eof bool) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			return sendConnectionData(ep, id, data, eof)
+		})
+	}()
+
+	for {
+		select {
+		case msg, more := <-in:
+			if !more {
+				return true
+			}
+			switch x := msg.Event.(type) {
+			case *tunnel.AgentToControllerWrapper_ConnectionData:
+				data := msg.GetConnectionData()
+				if len(data.Data) > 0 {
+					n, err := w.Write(data.Data)
+					record.ResponseBytes += int64(n)
+					metrics.addResponseBytes(n)
+					if err != nil {
+						log.Printf("Error: cannot write to connection %s: %v", id, err)
+						record.Error = err.Error()
+						return false
+					}
+					window.consume(n)
+				}
+				if data.Closed {
+					return true
+				}
+			case *tunnel.AgentToControllerWrapper_WindowUpdate:
+				sendWindow.Grant(msg.GetWindowUpdate().Bytes)
+			default:
+				log.Printf("Received unknown message on connection %s: %T", id, x)
+			}
+		case err := <-clientRead:
+			if err != nil {
+				log.Printf("Connection %s: %v", id, err)
+				record.Error = err.Error()
+				return false
+			}
+			// The agent has been told, and will close its end.
+			clientRead = nil
+		}
+	}
+}
+
+func sendConnectionData(ep agent.Search, id string, data []byte, closed bool) bool {
+	message := &connectionDataMessage{
+		data: &tunnel.ConnectionData{Id: id, Data: data, Closed: closed},
+	}
+	_, found := agents.Send(ep, message)
+	return found
+}
@@ -18,25 +18,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
-	"github.com/lestrrat-go/jwx/jwa"
-	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/opsmx/oes-birger/app/controller/agent"
 	"github.com/opsmx/oes-birger/app/controller/cncserver"
+	"github.com/opsmx/oes-birger/pkg/audit"
 	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"github.com/opsmx/oes-birger/pkg/ulid"
 	"github.com/opsmx/oes-birger/pkg/util"
@@ -46,18 +46,14 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-const (
-	serviceAuthPath = "/app/secrets/serviceAuth"
-)
-
 var (
 	versionBuild = -1
 	version      = util.Versions{Major: 2, Minor: 2, Patch: 1, Build: versionBuild}
 
 	configFile = flag.String("configFile", "/app/config/config.yaml", "The file with the controller config")
 
-	jwtKeyset     = jwk.NewSet()
-	jwtCurrentKey string
+	serviceKeys = jwtutil.NewKeyring()
+	jwtDenylist *jwtutil.Denylist
 
 	config *ControllerConfig
 
@@ -65,8 +61,13 @@ var (
 
 	ulidContext = ulid.NewContext()
 
+	// controllerID uniquely identifies this controller instance to its peers.
+	controllerID = ulidContext.Ulid()
+
 	hook *webhook.Runner
 
+	auditLog = audit.NewLogger()
+
 	agents = agent.MakeAgents()
 
 	// metrics
@@ -76,19 +77,31 @@ var (
 	}, []string{"agent"})
 )
 
-func getAgentNameFromContext(ctx context.Context) (string, error) {
+func getPeerCertificateFromContext(ctx context.Context) (*x509.Certificate, error) {
 	p, ok := peer.FromContext(ctx)
 	if !ok {
-		return "", status.Error(codes.Unauthenticated, "no peer found")
+		return nil, status.Error(codes.Unauthenticated, "no peer found")
 	}
 	tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo)
 	if !ok {
-		return "", status.Error(codes.Unauthenticated, "unexpected peer transport credentials")
+		return nil, status.Error(codes.Unauthenticated, "unexpected peer transport credentials")
 	}
 	if len(tlsAuth.State.VerifiedChains) == 0 || len(tlsAuth.State.VerifiedChains[0]) == 0 {
-		return "", status.Error(codes.Unauthenticated, "could not verify peer certificate")
+		return nil, status.Error(codes.Unauthenticated, "could not verify peer certificate")
 	}
-	names, err := ca.GetCertificateNameFromCert(tlsAuth.State.VerifiedChains[0][0])
+	return tlsAuth.State.VerifiedChains[0][0], nil
+}
+
+func getCertificateNameFromContext(ctx context.Context) (*ca.CertificateName, error) {
+	cert, err := getPeerCertificateFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ca.GetCertificateNameFromCert(cert)
+}
+
+func getAgentNameFromContext(ctx context.Context) (string, error) {
+	names, err := getCertificateNameFromContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -98,6 +111,33 @@ func getAgentNameFromContext(ctx context.Context) (string, error) {
 	return names.Agent, nil
 }
 
+// getRemoteCommandNameFromContext returns the agent and command endpoint
+// the remote-command certificate was issued for.
+func getRemoteCommandNameFromContext(ctx context.Context) (string, string, error) {
+	names, err := getCertificateNameFromContext(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if names.Purpose != ca.CertificatePurposeRemoteCommand {
+		return "", "", status.Error(codes.PermissionDenied, "not a remote-command certificate")
+	}
+	if len(names.Agent) == 0 || len(names.Name) == 0 {
+		return "", "", status.Error(codes.PermissionDenied, "remote-command certificate does not name an agent and command")
+	}
+	return names.Agent, names.Name, nil
+}
+
+func getPeerControllerNameFromContext(ctx context.Context) (string, error) {
+	names, err := getCertificateNameFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	if names.Purpose != ca.CertificatePurposeController {
+		return "", status.Error(codes.PermissionDenied, "not a controller certificate")
+	}
+	return names.Name, nil
+}
+
 func makeHeaders(headers map[string][]string) []*tunnel.HttpHeader {
 	ret := make([]*tunnel.HttpHeader, 0)
 	for name, values := range headers {
@@ -164,46 +204,6 @@ func runPrometheusHTTPServer(port uint16) {
 	log.Fatal(server.ListenAndServe())
 }
 
-func loadKeyset() {
-	if config.ServiceAuth.CurrentKeyName == "" {
-		log.Fatalf("No primary serviceAuth key name provided")
-	}
-	jwtCurrentKey = config.ServiceAuth.CurrentKeyName
-
-	err := filepath.WalkDir(serviceAuthPath, func(path string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.Type().IsRegular() {
-			return nil
-		}
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		key, err := jwk.New(content)
-		if err != nil {
-			return err
-		}
-		err = key.Set(jwk.KeyIDKey, info.Name())
-		if err != nil {
-			return err
-		}
-		err = key.Set(jwk.AlgorithmKey, jwa.HS256)
-		if err != nil {
-			return err
-		}
-		jwtKeyset.Add(key)
-		log.Printf("Loaded service key name %s, length %d", info.Name(), len(content))
-		return nil
-	})
-	if err != nil {
-		log.Fatalf("cannot load key serviceAuth keys: %v", err)
-	}
-
-	log.Printf("Loaded %d serviceKeys", jwtKeyset.Len())
-}
-
 func parseConfig(filename string) (*ControllerConfig, error) {
 	f, err := os.Open(*configFile)
 	if err != nil {
@@ -219,7 +219,7 @@ func parseConfig(filename string) (*ControllerConfig, error) {
 }
 
 func main() {
-	log.Printf("Controller version %s starting", version.String())
+	log.Printf("Controller version %s starting, controller ID %s", version.String(), controllerID)
 
 	flag.Parse()
 
@@ -231,13 +231,32 @@ func main() {
 	}
 	config.Dump()
 
-	loadKeyset()
+	loadServiceKeys()
+	go watchServiceKeys()
+
+	jwtDenylist, err = jwtutil.LoadDenylist(config.ServiceAuth.TokenDenylistFile)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	loadAgentBinaries(config.AgentBinariesPath)
 
 	if len(config.Webhook) > 0 {
 		hook = webhook.NewRunner(config.Webhook)
 		go hook.Run()
 	}
 
+	auditLog, err = audit.MakeLogger(config.Audit)
+	if err != nil {
+		log.Fatalf("Cannot start audit log: %v", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "controller", config.Tracing)
+	if err != nil {
+		log.Fatalf("Cannot set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	//
 	// Make a new CA, for our use to generate server and other certificates.
 	//
@@ -247,23 +266,51 @@ func main() {
 	}
 	authority = caLocal
 
+	// Agents using a revoked certificate are disconnected immediately,
+	// rather than when they next reconnect.
+	authority.OnRevoke(func(revoked ca.RevokedCertificate) {
+		count := agents.DisconnectByCertificateSerial(revoked.Serial)
+		log.Printf("Certificate serial %s revoked, %d agent connections closed", revoked.Serial, count)
+	})
+
 	//
-	// Make a server certificate.
+	// Make a server certificate, which is renewed before it expires.
 	//
 	log.Println("Generating a server certificate...")
-	serverCert, err := authority.MakeServerCert(config.ServerNames)
+	serverCert, err := ca.NewRenewableCertificate(func() (*tls.Certificate, error) {
+		return authority.MakeServerCert(config.ServerNames)
+	})
 	if err != nil {
 		log.Fatalf("Cannot make server certificate: %v", err)
 	}
+	renewable := []*ca.RenewableCertificate{serverCert}
 
-	go runHTTPSServer(*serverCert)
+	go runHTTPSServer(serverCert)
 
-	cnc := cncserver.MakeCNCServer(config, authority, agents, jwtKeyset, jwtCurrentKey, version.String())
-	go cnc.RunServer(*serverCert)
+	cnc := cncserver.MakeCNCServer(config, authority, agents, serviceKeys, jwtDenylist, version.String())
+	go cnc.RunServer(authority.ServerTLSConfig(serverCert,
+		tls.RequireAndVerifyClientCert, tls.VersionTLS12, []string{"h2", "http/1.1"}))
 
-	go runCmdToolGRPCServer(*serverCert)
+	go runCmdToolGRPCServer(serverCert)
+
+	go runAgentGRPCServer(serverCert)
+
+	go runPeerGRPCServer(serverCert)
+
+	runTCPForwarders(serverCert)
+
+	if len(config.Peers) > 0 {
+		peerCert, err := ca.NewRenewableCertificate(makePeerClientCert)
+		if err != nil {
+			log.Fatalf("Cannot make peer client certificate: %v", err)
+		}
+		renewable = append(renewable, peerCert)
+		for _, address := range config.Peers {
+			go runPeerClient(address, peerCert)
+		}
+	}
 
-	go runAgentGRPCServer(*serverCert)
+	go watchCertificates(renewable)
 
 	runPrometheusHTTPServer(config.PrometheusListenPort)
 }
@@ -0,0 +1,174 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"log"
+	"sync"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+const (
+	// streamWindowSize is how many bytes of response an agent may send
+	// for one request before it must wait for us to pass some of it on.
+	streamWindowSize = 256 * 1024
+
+	// windowUpdateThreshold is how much must be consumed before credit
+	// is returned to the agent, to avoid a WindowUpdate for every chunk.
+	windowUpdateThreshold = streamWindowSize / 4
+)
+
+// windowUpdateMessage returns send credit to the agent for a request.
+type windowUpdateMessage struct {
+	update *tunnel.WindowUpdate
+}
+
+//
+// receiveWindow counts the bytes of a request's response which have been
+// passed on to the client, and returns credit to the agent as they are.
+// Bytes consumed before the agent's session is known are held until start()
+// is called.
+//
+type receiveWindow struct {
+	sync.Mutex
+	id       string
+	ep       agent.Search
+	started  bool
+	consumed int64
+}
+
+func newReceiveWindow(id string) *receiveWindow {
+	return &receiveWindow{id: id}
+}
+
+// start sets the agent session the request was sent to.
+func (w *receiveWindow) start(ep agent.Search) {
+	w.Lock()
+	defer w.Unlock()
+	w.ep = ep
+	w.started = true
+}
+
+// consume records that n bytes have been passed on to the client.
+func (w *receiveWindow) consume(n int) {
+	w.Lock()
+	w.consumed += int64(n)
+	if !w.started || w.consumed < windowUpdateThreshold {
+		w.Unlock()
+		return
+	}
+	message := &windowUpdateMessage{
+		update: &tunnel.WindowUpdate{Id: w.id, Bytes: w.consumed},
+	}
+	ep := w.ep
+	w.consumed = 0
+	w.Unlock()
+
+	if _, found := agents.Send(ep, message); !found {
+		log.Printf("Unable to send window update for %s: agent session %s went away", w.id, ep.Session)
+	}
+}
+
+//
+// responseQueue holds the messages from an agent for one request until its
+// consumer is ready for them, so the tunnel's receive loop never waits on a
+// slow client.  The agent's send window limits how much can build up.
+//
+type responseQueue struct {
+	sync.Mutex
+	out       chan *tunnel.AgentToControllerWrapper
+	pending   []*tunnel.AgentToControllerWrapper
+	closing   bool
+	wake      chan struct{}
+	aborted   chan struct{}
+	abortOnce sync.Once
+}
+
+func newResponseQueue(out chan *tunnel.AgentToControllerWrapper) *responseQueue {
+	q := &responseQueue{
+		out:     out,
+		wake:    make(chan struct{}, 1),
+		aborted: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// push queues a message for the consumer.  It never blocks.
+func (q *responseQueue) push(msg *tunnel.AgentToControllerWrapper) {
+	q.Lock()
+	if q.closing {
+		q.Unlock()
+		return
+	}
+	q.pending = append(q.pending, msg)
+	q.Unlock()
+	q.signal()
+}
+
+// close closes the consumer's channel once the queued messages have been delivered.
+func (q *responseQueue) close() {
+	q.Lock()
+	q.closing = true
+	q.Unlock()
+	q.signal()
+}
+
+// abort discards any queued messages, for when the consumer has gone away.
+func (q *responseQueue) abort() {
+	q.abortOnce.Do(func() { close(q.aborted) })
+}
+
+func (q *responseQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *responseQueue) run() {
+	for {
+		q.Lock()
+		pending := q.pending
+		q.pending = nil
+		closing := q.closing
+		q.Unlock()
+
+		if len(pending) == 0 {
+			if closing {
+				close(q.out)
+				return
+			}
+			select {
+			case <-q.wake:
+			case <-q.aborted:
+				return
+			}
+			continue
+		}
+
+		for _, msg := range pending {
+			select {
+			case q.out <- msg:
+			case <-q.aborted:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// flowAgent registers an agent which receives window updates, and returns
+// a search which finds it.
+func flowAgent(t *testing.T, name string) (*agent.DirectlyConnectedAgent, agent.Search) {
+	state := &agent.DirectlyConnectedAgent{
+		Name:      name,
+		Session:   "session",
+		Endpoints: []agent.Endpoint{{Type: "jenkins", Name: "j1", Configured: true}},
+		InRequest: make(chan interface{}, 10),
+		Closed:    make(chan struct{}),
+	}
+	agents.AddAgent(state)
+	t.Cleanup(func() { agents.RemoveAgent(state) })
+	return state, agent.Search{Name: name, EndpointType: "jenkins", EndpointName: "j1", Session: "session"}
+}
+
+// windowUpdates returns the credit returned to the agent, in order.
+func windowUpdates(t *testing.T, state *agent.DirectlyConnectedAgent, id string) []int64 {
+	t.Helper()
+	updates := []int64{}
+	for {
+		select {
+		case m := <-state.InRequest:
+			msg, ok := m.(*windowUpdateMessage)
+			if !ok {
+				t.Fatalf("got %T, want *windowUpdateMessage", m)
+			}
+			if msg.update.Id != id {
+				t.Errorf("window update for %s, want %s", msg.update.Id, id)
+			}
+			updates = append(updates, msg.update.Bytes)
+		default:
+			return updates
+		}
+	}
+}
+
+func TestReceiveWindow_consume(t *testing.T) {
+	type step struct {
+		start   bool
+		consume int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  []int64
+	}{
+		{
+			"below the threshold",
+			[]step{{start: true}, {consume: windowUpdateThreshold - 1}},
+			[]int64{},
+		},
+		{
+			"at the threshold",
+			[]step{{start: true}, {consume: windowUpdateThreshold - 1}, {consume: 1}},
+			[]int64{windowUpdateThreshold},
+		},
+		{
+			"credit is returned as it builds up",
+			[]step{
+				{start: true},
+				{consume: windowUpdateThreshold + 10},
+				{consume: 10},
+				{consume: windowUpdateThreshold},
+			},
+			[]int64{windowUpdateThreshold + 10, windowUpdateThreshold + 10},
+		},
+		{
+			"held until started",
+			[]step{{consume: streamWindowSize}, {start: true}, {consume: 1}},
+			[]int64{streamWindowSize + 1},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ep := flowAgent(t, fmt.Sprintf("flow-agent-%d", i))
+			w := newReceiveWindow("flow-request")
+			for _, s := range tt.steps {
+				if s.start {
+					w.start(ep)
+				}
+				if s.consume > 0 {
+					w.consume(s.consume)
+				}
+			}
+			got := windowUpdates(t, state, "flow-request")
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("window updates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReceiveWindow_agentGone(t *testing.T) {
+	w := newReceiveWindow("flow-request")
+	w.start(agent.Search{Name: "no-such-agent", EndpointType: "jenkins", EndpointName: "j1"})
+	// This must not block, or panic, when the agent has gone away.
+	w.consume(streamWindowSize)
+}
+
+func makeTestResponse(n int) *tunnel.AgentToControllerWrapper {
+	return &tunnel.AgentToControllerWrapper{
+		Event: &tunnel.AgentToControllerWrapper_HttpChunkedResponse{
+			HttpChunkedResponse: &tunnel.HttpChunkedResponse{Id: "queued", Body: []byte(fmt.Sprint(n))},
+		},
+	}
+}
+
+// readResponses reads from out until it is closed, and returns the
+// bodies in the order received.
+func readResponses(t *testing.T, out chan *tunnel.AgentToControllerWrapper) []string {
+	t.Helper()
+	got := []string{}
+	for {
+		msg, more := nextResponse(t, out)
+		if !more {
+			return got
+		}
+		got = append(got, string(msg.GetHttpChunkedResponse().Body))
+	}
+}
+
+func TestResponseQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		// pushed before the consumer starts reading
+		before int
+		// pushed while the consumer is reading
+		during int
+	}{
+		{"empty", 0, 0},
+		{"queued before reading", 100, 0},
+		{"pushed while reading", 0, 100},
+		{"both", 50, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := make(chan *tunnel.AgentToControllerWrapper)
+			q := newResponseQueue(out)
+			want := []string{}
+			for i := 0; i < tt.before; i++ {
+				q.push(makeTestResponse(i))
+				want = append(want, fmt.Sprint(i))
+			}
+			for i := tt.before; i < tt.before+tt.during; i++ {
+				want = append(want, fmt.Sprint(i))
+			}
+			go func() {
+				for i := tt.before; i < tt.before+tt.during; i++ {
+					q.push(makeTestResponse(i))
+				}
+				q.close()
+			}()
+
+			got := readResponses(t, out)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestResponseQueue_closeWhileBlocked(t *testing.T) {
+	out := make(chan *tunnel.AgentToControllerWrapper)
+	q := newResponseQueue(out)
+	q.push(makeTestResponse(0))
+	q.push(makeTestResponse(1))
+
+	// The queue is now blocked delivering to a consumer which is not
+	// reading.  Closing it must neither lose what is queued nor accept more.
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+	q.push(makeTestResponse(2))
+
+	got := readResponses(t, out)
+	if fmt.Sprint(got) != "[0 1]" {
+		t.Errorf("got %v, want [0 1]", got)
+	}
+}
+
+func TestResponseQueue_abortWhileBlocked(t *testing.T) {
+	out := make(chan *tunnel.AgentToControllerWrapper)
+	q := newResponseQueue(out)
+	q.push(makeTestResponse(0))
+	time.Sleep(20 * time.Millisecond)
+
+	// Once aborted, queued messages are discarded and pushes never block.
+	q.abort()
+	q.abort()
+	for i := 1; i < 100; i++ {
+		q.push(makeTestResponse(i))
+	}
+	q.close()
+
+	select {
+	case msg := <-out:
+		t.Errorf("got %v after the queue was aborted", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/util"
+)
+
+// proxyAuthRealm is sent to clients which must authenticate to the proxy.
+const proxyAuthRealm = `Basic realm="oes-birger"`
+
+//
+// forwardProxyHandler sends CONNECT and absolute-URI requests to the
+// forward proxy, if it is enabled, and all others to next.
+//
+func forwardProxyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.ForwardProxy.Enabled || (r.Method != http.MethodConnect && !r.URL.IsAbs()) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		serveForwardProxy(w, r)
+	})
+}
+
+func serveForwardProxy(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host = stripPort(r.Host)
+	}
+	route, found := config.ForwardProxy.route(host)
+	if !found {
+		util.FailRequest(w, fmt.Errorf("host %s is not reachable through this proxy", host), http.StatusForbidden)
+		return
+	}
+	ep := agent.Search{
+		Name:         route.Agent,
+		EndpointType: route.EndpointType,
+		EndpointName: route.EndpointName,
+	}
+
+	identity, err := authenticateProxyRequest(r, ep)
+	if err != nil {
+		log.Printf("Refused proxy request for %s from %s: %v", host, r.RemoteAddr, err)
+		w.Header().Set("Proxy-Authenticate", proxyAuthRealm)
+		util.FailRequest(w, err, http.StatusProxyAuthRequired)
+		return
+	}
+	r.Header.Del("Proxy-Authorization")
+	r.Header.Del("Proxy-Connection")
+
+	if r.Method == http.MethodConnect {
+		serveConnect(w, r, ep, identity)
+		return
+	}
+	if ep.EndpointType == tcpEndpointType {
+		util.FailRequest(w, fmt.Errorf("host %s only accepts CONNECT", host), http.StatusMethodNotAllowed)
+		return
+	}
+	r.RequestURI = r.URL.RequestURI()
+	runAPIHandler(ep, identity, w, r)
+}
+
+//
+// authenticateProxyRequest returns the identity of the client if it
+// presented a service certificate or token for ep.  Tokens are sent in
+// Proxy-Authorization, either as a bearer token or as the Basic password.
+//
+func authenticateProxyRequest(r *http.Request, ep agent.Search) (audit.Identity, error) {
+	found, identity, validated := extractEndpointFromCert(r)
+	if !validated {
+		token, ok := parseProxyAuthorization(r.Header.Get("Proxy-Authorization"))
+		if !ok {
+			return audit.Identity{}, fmt.Errorf("no valid credentials or JWT found")
+		}
+		found, identity, validated = validateServiceToken(r, token)
+		if !validated {
+			return audit.Identity{}, fmt.Errorf("no valid credentials or JWT found")
+		}
+	}
+	if found.Name != ep.Name || found.EndpointType != ep.EndpointType || found.EndpointName != ep.EndpointName {
+		return audit.Identity{}, fmt.Errorf("credentials are not for %s/%s/%s", ep.Name, ep.EndpointType, ep.EndpointName)
+	}
+	return identity, nil
+}
+
+// parseProxyAuthorization returns the token from a Proxy-Authorization header.
+func parseProxyAuthorization(header string) (string, bool) {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	switch strings.ToLower(parts[0]) {
+	case "bearer":
+		return strings.TrimSpace(parts[1]), true
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return "", false
+		}
+		credentials := strings.SplitN(string(decoded), ":", 2)
+		if len(credentials) != 2 {
+			return "", false
+		}
+		return credentials[1], true
+	}
+	return "", false
+}
+
+//
+// serveConnect tunnels a CONNECT request through the agent's "tcp"
+// endpoint.  HTTP/1 connections are taken over, and HTTP/2 streams are
+// used as they are.
+//
+func serveConnect(w http.ResponseWriter, r *http.Request, ep agent.Search, identity audit.Identity) {
+	if ep.EndpointType != tcpEndpointType {
+		util.FailRequest(w, fmt.Errorf("host %s does not accept CONNECT", stripPort(r.Host)), http.StatusMethodNotAllowed)
+		return
+	}
+	if _, _, err := net.SplitHostPort(r.Host); err != nil {
+		util.FailRequest(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	tunnelConnection(r.Context(), ep, identity, r.Method, r.Host, func(err error) (io.Writer, io.Reader, error) {
+		if err != nil {
+			util.FailRequest(w, err, http.StatusBadGateway)
+			return nil, nil, err
+		}
+		if r.ProtoMajor >= 2 {
+			w.WriteHeader(http.StatusOK)
+			flusher := w.(http.Flusher)
+			flusher.Flush()
+			return &flushWriter{w: w, flusher: flusher}, r.Body, nil
+		}
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return nil, nil, fmt.Errorf("connection cannot be taken over")
+		}
+		c, brw, err := hijacker.Hijack()
+		if err != nil {
+			return nil, nil, err
+		}
+		conn = c
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return nil, nil, err
+		}
+		// Anything the client sent after its request may already be
+		// buffered, so reads come from brw.
+		return conn, brw.Reader, nil
+	})
+}
+
+// flushWriter sends each write to an HTTP/2 client as it is made.
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.flusher.Flush()
+	return n, err
+}
+
+// stripPort returns the host from a host or host:port.
+func stripPort(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	return host
+}
@@ -0,0 +1,207 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
+)
+
+//
+// useForwardProxy enables the forward proxy for the duration of the test,
+// with "*.k8s.example" sent to a kubernetes endpoint and "db.internal"
+// to a tcp endpoint.  It returns the service listener.
+//
+func useForwardProxy(t *testing.T) *httptest.Server {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = &ControllerConfig{
+		ForwardProxy: forwardProxyConfig{
+			Enabled: true,
+			Hosts: []proxyHostConfig{
+				{Host: "*.k8s.example", Agent: "proxy-agent", EndpointType: "kubernetes", EndpointName: "k1"},
+				{Host: "db.internal", Agent: "proxy-agent", EndpointType: tcpEndpointType, EndpointName: "db"},
+			},
+		},
+	}
+	server := httptest.NewTLSServer(forwardProxyHandler(http.NotFoundHandler()))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func proxyToken(t *testing.T, key jwk.Key, endpointType string, endpointName string, pathPrefix string) string {
+	token, err := jwtutil.MakeServiceJWT(key, &jwtutil.ServiceClaims{
+		Type:       endpointType,
+		Name:       endpointName,
+		Agent:      "proxy-agent",
+		ID:         "proxy-token",
+		IssuedAt:   time.Now(),
+		Expiry:     time.Now().Add(time.Hour),
+		PathPrefix: pathPrefix,
+	})
+	if err != nil {
+		t.Fatalf("MakeServiceJWT() = %v", err)
+	}
+	return token
+}
+
+// proxyGet fetches target through the proxy, sending token as the Basic password.
+func proxyGet(t *testing.T, server *httptest.Server, target string, token string) *http.Response {
+	proxyURL, _ := url.Parse(server.URL)
+	if len(token) > 0 {
+		proxyURL.User = url.UserPassword("user", token)
+	}
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get(target)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestForwardProxy(t *testing.T) {
+	key := makeServiceKey(t, "key1", "this is a key")
+	otherKey := makeServiceKey(t, "key1", "this is another key")
+	useServiceKeys(t, key)
+	server := useForwardProxy(t)
+	_, requests := runFakeUpgradeAgent(t, "proxy-agent", false)
+
+	tests := []struct {
+		name    string
+		target  string
+		token   string
+		want    int
+		wantURI string
+	}{
+		{"allowed host", "http://api.k8s.example/api/v1", proxyToken(t, key, "kubernetes", "k1", ""), http.StatusOK, "/api/v1"},
+		{"query is kept", "http://api.k8s.example/api?watch=1", proxyToken(t, key, "kubernetes", "k1", ""), http.StatusOK, "/api?watch=1"},
+		{"denied host", "http://other.example/", proxyToken(t, key, "kubernetes", "k1", ""), http.StatusForbidden, ""},
+		{"missing token", "http://api.k8s.example/", "", http.StatusProxyAuthRequired, ""},
+		{"invalid token", "http://api.k8s.example/", "not.a.token", http.StatusProxyAuthRequired, ""},
+		{"token signed by another key", "http://api.k8s.example/", proxyToken(t, otherKey, "kubernetes", "k1", ""), http.StatusProxyAuthRequired, ""},
+		{"token for another endpoint", "http://api.k8s.example/", proxyToken(t, key, "kubernetes", "k2", ""), http.StatusProxyAuthRequired, ""},
+		{"path allowed", "http://api.k8s.example/api/v1", proxyToken(t, key, "kubernetes", "k1", "/api"), http.StatusOK, "/api/v1"},
+		{"path not allowed", "http://api.k8s.example/metrics", proxyToken(t, key, "kubernetes", "k1", "/api"), http.StatusProxyAuthRequired, ""},
+		{"tcp endpoint needs CONNECT", "http://db.internal/", proxyToken(t, key, tcpEndpointType, "db", ""), http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := proxyGet(t, server, tt.target, tt.token)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusProxyAuthRequired && resp.Header.Get("Proxy-Authenticate") == "" {
+				t.Errorf("no Proxy-Authenticate header")
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			req := <-requests
+			if req.URI != tt.wantURI {
+				t.Errorf("agent got URI %q, want %q", req.URI, tt.wantURI)
+			}
+			if hasHeader(req.Headers, "Proxy-Authorization") {
+				t.Errorf("agent got the proxy credentials")
+			}
+		})
+	}
+}
+
+// proxyConnect sends a CONNECT request, and returns the connection and
+// the proxy's response.
+func proxyConnect(t *testing.T, server *httptest.Server, hostport string, token string) (*tls.Conn, *bufio.Reader, *http.Response) {
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), tlsConfig)
+	if err != nil {
+		t.Fatalf("Dial() = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", hostport, hostport)
+	if len(token) > 0 {
+		fmt.Fprintf(conn, "Proxy-Authorization: Bearer %s\r\n", token)
+	}
+	fmt.Fprintf(conn, "\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() = %v", err)
+	}
+	return conn, r, resp
+}
+
+func TestForwardProxy_connect(t *testing.T) {
+	key := makeServiceKey(t, "key1", "this is a key")
+	useServiceKeys(t, key)
+	server := useForwardProxy(t)
+	addresses := runFakeTCPAgent(t, "proxy-agent", "")
+
+	conn, r, resp := proxyConnect(t, server, "db.internal:5432", proxyToken(t, key, tcpEndpointType, "db", ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if address := <-addresses; address != "db.internal:5432" {
+		t.Errorf("agent asked to connect to %q, want db.internal:5432", address)
+	}
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	echoed := make([]byte, 5)
+	if _, err := io.ReadFull(r, echoed); err != nil || string(echoed) != "hello" {
+		t.Fatalf("read %q, %v, want hello", echoed, err)
+	}
+}
+
+func TestForwardProxy_connectRefused(t *testing.T) {
+	key := makeServiceKey(t, "key1", "this is a key")
+	useServiceKeys(t, key)
+	server := useForwardProxy(t)
+	runFakeTCPAgent(t, "proxy-agent", "")
+
+	tests := []struct {
+		name     string
+		hostport string
+		token    string
+		want     int
+	}{
+		{"denied host", "other.example:5432", proxyToken(t, key, tcpEndpointType, "db", ""), http.StatusForbidden},
+		{"missing token", "db.internal:5432", "", http.StatusProxyAuthRequired},
+		{"invalid token", "db.internal:5432", "not.a.token", http.StatusProxyAuthRequired},
+		{"token for another endpoint", "db.internal:5432", proxyToken(t, key, "kubernetes", "k1", ""), http.StatusProxyAuthRequired},
+		{"not a tcp endpoint", "api.k8s.example:443", proxyToken(t, key, "kubernetes", "k1", ""), http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, resp := proxyConnect(t, server, tt.hostport, tt.token)
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
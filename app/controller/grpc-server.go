@@ -17,6 +17,7 @@ package main
  */
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -27,30 +28,39 @@ import (
 	"time"
 
 	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 func (s *agentTunnelServer) sendWebhook(state agent.Agent, endpoints []*tunnel.EndpointHealth) {
 	if hook == nil {
 		return
 	}
-	eh := make([]agent.Endpoint, len(endpoints))
+	req := &agent.BaseStatistics{
+		Name:      state.GetName(),
+		Session:   state.GetSession(),
+		Endpoints: endpointsFromPB(endpoints),
+	}
+	hook.Send(req)
+}
+
+func endpointsFromPB(endpoints []*tunnel.EndpointHealth) []agent.Endpoint {
+	ret := make([]agent.Endpoint, len(endpoints))
 	for i, ep := range endpoints {
-		eh[i] = agent.Endpoint{
+		ret[i] = agent.Endpoint{
 			Name:       ep.Name,
 			Type:       ep.Type,
 			Configured: ep.Configured,
 			Namespaces: ep.Namespaces,
 		}
 	}
-	req := &agent.BaseStatistics{
-		Name:      state.GetName(),
-		Session:   state.GetSession(),
-		Endpoints: eh,
-	}
-	hook.Send(req)
+	return ret
 }
 
 func (s *agentTunnelServer) makePingResponse(req *tunnel.PingRequest) *tunnel.ControllerToAgentWrapper {
@@ -64,26 +74,172 @@ func (s *agentTunnelServer) makePingResponse(req *tunnel.PingRequest) *tunnel.Co
 
 type sessionList struct {
 	sync.RWMutex
-	m map[string]chan *tunnel.AgentToControllerWrapper
+	m      map[string]*responseQueue
+	closed bool
 }
 
-func (s *agentTunnelServer) removeHTTPId(httpids *sessionList, id string) {
+func (httpids *sessionList) remove(id string) {
 	httpids.Lock()
 	defer httpids.Unlock()
-	delete(httpids.m, id)
+	if dest, found := httpids.m[id]; found {
+		dest.abort()
+		delete(httpids.m, id)
+	}
+}
+
+//
+// add registers the channel which receives responses for a request.  Once
+// the session has closed, the channel is closed at once, as no responses
+// will arrive.
+//
+func (httpids *sessionList) add(id string, c chan *tunnel.AgentToControllerWrapper) {
+	httpids.Lock()
+	defer httpids.Unlock()
+	if httpids.closed {
+		newResponseQueue(c).close()
+		return
+	}
+	httpids.m[id] = newResponseQueue(c)
 }
 
-func (s *agentTunnelServer) addHTTPId(httpids *sessionList, id string, c chan *tunnel.AgentToControllerWrapper) {
+func (httpids *sessionList) closeAll() {
 	httpids.Lock()
 	defer httpids.Unlock()
-	httpids.m[id] = c
+	httpids.closed = true
+	for id, v := range httpids.m {
+		v.close()
+		delete(httpids.m, id)
+	}
+}
+
+// closeID closes and forgets the channel for a request which will receive
+// no further responses.
+func (httpids *sessionList) closeID(id string) {
+	httpids.Lock()
+	defer httpids.Unlock()
+	if dest, found := httpids.m[id]; found {
+		dest.close()
+		delete(httpids.m, id)
+	}
+}
+
+//
+// route delivers a response from an agent to the request waiting on it.
+// Responses are queued per request, so this does not block on a slow
+// consumer.  The source is used only for logging.
+//
+func (httpids *sessionList) route(in *tunnel.AgentToControllerWrapper, source fmt.Stringer) {
+	httpids.Lock()
+	defer httpids.Unlock()
+	switch x := in.Event.(type) {
+	case *tunnel.AgentToControllerWrapper_HttpResponse:
+		resp := in.GetHttpResponse()
+		dest := httpids.m[resp.Id]
+		if dest == nil {
+			log.Printf("Got response to unknown HTTP request id %s from %s", resp.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindHTTP).Inc()
+			return
+		}
+		dest.push(in)
+		if resp.ContentLength == 0 {
+			dest.close()
+			delete(httpids.m, resp.Id)
+		}
+	case *tunnel.AgentToControllerWrapper_HttpChunkedResponse:
+		resp := in.GetHttpChunkedResponse()
+		dest := httpids.m[resp.Id]
+		if dest == nil {
+			log.Printf("Got response to unknown HTTP request id %s from %s", resp.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindHTTP).Inc()
+			return
+		}
+		dest.push(in)
+		if len(resp.Body) == 0 {
+			dest.close()
+			delete(httpids.m, resp.Id)
+		}
+	case *tunnel.AgentToControllerWrapper_CommandTermination:
+		resp := in.GetCommandTermination()
+		dest := httpids.m[resp.Id]
+		if dest == nil {
+			log.Printf("Got response to unknown CMD request id %s from %s", resp.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindCommand).Inc()
+			return
+		}
+		dest.push(in)
+		dest.close()
+		delete(httpids.m, resp.Id)
+	case *tunnel.AgentToControllerWrapper_CommandData:
+		resp := in.GetCommandData()
+		dest := httpids.m[resp.Id]
+		if dest == nil {
+			log.Printf("Got response to unknown CMD request id %s from %s", resp.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindCommand).Inc()
+			return
+		}
+		dest.push(in)
+	case *tunnel.AgentToControllerWrapper_ConnectionOpened:
+		resp := in.GetConnectionOpened()
+		dest := httpids.m[resp.Id]
+		if dest == nil {
+			log.Printf("Got response to unknown connection id %s from %s", resp.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindTCP).Inc()
+			return
+		}
+		dest.push(in)
+		if len(resp.Error) > 0 {
+			dest.close()
+			delete(httpids.m, resp.Id)
+		}
+	case *tunnel.AgentToControllerWrapper_ConnectionData:
+		data := in.GetConnectionData()
+		dest := httpids.m[data.Id]
+		if dest == nil {
+			log.Printf("Got data for unknown connection %s from %s", data.Id, source)
+			unknownIDDropsCounter.WithLabelValues(audit.KindHTTP).Inc()
+			return
+		}
+		dest.push(in)
+		if data.Closed {
+			dest.close()
+			delete(httpids.m, data.Id)
+		}
+	case *tunnel.AgentToControllerWrapper_WindowUpdate:
+		// Credit for a request body may arrive after the response has
+		// finished, so unknown IDs are expected.
+		if dest := httpids.m[in.GetWindowUpdate().Id]; dest != nil {
+			dest.push(in)
+		}
+	default:
+		log.Printf("Cannot route message from %s: %T", source, x)
+	}
+}
+
+//
+// startSendSpan starts a span for sending a request over the agent's
+// tunnel, continuing the trace in traceContext.  traceContext is updated
+// so the agent's spans are children of this one.
+//
+func startSendSpan(traceContext *map[string]string, session string, id string) *tracing.Span {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), *traceContext), "handleHTTPRequests", tracing.SpanKindProducer,
+		tracing.String("session", session),
+		tracing.String("transaction.id", id))
+	*traceContext = tracing.Inject(ctx)
+	return span
 }
 
-func (s *agentTunnelServer) handleHTTPRequests(session string, requestChan chan interface{}, httpids *sessionList, stream tunnel.AgentTunnelService_EventTunnelServer) {
-	for interfacedRequest := range requestChan {
+func (s *agentTunnelServer) handleHTTPRequests(session string, requestChan chan interface{}, closed <-chan struct{}, httpids *sessionList, stream tunnel.AgentTunnelService_EventTunnelServer) {
+	for {
+		var interfacedRequest interface{}
+		select {
+		case interfacedRequest = <-requestChan:
+		case <-closed:
+			return
+		}
 		switch value := interfacedRequest.(type) {
 		case *HTTPMessage:
-			s.addHTTPId(httpids, value.Cmd.Id, value.Out)
+			httpids.add(value.Cmd.Id, value.Out)
+			span := startSendSpan(&value.Cmd.TraceContext, session, value.Cmd.Id)
 			resp := &tunnel.ControllerToAgentWrapper{
 				Event: &tunnel.ControllerToAgentWrapper_HttpRequest{
 					HttpRequest: value.Cmd,
@@ -91,10 +247,26 @@ func (s *agentTunnelServer) handleHTTPRequests(session string, requestChan chan
 			}
 			if err := stream.Send(resp); err != nil {
 				log.Printf("Unable to send to agent %s for HTTP request %s", session, value.Cmd.Id)
+				span.SetError(err.Error())
 			}
+			span.End()
+		case *openConnectionMessage:
+			httpids.add(value.req.Id, value.out)
+			span := startSendSpan(&value.req.TraceContext, session, value.req.Id)
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_OpenConnection{
+					OpenConnection: value.req,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for connection %s", session, value.req.Id)
+				span.SetError(err.Error())
+			}
+			span.End()
 		case *runCmdMessage:
 			log.Printf("cmd %s %s %v %v running", value.cmd.Id, value.cmd.Name, value.cmd.Arguments, value.cmd.Environment)
-			s.addHTTPId(httpids, value.cmd.Id, value.out)
+			httpids.add(value.cmd.Id, value.out)
+			span := startSendSpan(&value.cmd.TraceContext, session, value.cmd.Id)
 			resp := &tunnel.ControllerToAgentWrapper{
 				Event: &tunnel.ControllerToAgentWrapper_CommandRequest{
 					CommandRequest: value.cmd,
@@ -102,6 +274,65 @@ func (s *agentTunnelServer) handleHTTPRequests(session string, requestChan chan
 			}
 			if err := stream.Send(resp); err != nil {
 				log.Printf("Unable to send to agent %s for CMD request %s", session, value.cmd.Id)
+				span.SetError(err.Error())
+			}
+			span.End()
+		case *cmdDataMessage:
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_CommandData{
+					CommandData: value.data,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for CMD data %s", session, value.data.Id)
+			}
+		case *cmdResizeMessage:
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_CommandResize{
+					CommandResize: value.resize,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for CMD resize %s", session, value.resize.Id)
+			}
+		case *requestBodyMessage:
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_HttpRequestBody{
+					HttpRequestBody: value.body,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for HTTP request body %s", session, value.body.Id)
+			}
+		case *connectionDataMessage:
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_ConnectionData{
+					ConnectionData: value.data,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for connection data %s", session, value.data.Id)
+			}
+		case *windowUpdateMessage:
+			resp := &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_WindowUpdate{
+					WindowUpdate: value.update,
+				},
+			}
+			if err := stream.Send(resp); err != nil {
+				log.Printf("Unable to send to agent %s for window update %s", session, value.update.Id)
+			}
+		case *agentBinaryMessage:
+			if err := stream.Send(value.event); err != nil {
+				log.Printf("Unable to send agent binary to agent %s: %v", session, err)
+			}
+		case *agentCertificateMessage:
+			if err := stream.Send(makeAgentCertificate(value.cert, value.message)); err != nil {
+				log.Printf("Unable to send certificate to agent %s: %v", session, err)
+			}
+		case *agentTrustBundleMessage:
+			if err := stream.Send(makeAgentTrustBundle(value.bundle)); err != nil {
+				log.Printf("Unable to send trust bundle to agent %s: %v", session, err)
 			}
 		default:
 			log.Printf("Got unexpected message type: %T", interfacedRequest)
@@ -109,9 +340,16 @@ func (s *agentTunnelServer) handleHTTPRequests(session string, requestChan chan
 	}
 }
 
-func (s *agentTunnelServer) handleHTTPCancelRequest(session string, cancelChan chan string, httpids *sessionList, stream tunnel.AgentTunnelService_EventTunnelServer) {
-	for id := range cancelChan {
-		s.removeHTTPId(httpids, id)
+func (s *agentTunnelServer) handleHTTPCancelRequest(session string, cancelChan chan string, closed <-chan struct{}, httpids *sessionList, stream tunnel.AgentTunnelService_EventTunnelServer) {
+	for {
+		var id string
+		select {
+		case id = <-cancelChan:
+		case <-closed:
+			log.Printf("cancel channel closed for agent %s", session)
+			return
+		}
+		httpids.remove(id)
 		resp := &tunnel.ControllerToAgentWrapper{
 			Event: &tunnel.ControllerToAgentWrapper_CancelRequest{
 				CancelRequest: &tunnel.CancelRequest{Id: id},
@@ -121,14 +359,27 @@ func (s *agentTunnelServer) handleHTTPCancelRequest(session string, cancelChan c
 			log.Printf("Unable to send to agent %s for cancel request %s", session, id)
 		}
 	}
-	log.Printf("cancel channel closed for agent %s", session)
 }
 
-func (s *agentTunnelServer) closeAllHTTP(httpids *sessionList) {
-	httpids.Lock()
-	defer httpids.Unlock()
-	for _, v := range httpids.m {
-		close(v)
+// agentReceived holds the result of one stream.Recv() call.
+type agentReceived struct {
+	in  *tunnel.AgentToControllerWrapper
+	err error
+}
+
+// receiveFromAgent reads messages from the stream until it fails, so the
+// tunnel can also wait on other events.  It stops when done is closed.
+func receiveFromAgent(stream tunnel.AgentTunnelService_EventTunnelServer, received chan<- agentReceived, done <-chan struct{}) {
+	for {
+		in, err := stream.Recv()
+		select {
+		case received <- agentReceived{in: in, err: err}:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
 	}
 }
 
@@ -138,32 +389,59 @@ func (s *agentTunnelServer) EventTunnel(stream tunnel.AgentTunnelService_EventTu
 	if err != nil {
 		return err
 	}
+	cert, err := getPeerCertificateFromContext(stream.Context())
+	if err != nil {
+		return err
+	}
 
 	sessionIdentity := ulidContext.Ulid()
 
-	inRequest := make(chan interface{}, 1)
-	inCancelRequest := make(chan string, 1)
-	httpids := &sessionList{m: make(map[string]chan *tunnel.AgentToControllerWrapper)}
+	// These are unbuffered, so a message is either taken by the
+	// handlers or refused once the agent is closed, never left behind.
+	inRequest := make(chan interface{})
+	inCancelRequest := make(chan string)
+	httpids := &sessionList{m: make(map[string]*responseQueue)}
 
 	state := &agent.DirectlyConnectedAgent{
-		Name:            agentIdentity,
-		Session:         sessionIdentity,
-		InRequest:       inRequest,
-		InCancelRequest: inCancelRequest,
-		ConnectedAt:     tunnel.Now(),
+		Name:              agentIdentity,
+		Session:           sessionIdentity,
+		InRequest:         inRequest,
+		InCancelRequest:   inCancelRequest,
+		ConnectedAt:       tunnel.Now(),
+		CertificateSerial: cert.SerialNumber.String(),
+		Disconnected:      make(chan struct{}),
+		Closed:            make(chan struct{}),
 	}
 
 	log.Printf("Agent %s connected, awaiting hello message", state)
 
-	go s.handleHTTPRequests(sessionIdentity, inRequest, httpids, stream)
+	go s.handleHTTPRequests(sessionIdentity, inRequest, state.Closed, httpids, stream)
+
+	go s.handleHTTPCancelRequest(sessionIdentity, inCancelRequest, state.Closed, httpids, stream)
 
-	go s.handleHTTPCancelRequest(sessionIdentity, inCancelRequest, httpids, stream)
+	received := make(chan agentReceived)
+	done := make(chan struct{})
+	defer close(done)
+	go receiveFromAgent(stream, received, done)
 
 	for {
-		in, err := stream.Recv()
+		var r agentReceived
+		select {
+		case r = <-received:
+		case <-state.Disconnected:
+			log.Printf("Disconnecting %s: certificate revoked", state)
+			httpids.closeAll()
+			err2 := agents.RemoveAgent(state)
+			if err2 != nil {
+				log.Printf("while removing agent: %v", err2)
+			}
+			return status.Error(codes.PermissionDenied, "certificate has been revoked")
+		}
+
+		in, err := r.in, r.err
 		if err == io.EOF {
 			log.Printf("Closing %s", state)
-			s.closeAllHTTP(httpids)
+			httpids.closeAll()
 			err2 := agents.RemoveAgent(state)
 			if err2 != nil {
 				log.Printf("while removing agent: %v", err2)
@@ -172,7 +450,7 @@ func (s *agentTunnelServer) EventTunnel(stream tunnel.AgentTunnelService_EventTu
 		}
 		if err != nil {
 			log.Printf("Agent closed connection: %s", state)
-			s.closeAllHTTP(httpids)
+			httpids.closeAll()
 			err2 := agents.RemoveAgent(state)
 			if err2 != nil {
 				log.Printf("while removing agent: %v", err2)
@@ -194,72 +472,36 @@ func (s *agentTunnelServer) EventTunnel(stream tunnel.AgentTunnelService_EventTu
 			}
 		case *tunnel.AgentToControllerWrapper_AgentHello:
 			req := in.GetAgentHello()
-			endpoints := make([]agent.Endpoint, len(req.Endpoints))
-			for i, ep := range req.Endpoints {
-				endpoints[i] = agent.Endpoint{
-					Name:       ep.Name,
-					Type:       ep.Type,
-					Configured: ep.Configured,
-					Namespaces: ep.Namespaces,
-				}
-			}
-			state.Endpoints = endpoints
+			state.Endpoints = endpointsFromPB(req.Endpoints)
 			state.Version = req.Version
 			state.Hostname = req.Hostname
+			state.OS = req.Os
+			state.Arch = req.Arch
+			state.BinaryHash = req.BinaryHash
+			state.StreamsRequestBodies = req.StreamsRequestBodies
+			state.TunnelsUpgrades = req.TunnelsUpgrades
+			state.PacesCommandInput = req.PacesCommandInput
 			agents.AddAgent(state)
 			s.sendWebhook(state, req.Endpoints)
-		case *tunnel.AgentToControllerWrapper_HttpResponse:
-			resp := in.GetHttpResponse()
-			atomic.StoreUint64(&state.LastUse, tunnel.Now())
-			httpids.Lock()
-			dest := httpids.m[resp.Id]
-			if dest != nil {
-				dest <- in
-				if resp.ContentLength == 0 {
-					delete(httpids.m, resp.Id)
-				}
-			} else {
-				log.Printf("Got response to unknown HTTP request id %s from %s", resp.Id, agentIdentity)
-			}
-			httpids.Unlock()
-		case *tunnel.AgentToControllerWrapper_HttpChunkedResponse:
-			resp := in.GetHttpChunkedResponse()
-			atomic.StoreUint64(&state.LastUse, tunnel.Now())
-			httpids.Lock()
-			dest := httpids.m[resp.Id]
-			if dest != nil {
-				dest <- in
-				if len(resp.Body) == 0 {
-					delete(httpids.m, resp.Id)
-				}
-			} else {
-				log.Printf("Got response to unknown HTTP request id %s from %s", resp.Id, state)
+			go sendTrustBundle(state)
+			if binary := findAgentBinary(req.Os, req.Arch); binary != nil {
+				go state.Send(&agentBinaryMessage{event: makeAgentBinaryInfo(binary)})
 			}
-			httpids.Unlock()
-		case *tunnel.AgentToControllerWrapper_CommandTermination:
-			resp := in.GetCommandTermination()
+		case *tunnel.AgentToControllerWrapper_AgentBinaryRequest:
+			req := in.GetAgentBinaryRequest()
+			go sendAgentBinary(state, req.Hash)
+		case *tunnel.AgentToControllerWrapper_AgentCertificateRequest:
+			log.Printf("Agent %s requested a renewed certificate", state)
+			go signAgentCertificate(state, in.GetAgentCertificateRequest().Csr)
+		case *tunnel.AgentToControllerWrapper_HttpResponse,
+			*tunnel.AgentToControllerWrapper_HttpChunkedResponse,
+			*tunnel.AgentToControllerWrapper_CommandTermination,
+			*tunnel.AgentToControllerWrapper_CommandData,
+			*tunnel.AgentToControllerWrapper_ConnectionOpened,
+			*tunnel.AgentToControllerWrapper_ConnectionData,
+			*tunnel.AgentToControllerWrapper_WindowUpdate:
 			atomic.StoreUint64(&state.LastUse, tunnel.Now())
-			httpids.Lock()
-			dest := httpids.m[resp.Id]
-			if dest != nil {
-				dest <- in
-				close(dest)
-				delete(httpids.m, resp.Id)
-			} else {
-				log.Printf("Got response to unknown CMD request id %s from %s", resp.Id, state)
-			}
-			httpids.Unlock()
-		case *tunnel.AgentToControllerWrapper_CommandData:
-			resp := in.GetCommandData()
-			atomic.StoreUint64(&state.LastUse, tunnel.Now())
-			httpids.Lock()
-			dest := httpids.m[resp.Id]
-			if dest != nil {
-				dest <- in
-			} else {
-				log.Printf("Got response to unknown CMD request id %s from %s", resp.Id, state)
-			}
-			httpids.Unlock()
+			httpids.route(in, state)
 		case nil:
 			// ignore for now
 		default:
@@ -276,7 +518,7 @@ func newAgentServer() *agentTunnelServer {
 	return &agentTunnelServer{}
 }
 
-func runAgentGRPCServer(serverCert tls.Certificate) {
+func runAgentGRPCServer(serverCert *ca.RenewableCertificate) {
 	//
 	// Set up GRPC server
 	//
@@ -286,16 +528,8 @@ func runAgentGRPCServer(serverCert tls.Certificate) {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	certPool, err := authority.MakeCertPool()
-	if err != nil {
-		log.Fatalf("While making certpool: %v", err)
-	}
-	creds := credentials.NewTLS(&tls.Config{
-		ClientCAs:    certPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{serverCert},
-		MinVersion:   tls.VersionTLS13,
-	})
+	creds := credentials.NewTLS(authority.ServerTLSConfig(serverCert,
+		tls.RequireAndVerifyClientCert, tls.VersionTLS13, []string{"h2"}))
 	grpcServer := grpc.NewServer(grpc.Creds(creds))
 	tunnel.RegisterAgentTunnelServiceServer(grpcServer, newAgentServer())
 	if err := grpcServer.Serve(lis); err != nil {
@@ -311,11 +545,12 @@ func newCmdToolServer() *cmdToolTunnelServer {
 	return &cmdToolTunnelServer{}
 }
 
-func (s *cmdToolTunnelServer) makeCommandTermination(exitstatus int) *tunnel.ControllerToCmdToolWrapper {
+func (s *cmdToolTunnelServer) makeCommandTermination(exitstatus int, message string) *tunnel.ControllerToCmdToolWrapper {
 	return &tunnel.ControllerToCmdToolWrapper{
 		Event: &tunnel.ControllerToCmdToolWrapper_CommandTermination{
 			CommandTermination: &tunnel.CmdToolCommandTermination{
 				ExitCode: int32(exitstatus),
+				Message:  message,
 			},
 		},
 	}
@@ -326,15 +561,63 @@ type runCmdMessage struct {
 	cmd *tunnel.CommandRequest
 }
 
+// cmdDataMessage carries data for a running command's stdin.
+type cmdDataMessage struct {
+	data *tunnel.CommandData
+}
+
+// cmdResizeMessage carries a new terminal size for a running command.
+type cmdResizeMessage struct {
+	resize *tunnel.CommandResize
+}
+
+// cmdToolStream serialises sends to the command-tool, which come both from
+// the agent's responses and from returning credit for stdin.
+type cmdToolStream struct {
+	sync.Mutex
+	stream tunnel.CmdToolTunnelService_EventTunnelServer
+}
+
+func (s *cmdToolStream) Send(msg *tunnel.ControllerToCmdToolWrapper) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.stream.Send(msg)
+}
+
+func makeCmdToolWindowUpdate(n int64) *tunnel.ControllerToCmdToolWrapper {
+	return &tunnel.ControllerToCmdToolWrapper{
+		Event: &tunnel.ControllerToCmdToolWrapper_WindowUpdate{
+			WindowUpdate: &tunnel.CmdToolWindowUpdate{Bytes: n},
+		},
+	}
+}
+
+//
+// EventTunnel runs one command for the command-tool.  Stdin is paced on
+// both sides: the tool is given credit as its input is passed on to the
+// agent, and, for agents which return credit, the agent's send window
+// is waited on before passing it on.
+//
 func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_EventTunnelServer) error {
-	agentIdentity, err := getAgentNameFromContext(stream.Context())
+	sender := &cmdToolStream{stream: stream}
+	agentIdentity, commandName, err := getRemoteCommandNameFromContext(stream.Context())
 	if err != nil {
 		return err
 	}
-	log.Printf("CmdTool %s connected", agentIdentity)
+	names, err := getCertificateNameFromContext(stream.Context())
+	if err != nil {
+		return err
+	}
+	log.Printf("CmdTool %s connected for command %s", agentIdentity, commandName)
 
 	sessionIdentity := ulidContext.Ulid()
 	agentResponseChan := make(chan *tunnel.AgentToControllerWrapper)
+	operationID := ulidContext.Ulid()
+	commandRecord := newCommandAudit(stream.Context(), audit.CertificateIdentity(names), agentIdentity, commandName, operationID)
+	window := newReceiveWindow(operationID)
+	inputWindow := tunnel.NewSendWindow(tunnel.RequestBodyWindow)
+	pacedInput := false
+	var toolCredit int64
 
 	go func() {
 		for in := range agentResponseChan {
@@ -342,11 +625,13 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 			case *tunnel.AgentToControllerWrapper_CommandTermination:
 				resp := in.GetCommandTermination()
 				log.Printf("Got command exit code %d", resp.ExitCode)
-				if err := stream.Send(s.makeCommandTermination(int(resp.ExitCode))); err != nil {
+				commandRecord.exited(int(resp.ExitCode), resp.Message)
+				if err := sender.Send(s.makeCommandTermination(int(resp.ExitCode), resp.Message)); err != nil {
 					log.Printf("While sending: %v", err)
 				}
 			case *tunnel.AgentToControllerWrapper_CommandData:
 				resp := in.GetCommandData()
+				commandRecord.addResponseBytes(len(resp.Body))
 				msg := &tunnel.ControllerToCmdToolWrapper{
 					Event: &tunnel.ControllerToCmdToolWrapper_CommandData{
 						CommandData: &tunnel.CmdToolCommandData{
@@ -356,9 +641,12 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 						},
 					},
 				}
-				if err := stream.Send(msg); err != nil {
+				if err := sender.Send(msg); err != nil {
 					log.Printf("Sending CommandData to tool: %v", err)
 				}
+				window.consume(len(resp.Body))
+			case *tunnel.AgentToControllerWrapper_WindowUpdate:
+				inputWindow.Grant(in.GetWindowUpdate().Bytes)
 			case nil:
 				// ignore for now
 			default:
@@ -367,7 +655,6 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 		}
 	}()
 
-	operationID := ulidContext.Ulid()
 	ep := agent.Search{
 		Name:         agentIdentity,
 		EndpointType: "remote-command",
@@ -377,6 +664,7 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 		in, err := stream.Recv()
 		if err == io.EOF {
 			log.Printf("CmdTool %s closed connection %s", agentIdentity, sessionIdentity)
+			commandRecord.cancelled("remote-command closed the connection before the command exited")
 			err2 := agents.Cancel(ep, operationID)
 			if err2 != nil {
 				log.Printf("while cancelling operation: %v", err2)
@@ -385,6 +673,7 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 		}
 		if err != nil {
 			log.Printf("CmdTool %s closed connection: %s", agentIdentity, sessionIdentity)
+			commandRecord.cancelled(err.Error())
 			err2 := agents.Cancel(ep, operationID)
 			if err2 != nil {
 				log.Printf("while cancelling operation: %v", err2)
@@ -396,19 +685,83 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 		case *tunnel.CmdToolToControllerWrapper_CommandRequest:
 			req := in.GetCommandRequest()
 			log.Printf("CmdTool %s request: %v", agentIdentity, req)
+			commandRecord.started(req.Name, req.Arguments)
+			if req.Name != commandName {
+				close(agentResponseChan)
+				err := status.Errorf(codes.PermissionDenied, "certificate is not authorized for command %s", req.Name)
+				commandRecord.finish(err.Error())
+				return err
+			}
 			ep.EndpointName = req.Name
 			cmd := &tunnel.CommandRequest{
-				Id:          operationID,
-				Name:        req.Name,
-				Arguments:   req.Arguments,
-				Environment: req.Environment,
+				Id:           operationID,
+				Name:         req.Name,
+				Arguments:    req.Arguments,
+				Environment:  req.Environment,
+				Stdin:        req.Stdin,
+				Tty:          req.Tty,
+				TerminalSize: req.TerminalSize,
+				TraceContext: commandRecord.traceContext(),
+				Window:       streamWindowSize,
 			}
 			message := &runCmdMessage{out: agentResponseChan, cmd: cmd}
 			sessionID, found := agents.Send(ep, message)
 			ep.Session = sessionID
 			if !found {
 				close(agentResponseChan)
-				return fmt.Errorf("unknown agent: %s", agentIdentity)
+				err := fmt.Errorf("unknown agent: %s", agentIdentity)
+				commandRecord.finish(err.Error())
+				return err
+			}
+			window.start(ep)
+			pacedInput = agents.CanPaceCommandInput(ep)
+			commandRecord.sentTo(sessionID)
+		case *tunnel.CmdToolToControllerWrapper_CommandData:
+			req := in.GetCommandData()
+			if ep.Session == "" {
+				log.Printf("CmdTool %s sent command data before a command request", agentIdentity)
+				continue
+			}
+			if pacedInput && len(req.Body) > 0 {
+				// An error means the tool has gone, which Recv() will report.
+				if _, err := inputWindow.Wait(stream.Context()); err != nil {
+					continue
+				}
+				inputWindow.Consume(len(req.Body))
+			}
+			commandRecord.addRequestBytes(len(req.Body))
+			message := &cmdDataMessage{
+				data: &tunnel.CommandData{
+					Id:      operationID,
+					Body:    req.Body,
+					Channel: tunnel.ChannelDirection_STDIN,
+					Closed:  req.Closed,
+				},
+			}
+			if _, found := agents.Send(ep, message); !found {
+				log.Printf("CmdTool %s: agent session %s went away", agentIdentity, ep.Session)
+			}
+			toolCredit += int64(len(req.Body))
+			if toolCredit >= windowUpdateThreshold {
+				if err := sender.Send(makeCmdToolWindowUpdate(toolCredit)); err != nil {
+					log.Printf("Sending window update to tool: %v", err)
+				}
+				toolCredit = 0
+			}
+		case *tunnel.CmdToolToControllerWrapper_CommandResize:
+			req := in.GetCommandResize()
+			if ep.Session == "" {
+				log.Printf("CmdTool %s sent resize before a command request", agentIdentity)
+				continue
+			}
+			message := &cmdResizeMessage{
+				resize: &tunnel.CommandResize{
+					Id:           operationID,
+					TerminalSize: req.TerminalSize,
+				},
+			}
+			if _, found := agents.Send(ep, message); !found {
+				log.Printf("CmdTool %s: agent session %s went away", agentIdentity, ep.Session)
 			}
 		case nil:
 			// ignore for now
@@ -418,7 +771,7 @@ func (s *cmdToolTunnelServer) EventTunnel(stream tunnel.CmdToolTunnelService_Eve
 	}
 }
 
-func runCmdToolGRPCServer(serverCert tls.Certificate) {
+func runCmdToolGRPCServer(serverCert *ca.RenewableCertificate) {
 	//
 	// Set up GRPC server
 	//
@@ -428,16 +781,8 @@ func runCmdToolGRPCServer(serverCert tls.Certificate) {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	certPool, err := authority.MakeCertPool()
-	if err != nil {
-		log.Fatalf("While making certpool: %v", err)
-	}
-	creds := credentials.NewTLS(&tls.Config{
-		ClientCAs:    certPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		Certificates: []tls.Certificate{serverCert},
-		MinVersion:   tls.VersionTLS13,
-	})
+	creds := credentials.NewTLS(authority.ServerTLSConfig(serverCert,
+		tls.RequireAndVerifyClientCert, tls.VersionTLS13, []string{"h2"}))
 	grpcServer := grpc.NewServer(grpc.Creds(creds))
 	tunnel.RegisterCmdToolTunnelServiceServer(grpcServer, newCmdToolServer())
 	if err := grpcServer.Serve(lis); err != nil {
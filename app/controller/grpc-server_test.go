@@ -0,0 +1,174 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"google.golang.org/grpc"
+)
+
+type fakeCmdToolStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	in  chan *tunnel.CmdToolToControllerWrapper
+	out chan *tunnel.ControllerToCmdToolWrapper
+}
+
+func (s *fakeCmdToolStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeCmdToolStream) Send(msg *tunnel.ControllerToCmdToolWrapper) error {
+	s.out <- msg
+	return nil
+}
+
+func (s *fakeCmdToolStream) Recv() (*tunnel.CmdToolToControllerWrapper, error) {
+	msg, more := <-s.in
+	if !more {
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+func makeToolStdin(data []byte) *tunnel.CmdToolToControllerWrapper {
+	return &tunnel.CmdToolToControllerWrapper{
+		Event: &tunnel.CmdToolToControllerWrapper_CommandData{
+			CommandData: &tunnel.CmdToolCommandData{Body: data, Channel: tunnel.ChannelDirection_STDIN},
+		},
+	}
+}
+
+// nextCommandInput returns the next stdin passed to the agent, or nil if
+// none arrives in time.
+func nextCommandInput(t *testing.T, state *agent.DirectlyConnectedAgent, wait time.Duration) *tunnel.CommandData {
+	t.Helper()
+	select {
+	case m := <-state.InRequest:
+		msg, ok := m.(*cmdDataMessage)
+		if !ok {
+			t.Fatalf("got %T, want *cmdDataMessage", m)
+		}
+		return msg.data
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+func TestCmdToolTunnel_pacesInput(t *testing.T) {
+	saved := agents
+	defer func() { agents = saved }()
+
+	tests := []struct {
+		name  string
+		paced bool
+	}{
+		{"paced agent", true},
+		{"agent without credit", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agents = agent.MakeAgents()
+			state := &agent.DirectlyConnectedAgent{
+				Name:              "smith",
+				Session:           "session",
+				Endpoints:         []agent.Endpoint{{Type: "remote-command", Name: "cmd", Configured: true}},
+				InRequest:         make(chan interface{}, 10),
+				InCancelRequest:   make(chan string, 10),
+				Closed:            make(chan struct{}),
+				PacesCommandInput: tt.paced,
+			}
+			agents.AddAgent(state)
+
+			stream := &fakeCmdToolStream{
+				ctx: certificateContext(t, ca.CertificateName{Agent: "smith", Name: "cmd", Purpose: ca.CertificatePurposeRemoteCommand}),
+				in:  make(chan *tunnel.CmdToolToControllerWrapper),
+				out: make(chan *tunnel.ControllerToCmdToolWrapper, 10),
+			}
+			finished := make(chan error)
+			go func() { finished <- newCmdToolServer().EventTunnel(stream) }()
+
+			stream.in <- &tunnel.CmdToolToControllerWrapper{
+				Event: &tunnel.CmdToolToControllerWrapper_CommandRequest{
+					CommandRequest: &tunnel.CmdToolCommandRequest{Name: "cmd", Stdin: true},
+				},
+			}
+			var run *runCmdMessage
+			select {
+			case m := <-state.InRequest:
+				run = m.(*runCmdMessage)
+			case <-time.After(5 * time.Second):
+				t.Fatal("command was not sent to the agent")
+			}
+
+			// One chunk more than the window, which a paced agent must
+			// return credit for before it is sent.
+			chunk := make([]byte, windowUpdateThreshold)
+			chunks := tunnel.RequestBodyWindow/len(chunk) + 1
+			go func() {
+				for i := 0; i < chunks; i++ {
+					stream.in <- makeToolStdin(chunk)
+				}
+			}()
+			for i := 0; i < chunks-1; i++ {
+				if data := nextCommandInput(t, state, 5*time.Second); data == nil || data.Id != run.cmd.Id {
+					t.Fatalf("chunk %d: got %v", i, data)
+				}
+			}
+			if tt.paced {
+				if data := nextCommandInput(t, state, 100*time.Millisecond); data != nil {
+					t.Fatal("input sent beyond the agent's window")
+				}
+				run.out <- &tunnel.AgentToControllerWrapper{
+					Event: &tunnel.AgentToControllerWrapper_WindowUpdate{
+						WindowUpdate: &tunnel.WindowUpdate{Id: run.cmd.Id, Bytes: int64(len(chunk))},
+					},
+				}
+			}
+			if data := nextCommandInput(t, state, 5*time.Second); data == nil {
+				t.Fatal("last chunk was not sent")
+			}
+
+			// The tool is given credit for each chunk passed on.
+			credit := int64(0)
+			for credit < int64(chunks*len(chunk)) {
+				select {
+				case msg := <-stream.out:
+					if update := msg.GetWindowUpdate(); update != nil {
+						credit += update.Bytes
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("tool was given %d bytes of credit, want %d", credit, chunks*len(chunk))
+				}
+			}
+
+			close(stream.in)
+			select {
+			case <-finished:
+			case <-time.After(5 * time.Second):
+				t.Fatal("EventTunnel did not return")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// certificateFor returns a certificate carrying name, as our CA issues.
+// It is not signed, as only its subject is examined.
+func certificateFor(t *testing.T, name ca.CertificateName) *x509.Certificate {
+	jsonName, err := json.Marshal(name)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			Names: []pkix.AttributeTypeAndValue{
+				{Type: asn1.ObjectIdentifier{2, 5, 4, ca.OpsMxOIDValue}, Value: string(jsonName)},
+			},
+		},
+	}
+}
+
+// certificateContext returns a GRPC context whose peer authenticated with
+// a certificate carrying name.
+func certificateContext(t *testing.T, name ca.CertificateName) context.Context {
+	info := credentials.TLSInfo{
+		State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{certificateFor(t, name)}},
+		},
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+// nextResponse returns the next message for a request, or fails the test
+// if none arrives.
+func nextResponse(t *testing.T, out chan *tunnel.AgentToControllerWrapper) (*tunnel.AgentToControllerWrapper, bool) {
+	t.Helper()
+	select {
+	case msg, more := <-out:
+		return msg, more
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no response for the request")
+		return nil, false
+	}
+}
+
+// eventually waits for cond to become true, or fails the test.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	endpointLabels = []string{"agent", "endpoint_type", "endpoint_name"}
+
+	requestsInFlightGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "controller_requests_in_flight",
+		Help: "The number of API requests and remote commands currently running",
+	}, endpointLabels)
+	requestDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "controller_request_duration_seconds",
+		Help:    "How long API requests and remote commands took, by HTTP status class (2xx, 4xx, ...) or command result (success, failure, error)",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, append(endpointLabels, "status_class"))
+	requestBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_request_bytes_total",
+		Help: "Bytes sent to agents in API request bodies and command input",
+	}, endpointLabels)
+	responseBytesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_response_bytes_total",
+		Help: "Bytes returned to clients in API response bodies and command output",
+	}, endpointLabels)
+	requestsCancelledCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_requests_cancelled_total",
+		Help: "API requests and remote commands the client went away from before they finished",
+	}, endpointLabels)
+	unknownIDDropsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_unknown_id_drops_total",
+		Help: "Messages from agents dropped because the request they belong to was not found",
+	}, []string{"kind"})
+)
+
+// The status classes used for commands.
+const (
+	commandSuccess = "success" // exit code 0
+	commandFailure = "failure" // non-zero exit code
+	commandError   = "error"   // no exit code, such as when the agent went away
+)
+
+//
+// requestMetrics tracks one API request or remote command.  It counts
+// as in flight from when it is made until finish is called.
+//
+type requestMetrics struct {
+	labels []string
+	start  time.Time
+}
+
+func startRequestMetrics(agentName string, endpointType string, endpointName string) *requestMetrics {
+	m := &requestMetrics{
+		labels: []string{agentName, endpointType, endpointName},
+		start:  time.Now(),
+	}
+	requestsInFlightGauge.WithLabelValues(m.labels...).Inc()
+	return m
+}
+
+func (m *requestMetrics) addRequestBytes(n int) {
+	if n > 0 {
+		requestBytesCounter.WithLabelValues(m.labels...).Add(float64(n))
+	}
+}
+
+func (m *requestMetrics) addResponseBytes(n int) {
+	if n > 0 {
+		responseBytesCounter.WithLabelValues(m.labels...).Add(float64(n))
+	}
+}
+
+func (m *requestMetrics) cancelled() {
+	requestsCancelledCounter.WithLabelValues(m.labels...).Inc()
+}
+
+// finish must be called exactly once.
+func (m *requestMetrics) finish(statusClass string) {
+	requestsInFlightGauge.WithLabelValues(m.labels...).Dec()
+	labels := append(append([]string{}, m.labels...), statusClass)
+	requestDurationHistogram.WithLabelValues(labels...).Observe(time.Since(m.start).Seconds())
+}
+
+// The status classes used for forwarded TCP connections.
+const (
+	connectionClosed = "closed" // connected, and later closed by either end
+	connectionFailed = "failed" // the agent could not connect, or the connection failed
+)
+
+func commandStatusClass(exitCode int) string {
+	if exitCode == 0 {
+		return commandSuccess
+	}
+	return commandFailure
+}
@@ -0,0 +1,819 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	peerRetryInterval = 10 * time.Second
+)
+
+//
+// Peer controllers connect to each other, and each one advertises the agents
+// directly connected to it.  The controller accepting the connection
+// registers a PeerConnectedAgent for each, and sends requests for those
+// agents back down the same stream.  Since every controller connects to
+// every configured peer, each side learns about the other's agents.
+//
+
+type peerTunnelServer struct {
+	tunnel.UnimplementedPeerTunnelServiceServer
+}
+
+func newPeerServer() *peerTunnelServer {
+	return &peerTunnelServer{}
+}
+
+type peerName string
+
+func (p peerName) String() string {
+	return fmt.Sprintf("(peer=%s)", string(p))
+}
+
+//
+// handlePeerRequests sends requests for the peer's agents down the stream
+// until done is closed.  It returns an error if the stream fails, so the
+// peer's agents can be removed.
+//
+func (s *peerTunnelServer) handlePeerRequests(peer peerName, requestChan chan *agent.PeerRequest, cancelChan chan *agent.PeerCancelRequest, done <-chan struct{}, httpids *sessionList, stream tunnel.PeerTunnelService_EventTunnelServer) error {
+	for {
+		var msg *tunnel.ControllerToAgentWrapper
+		var target *tunnel.PeerAgentRequest
+		select {
+		case <-done:
+			return nil
+		case req := <-requestChan:
+			target = &tunnel.PeerAgentRequest{AgentName: req.Name, AgentSession: req.Session}
+			switch value := req.Message.(type) {
+			case *HTTPMessage:
+				httpids.add(value.Cmd.Id, value.Out)
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_HttpRequest{
+						HttpRequest: value.Cmd,
+					},
+				}
+			case *openConnectionMessage:
+				httpids.add(value.req.Id, value.out)
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_OpenConnection{
+						OpenConnection: value.req,
+					},
+				}
+			case *runCmdMessage:
+				httpids.add(value.cmd.Id, value.out)
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_CommandRequest{
+						CommandRequest: value.cmd,
+					},
+				}
+			case *cmdDataMessage:
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_CommandData{
+						CommandData: value.data,
+					},
+				}
+			case *cmdResizeMessage:
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_CommandResize{
+						CommandResize: value.resize,
+					},
+				}
+			case *requestBodyMessage:
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_HttpRequestBody{
+						HttpRequestBody: value.body,
+					},
+				}
+			case *connectionDataMessage:
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_ConnectionData{
+						ConnectionData: value.data,
+					},
+				}
+			case *windowUpdateMessage:
+				msg = &tunnel.ControllerToAgentWrapper{
+					Event: &tunnel.ControllerToAgentWrapper_WindowUpdate{
+						WindowUpdate: value.update,
+					},
+				}
+			default:
+				log.Printf("Got unexpected message type for %s: %T", peer, req.Message)
+				continue
+			}
+		case req := <-cancelChan:
+			target = &tunnel.PeerAgentRequest{AgentName: req.Name, AgentSession: req.Session}
+			httpids.remove(req.ID)
+			msg = &tunnel.ControllerToAgentWrapper{
+				Event: &tunnel.ControllerToAgentWrapper_CancelRequest{
+					CancelRequest: &tunnel.CancelRequest{Id: req.ID},
+				},
+			}
+		}
+		target.Message = msg
+		resp := &tunnel.ControllerToPeerWrapper{
+			Event: &tunnel.ControllerToPeerWrapper_AgentRequest{
+				AgentRequest: target,
+			},
+		}
+		if err := stream.Send(resp); err != nil {
+			log.Printf("Unable to send to %s for agent %s: %v", peer, target.AgentName, err)
+			return err
+		}
+	}
+}
+
+// peerReceived holds the result of one stream.Recv() call.
+type peerReceived struct {
+	in  *tunnel.PeerToControllerWrapper
+	err error
+}
+
+// receiveFromPeer reads messages from the stream until it fails, so the
+// tunnel can also wait on other events.  It stops when done is closed.
+func receiveFromPeer(stream tunnel.PeerTunnelService_EventTunnelServer, received chan<- peerReceived, done <-chan struct{}) {
+	for {
+		in, err := stream.Recv()
+		select {
+		case received <- peerReceived{in: in, err: err}:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// updatePeerAgents makes our list of agents reachable through this peer match
+// what it has advertised.
+func updatePeerAgents(peerAgents map[string]*agent.PeerConnectedAgent, list *tunnel.PeerAgentList, controller string, requestChan chan *agent.PeerRequest, cancelChan chan *agent.PeerCancelRequest, done <-chan struct{}) {
+	seen := map[string]bool{}
+	for _, info := range list.Agents {
+		seen[info.Session] = true
+		if _, found := peerAgents[info.Session]; found {
+			continue
+		}
+		state := &agent.PeerConnectedAgent{
+			Name:            info.Name,
+			Session:         info.Session,
+			Endpoints:       endpointsFromPB(info.Endpoints),
+			Version:         info.Version,
+			Hostname:        info.Hostname,
+			ControllerID:    controller,
+			InRequest:       requestChan,
+			InCancelRequest: cancelChan,
+			Done:            done,
+			ConnectedAt:     info.ConnectedAt,
+
+			StreamsRequestBodies: info.StreamsRequestBodies,
+			TunnelsUpgrades:      info.TunnelsUpgrades,
+			PacesCommandInput:    info.PacesCommandInput,
+		}
+		peerAgents[info.Session] = state
+		agents.AddAgent(state)
+	}
+	for session, state := range peerAgents {
+		if seen[session] {
+			continue
+		}
+		if err := agents.RemoveAgent(state); err != nil {
+			log.Printf("while removing agent: %v", err)
+		}
+		delete(peerAgents, session)
+	}
+}
+
+// This runs in its own goroutine, one per GRPC connection from a peer controller.
+func (s *peerTunnelServer) EventTunnel(stream tunnel.PeerTunnelService_EventTunnelServer) error {
+	name, err := getPeerControllerNameFromContext(stream.Context())
+	if err != nil {
+		return err
+	}
+	peer := peerName(name)
+
+	// These are unbuffered, so a message is either taken by
+	// handlePeerRequests or refused once done is closed.
+	inRequest := make(chan *agent.PeerRequest)
+	inCancelRequest := make(chan *agent.PeerCancelRequest)
+	httpids := &sessionList{m: make(map[string]*responseQueue)}
+	peerAgents := map[string]*agent.PeerConnectedAgent{}
+	done := make(chan struct{})
+
+	log.Printf("Peer controller %s connected, awaiting hello message", peer)
+
+	sendFailed := make(chan error, 1)
+	go func() {
+		if err := s.handlePeerRequests(peer, inRequest, inCancelRequest, done, httpids, stream); err != nil {
+			sendFailed <- err
+		}
+	}()
+
+	defer func() {
+		close(done)
+		for _, state := range peerAgents {
+			if err := agents.RemoveAgent(state); err != nil {
+				log.Printf("while removing agent: %v", err)
+			}
+		}
+		httpids.closeAll()
+	}()
+
+	received := make(chan peerReceived)
+	go receiveFromPeer(stream, received, done)
+
+	helloSeen := false
+	for {
+		var r peerReceived
+		select {
+		case r = <-received:
+		case err := <-sendFailed:
+			log.Printf("Disconnecting %s: %v", peer, err)
+			return err
+		}
+
+		in, err := r.in, r.err
+		if err == io.EOF {
+			log.Printf("Closing %s", peer)
+			return nil
+		}
+		if err != nil {
+			log.Printf("Peer closed connection: %s", peer)
+			return err
+		}
+
+		switch x := in.Event.(type) {
+		case *tunnel.PeerToControllerWrapper_PeerHello:
+			req := in.GetPeerHello()
+			if req.ControllerId == controllerID {
+				return status.Error(codes.FailedPrecondition, "connected to self")
+			}
+			log.Printf("Peer %s hello, version %s", peer, req.Version)
+			helloSeen = true
+		case *tunnel.PeerToControllerWrapper_AgentList:
+			if !helloSeen {
+				return status.Error(codes.FailedPrecondition, "agent list sent before hello")
+			}
+			updatePeerAgents(peerAgents, in.GetAgentList(), name, inRequest, inCancelRequest, done)
+		case *tunnel.PeerToControllerWrapper_AgentMessage:
+			if !helloSeen {
+				return status.Error(codes.FailedPrecondition, "agent message sent before hello")
+			}
+			httpids.route(in.GetAgentMessage(), peer)
+		case *tunnel.PeerToControllerWrapper_RequestClosed:
+			if !helloSeen {
+				return status.Error(codes.FailedPrecondition, "request closed sent before hello")
+			}
+			httpids.closeID(in.GetRequestClosed().Id)
+		case *tunnel.PeerToControllerWrapper_Revocations:
+			if !helloSeen {
+				return status.Error(codes.FailedPrecondition, "revocation list sent before hello")
+			}
+			if err := authority.MergeRevokedCertificates(revocationsFromPB(in.GetRevocations())); err != nil {
+				log.Printf("Unable to save revocations from %s: %v", peer, err)
+			}
+		case nil:
+			// ignore for now
+		default:
+			log.Printf("Received unknown message: %s: %T", peer, x)
+		}
+	}
+}
+
+func runPeerGRPCServer(serverCert *ca.RenewableCertificate) {
+	//
+	// Set up GRPC server
+	//
+	log.Printf("Starting Peer GRPC server on port %d...", config.PeerListenPort)
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", config.PeerListenPort))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	creds := credentials.NewTLS(authority.ServerTLSConfig(serverCert,
+		tls.RequireAndVerifyClientCert, tls.VersionTLS13, []string{"h2"}))
+	grpcServer := grpc.NewServer(grpc.Creds(creds))
+	tunnel.RegisterPeerTunnelServiceServer(grpcServer, newPeerServer())
+	if err := grpcServer.Serve(lis); err != nil {
+		log.Fatalf("Failed to start Peer GRPC server: %v", err)
+	}
+}
+
+//
+// makePeerClientCert generates a client certificate, signed by our authority,
+// which we present when connecting to peer controllers.
+//
+func makePeerClientCert() (*tls.Certificate, error) {
+	name := ca.CertificateName{
+		Name:    controllerID,
+		Purpose: ca.CertificatePurposeController,
+	}
+	_, cert64, key64, err := authority.GenerateCertificate(name)
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(cert64)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := base64.StdEncoding.DecodeString(key64)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// runPeerClient maintains a connection to a single peer controller, reconnecting
+// as needed.  It returns only if the peer turns out to be this controller.
+func runPeerClient(address string, clientCert *ca.RenewableCertificate) {
+	for {
+		// The trusted CAs may change while we run, so pick them up
+		// again on each connection.
+		certPool, err := authority.MakeCertPool()
+		if err != nil {
+			log.Fatalf("While making certpool: %v", err)
+		}
+		creds := credentials.NewTLS(&tls.Config{
+			GetClientCertificate: clientCert.GetClientCertificate,
+			RootCAs:              certPool,
+			MinVersion:           tls.VersionTLS13,
+		})
+
+		err = runPeerSession(address, creds)
+		if status.Code(err) == codes.FailedPrecondition {
+			log.Printf("Peer %s: %v, not reconnecting", address, err)
+			return
+		}
+		log.Printf("Peer %s: connection ended: %v", address, err)
+		time.Sleep(peerRetryInterval)
+	}
+}
+
+//
+// peerForward is a request from a peer which one of our agents is
+// handling.  Messages for the agent are queued, and delivered in order by
+// their own goroutine, so an agent which is slow to take them holds up
+// only its own requests and not the peer's receive loop.
+//
+type peerForward struct {
+	ep       agent.Search
+	done     chan struct{}
+	finished chan struct{}
+
+	sync.Mutex
+	pending []interface{}
+	wake    chan struct{}
+}
+
+func newPeerForward(ep agent.Search) *peerForward {
+	return &peerForward{
+		ep:       ep,
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// push queues a message for the agent.  It never blocks.
+func (fwd *peerForward) push(message interface{}) {
+	fwd.Lock()
+	fwd.pending = append(fwd.pending, message)
+	fwd.Unlock()
+	select {
+	case fwd.wake <- struct{}{}:
+	default:
+	}
+}
+
+// peerClientSession holds the state for one connection to a peer controller,
+// where we are forwarding requests from that peer to our directly connected agents.
+type peerClientSession struct {
+	sync.Mutex
+	address     string
+	ctx         context.Context
+	dataflow    chan *tunnel.PeerToControllerWrapper
+	outstanding map[string]*peerForward
+}
+
+func (p *peerClientSession) send(msg *tunnel.PeerToControllerWrapper) {
+	select {
+	case p.dataflow <- msg:
+	case <-p.ctx.Done():
+	}
+}
+
+func makePeerAgentList() *tunnel.PeerToControllerWrapper {
+	direct := agents.GetDirectlyConnectedAgents()
+	list := make([]*tunnel.PeerAgentInfo, len(direct))
+	for i, a := range direct {
+		list[i] = &tunnel.PeerAgentInfo{
+			Name:        a.Name,
+			Session:     a.Session,
+			Endpoints:   endpointsToPB(a.Endpoints),
+			Version:     a.Version,
+			Hostname:    a.Hostname,
+			ConnectedAt: a.ConnectedAt,
+
+			StreamsRequestBodies: a.StreamsRequestBodies,
+			TunnelsUpgrades:      a.TunnelsUpgrades,
+			PacesCommandInput:    a.PacesCommandInput,
+		}
+	}
+	return &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_AgentList{
+			AgentList: &tunnel.PeerAgentList{Agents: list},
+		},
+	}
+}
+
+// makePeerRevocationList sends our revoked certificates to a peer, so
+// a certificate revoked on any controller is refused by all of them.
+func makePeerRevocationList() *tunnel.PeerToControllerWrapper {
+	revoked := authority.RevokedCertificates()
+	list := make([]*tunnel.PeerRevokedCertificate, len(revoked))
+	for i, item := range revoked {
+		list[i] = &tunnel.PeerRevokedCertificate{
+			Serial:    item.Serial,
+			RevokedAt: uint64(item.RevokedAt.UnixNano() / 1000000),
+		}
+		if !item.ExpiresAt.IsZero() {
+			list[i].ExpiresAt = uint64(item.ExpiresAt.UnixNano() / 1000000)
+		}
+		if item.Name != nil {
+			if name, err := json.Marshal(item.Name); err == nil {
+				list[i].Name = string(name)
+			}
+		}
+	}
+	return &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_Revocations{
+			Revocations: &tunnel.PeerRevocationList{Certificates: list},
+		},
+	}
+}
+
+func revocationsFromPB(list *tunnel.PeerRevocationList) []ca.RevokedCertificate {
+	ret := make([]ca.RevokedCertificate, 0, len(list.Certificates))
+	for _, item := range list.Certificates {
+		revoked := ca.RevokedCertificate{
+			Serial:    item.Serial,
+			RevokedAt: time.Unix(0, int64(item.RevokedAt)*1000000).UTC(),
+		}
+		if item.ExpiresAt > 0 {
+			revoked.ExpiresAt = time.Unix(0, int64(item.ExpiresAt)*1000000).UTC()
+		}
+		if len(item.Name) > 0 {
+			var name ca.CertificateName
+			if err := json.Unmarshal([]byte(item.Name), &name); err == nil {
+				revoked.Name = &name
+			}
+		}
+		ret = append(ret, revoked)
+	}
+	return ret
+}
+
+func endpointsToPB(endpoints []agent.Endpoint) []*tunnel.EndpointHealth {
+	ret := make([]*tunnel.EndpointHealth, len(endpoints))
+	for i, ep := range endpoints {
+		ret[i] = &tunnel.EndpointHealth{
+			Name:       ep.Name,
+			Type:       ep.Type,
+			Configured: ep.Configured,
+			Namespaces: ep.Namespaces,
+		}
+	}
+	return ret
+}
+
+func makeRequestClosed(id string) *tunnel.PeerToControllerWrapper {
+	return &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_RequestClosed{
+			RequestClosed: &tunnel.CancelRequest{Id: id},
+		},
+	}
+}
+
+// isFinalAgentMessage returns true if no further messages will arrive for this request.
+func isFinalAgentMessage(in *tunnel.AgentToControllerWrapper) bool {
+	switch in.Event.(type) {
+	case *tunnel.AgentToControllerWrapper_HttpResponse:
+		return in.GetHttpResponse().ContentLength == 0
+	case *tunnel.AgentToControllerWrapper_HttpChunkedResponse:
+		return len(in.GetHttpChunkedResponse().Body) == 0
+	case *tunnel.AgentToControllerWrapper_CommandTermination:
+		return true
+	case *tunnel.AgentToControllerWrapper_ConnectionOpened:
+		return len(in.GetConnectionOpened().Error) > 0
+	case *tunnel.AgentToControllerWrapper_ConnectionData:
+		return in.GetConnectionData().Closed
+	}
+	return false
+}
+
+func (p *peerClientSession) forwardResponses(id string, fwd *peerForward, out chan *tunnel.AgentToControllerWrapper) {
+	defer func() {
+		p.Lock()
+		delete(p.outstanding, id)
+		p.Unlock()
+		close(fwd.finished)
+	}()
+	for {
+		select {
+		case in, more := <-out:
+			if !more {
+				p.send(makeRequestClosed(id))
+				return
+			}
+			p.send(&tunnel.PeerToControllerWrapper{
+				Event: &tunnel.PeerToControllerWrapper_AgentMessage{
+					AgentMessage: in,
+				},
+			})
+			if isFinalAgentMessage(in) {
+				return
+			}
+		case <-fwd.done:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *peerClientSession) startRequest(id string, ep agent.Search, message interface{}, out chan *tunnel.AgentToControllerWrapper) {
+	fwd := newPeerForward(ep)
+	fwd.push(message)
+	p.Lock()
+	p.outstanding[id] = fwd
+	p.Unlock()
+	go p.deliver(id, fwd, out)
+}
+
+//
+// deliver sends the queued messages for a request to the agent, starting
+// with the request itself, until the request finishes or is cancelled.
+// Responses are forwarded to the peer once the agent has the request.
+//
+func (p *peerClientSession) deliver(id string, fwd *peerForward, out chan *tunnel.AgentToControllerWrapper) {
+	started := false
+	for {
+		fwd.Lock()
+		pending := fwd.pending
+		fwd.pending = nil
+		fwd.Unlock()
+
+		for _, message := range pending {
+			if _, found := agents.Send(fwd.ep, message); found {
+				if !started {
+					started = true
+					go p.forwardResponses(id, fwd, out)
+				}
+				continue
+			}
+			if !started {
+				p.Lock()
+				delete(p.outstanding, id)
+				p.Unlock()
+				p.send(makeRequestClosed(id))
+				return
+			}
+			log.Printf("Peer %s: agent for request %s went away", p.address, id)
+		}
+
+		select {
+		case <-fwd.wake:
+		case <-fwd.finished:
+			return
+		case <-fwd.done:
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *peerClientSession) cancelRequest(id string) {
+	p.Lock()
+	fwd, found := p.outstanding[id]
+	delete(p.outstanding, id)
+	p.Unlock()
+	if !found {
+		return
+	}
+	close(fwd.done)
+	if err := agents.Cancel(fwd.ep, id); err != nil {
+		log.Printf("while cancelling request for peer %s: %v", p.address, err)
+	}
+}
+
+// sendToRequest queues stdin data, window updates, or other updates for the
+// agent handling the request.  kind is used for the unknown ID metric.
+func (p *peerClientSession) sendToRequest(id string, kind string, message interface{}) {
+	p.Lock()
+	fwd, found := p.outstanding[id]
+	p.Unlock()
+	if !found {
+		log.Printf("Peer %s sent update for unknown request %s", p.address, id)
+		unknownIDDropsCounter.WithLabelValues(kind).Inc()
+		return
+	}
+	fwd.push(message)
+}
+
+func (p *peerClientSession) cancelAll() {
+	p.Lock()
+	outstanding := p.outstanding
+	p.outstanding = map[string]*peerForward{}
+	p.Unlock()
+	for id, fwd := range outstanding {
+		close(fwd.done)
+		if err := agents.Cancel(fwd.ep, id); err != nil {
+			log.Printf("while cancelling request for peer %s: %v", p.address, err)
+		}
+	}
+}
+
+func (p *peerClientSession) handleAgentRequest(req *tunnel.PeerAgentRequest) {
+	ep := agent.Search{
+		Name:    req.AgentName,
+		Session: req.AgentSession,
+	}
+	if req.Message == nil {
+		return
+	}
+	switch x := req.Message.Event.(type) {
+	case *tunnel.ControllerToAgentWrapper_HttpRequest:
+		cmd := req.Message.GetHttpRequest()
+		ep.EndpointType = cmd.Type
+		ep.EndpointName = cmd.Name
+		out := make(chan *tunnel.AgentToControllerWrapper)
+		p.startRequest(cmd.Id, ep, &HTTPMessage{Out: out, Cmd: cmd}, out)
+	case *tunnel.ControllerToAgentWrapper_CommandRequest:
+		cmd := req.Message.GetCommandRequest()
+		ep.EndpointType = "remote-command"
+		ep.EndpointName = cmd.Name
+		out := make(chan *tunnel.AgentToControllerWrapper)
+		p.startRequest(cmd.Id, ep, &runCmdMessage{out: out, cmd: cmd}, out)
+	case *tunnel.ControllerToAgentWrapper_OpenConnection:
+		open := req.Message.GetOpenConnection()
+		ep.EndpointType = open.Type
+		ep.EndpointName = open.Name
+		out := make(chan *tunnel.AgentToControllerWrapper)
+		p.startRequest(open.Id, ep, &openConnectionMessage{out: out, req: open}, out)
+	case *tunnel.ControllerToAgentWrapper_CancelRequest:
+		p.cancelRequest(req.Message.GetCancelRequest().Id)
+	case *tunnel.ControllerToAgentWrapper_CommandData:
+		data := req.Message.GetCommandData()
+		p.sendToRequest(data.Id, audit.KindCommand, &cmdDataMessage{data: data})
+	case *tunnel.ControllerToAgentWrapper_CommandResize:
+		resize := req.Message.GetCommandResize()
+		p.sendToRequest(resize.Id, audit.KindCommand, &cmdResizeMessage{resize: resize})
+	case *tunnel.ControllerToAgentWrapper_HttpRequestBody:
+		body := req.Message.GetHttpRequestBody()
+		p.sendToRequest(body.Id, audit.KindHTTP, &requestBodyMessage{body: body})
+	case *tunnel.ControllerToAgentWrapper_ConnectionData:
+		data := req.Message.GetConnectionData()
+		p.sendToRequest(data.Id, "connection", &connectionDataMessage{data: data})
+	case *tunnel.ControllerToAgentWrapper_WindowUpdate:
+		update := req.Message.GetWindowUpdate()
+		p.sendToRequest(update.Id, "window", &windowUpdateMessage{update: update})
+	default:
+		log.Printf("Peer %s sent unsupported agent request: %T", p.address, x)
+	}
+}
+
+func runPeerSession(address string, creds credentials.TransportCredentials) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := tunnel.NewPeerTunnelServiceClient(conn)
+	stream, err := client.EventTunnel(ctx)
+	if err != nil {
+		return err
+	}
+
+	p := &peerClientSession{
+		address:     address,
+		ctx:         ctx,
+		dataflow:    make(chan *tunnel.PeerToControllerWrapper, 20),
+		outstanding: map[string]*peerForward{},
+	}
+	defer p.cancelAll()
+
+	notify := agents.Subscribe()
+	defer agents.Unsubscribe(notify)
+	revoked := authority.SubscribeRevocations()
+	defer authority.UnsubscribeRevocations(revoked)
+
+	hello := &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_PeerHello{
+			PeerHello: &tunnel.PeerHello{
+				ControllerId: controllerID,
+				Version:      version.String(),
+			},
+		},
+	}
+	if err := stream.Send(hello); err != nil {
+		return err
+	}
+	if err := stream.Send(makePeerAgentList()); err != nil {
+		return err
+	}
+	if err := stream.Send(makePeerRevocationList()); err != nil {
+		return err
+	}
+
+	log.Printf("Connected to peer controller %s", address)
+
+	// All sends happen on this goroutine, as a stream may not be
+	// written to concurrently.
+	go func() {
+		for {
+			select {
+			case msg := <-p.dataflow:
+				if err := stream.Send(msg); err != nil {
+					log.Printf("Unable to send to peer %s: %v", address, err)
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-notify:
+				p.send(makePeerAgentList())
+			case <-revoked:
+				p.send(makePeerRevocationList())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch x := in.Event.(type) {
+		case *tunnel.ControllerToPeerWrapper_AgentRequest:
+			p.handleAgentRequest(in.GetAgentRequest())
+		case nil:
+			// ignore for now
+		default:
+			log.Printf("Received unknown message from peer %s: %T", address, x)
+		}
+	}
+}
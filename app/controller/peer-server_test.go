@@ -0,0 +1,413 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakePeerStream is the server side of a peer controller's tunnel.
+type fakePeerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	in  chan *tunnel.PeerToControllerWrapper
+	out chan *tunnel.ControllerToPeerWrapper
+
+	sync.Mutex
+	sendErr error
+}
+
+func newFakePeerStream(t *testing.T, name string) *fakePeerStream {
+	return &fakePeerStream{
+		ctx: certificateContext(t, ca.CertificateName{Name: name, Purpose: ca.CertificatePurposeController}),
+		in:  make(chan *tunnel.PeerToControllerWrapper),
+		out: make(chan *tunnel.ControllerToPeerWrapper, 10),
+	}
+}
+
+func (s *fakePeerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakePeerStream) Send(msg *tunnel.ControllerToPeerWrapper) error {
+	s.Lock()
+	err := s.sendErr
+	s.Unlock()
+	if err != nil {
+		return err
+	}
+	s.out <- msg
+	return nil
+}
+
+func (s *fakePeerStream) Recv() (*tunnel.PeerToControllerWrapper, error) {
+	msg, more := <-s.in
+	if !more {
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+func (s *fakePeerStream) failSends(err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.sendErr = err
+}
+
+// connect runs the peer's tunnel, and advertises one agent with a
+// "jenkins" endpoint.  The tunnel's result is sent on the returned channel.
+func (s *fakePeerStream) connect(agentName string, session string) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- newPeerServer().EventTunnel(s)
+	}()
+	s.in <- &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_PeerHello{
+			PeerHello: &tunnel.PeerHello{ControllerId: "other-controller", Version: "test"},
+		},
+	}
+	s.in <- &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_AgentList{
+			AgentList: &tunnel.PeerAgentList{
+				Agents: []*tunnel.PeerAgentInfo{
+					{
+						Name:    agentName,
+						Session: session,
+						Endpoints: []*tunnel.EndpointHealth{
+							{Type: "jenkins", Name: "j1", Configured: true},
+						},
+					},
+				},
+			},
+		},
+	}
+	return result
+}
+
+func peerSearch(agentName string) agent.Search {
+	return agent.Search{Name: agentName, EndpointType: "jenkins", EndpointName: "j1"}
+}
+
+func isRegistered(agentName string) bool {
+	for _, stats := range agents.GetStatistics().([]interface{}) {
+		if s, ok := stats.(*agent.PeerConnectedAgentStatistics); ok && s.Name == agentName {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPeerTunnel_forwardsRequests(t *testing.T) {
+	stream := newFakePeerStream(t, "peer-forward")
+	result := stream.connect("peer-agent-1", "session-1")
+	eventually(t, "the peer's agent to be registered", func() bool { return isRegistered("peer-agent-1") })
+
+	message := &HTTPMessage{
+		Out: make(chan *tunnel.AgentToControllerWrapper),
+		Cmd: &tunnel.HttpRequest{Id: "peer-request-1", Type: "jenkins", Name: "j1", Method: "GET", URI: "/"},
+	}
+	session, found := agents.Send(peerSearch("peer-agent-1"), message)
+	if !found || session != "session-1" {
+		t.Fatalf("Send() = %s, %v; want session-1, true", session, found)
+	}
+
+	sent := <-stream.out
+	request := sent.GetAgentRequest()
+	if request == nil || request.AgentName != "peer-agent-1" || request.AgentSession != "session-1" {
+		t.Fatalf("sent %v, want a request for peer-agent-1", sent)
+	}
+	if id := request.Message.GetHttpRequest().GetId(); id != "peer-request-1" {
+		t.Errorf("forwarded request ID = %s, want peer-request-1", id)
+	}
+
+	// The peer relays the agent's response back to the waiting request.
+	stream.in <- &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_AgentMessage{
+			AgentMessage: &tunnel.AgentToControllerWrapper{
+				Event: &tunnel.AgentToControllerWrapper_HttpResponse{
+					HttpResponse: &tunnel.HttpResponse{Id: "peer-request-1", Status: 204},
+				},
+			},
+		},
+	}
+	resp, more := nextResponse(t, message.Out)
+	if !more || resp.GetHttpResponse().GetStatus() != 204 {
+		t.Fatalf("response = %v, want 204", resp)
+	}
+	if _, more := nextResponse(t, message.Out); more {
+		t.Errorf("response channel still open after an empty response")
+	}
+
+	// When the peer disconnects, its agents are removed.
+	close(stream.in)
+	if err := <-result; err != nil {
+		t.Errorf("EventTunnel() = %v, want nil on EOF", err)
+	}
+	if isRegistered("peer-agent-1") {
+		t.Errorf("peer-agent-1 still registered after the peer disconnected")
+	}
+	if _, found := agents.Send(peerSearch("peer-agent-1"), message); found {
+		t.Errorf("Send() found an agent after the peer disconnected")
+	}
+}
+
+func TestPeerTunnel_sendFailure(t *testing.T) {
+	stream := newFakePeerStream(t, "peer-failing")
+	result := stream.connect("peer-agent-2", "session-2")
+	eventually(t, "the peer's agent to be registered", func() bool { return isRegistered("peer-agent-2") })
+
+	sendErr := errors.New("stream broken")
+	stream.failSends(sendErr)
+	message := &HTTPMessage{
+		Out: make(chan *tunnel.AgentToControllerWrapper),
+		Cmd: &tunnel.HttpRequest{Id: "peer-request-2", Type: "jenkins", Name: "j1", Method: "GET", URI: "/"},
+	}
+	agents.Send(peerSearch("peer-agent-2"), message)
+
+	select {
+	case err := <-result:
+		if err != sendErr {
+			t.Errorf("EventTunnel() = %v, want %v", err, sendErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("EventTunnel() did not return after a failed send")
+	}
+	if isRegistered("peer-agent-2") {
+		t.Errorf("peer-agent-2 still registered after a failed send")
+	}
+
+	// The request which could not be sent is ended, not left waiting.
+	if _, more := nextResponse(t, message.Out); more {
+		t.Errorf("response channel still open after the peer went away")
+	}
+	close(stream.in)
+}
+
+func TestPeerTunnel_mergesRevocations(t *testing.T) {
+	certPEM, keyPEM, err := ca.MakeCertificateAuthority(ca.KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := authority
+	defer func() { authority = saved }()
+	if authority, err = ca.MakeCAFromData(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := newFakePeerStream(t, "peer-revoking")
+	result := stream.connect("peer-agent-3", "session-3")
+	revokedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	stream.in <- &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_Revocations{
+			Revocations: &tunnel.PeerRevocationList{
+				Certificates: []*tunnel.PeerRevokedCertificate{
+					{Serial: "1234", Name: `{"agent":"smith","purpose":"agent"}`, RevokedAt: uint64(revokedAt.UnixNano() / 1000000), ExpiresAt: uint64(expiresAt.UnixNano() / 1000000)},
+				},
+			},
+		},
+	}
+	eventually(t, "the peer's revocation to be merged", func() bool { return authority.IsRevoked(big.NewInt(1234)) })
+
+	// What we send on to our own peers matches what we were sent.
+	sent := revocationsFromPB(makePeerRevocationList().GetRevocations())
+	if len(sent) != 1 {
+		t.Fatalf("sending %d revocations, want 1", len(sent))
+	}
+	if sent[0].Serial != "1234" || !sent[0].RevokedAt.Equal(revokedAt) || !sent[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("sending %#v", sent[0])
+	}
+	if sent[0].Name == nil || sent[0].Name.Agent != "smith" {
+		t.Errorf("sending name %#v, want agent smith", sent[0].Name)
+	}
+
+	close(stream.in)
+	if err := <-result; err != nil {
+		t.Errorf("EventTunnel() = %v", err)
+	}
+}
+
+func TestPeerTunnel_rejectsSelf(t *testing.T) {
+	stream := newFakePeerStream(t, "peer-self")
+	result := make(chan error, 1)
+	go func() {
+		result <- newPeerServer().EventTunnel(stream)
+	}()
+	stream.in <- &tunnel.PeerToControllerWrapper{
+		Event: &tunnel.PeerToControllerWrapper_PeerHello{
+			PeerHello: &tunnel.PeerHello{ControllerId: controllerID},
+		},
+	}
+	if err := <-result; err == nil {
+		t.Errorf("EventTunnel() = nil, want an error when connected to self")
+	}
+	close(stream.in)
+}
+
+func TestPeerTunnel_requiresControllerCertificate(t *testing.T) {
+	stream := newFakePeerStream(t, "peer-agent-cert")
+	stream.ctx = certificateContext(t, ca.CertificateName{Agent: "a1", Purpose: ca.CertificatePurposeAgent})
+	if err := newPeerServer().EventTunnel(stream); err == nil {
+		t.Errorf("EventTunnel() = nil, want an error for an agent certificate")
+	}
+}
+
+func TestIsFinalAgentMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *tunnel.AgentToControllerWrapper
+		want bool
+	}{
+		{"empty response", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_HttpResponse{HttpResponse: &tunnel.HttpResponse{}}}, true},
+		{"response with body", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_HttpResponse{HttpResponse: &tunnel.HttpResponse{ContentLength: -1}}}, false},
+		{"body chunk", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_HttpChunkedResponse{HttpChunkedResponse: &tunnel.HttpChunkedResponse{Body: []byte("x")}}}, false},
+		{"last body chunk", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_HttpChunkedResponse{HttpChunkedResponse: &tunnel.HttpChunkedResponse{}}}, true},
+		{"command termination", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_CommandTermination{CommandTermination: &tunnel.CommandTermination{}}}, true},
+		{"connection refused", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_ConnectionOpened{ConnectionOpened: &tunnel.ConnectionOpened{Error: "refused"}}}, true},
+		{"connection opened", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_ConnectionOpened{ConnectionOpened: &tunnel.ConnectionOpened{}}}, false},
+		{"connection closed", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_ConnectionData{ConnectionData: &tunnel.ConnectionData{Closed: true}}}, true},
+		{"window update", &tunnel.AgentToControllerWrapper{Event: &tunnel.AgentToControllerWrapper_WindowUpdate{WindowUpdate: &tunnel.WindowUpdate{}}}, false},
+	}
+	for _, tt := range tests {
+		if got := isFinalAgentMessage(tt.in); got != tt.want {
+			t.Errorf("%s: isFinalAgentMessage() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPeerTunnel_rejectsMessagesBeforeHello(t *testing.T) {
+	tests := []struct {
+		name string
+		in   *tunnel.PeerToControllerWrapper
+	}{
+		{"agent list", &tunnel.PeerToControllerWrapper{Event: &tunnel.PeerToControllerWrapper_AgentList{AgentList: &tunnel.PeerAgentList{}}}},
+		{"revocations", &tunnel.PeerToControllerWrapper{Event: &tunnel.PeerToControllerWrapper_Revocations{Revocations: &tunnel.PeerRevocationList{}}}},
+		{"agent message", &tunnel.PeerToControllerWrapper{Event: &tunnel.PeerToControllerWrapper_AgentMessage{AgentMessage: &tunnel.AgentToControllerWrapper{}}}},
+		{"request closed", &tunnel.PeerToControllerWrapper{Event: &tunnel.PeerToControllerWrapper_RequestClosed{RequestClosed: &tunnel.CancelRequest{Id: "x"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newFakePeerStream(t, "peer-no-hello")
+			result := make(chan error, 1)
+			go func() {
+				result <- newPeerServer().EventTunnel(stream)
+			}()
+			stream.in <- tt.in
+			select {
+			case err := <-result:
+				if status.Code(err) != codes.FailedPrecondition {
+					t.Errorf("EventTunnel() = %v, want FailedPrecondition", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("EventTunnel() accepted a message before hello")
+			}
+			close(stream.in)
+		})
+	}
+}
+
+func makePeerAgentRequest(agentName string, message *tunnel.ControllerToAgentWrapper) *tunnel.PeerAgentRequest {
+	return &tunnel.PeerAgentRequest{AgentName: agentName, AgentSession: "session", Message: message}
+}
+
+func makePeerHTTPRequest(id string) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_HttpRequest{
+			HttpRequest: &tunnel.HttpRequest{Id: id, Type: "jenkins", Name: "j1", Method: "GET", URI: "/"},
+		},
+	}
+}
+
+func makePeerWindowUpdate(id string, n int64) *tunnel.ControllerToAgentWrapper {
+	return &tunnel.ControllerToAgentWrapper{
+		Event: &tunnel.ControllerToAgentWrapper_WindowUpdate{
+			WindowUpdate: &tunnel.WindowUpdate{Id: id, Bytes: n},
+		},
+	}
+}
+
+func TestPeerClientSession_stalledAgent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &peerClientSession{
+		address:     "peer",
+		ctx:         ctx,
+		dataflow:    make(chan *tunnel.PeerToControllerWrapper, 20),
+		outstanding: map[string]*peerForward{},
+	}
+
+	// This agent never takes its messages.
+	stalled := &agent.DirectlyConnectedAgent{
+		Name:      "stalled-agent",
+		Session:   "session",
+		Endpoints: []agent.Endpoint{{Type: "jenkins", Name: "j1", Configured: true}},
+		InRequest: make(chan interface{}),
+		Closed:    make(chan struct{}),
+	}
+	agents.AddAgent(stalled)
+	defer agents.RemoveAgent(stalled)
+	live, _ := flowAgent(t, "live-agent")
+
+	handled := make(chan struct{})
+	go func() {
+		p.handleAgentRequest(makePeerAgentRequest("stalled-agent", makePeerHTTPRequest("stalled-request")))
+		p.handleAgentRequest(makePeerAgentRequest("stalled-agent", makePeerWindowUpdate("stalled-request", 10)))
+		p.handleAgentRequest(makePeerAgentRequest("live-agent", makePeerHTTPRequest("live-request")))
+		for i := int64(1); i <= 3; i++ {
+			p.handleAgentRequest(makePeerAgentRequest("live-agent", makePeerWindowUpdate("live-request", i)))
+		}
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("a stalled agent held up requests for other agents")
+	}
+
+	// The other agent gets its request and updates, in order.
+	next := func() interface{} {
+		select {
+		case m := <-live.InRequest:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatalf("live-agent did not get its messages")
+			return nil
+		}
+	}
+	if msg, ok := next().(*HTTPMessage); !ok || msg.Cmd.Id != "live-request" {
+		t.Fatalf("got %v, want the request first", msg)
+	}
+	for i := int64(1); i <= 3; i++ {
+		msg, ok := next().(*windowUpdateMessage)
+		if !ok || msg.update.Bytes != i {
+			t.Fatalf("got %v, want window update %d", msg, i)
+		}
+	}
+}
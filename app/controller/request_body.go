@@ -0,0 +1,93 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// requestBodyChunkSize is the most sent in one HttpRequestBody message.
+const requestBodyChunkSize = 32 * 1024
+
+var errAgentGone = errors.New("agent session went away")
+
+// requestBodyMessage carries part of a streamed request body to the agent.
+type requestBodyMessage struct {
+	body *tunnel.HttpRequestBody
+}
+
+//
+// streamRequestBody sends body to the agent session in ep as the agent
+// grants credit in window, ending with an empty chunk.  count is called
+// with the size of each chunk sent.  If reading the body fails, the error
+// is returned and the body is not terminated, so the request should be
+// cancelled.
+//
+func streamRequestBody(ctx context.Context, ep agent.Search, id string, body io.Reader, window *tunnel.SendWindow, count func(int)) error {
+	return streamToAgent(ctx, body, window, count, func(data []byte, eof bool) bool {
+		if eof {
+			return sendRequestBody(ep, id, []byte{})
+		}
+		return sendRequestBody(ep, id, data)
+	})
+}
+
+//
+// streamToAgent reads from r as window allows, calling send with each
+// chunk and then once with eof set.  send returns false if the agent
+// has gone away.
+//
+func streamToAgent(ctx context.Context, r io.Reader, window *tunnel.SendWindow, count func(int), send func(data []byte, eof bool) bool) error {
+	for {
+		available, err := window.Wait(ctx)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, requestBodyChunkSize)
+		if available < int64(len(buf)) {
+			buf = buf[:available]
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			window.Consume(n)
+			count(n)
+			if !send(buf[:n], false) {
+				return errAgentGone
+			}
+		}
+		if err == io.EOF {
+			send(nil, true)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func sendRequestBody(ep agent.Search, id string, body []byte) bool {
+	message := &requestBodyMessage{
+		body: &tunnel.HttpRequestBody{Id: id, Body: body},
+	}
+	_, found := agents.Send(ep, message)
+	return found
+}
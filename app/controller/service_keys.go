@@ -0,0 +1,130 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	serviceAuthPath = "/app/secrets/serviceAuth"
+
+	// serviceKeyCheckInterval is how often the key directory and config
+	// file are checked for changes.  Kubernetes takes up to a minute to
+	// update mounted secrets and config maps, so faster is not useful.
+	serviceKeyCheckInterval = 30 * time.Second
+)
+
+// readServiceKeys loads every key in the directory, named by its filename.
+func readServiceKeys(dir string) (map[string]jwk.Key, error) {
+	keys := map[string]jwk.Key{}
+	err := filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Type().IsRegular() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := jwk.New(content)
+		if err != nil {
+			return err
+		}
+		err = key.Set(jwk.KeyIDKey, info.Name())
+		if err != nil {
+			return err
+		}
+		err = key.Set(jwk.AlgorithmKey, jwa.HS256)
+		if err != nil {
+			return err
+		}
+		keys[info.Name()] = key
+		return nil
+	})
+	return keys, err
+}
+
+func loadServiceKeys() {
+	if config.ServiceAuth.CurrentKeyName == "" {
+		log.Fatalf("No primary serviceAuth key name provided")
+	}
+
+	keys, err := readServiceKeys(serviceAuthPath)
+	if err != nil {
+		log.Fatalf("cannot load key serviceAuth keys: %v", err)
+	}
+
+	err = serviceKeys.Update(keys, config.ServiceAuth.CurrentKeyName, config.ServiceAuth.keyOverlap(), time.Now())
+	if err != nil {
+		log.Fatalf("cannot use serviceAuth keys: %v", err)
+	}
+
+	log.Printf("Loaded %d serviceKeys", len(keys))
+}
+
+//
+// reloadServiceKeys reads the key directory, and the current key name and
+// overlap from the config file, and swaps them in.  Errors are logged, and
+// the keys in use are kept.
+//
+func reloadServiceKeys() {
+	f, err := os.Open(*configFile)
+	if err != nil {
+		log.Printf("Service keys not reloaded: %v", err)
+		return
+	}
+	c, err := loadServiceAuthConfig(f)
+	f.Close()
+	if err != nil {
+		log.Printf("Service keys not reloaded: %v", err)
+		return
+	}
+	if c.CurrentKeyName == "" {
+		log.Printf("Service keys not reloaded: no primary serviceAuth key name provided")
+		return
+	}
+
+	keys, err := readServiceKeys(serviceAuthPath)
+	if err != nil {
+		log.Printf("Service keys not reloaded: %v", err)
+		return
+	}
+
+	err = serviceKeys.Update(keys, c.CurrentKeyName, c.keyOverlap(), time.Now())
+	if err != nil {
+		log.Printf("Service keys not reloaded: %v", err)
+	}
+}
+
+func watchServiceKeys() {
+	t := time.NewTicker(serviceKeyCheckInterval)
+	defer t.Stop()
+	for range t.C {
+		reloadServiceKeys()
+	}
+}
@@ -17,34 +17,30 @@ package main
  */
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
 	"github.com/opsmx/oes-birger/pkg/ca"
 	"github.com/opsmx/oes-birger/pkg/jwtutil"
+	"github.com/opsmx/oes-birger/pkg/tracing"
 	"github.com/opsmx/oes-birger/pkg/tunnel"
 	"github.com/opsmx/oes-birger/pkg/util"
 	"github.com/tevino/abool"
 )
 
-func runHTTPSServer(serverCert tls.Certificate) {
+func runHTTPSServer(serverCert *ca.RenewableCertificate) {
 	log.Printf("Running service HTTPS listener on port %d", config.ServiceListenPort)
 
-	certPool, err := authority.MakeCertPool()
-	if err != nil {
-		log.Fatalf("While making certpool: %v", err)
-	}
-
-	tlsConfig := &tls.Config{
-		ClientCAs:    certPool,
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: []tls.Certificate{serverCert},
-		MinVersion:   tls.VersionTLS12,
-	}
+	tlsConfig := authority.ServerTLSConfig(serverCert,
+		tls.VerifyClientCertIfGiven, tls.VersionTLS12, []string{"h2", "http/1.1"})
 
 	mux := http.NewServeMux()
 
@@ -53,76 +49,107 @@ func runHTTPSServer(serverCert tls.Certificate) {
 	server := &http.Server{
 		Addr:      fmt.Sprintf(":%d", config.ServiceListenPort),
 		TLSConfig: tlsConfig,
-		Handler:   mux,
+		Handler:   forwardProxyHandler(mux),
 	}
 
 	log.Fatal(server.ListenAndServeTLS("", ""))
 }
 
-func extractEndpointFromCert(r *http.Request) (agentIdentity string, endpointType string, endpointName string, validated bool) {
+func extractEndpointFromCert(r *http.Request) (ep agent.Search, identity audit.Identity, validated bool) {
 	if len(r.TLS.PeerCertificates) == 0 {
-		return "", "", "", false
+		return agent.Search{}, audit.Identity{}, false
 	}
 
 	names, err := ca.GetCertificateNameFromCert(r.TLS.PeerCertificates[0])
 	if err != nil {
 		log.Printf("%v", err)
-		return "", "", "", false
+		return agent.Search{}, audit.Identity{}, false
 	}
 
 	if names.Purpose != ca.CertificatePurposeService {
-		return "", "", "", false
+		return agent.Search{}, audit.Identity{}, false
 	}
 
-	return names.Agent, names.Type, names.Name, true
+	ep = agent.Search{
+		Name:         names.Agent,
+		EndpointType: names.Type,
+		EndpointName: names.Name,
+	}
+	return ep, audit.CertificateIdentity(names), true
 }
 
-func extractEndpointFromJWT(r *http.Request) (agentIdentity string, endpointType string, endpointName string, validated bool) {
+func extractEndpointFromJWT(r *http.Request) (ep agent.Search, identity audit.Identity, validated bool) {
 	authPassword := r.Header.Get("X-Opsmx-Token")
 	r.Header.Del("X-Opsmx-Token")
 
 	if authPassword == "" {
 		var ok bool
 		if _, authPassword, ok = r.BasicAuth(); !ok {
-			return "", "", "", false
+			return agent.Search{}, audit.Identity{}, false
 		}
 	}
 
-	endpointType, endpointName, agentIdentity, err := jwtutil.ValidateJWT(jwtKeyset, authPassword)
+	return validateServiceToken(r, authPassword)
+}
+
+//
+// validateServiceToken returns the endpoint a service token is for, if it
+// is valid, has not been revoked, and its restrictions permit the request.
+// Legacy tokens, without an expiry or ID, are refused if the config
+// requires expiring tokens.
+//
+func validateServiceToken(r *http.Request, token string) (ep agent.Search, identity audit.Identity, validated bool) {
+	claims, err := jwtutil.ValidateServiceJWT(serviceKeys.Keyset(), token)
 	if err != nil {
 		log.Printf("%v", err)
-		return "", "", "", false
+		return agent.Search{}, audit.Identity{}, false
 	}
 
-	return agentIdentity, endpointType, endpointName, true
+	if claims.IsLegacy() && config.ServiceAuth.RequireExpiringTokens {
+		log.Printf("service token for %s/%s on agent %s has no expiry or ID, and serviceAuth.requireExpiringTokens is set",
+			claims.Type, claims.Name, claims.Agent)
+		return agent.Search{}, audit.Identity{}, false
+	}
+
+	if len(claims.ID) > 0 && jwtDenylist.IsRevoked(claims.ID) {
+		log.Printf("service token ID %s has been revoked", claims.ID)
+		return agent.Search{}, audit.Identity{}, false
+	}
+
+	if err := claims.Allows(r); err != nil {
+		log.Printf("service token ID %s: %v", claims.ID, err)
+		return agent.Search{}, audit.Identity{}, false
+	}
+
+	ep = agent.Search{
+		Name:         claims.Agent,
+		EndpointType: claims.Type,
+		EndpointName: claims.Name,
+	}
+	return ep, audit.TokenClaimsIdentity(claims), true
 }
 
-func extractEndpoint(r *http.Request) (agentIdentity string, endpointType string, endpointName string, err error) {
-	agentIdentity, endpointType, endpointName, found := extractEndpointFromCert(r)
+func extractEndpoint(r *http.Request) (agent.Search, audit.Identity, error) {
+	ep, identity, found := extractEndpointFromCert(r)
 	if found {
-		return agentIdentity, endpointType, endpointName, nil
+		return ep, identity, nil
 	}
 
-	agentIdentity, endpointType, endpointName, found = extractEndpointFromJWT(r)
+	ep, identity, found = extractEndpointFromJWT(r)
 	if found {
-		return agentIdentity, endpointType, endpointName, nil
+		return ep, identity, nil
 	}
 
-	return "", "", "", fmt.Errorf("no valid credentials or JWT found")
+	return agent.Search{}, audit.Identity{}, fmt.Errorf("no valid credentials or JWT found")
 }
 
 func serviceAPIHandler(w http.ResponseWriter, r *http.Request) {
-	agentIdentity, endpointType, endpointName, err := extractEndpoint(r)
+	ep, identity, err := extractEndpoint(r)
 	if err != nil {
 		util.FailRequest(w, err, http.StatusBadRequest)
 		return
 	}
-	ep := agent.Search{
-		Name:         agentIdentity,
-		EndpointType: endpointType,
-		EndpointName: endpointName,
-	}
-	runAPIHandler(ep, w, r)
+	runAPIHandler(ep, identity, w, r)
 }
 
 func copyHeaders(resp *tunnel.HttpResponse, w http.ResponseWriter) {
@@ -136,9 +163,10 @@ func copyHeaders(resp *tunnel.HttpResponse, w http.ResponseWriter) {
 	}
 }
 
-func handleDone(n <-chan struct{}, cc *abool.AtomicBool, target agent.Search, id string) {
+func handleDone(n <-chan struct{}, cc *abool.AtomicBool, target agent.Search, id string, metrics *requestMetrics) {
 	<-n
 	if cc.IsNotSet() {
+		metrics.cancelled()
 		err := agents.Cancel(target, id)
 		if err != nil {
 			log.Printf("while cancelling http request: %v", err)
@@ -146,41 +174,141 @@ func handleDone(n <-chan struct{}, cc *abool.AtomicBool, target agent.Search, id
 	}
 }
 
-func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
+func runAPIHandler(ep agent.Search, identity audit.Identity, w http.ResponseWriter, r *http.Request) {
 	apiRequestCounter.WithLabelValues(ep.Name).Inc()
 
 	transactionID := ulidContext.Ulid()
 
-	body, _ := ioutil.ReadAll(r.Body)
+	// Bodies are streamed to agents which accept them, and otherwise
+	// read here and sent with the request.
+	streamBody := r.ContentLength != 0 && agents.CanStreamRequestBodies(ep)
+	var body []byte
+	if !streamBody {
+		body, _ = ioutil.ReadAll(r.Body)
+	}
+	var streamedBytes int64
+
+	// Upgraded connections are tunnelled only if the client's connection
+	// can be taken over, and every agent it may be sent to handles them.
+	upgrade := isUpgradeRequest(r)
+	if upgrade {
+		if _, ok := w.(http.Hijacker); !ok || !agents.CanTunnelUpgrades(ep) {
+			upgrade = false
+			removeUpgrade(r.Header)
+		}
+	}
+
+	record := &audit.Record{
+		Time:          time.Now().UTC(),
+		Kind:          audit.KindHTTP,
+		TransactionID: transactionID,
+		Identity:      identity,
+		Agent:         ep.Name,
+		EndpointType:  ep.EndpointType,
+		EndpointName:  ep.EndpointName,
+		Method:        r.Method,
+		URI:           r.RequestURI,
+		RequestBytes:  int64(len(body)),
+	}
+	metrics := startRequestMetrics(ep.Name, ep.EndpointType, ep.EndpointName)
+	metrics.addRequestBytes(len(body))
+	ctx, span := tracing.Start(tracing.ExtractHTTP(r.Context(), r.Header), "runAPIHandler", tracing.SpanKindServer,
+		tracing.String("agent", ep.Name),
+		tracing.String("endpoint.type", ep.EndpointType),
+		tracing.String("endpoint.name", ep.EndpointName),
+		tracing.String("transaction.id", transactionID),
+		tracing.String("http.method", r.Method),
+		tracing.String("http.target", r.RequestURI))
+	defer func() {
+		record.RequestBytes += atomic.LoadInt64(&streamedBytes)
+		record.Finish(time.Now())
+		auditLog.Log(record)
+		metrics.finish(util.HTTPStatusClass(record.Status))
+		span.SetAttributes(tracing.Int("http.status_code", record.Status))
+		if len(record.Error) > 0 {
+			span.SetError(record.Error)
+		}
+		span.End()
+	}()
+
 	req := &tunnel.HttpRequest{
-		Id:      transactionID,
-		Type:    ep.EndpointType,
-		Name:    ep.EndpointName,
-		Method:  r.Method,
-		URI:     r.RequestURI,
-		Headers: makeHeaders(r.Header),
-		Body:    body,
+		Id:           transactionID,
+		Type:         ep.EndpointType,
+		Name:         ep.EndpointName,
+		Method:       r.Method,
+		URI:          r.RequestURI,
+		Headers:      makeHeaders(r.Header),
+		Body:         body,
+		TraceContext: tracing.Inject(ctx),
+		Window:       streamWindowSize,
+	}
+	if streamBody {
+		req.BodyStreamed = true
+		req.ContentLength = r.ContentLength
+		ep.StreamedRequestBody = true
+	}
+	if upgrade {
+		req.Upgrade = true
+		ep.Upgrade = true
 	}
 	message := &HTTPMessage{Out: make(chan *tunnel.AgentToControllerWrapper), Cmd: req}
 	sessionID, found := agents.Send(ep, message)
 	if !found {
+		record.Status = http.StatusBadGateway
+		record.Error = "no agent session found"
 		w.WriteHeader(http.StatusBadGateway)
 		return
 	}
 	ep.Session = sessionID
+	record.Session = sessionID
+	window := newReceiveWindow(transactionID)
+	window.start(ep)
+
+	countRequestBytes := func(n int) {
+		atomic.AddInt64(&streamedBytes, int64(n))
+		metrics.addRequestBytes(n)
+	}
+
+	var bodyWindow *tunnel.SendWindow
+	bodyFailed := make(chan error, 1)
+	if streamBody {
+		bodyWindow = tunnel.NewSendWindow(tunnel.RequestBodyWindow)
+		bodyCtx, stopBody := context.WithCancel(r.Context())
+		go func() {
+			err := streamRequestBody(bodyCtx, ep, transactionID, r.Body, bodyWindow, countRequestBytes)
+			if err != nil && bodyCtx.Err() == nil {
+				bodyFailed <- err
+			}
+		}()
+		defer stopBody()
+	}
 
 	cleanClose := abool.New()
 	notify := r.Context().Done()
-	go handleDone(notify, cleanClose, ep, transactionID)
+	go handleDone(notify, cleanClose, ep, transactionID, metrics)
 
 	seenHeader := false
 	isChunked := false
 	flusher := w.(http.Flusher)
 	for {
-		in, more := <-message.Out
+		var in *tunnel.AgentToControllerWrapper
+		var more bool
+		select {
+		case in, more = <-message.Out:
+		case err := <-bodyFailed:
+			log.Printf("Unable to send request body for %s: %v", transactionID, err)
+			record.Error = fmt.Sprintf("unable to send request body: %v", err)
+			if !seenHeader {
+				record.Status = http.StatusBadRequest
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		}
 		if !more {
 			if !seenHeader {
 				log.Printf("Request timed out sending to agent")
+				record.Status = http.StatusBadGateway
+				record.Error = "request timed out sending to agent"
 				w.WriteHeader(http.StatusBadGateway)
 			}
 			cleanClose.Set()
@@ -192,6 +320,13 @@ func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
 			resp := in.GetHttpResponse()
 			seenHeader = true
 			isChunked = resp.ContentLength < 0
+			record.Status = int(resp.Status)
+			if upgrade && resp.Status == http.StatusSwitchingProtocols {
+				if tunnelUpgradedConnection(ep, transactionID, w, resp, message.Out, window, record, metrics, countRequestBytes) {
+					cleanClose.Set()
+				}
+				return
+			}
 			copyHeaders(resp, w)
 			w.WriteHeader(int(resp.Status))
 			if resp.ContentLength == 0 {
@@ -202,6 +337,8 @@ func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
 			resp := in.GetHttpChunkedResponse()
 			if !seenHeader {
 				log.Printf("Error: got ChunkedResponse before HttpResponse")
+				record.Status = http.StatusBadGateway
+				record.Error = "response body received before headers"
 				w.WriteHeader(http.StatusBadGateway)
 				return
 			}
@@ -210,8 +347,11 @@ func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			n, err := w.Write(resp.Body)
+			record.ResponseBytes += int64(n)
+			metrics.addResponseBytes(n)
 			if err != nil {
 				log.Printf("Error: cannot write: %v", err)
+				record.Error = err.Error()
 				if !seenHeader {
 					w.WriteHeader(http.StatusBadGateway)
 				}
@@ -219,6 +359,7 @@ func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
 			}
 			if n != len(resp.Body) {
 				log.Printf("Error: did not write full message: %d of %d written", n, len(resp.Body))
+				record.Error = "short write to client"
 				if !seenHeader {
 					w.WriteHeader(http.StatusBadGateway)
 				}
@@ -227,6 +368,9 @@ func runAPIHandler(ep agent.Search, w http.ResponseWriter, r *http.Request) {
 			if isChunked {
 				flusher.Flush()
 			}
+			window.consume(n)
+		case *tunnel.AgentToControllerWrapper_WindowUpdate:
+			bodyWindow.Grant(in.GetWindowUpdate().Bytes)
 		case nil:
 			// ignore for now
 		default:
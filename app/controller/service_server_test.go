@@ -0,0 +1,148 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
+)
+
+func makeServiceKey(t *testing.T, name string, content string) jwk.Key {
+	key, err := jwk.New([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Set(jwk.KeyIDKey, name); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Set(jwk.AlgorithmKey, jwa.HS256); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// useServiceKeys replaces the service keys and token denylist for the
+// duration of the test.
+func useServiceKeys(t *testing.T, key jwk.Key) *jwtutil.Denylist {
+	savedKeys, savedDenylist := serviceKeys, jwtDenylist
+	t.Cleanup(func() { serviceKeys, jwtDenylist = savedKeys, savedDenylist })
+
+	serviceKeys = jwtutil.NewKeyring()
+	if err := serviceKeys.Update(map[string]jwk.Key{"key1": key}, "key1", time.Hour, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	denylist, err := jwtutil.LoadDenylist("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtDenylist = denylist
+	return denylist
+}
+
+func TestValidateServiceToken(t *testing.T) {
+	key := makeServiceKey(t, "key1", "this is a key")
+	otherKey := makeServiceKey(t, "key1", "this is another key")
+	denylist := useServiceKeys(t, key)
+	if err := denylist.Revoke("revoked-id", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	savedConfig := config
+	defer func() { config = savedConfig }()
+
+	now := time.Now()
+	claims := func(modify func(*jwtutil.ServiceClaims)) jwtutil.ServiceClaims {
+		c := jwtutil.ServiceClaims{
+			Type:     "jenkins",
+			Name:     "j1",
+			Agent:    "smith",
+			ID:       "token-id",
+			IssuedAt: now,
+			Expiry:   now.Add(time.Hour),
+		}
+		if modify != nil {
+			modify(&c)
+		}
+		return c
+	}
+
+	legacy := jwtutil.ServiceClaims{Type: "jenkins", Name: "j1", Agent: "smith"}
+
+	tests := []struct {
+		name            string
+		key             jwk.Key
+		claims          jwtutil.ServiceClaims
+		method          string
+		path            string
+		requireExpiring bool
+		want            bool
+	}{
+		{"valid", key, claims(nil), "GET", "/job/x", false, true},
+		{"legacy token without expiry", key, legacy, "GET", "/", false, true},
+		{"wrong key", otherKey, claims(nil), "GET", "/", false, false},
+		{"expired", key, claims(func(c *jwtutil.ServiceClaims) {
+			c.IssuedAt = now.Add(-2 * time.Hour)
+			c.Expiry = now.Add(-time.Hour)
+		}), "GET", "/", false, false},
+		{"revoked", key, claims(func(c *jwtutil.ServiceClaims) { c.ID = "revoked-id" }), "GET", "/", false, false},
+		{"method allowed", key, claims(func(c *jwtutil.ServiceClaims) { c.Methods = []string{"GET"} }), "GET", "/", false, true},
+		{"method not allowed", key, claims(func(c *jwtutil.ServiceClaims) { c.Methods = []string{"GET"} }), "POST", "/", false, false},
+		{"path allowed", key, claims(func(c *jwtutil.ServiceClaims) { c.PathPrefix = "/job" }), "GET", "/job/x", false, true},
+		{"path not allowed", key, claims(func(c *jwtutil.ServiceClaims) { c.PathPrefix = "/job" }), "GET", "/script", false, false},
+		{"expiring token required", key, claims(nil), "GET", "/", true, true},
+		{"legacy token refused", key, legacy, "GET", "/", true, false},
+		{"token without ID refused", key, claims(func(c *jwtutil.ServiceClaims) { c.ID = "" }), "GET", "/", true, false},
+		{"token without expiry refused", key, claims(func(c *jwtutil.ServiceClaims) { c.Expiry = time.Time{} }), "GET", "/", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = &ControllerConfig{ServiceAuth: serviceAuthConfig{RequireExpiringTokens: tt.requireExpiring}}
+			token, err := jwtutil.MakeServiceJWT(tt.key, &tt.claims)
+			if err != nil {
+				t.Fatalf("MakeServiceJWT() = %v", err)
+			}
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			ep, identity, ok := validateServiceToken(r, token)
+			if ok != tt.want {
+				t.Fatalf("validateServiceToken() = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			want := agent.Search{Name: "smith", EndpointType: "jenkins", EndpointName: "j1"}
+			if ep != want {
+				t.Errorf("endpoint = %v, want %v", ep, want)
+			}
+			if identity.Token == nil || identity.Token.ID != tt.claims.ID {
+				t.Errorf("identity = %#v, want token ID %q", identity, tt.claims.ID)
+			}
+		})
+	}
+}
+
+func TestValidateServiceToken_malformed(t *testing.T) {
+	useServiceKeys(t, makeServiceKey(t, "key1", "this is a key"))
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, _, ok := validateServiceToken(r, "not.a.token"); ok {
+		t.Errorf("validateServiceToken() accepted a malformed token")
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tracing"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// tcpEndpointType is the agent endpoint type which forwards TCP connections.
+const tcpEndpointType = "tcp"
+
+// tlsHandshakeTimeout limits how long a client of a TLS forwarder may
+// take to present its certificate.
+const tlsHandshakeTimeout = 30 * time.Second
+
+// openConnectionMessage asks the agent to open a TCP connection.
+type openConnectionMessage struct {
+	out chan *tunnel.AgentToControllerWrapper
+	req *tunnel.OpenConnection
+}
+
+func runTCPForwarders(serverCert *ca.RenewableCertificate) {
+	for _, fwd := range config.TCPForwards {
+		go runTCPForwarder(fwd, serverCert)
+	}
+}
+
+func runTCPForwarder(fwd tcpForwardConfig, serverCert *ca.RenewableCertificate) {
+	log.Printf("Running TCP forwarder on %s for %s", fwd.ListenAddress, fwd)
+
+	var listener net.Listener
+	var err error
+	if fwd.RequireClientCertificate {
+		tlsConfig := authority.ServerTLSConfig(serverCert,
+			tls.RequireAndVerifyClientCert, tls.VersionTLS12, nil)
+		listener, err = tls.Listen("tcp", fwd.ListenAddress, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", fwd.ListenAddress)
+	}
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", fwd.ListenAddress, err)
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("Accepting on %s: %v", fwd.ListenAddress, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go forwardConnection(fwd, conn)
+	}
+}
+
+//
+// authenticateConnection returns the identity of the client.  Clients
+// of a TLS forwarder must present a service certificate for the
+// forwarder's endpoint.
+//
+func authenticateConnection(fwd tcpForwardConfig, conn net.Conn) (audit.Identity, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return audit.AddressIdentity(conn.RemoteAddr().String()), nil
+	}
+
+	conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return audit.Identity{}, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return audit.Identity{}, fmt.Errorf("no client certificate")
+	}
+	names, err := ca.GetCertificateNameFromCert(certs[0])
+	if err != nil {
+		return audit.Identity{}, err
+	}
+	if names.Purpose != ca.CertificatePurposeService ||
+		names.Agent != fwd.Agent ||
+		names.Type != tcpEndpointType ||
+		names.Name != fwd.EndpointName {
+		return audit.Identity{}, fmt.Errorf("certificate is not for %s", fwd)
+	}
+	return audit.CertificateIdentity(names), nil
+}
+
+//
+// connectionOpenedFunc is called once the agent has answered a request to
+// open a connection.  If err is set, it tells the client the connection
+// failed.  Otherwise, it returns the client's side of the connection.
+//
+type connectionOpenedFunc func(err error) (io.Writer, io.Reader, error)
+
+// forwardConnection forwards a connection accepted by a TCP forwarder.
+func forwardConnection(fwd tcpForwardConfig, conn net.Conn) {
+	defer conn.Close()
+
+	identity, err := authenticateConnection(fwd, conn)
+	if err != nil {
+		log.Printf("Refused connection from %s to %s: %v", conn.RemoteAddr(), fwd.ListenAddress, err)
+		return
+	}
+
+	ep := agent.Search{
+		Name:         fwd.Agent,
+		EndpointType: tcpEndpointType,
+		EndpointName: fwd.EndpointName,
+	}
+	tunnelConnection(context.Background(), ep, identity, "", fwd.Address, func(err error) (io.Writer, io.Reader, error) {
+		return conn, conn, err
+	})
+}
+
+//
+// tunnelConnection asks the agent to open a connection to address, and
+// then copies bytes between it and the client until either end closes.
+// method is recorded in the audit log, and is empty for TCP forwarders.
+//
+func tunnelConnection(parent context.Context, ep agent.Search, identity audit.Identity, method string, address string, opened connectionOpenedFunc) {
+	transactionID := ulidContext.Ulid()
+	apiRequestCounter.WithLabelValues(ep.Name).Inc()
+
+	var requestBytes int64
+	record := &audit.Record{
+		Time:          time.Now().UTC(),
+		Kind:          audit.KindTCP,
+		TransactionID: transactionID,
+		Identity:      identity,
+		Agent:         ep.Name,
+		EndpointType:  ep.EndpointType,
+		EndpointName:  ep.EndpointName,
+		Method:        method,
+		Address:       address,
+	}
+	metrics := startRequestMetrics(ep.Name, ep.EndpointType, ep.EndpointName)
+	ctx, span := tracing.Start(parent, "tunnelConnection", tracing.SpanKindServer,
+		tracing.String("agent", ep.Name),
+		tracing.String("endpoint.type", ep.EndpointType),
+		tracing.String("endpoint.name", ep.EndpointName),
+		tracing.String("transaction.id", transactionID),
+		tracing.String("net.peer.name", address))
+	statusClass := connectionFailed
+	defer func() {
+		record.RequestBytes = atomic.LoadInt64(&requestBytes)
+		record.Finish(time.Now())
+		auditLog.Log(record)
+		metrics.finish(statusClass)
+		if len(record.Error) > 0 {
+			span.SetError(record.Error)
+		}
+		span.End()
+	}()
+	fail := func(message string) {
+		record.Error = message
+		opened(fmt.Errorf("%s", message))
+	}
+
+	out := make(chan *tunnel.AgentToControllerWrapper)
+	req := &tunnel.OpenConnection{
+		Id:           transactionID,
+		Type:         ep.EndpointType,
+		Name:         ep.EndpointName,
+		Address:      address,
+		TraceContext: tracing.Inject(ctx),
+		Window:       streamWindowSize,
+	}
+	sessionID, found := agents.Send(ep, &openConnectionMessage{out: out, req: req})
+	if !found {
+		fail("no agent session found")
+		return
+	}
+	ep.Session = sessionID
+	record.Session = sessionID
+	window := newReceiveWindow(transactionID)
+	window.start(ep)
+
+	in, more := <-out
+	if !more {
+		fail("agent went away before connecting")
+		return
+	}
+	connection := in.GetConnectionOpened()
+	if connection == nil {
+		log.Printf("Error: got %T before ConnectionOpened", in.Event)
+		fail("connection data received before the connection was opened")
+		cancelConnection(ep, transactionID)
+		return
+	}
+	if len(connection.Error) > 0 {
+		log.Printf("Agent could not open connection %s: %s", transactionID, connection.Error)
+		fail(connection.Error)
+		return
+	}
+
+	w, r, err := opened(nil)
+	if err != nil {
+		log.Printf("Unable to take over connection for %s: %v", transactionID, err)
+		record.Error = err.Error()
+		cancelConnection(ep, transactionID)
+		return
+	}
+	countRequestBytes := func(n int) {
+		atomic.AddInt64(&requestBytes, int64(n))
+		metrics.addRequestBytes(n)
+	}
+	if !pipeConnection(ep, transactionID, w, r, out, window, record, metrics, countRequestBytes) {
+		cancelConnection(ep, transactionID)
+		return
+	}
+	statusClass = connectionClosed
+}
+
+func cancelConnection(ep agent.Search, id string) {
+	if err := agents.Cancel(ep, id); err != nil {
+		log.Printf("while cancelling connection: %v", err)
+	}
+}
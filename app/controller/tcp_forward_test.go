@@ -0,0 +1,253 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+func TestTCPForwardConfig_validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		listenAddress string
+		requireCert   bool
+		wantErr       bool
+	}{
+		{"loopback", "127.0.0.1:15432", false, false},
+		{"ipv6 loopback", "[::1]:15432", false, false},
+		{"localhost", "localhost:15432", false, false},
+		{"all interfaces", ":15432", false, true},
+		{"all interfaces with certificates", ":15432", true, false},
+		{"other address", "10.1.2.3:15432", false, true},
+		{"other host", "db.example.com:15432", false, true},
+		{"other address with certificates", "10.1.2.3:15432", true, false},
+		{"no port", "127.0.0.1", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &tcpForwardConfig{
+				ListenAddress:            tt.listenAddress,
+				Agent:                    "smith",
+				EndpointName:             "db",
+				RequireClientCertificate: tt.requireCert,
+			}
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+//
+// runFakeTCPAgent registers an agent with a "tcp" endpoint.  It refuses
+// connections with refusal if that is set, and otherwise echoes what the
+// client sends.  The addresses it is asked to connect to are sent on the
+// returned channel.
+//
+func runFakeTCPAgent(t *testing.T, name string, refusal string) chan string {
+	state := &agent.DirectlyConnectedAgent{
+		Name:      name,
+		Session:   "session",
+		Endpoints: []agent.Endpoint{{Type: tcpEndpointType, Name: "db", Configured: true}},
+		InRequest: make(chan interface{}, 10),
+		Closed:    make(chan struct{}),
+	}
+	agents.AddAgent(state)
+	t.Cleanup(func() { agents.RemoveAgent(state) })
+
+	addresses := make(chan string, 1)
+	go func() {
+		var out chan *tunnel.AgentToControllerWrapper
+		var id string
+		send := func(msg *tunnel.AgentToControllerWrapper) {
+			select {
+			case out <- msg:
+			case <-state.Closed:
+			}
+		}
+		for {
+			var m interface{}
+			select {
+			case m = <-state.InRequest:
+			case <-state.Closed:
+				return
+			}
+			switch msg := m.(type) {
+			case *openConnectionMessage:
+				addresses <- msg.req.Address
+				out, id = msg.out, msg.req.Id
+				send(&tunnel.AgentToControllerWrapper{
+					Event: &tunnel.AgentToControllerWrapper_ConnectionOpened{
+						ConnectionOpened: &tunnel.ConnectionOpened{Id: id, Error: refusal},
+					},
+				})
+			case *connectionDataMessage:
+				send(&tunnel.AgentToControllerWrapper{
+					Event: &tunnel.AgentToControllerWrapper_ConnectionData{
+						ConnectionData: &tunnel.ConnectionData{Id: id, Data: msg.data.Data, Closed: msg.data.Closed},
+					},
+				})
+			}
+		}
+	}()
+	return addresses
+}
+
+// forwardTestConnection runs forwardConnection, and returns the client's
+// end of the connection.
+func forwardTestConnection(fwd tcpForwardConfig) (net.Conn, chan struct{}) {
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		forwardConnection(fwd, server)
+		close(done)
+	}()
+	return client, done
+}
+
+func waitForwarded(t *testing.T, done chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("forwardConnection() did not return")
+	}
+}
+
+func TestForwardConnection(t *testing.T) {
+	addresses := runFakeTCPAgent(t, "tcp-agent", "")
+	fwd := tcpForwardConfig{ListenAddress: "127.0.0.1:0", Agent: "tcp-agent", EndpointName: "db", Address: "db:5432"}
+	client, done := forwardTestConnection(fwd)
+	defer client.Close()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	echoed := make([]byte, 5)
+	if _, err := io.ReadFull(client, echoed); err != nil || string(echoed) != "hello" {
+		t.Fatalf("read %q, %v, want hello", echoed, err)
+	}
+	if address := <-addresses; address != "db:5432" {
+		t.Errorf("agent asked to connect to %q, want db:5432", address)
+	}
+
+	// When the client closes, so does the agent, and the forward ends.
+	client.Close()
+	waitForwarded(t, done)
+}
+
+func TestForwardConnection_refused(t *testing.T) {
+	runFakeTCPAgent(t, "refusing-agent", "address is not allowed")
+	fwd := tcpForwardConfig{ListenAddress: "127.0.0.1:0", Agent: "refusing-agent", EndpointName: "db", Address: "other:22"}
+	client, done := forwardTestConnection(fwd)
+	defer client.Close()
+
+	if data, err := ioutil.ReadAll(client); err != nil || len(data) != 0 {
+		t.Errorf("read %q, %v, want the connection closed", data, err)
+	}
+	waitForwarded(t, done)
+}
+
+func TestForwardConnection_noAgent(t *testing.T) {
+	fwd := tcpForwardConfig{ListenAddress: "127.0.0.1:0", Agent: "no-such-agent", EndpointName: "db"}
+	client, done := forwardTestConnection(fwd)
+	defer client.Close()
+
+	if data, err := ioutil.ReadAll(client); err != nil || len(data) != 0 {
+		t.Errorf("read %q, %v, want the connection closed", data, err)
+	}
+	waitForwarded(t, done)
+}
+
+// clientCertificate returns a certificate for name, issued by authority.
+func clientCertificate(t *testing.T, name ca.CertificateName) tls.Certificate {
+	_, cert64, key64, err := authority.GenerateCertificate(name)
+	if err != nil {
+		t.Fatalf("GenerateCertificate() = %v", err)
+	}
+	certPEM, _ := base64.StdEncoding.DecodeString(cert64)
+	keyPEM, _ := base64.StdEncoding.DecodeString(key64)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair() = %v", err)
+	}
+	return cert
+}
+
+func TestAuthenticateConnection(t *testing.T) {
+	useFileCA(t)
+	serverCert, err := ca.NewRenewableCertificate(func() (*tls.Certificate, error) {
+		return authority.MakeServerCert([]string{"controller.local"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := authority.MakeCertPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fwd := tcpForwardConfig{Agent: "smith", EndpointName: "db", RequireClientCertificate: true}
+	service := ca.CertificateName{Purpose: ca.CertificatePurposeService, Agent: "smith", Type: tcpEndpointType, Name: "db"}
+
+	tests := []struct {
+		name    string
+		modify  func(*ca.CertificateName)
+		wantErr bool
+	}{
+		{"service certificate for the endpoint", nil, false},
+		{"other agent", func(n *ca.CertificateName) { n.Agent = "other" }, true},
+		{"other endpoint", func(n *ca.CertificateName) { n.Name = "other" }, true},
+		{"other endpoint type", func(n *ca.CertificateName) { n.Type = "jenkins" }, true},
+		{"agent certificate", func(n *ca.CertificateName) { n.Purpose = ca.CertificatePurposeAgent }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := service
+			if tt.modify != nil {
+				tt.modify(&name)
+			}
+			clientConfig := &tls.Config{
+				Certificates: []tls.Certificate{clientCertificate(t, name)},
+				RootCAs:      roots,
+				ServerName:   "controller.local",
+			}
+
+			client, server := net.Pipe()
+			defer client.Close()
+			go tls.Client(client, clientConfig).Handshake()
+
+			serverConfig := authority.ServerTLSConfig(serverCert, tls.RequireAndVerifyClientCert, tls.VersionTLS12, nil)
+			identity, err := authenticateConnection(fwd, tls.Server(server, serverConfig))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authenticateConnection() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && identity.Certificate == nil {
+				t.Errorf("identity = %#v, want the certificate's names", identity)
+			}
+		})
+	}
+}
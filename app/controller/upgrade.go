@@ -0,0 +1,93 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+// isUpgradeRequest returns true if the client asks to switch protocols,
+// as kubectl does for exec, attach and port-forward.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//
+// removeUpgrade turns an upgrade request into an ordinary one, for when
+// the connection cannot be tunnelled.  The endpoint will then answer
+// without switching protocols.
+//
+func removeUpgrade(header http.Header) {
+	header.Del("Upgrade")
+	header.Del("Connection")
+}
+
+//
+// tunnelUpgradedConnection takes over the client's connection once the
+// endpoint has switched protocols, and copies bytes between it and the
+// agent until either end closes.  It returns false if the connection
+// failed, so the agent should be told to cancel the request.
+//
+func tunnelUpgradedConnection(ep agent.Search, id string, w http.ResponseWriter, resp *tunnel.HttpResponse, in <-chan *tunnel.AgentToControllerWrapper, window *receiveWindow, record *audit.Record, metrics *requestMetrics, countRequest func(int)) bool {
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Printf("Unable to take over connection for %s: %v", id, err)
+		record.Error = err.Error()
+		return false
+	}
+	defer conn.Close()
+
+	switched := &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+	}
+	for _, header := range resp.Headers {
+		for _, value := range header.Values {
+			switched.Header.Add(header.Name, value)
+		}
+	}
+	if err = switched.Write(brw); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		log.Printf("Unable to switch protocols for %s: %v", id, err)
+		record.Error = err.Error()
+		return false
+	}
+
+	// Anything the client sent after its request may already be buffered,
+	// so reads come from brw.
+	return pipeConnection(ep, id, conn, brw.Reader, in, window, record, metrics, countRequest)
+}
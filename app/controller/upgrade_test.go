@@ -0,0 +1,174 @@
+package main
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opsmx/oes-birger/app/controller/agent"
+	"github.com/opsmx/oes-birger/pkg/audit"
+	"github.com/opsmx/oes-birger/pkg/tunnel"
+)
+
+//
+// runFakeUpgradeAgent registers an agent which switches protocols for
+// upgrade requests and echoes what the client sends, and answers other
+// requests with an empty 200.  The requests it receives are sent on the
+// returned channel.
+//
+func runFakeUpgradeAgent(t *testing.T, name string, tunnelsUpgrades bool) (agent.Search, chan *tunnel.HttpRequest) {
+	state := &agent.DirectlyConnectedAgent{
+		Name:            name,
+		Session:         "session",
+		Endpoints:       []agent.Endpoint{{Type: "kubernetes", Name: "k1", Configured: true}},
+		TunnelsUpgrades: tunnelsUpgrades,
+		InRequest:       make(chan interface{}, 10),
+		Closed:          make(chan struct{}),
+	}
+	agents.AddAgent(state)
+	t.Cleanup(func() { agents.RemoveAgent(state) })
+
+	requests := make(chan *tunnel.HttpRequest, 1)
+	go func() {
+		var out chan *tunnel.AgentToControllerWrapper
+		var id string
+		send := func(msg *tunnel.AgentToControllerWrapper) {
+			select {
+			case out <- msg:
+			case <-state.Closed:
+			}
+		}
+		for {
+			var m interface{}
+			select {
+			case m = <-state.InRequest:
+			case <-state.Closed:
+				return
+			}
+			switch msg := m.(type) {
+			case *HTTPMessage:
+				requests <- msg.Cmd
+				out, id = msg.Out, msg.Cmd.Id
+				resp := &tunnel.HttpResponse{Id: id, Status: http.StatusOK}
+				if msg.Cmd.Upgrade {
+					resp.Status = http.StatusSwitchingProtocols
+					resp.ContentLength = -1
+					resp.Headers = []*tunnel.HttpHeader{
+						{Name: "Connection", Values: []string{"Upgrade"}},
+						{Name: "Upgrade", Values: []string{"echo"}},
+					}
+				}
+				send(&tunnel.AgentToControllerWrapper{
+					Event: &tunnel.AgentToControllerWrapper_HttpResponse{HttpResponse: resp},
+				})
+			case *connectionDataMessage:
+				send(&tunnel.AgentToControllerWrapper{
+					Event: &tunnel.AgentToControllerWrapper_ConnectionData{
+						ConnectionData: &tunnel.ConnectionData{Id: id, Data: msg.data.Data, Closed: msg.data.Closed},
+					},
+				})
+			}
+		}
+	}()
+	return agent.Search{Name: name, EndpointType: "kubernetes", EndpointName: "k1"}, requests
+}
+
+func apiServer(ep agent.Search) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runAPIHandler(ep, audit.Identity{}, w, r)
+	}))
+}
+
+func hasHeader(headers []*tunnel.HttpHeader, name string) bool {
+	for _, header := range headers {
+		if http.CanonicalHeaderKey(header.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunAPIHandler_upgrade(t *testing.T) {
+	ep, requests := runFakeUpgradeAgent(t, "upgrade-agent", true)
+	server := apiServer(ep)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /exec HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() = %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "echo" {
+		t.Fatalf("response = %d %v, want 101 switching to echo", resp.StatusCode, resp.Header)
+	}
+	if req := <-requests; !req.Upgrade || !hasHeader(req.Headers, "Upgrade") {
+		t.Errorf("request = %v, want an upgrade", req)
+	}
+
+	// Bytes now flow both ways through the agent.
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	echoed := make([]byte, 5)
+	if _, err := io.ReadFull(r, echoed); err != nil || string(echoed) != "hello" {
+		t.Fatalf("read %q, %v, want hello", echoed, err)
+	}
+
+	// When the client closes, the agent's close ends the connection.
+	conn.(*net.TCPConn).CloseWrite()
+	if rest, err := ioutil.ReadAll(r); err != nil || len(rest) != 0 {
+		t.Errorf("after close read %q, %v, want EOF", rest, err)
+	}
+}
+
+func TestRunAPIHandler_upgradeNotSupported(t *testing.T) {
+	ep, requests := runFakeUpgradeAgent(t, "old-agent", false)
+	server := apiServer(ep)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/exec", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want the endpoint's answer without an upgrade", resp.StatusCode)
+	}
+
+	// The request is sent on as an ordinary one.
+	sent := <-requests
+	if sent.Upgrade || hasHeader(sent.Headers, "Upgrade") || hasHeader(sent.Headers, "Connection") {
+		t.Errorf("request = %v, want the upgrade removed", sent)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/opsmx/oes-birger/pkg/fwdapi"
@@ -35,7 +36,14 @@ var (
 	endpointName  = flag.String("name", "", "Item name")
 	agentIdentity = flag.String("agent", "", "agent name")
 	endpointType  = flag.String("type", "", "endpoint type")
-	action        = flag.String("action", "", "action, one of: agent, kubectl, agent-manifest, remote-command, control")
+	action        = flag.String("action", "", "action, one of: agent, kubectl, agent-manifest, remote-command, control, revoke, revoke-service-token, revoked-service-tokens")
+	serialNumber  = flag.String("serial", "", "certificate serial number to revoke")
+	tokenID       = flag.String("tokenId", "", "service token ID to revoke")
+	lifetime      = flag.Int64("lifetime", 0, "certificate or service token lifetime in seconds, if shorter than the controller's default")
+	keyType       = flag.String("keyType", "", "certificate key type, one of rsa, ecdsa, ed25519, if not the controller's default")
+	keyBits       = flag.Int("keyBits", 0, "certificate RSA key size or ECDSA curve size, used with keyType")
+	methods       = flag.String("methods", "", "comma separated HTTP methods the service token may be used with")
+	pathPrefix    = flag.String("pathPrefix", "", "URI path prefix the service token is limited to")
 )
 
 func usage(message string) {
@@ -49,9 +57,20 @@ func usage(message string) {
 	fmt.Fprintf(os.Stderr, "  'remote-command' requires: agent, endpointName.\n")
 	fmt.Fprintf(os.Stderr, "  'agent-manifest' requires: agent.\n")
 	fmt.Fprintf(os.Stderr, "  'control' requires no other options.\n")
+	fmt.Fprintf(os.Stderr, "  'revoke' requires: serial.\n")
+	fmt.Fprintf(os.Stderr, "  'revoke-service-token' requires: tokenId.\n")
+	fmt.Fprintf(os.Stderr, "  'revoked-service-tokens' requires no other options.\n")
 	os.Exit(-1)
 }
 
+func certificateOptions() fwdapi.CertificateOptions {
+	return fwdapi.CertificateOptions{
+		KeyType:         *keyType,
+		KeyBits:         *keyBits,
+		LifetimeSeconds: *lifetime,
+	}
+}
+
 func makeClient() *resty.Client {
 	client := resty.New()
 	client.SetRootCertificate(*caCertFile)
@@ -65,8 +84,9 @@ func makeClient() *resty.Client {
 
 func getKubeconfigCreds() {
 	request := fwdapi.KubeConfigRequest{
-		AgentName: *agentIdentity,
-		Name:      *endpointName,
+		AgentName:          *agentIdentity,
+		Name:               *endpointName,
+		CertificateOptions: certificateOptions(),
 	}
 	client := makeClient()
 	resp, err := client.R().
@@ -84,7 +104,8 @@ func getKubeconfigCreds() {
 
 func getAgentManifest() {
 	request := fwdapi.ManifestRequest{
-		AgentName: *agentIdentity,
+		AgentName:          *agentIdentity,
+		CertificateOptions: certificateOptions(),
 	}
 	client := makeClient()
 	resp, err := client.R().
@@ -100,11 +121,36 @@ func getAgentManifest() {
 	fmt.Printf("%s\n", string(resp.Body()))
 }
 
+func getRemoteCommand() {
+	request := fwdapi.RemoteCommandRequest{
+		AgentName:          *agentIdentity,
+		Name:               *endpointName,
+		CertificateOptions: certificateOptions(),
+	}
+	client := makeClient()
+	resp, err := client.R().
+		EnableTrace().
+		SetBody(request).
+		Post(fmt.Sprintf("%s%s", *url, fwdapi.CommandEndpoint))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	if resp.StatusCode() != 200 {
+		log.Fatalf("Request failed: %s", resp.Status())
+	}
+	fmt.Printf("%s\n", string(resp.Body()))
+}
+
 func getService() {
 	request := fwdapi.ServiceCredentialRequest{
-		AgentName: *agentIdentity,
-		Type:      *endpointType,
-		Name:      *endpointName,
+		AgentName:       *agentIdentity,
+		Type:            *endpointType,
+		Name:            *endpointName,
+		LifetimeSeconds: *lifetime,
+		PathPrefix:      *pathPrefix,
+	}
+	if len(*methods) > 0 {
+		request.Methods = strings.Split(*methods, ",")
 	}
 	client := makeClient()
 	resp, err := client.R().
@@ -122,7 +168,8 @@ func getService() {
 
 func getControl() {
 	request := fwdapi.ControlCredentialsRequest{
-		Name: *endpointName,
+		Name:               *endpointName,
+		CertificateOptions: certificateOptions(),
 	}
 	client := makeClient()
 	resp, err := client.R().
@@ -152,6 +199,56 @@ func getStatistics() {
 	fmt.Printf("%s\n", string(resp.Body()))
 }
 
+func revokeCertificate() {
+	request := fwdapi.RevokeCertificateRequest{
+		SerialNumber: *serialNumber,
+	}
+	client := makeClient()
+	resp, err := client.R().
+		EnableTrace().
+		SetBody(request).
+		Post(fmt.Sprintf("%s%s", *url, fwdapi.RevokeEndpoint))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	if resp.StatusCode() != 200 {
+		log.Fatalf("Request failed: %s", resp.Status())
+	}
+	fmt.Printf("%s\n", string(resp.Body()))
+}
+
+func revokeServiceToken() {
+	request := fwdapi.RevokeServiceTokenRequest{
+		TokenID: *tokenID,
+	}
+	client := makeClient()
+	resp, err := client.R().
+		EnableTrace().
+		SetBody(request).
+		Post(fmt.Sprintf("%s%s", *url, fwdapi.RevokeServiceTokenEndpoint))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	if resp.StatusCode() != 200 {
+		log.Fatalf("Request failed: %s", resp.Status())
+	}
+	fmt.Printf("%s\n", string(resp.Body()))
+}
+
+func getRevokedServiceTokens() {
+	client := makeClient()
+	resp, err := client.R().
+		EnableTrace().
+		Get(fmt.Sprintf("%s%s", *url, fwdapi.RevokedServiceTokensEndpoint))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+	}
+	if resp.StatusCode() != 200 {
+		log.Fatalf("Request failed: %s", resp.Status())
+	}
+	fmt.Printf("%s\n", string(resp.Body()))
+}
+
 func insist(s *string, name string, expected bool) {
 	if expected && (s == nil || *s == "") {
 		usage(fmt.Sprintf("%s: required", name))
@@ -179,6 +276,7 @@ func main() {
 		insist(agentIdentity, "agent", true)
 		insist(endpointName, "name", true)
 		insist(endpointType, "type", false)
+		getRemoteCommand()
 	case "service":
 		insist(agentIdentity, "agent", true)
 		insist(endpointName, "name", true)
@@ -194,6 +292,23 @@ func main() {
 		insist(endpointName, "name", false)
 		insist(endpointType, "type", false)
 		getStatistics()
+	case "revoke":
+		insist(agentIdentity, "agent", false)
+		insist(endpointName, "name", false)
+		insist(endpointType, "type", false)
+		insist(serialNumber, "serial", true)
+		revokeCertificate()
+	case "revoke-service-token":
+		insist(agentIdentity, "agent", false)
+		insist(endpointName, "name", false)
+		insist(endpointType, "type", false)
+		insist(tokenID, "tokenId", true)
+		revokeServiceToken()
+	case "revoked-service-tokens":
+		insist(agentIdentity, "agent", false)
+		insist(endpointName, "name", false)
+		insist(endpointType, "type", false)
+		getRevokedServiceTokens()
 	default:
 		usage(fmt.Sprintf("Unknown action: %s", *action))
 	}
@@ -20,8 +20,10 @@ import (
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"time"
 
 	"github.com/opsmx/oes-birger/pkg/ca"
 )
@@ -30,6 +32,11 @@ var (
 	namespace         = flag.String("namespace", "", "The namespace to place the secrets into")
 	caSecretName      = flag.String("caSecretName", "ca-secret", "the name of the CA secret")
 	controlSecretName = flag.String("controlSecretName", "oes-control-secret", "the name of the secret for the control secret")
+	keyType           = flag.String("keyType", ca.KeyTypeRSA, "the key type for the CA and control certificate, one of rsa, ecdsa, ed25519")
+	keyBits           = flag.Int("keyBits", 0, "the CA key size: RSA bits (default 4096) or ECDSA curve bits (default 256)")
+	caLifetimeDays    = flag.Int("caLifetimeDays", 3650, "the number of days the CA certificate is valid for")
+	parentCertFile    = flag.String("parentCertFile", "", "if set, make an intermediate CA signed by the CA certificate (and chain) in this file")
+	parentKeyFile     = flag.String("parentKeyFile", "", "the private key for parentCertFile")
 )
 
 func maybePrintNamespace(f *os.File) {
@@ -38,10 +45,39 @@ func maybePrintNamespace(f *os.File) {
 	}
 }
 
+// makeCA makes a self-signed root CA, or if a parent CA is given, an
+// intermediate CA signed by it.  This allows the root key to be kept
+// offline, and used only here.
+func makeCA(lifetime time.Duration) ([]byte, []byte, error) {
+	if len(*parentCertFile) == 0 && len(*parentKeyFile) == 0 {
+		return ca.MakeCertificateAuthority(*keyType, *keyBits, lifetime)
+	}
+	if len(*parentCertFile) == 0 || len(*parentKeyFile) == 0 {
+		return nil, nil, fmt.Errorf("parentCertFile and parentKeyFile must both be set")
+	}
+	parentCert, err := ioutil.ReadFile(*parentCertFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentKey, err := ioutil.ReadFile(*parentKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Making an intermediate CA signed by %s", *parentCertFile)
+	return ca.MakeIntermediateCertificateAuthority(parentCert, parentKey, *keyType, *keyBits, lifetime)
+}
+
 func main() {
 	flag.Parse()
 
-	cacert, caPrivateKey, err := ca.MakeCertificateAuthority()
+	if err := ca.CheckKeyType(*keyType, *keyBits); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *caLifetimeDays <= 0 {
+		log.Fatalf("caLifetimeDays must be positive")
+	}
+
+	cacert, caPrivateKey, err := makeCA(time.Duration(*caLifetimeDays) * 24 * time.Hour)
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
@@ -57,6 +93,11 @@ func main() {
 	name := ca.CertificateName{
 		Name:    "oes",
 		Purpose: ca.CertificatePurposeControl,
+		KeyType: *keyType,
+	}
+	if *keyType != ca.KeyTypeRSA {
+		// RSA CA keys are larger than we want for the control certificate.
+		name.KeyBits = *keyBits
 	}
 	ca64too, cert64, certPrivKey64, err := authority.GenerateCertificate(name)
 	if err != nil {
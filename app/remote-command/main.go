@@ -26,10 +26,14 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/opsmx/oes-birger/pkg/tunnel"
+	"golang.org/x/term"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -37,12 +41,14 @@ import (
 type environment []string
 
 var (
-	certFile   = flag.String("certFile", "tls.crt", "The file containing the certificate used to connect to the controller")
-	keyFile    = flag.String("keyFile", "tls.key", "The file containing the certificate used to connect to the controller")
-	caCertFile = flag.String("caCertFile", "ca.pem", "The file containing the CA certificate we will use to verify the controller's cert")
-	host       = flag.String("host", "forwarder-controller:9001", "The hostname of the controller")
-	cmd        = flag.String("cmd", "", "The remote command name to run")
-	env        environment
+	certFile    = flag.String("certFile", "tls.crt", "The file containing the certificate used to connect to the controller")
+	keyFile     = flag.String("keyFile", "tls.key", "The file containing the certificate used to connect to the controller")
+	caCertFile  = flag.String("caCertFile", "ca.pem", "The file containing the CA certificate we will use to verify the controller's cert")
+	host        = flag.String("host", "forwarder-controller:9004", "The hostname and remote-command port of the controller")
+	cmd         = flag.String("cmd", "", "The remote command name to run")
+	interactive = flag.Bool("i", false, "Send standard input to the command even if it is a terminal")
+	tty         = flag.Bool("t", false, "Run the command on a remote terminal, such as for an interactive shell")
+	env         environment
 )
 
 func usage(message string) {
@@ -73,21 +79,145 @@ func loadCert() []byte {
 	return cert
 }
 
-func runCommand(client tunnel.CmdToolTunnelServiceClient, cmd string, env []string, args []string) {
+// shouldSendStdin returns true if we will forward our standard input.  This is
+// always done when input is redirected from a file or pipe, and for a
+// terminal only when explicitly requested.
+func shouldSendStdin() bool {
+	if *interactive {
+		return true
+	}
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice == 0
+}
+
+// cmdStream serializes sends on the stream, as stdin and terminal size
+// changes are sent from different goroutines.
+type cmdStream struct {
+	sync.Mutex
+	stream tunnel.CmdToolTunnelService_EventTunnelClient
+}
+
+func (s *cmdStream) Send(msg *tunnel.CmdToolToControllerWrapper) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.stream.Send(msg)
+}
+
+// terminalState holds our local terminal's state while it is in raw mode,
+// so it can be restored before we exit.
+var terminalState *term.State
+
+func restoreTerminal() {
+	if terminalState != nil {
+		term.Restore(int(os.Stdin.Fd()), terminalState)
+		terminalState = nil
+	}
+}
+
+func exit(code int) {
+	restoreTerminal()
+	os.Exit(code)
+}
+
+func getTerminalSize() *tunnel.TerminalSize {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil
+	}
+	return &tunnel.TerminalSize{Rows: uint32(height), Columns: uint32(width)}
+}
+
+// resizeSender sends our terminal size each time the window changes.
+func resizeSender(stream *cmdStream) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	for range sigs {
+		size := getTerminalSize()
+		if size == nil {
+			continue
+		}
+		msg := &tunnel.CmdToolToControllerWrapper{
+			Event: &tunnel.CmdToolToControllerWrapper_CommandResize{
+				CommandResize: &tunnel.CmdToolCommandResize{
+					TerminalSize: size,
+				},
+			},
+		}
+		if err := stream.Send(msg); err != nil {
+			log.Printf("while sending terminal size: %v", err)
+			return
+		}
+	}
+}
+
+func makeStdinData(data []byte, closed bool) *tunnel.CmdToolToControllerWrapper {
+	return &tunnel.CmdToolToControllerWrapper{
+		Event: &tunnel.CmdToolToControllerWrapper_CommandData{
+			CommandData: &tunnel.CmdToolCommandData{
+				Body:    data,
+				Channel: tunnel.ChannelDirection_STDIN,
+				Closed:  closed,
+			},
+		},
+	}
+}
+
+// stdinSender sends our input, waiting for credit from the controller
+// whenever it has RequestBodyWindow bytes outstanding.
+func stdinSender(ctx context.Context, stream *cmdStream, window *tunnel.SendWindow, in io.Reader) {
+	buffer := make([]byte, 10240)
+	for {
+		if _, err := window.Wait(ctx); err != nil {
+			return
+		}
+		n, err := in.Read(buffer)
+		if n > 0 {
+			window.Consume(n)
+			if err := stream.Send(makeStdinData(buffer[:n], false)); err != nil {
+				log.Printf("while sending stdin: %v", err)
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("while reading stdin: %v", err)
+			}
+			if err := stream.Send(makeStdinData(nil, true)); err != nil {
+				log.Printf("while sending stdin: %v", err)
+			}
+			return
+		}
+	}
+}
+
+func runCommand(client tunnel.CmdToolTunnelServiceClient, cmd string, env []string, args []string, sendStdin bool, useTTY bool) {
 	ctx := context.Background()
-	stream, err := client.EventTunnel(ctx)
+	grpcStream, err := client.EventTunnel(ctx)
 	if err != nil {
 		log.Fatalf("%v.EventTunnel(_) = _, %v", client, err)
 	}
+	stream := &cmdStream{stream: grpcStream}
+
+	var terminalSize *tunnel.TerminalSize
+	if useTTY {
+		sendStdin = true
+		terminalSize = getTerminalSize()
+	}
 
 	waitc := make(chan struct{})
 
 	run := tunnel.CmdToolToControllerWrapper{
 		Event: &tunnel.CmdToolToControllerWrapper_CommandRequest{
 			CommandRequest: &tunnel.CmdToolCommandRequest{
-				Name:        cmd,
-				Arguments:   args,
-				Environment: env,
+				Name:         cmd,
+				Arguments:    args,
+				Environment:  env,
+				Stdin:        sendStdin,
+				Tty:          useTTY,
+				TerminalSize: terminalSize,
 			},
 		},
 	}
@@ -95,15 +225,27 @@ func runCommand(client tunnel.CmdToolTunnelServiceClient, cmd string, env []stri
 	if err != nil {
 		log.Fatalf("while sending to stream: %v", err)
 	}
+	if useTTY && term.IsTerminal(int(os.Stdin.Fd())) {
+		terminalState, err = term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			log.Fatalf("Unable to put terminal into raw mode: %v", err)
+		}
+		go resizeSender(stream)
+	}
+	window := tunnel.NewSendWindow(tunnel.RequestBodyWindow)
+	if sendStdin {
+		go stdinSender(ctx, stream, window, os.Stdin)
+	}
 	go func() {
 		for {
-			in, err := stream.Recv()
+			in, err := grpcStream.Recv()
 			if err == io.EOF {
 				// Server has closed the connection.
 				close(waitc)
 				return
 			}
 			if err != nil {
+				restoreTerminal()
 				log.Fatalf("Failed to receive a message: %T: %v", err, err)
 			}
 			switch x := in.Event.(type) {
@@ -119,7 +261,9 @@ func runCommand(client tunnel.CmdToolTunnelServiceClient, cmd string, env []stri
 				if len(req.Message) > 0 {
 					fmt.Fprintf(os.Stderr, "%s\n", req.Message)
 				}
-				os.Exit(int(req.ExitCode))
+				exit(int(req.ExitCode))
+			case *tunnel.ControllerToCmdToolWrapper_WindowUpdate:
+				window.Grant(in.GetWindowUpdate().Bytes)
 			case nil:
 				continue
 			default:
@@ -128,7 +272,8 @@ func runCommand(client tunnel.CmdToolTunnelServiceClient, cmd string, env []stri
 		}
 	}()
 	<-waitc
-	err = stream.CloseSend()
+	restoreTerminal()
+	err = grpcStream.CloseSend()
 	log.Fatalf("While closing stream: %v", err)
 }
 
@@ -175,5 +320,5 @@ func main() {
 
 	client := tunnel.NewCmdToolTunnelServiceClient(conn)
 
-	runCommand(client, *cmd, env, args)
+	runCommand(client, *cmd, env, args, shouldSendStdin(), *tty)
 }
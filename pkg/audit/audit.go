@@ -0,0 +1,224 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//
+// Package audit records who made each request through the controller,
+// and what the result was, to one or more sinks.
+//
+package audit
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
+)
+
+// The kinds of audited operations.
+const (
+	KindHTTP    = "http"
+	KindCommand = "command"
+	KindTCP     = "tcp"
+)
+
+// The ways an identity can be established.
+const (
+	IdentitySourceCertificate = "certificate"
+	IdentitySourceToken       = "token"
+	IdentitySourceAddress     = "address"
+)
+
+// Identity describes who made a request.  Only one of Certificate,
+// Token or Address is set, depending on Source.  Address is used only
+// for listeners which do not authenticate their clients.
+type Identity struct {
+	Source      string              `json:"source"`
+	Certificate *ca.CertificateName `json:"certificate,omitempty"`
+	Token       *TokenIdentity      `json:"token,omitempty"`
+	Address     string              `json:"address,omitempty"`
+}
+
+// TokenIdentity holds the claims from a service token.
+type TokenIdentity struct {
+	ID         string     `json:"id,omitempty"`
+	Agent      string     `json:"agent,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	IssuedAt   *time.Time `json:"issuedAt,omitempty"`
+	Expiry     *time.Time `json:"expiry,omitempty"`
+	Methods    []string   `json:"methods,omitempty"`
+	PathPrefix string     `json:"pathPrefix,omitempty"`
+}
+
+// CertificateIdentity returns the identity for a client certificate.
+func CertificateIdentity(name *ca.CertificateName) Identity {
+	return Identity{Source: IdentitySourceCertificate, Certificate: name}
+}
+
+// AddressIdentity returns the identity for an unauthenticated client.
+func AddressIdentity(address string) Identity {
+	return Identity{Source: IdentitySourceAddress, Address: address}
+}
+
+// TokenClaimsIdentity returns the identity for a service token.
+func TokenClaimsIdentity(claims *jwtutil.ServiceClaims) Identity {
+	token := &TokenIdentity{
+		ID:         claims.ID,
+		Agent:      claims.Agent,
+		Type:       claims.Type,
+		Name:       claims.Name,
+		Methods:    claims.Methods,
+		PathPrefix: claims.PathPrefix,
+	}
+	if !claims.IssuedAt.IsZero() {
+		t := claims.IssuedAt
+		token.IssuedAt = &t
+	}
+	if !claims.Expiry.IsZero() {
+		t := claims.Expiry
+		token.Expiry = &t
+	}
+	return Identity{Source: IdentitySourceToken, Token: token}
+}
+
+//
+// Record is a single audited operation.  HTTP requests set Method, URI,
+// and Status; commands set Arguments and ExitCode; TCP connections set
+// Address.
+//
+type Record struct {
+	Time          time.Time `json:"time"`
+	Kind          string    `json:"kind"`
+	TransactionID string    `json:"transactionId"`
+	Identity      Identity  `json:"identity"`
+	Agent         string    `json:"agent"`
+	Session       string    `json:"session,omitempty"`
+	EndpointType  string    `json:"endpointType"`
+	EndpointName  string    `json:"endpointName"`
+	Method        string    `json:"method,omitempty"`
+	URI           string    `json:"uri,omitempty"`
+	Status        int       `json:"status,omitempty"`
+	Arguments     []string  `json:"arguments,omitempty"`
+	ExitCode      *int      `json:"exitCode,omitempty"`
+	Address       string    `json:"address,omitempty"`
+	RequestBytes  int64     `json:"requestBytes"`
+	ResponseBytes int64     `json:"responseBytes"`
+	DurationMs    int64     `json:"durationMs"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Finish sets the duration of the operation, which started at Time.
+func (r *Record) Finish(now time.Time) {
+	r.DurationMs = now.Sub(r.Time).Milliseconds()
+}
+
+// SetExitCode records the exit code of a command.
+func (r *Record) SetExitCode(code int) {
+	r.ExitCode = &code
+}
+
+// Sink receives audit records.  Write must be safe to call concurrently.
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// Config holds the audit log configuration.  If no sinks are
+// configured, records are discarded.
+type Config struct {
+	// Filename is where records are written, one JSON object per line.
+	Filename string `yaml:"filename,omitempty"`
+
+	// MaxSizeMegabytes is the size at which the file is rotated.  The
+	// default is 100.
+	MaxSizeMegabytes int64 `yaml:"maxSizeMegabytes,omitempty"`
+
+	// MaxBackups is how many rotated files are kept.  The default is 10.
+	MaxBackups int `yaml:"maxBackups,omitempty"`
+
+	// Webhook, if set, is a URL each record is POSTed to as JSON.
+	Webhook string `yaml:"webhook,omitempty"`
+}
+
+func (c *Config) applyDefaults() error {
+	if c.MaxSizeMegabytes < 0 {
+		return fmt.Errorf("audit.maxSizeMegabytes must not be negative")
+	}
+	if c.MaxSizeMegabytes == 0 {
+		c.MaxSizeMegabytes = 100
+	}
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("audit.maxBackups must not be negative")
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = 10
+	}
+	return nil
+}
+
+// Logger sends each record to all of its sinks.
+type Logger struct {
+	sinks []Sink
+}
+
+// NewLogger returns a logger which writes to the sinks.
+func NewLogger(sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks}
+}
+
+//
+// MakeLogger returns a logger with the sinks described by the config.
+//
+func MakeLogger(c Config) (*Logger, error) {
+	if err := c.applyDefaults(); err != nil {
+		return nil, err
+	}
+	sinks := []Sink{}
+	if len(c.Filename) > 0 {
+		sink, err := NewFileSink(c.Filename, c.MaxSizeMegabytes*1024*1024, c.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(c.Webhook) > 0 {
+		sinks = append(sinks, NewWebhookSink(c.Webhook))
+	}
+	return NewLogger(sinks...), nil
+}
+
+//
+// Log writes the record to every sink.  Errors are logged, but do not
+// stop the record from being sent to the other sinks.
+//
+func (l *Logger) Log(record *Record) {
+	for _, sink := range l.sinks {
+		if err := sink.Write(record); err != nil {
+			log.Printf("Unable to write audit record %s: %v", record.TransactionID, err)
+		}
+	}
+}
+
+// Close closes all the sinks.
+func (l *Logger) Close() {
+	for _, sink := range l.sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("Unable to close audit sink: %v", err)
+		}
+	}
+}
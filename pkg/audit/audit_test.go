@@ -0,0 +1,288 @@
+package audit
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/ca"
+	"github.com/opsmx/oes-birger/pkg/jwtutil"
+)
+
+func readRecords(t *testing.T, filename string) []Record {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ret := []Record{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		ret = append(ret, record)
+	}
+	return ret
+}
+
+func TestFileSink_rotates(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(filename, 300, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink() = %v", err)
+	}
+	defer sink.Close()
+
+	ids := []string{"1", "2", "3", "4", "5"}
+	for _, id := range ids {
+		if err := sink.Write(&Record{TransactionID: id, Kind: KindHTTP}); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+
+	// Each record is about 200 bytes, so every write after the first
+	// rotates, and only the newest three records are kept.
+	want := map[string]string{
+		filename:        "5",
+		filename + ".1": "4",
+		filename + ".2": "3",
+	}
+	for name, id := range want {
+		records := readRecords(t, name)
+		if len(records) != 1 || records[0].TransactionID != id {
+			t.Errorf("%s has %#v, want transaction %s", filepath.Base(name), records, id)
+		}
+	}
+	if _, err := os.Stat(filename + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected %s.3 to be removed", filename)
+	}
+}
+
+func TestFileSink_keepsWritingWhenRotationFails(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(filename, 300, 1)
+	if err != nil {
+		t.Fatalf("NewFileSink() = %v", err)
+	}
+	defer sink.Close()
+
+	// A non-empty directory where the backup goes cannot be replaced.
+	if err := os.MkdirAll(filepath.Join(filename+".1", "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(&Record{TransactionID: "1"}); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if err := sink.Write(&Record{TransactionID: "2"}); err == nil {
+		t.Errorf("Write() expected a rotation error")
+	}
+	if records := readRecords(t, filename); len(records) != 2 {
+		t.Errorf("got %d records after failed rotation, want 2", len(records))
+	}
+
+	if err := os.RemoveAll(filename + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(&Record{TransactionID: "3"}); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if records := readRecords(t, filename); len(records) != 1 || records[0].TransactionID != "3" {
+		t.Errorf("%s has %#v, want transaction 3", filepath.Base(filename), records)
+	}
+	if records := readRecords(t, filename+".1"); len(records) != 2 {
+		t.Errorf("got %d records in the backup, want 2", len(records))
+	}
+}
+
+func TestFileSink_appends(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	for _, id := range []string{"1", "2"} {
+		sink, err := NewFileSink(filename, 0, 0)
+		if err != nil {
+			t.Fatalf("NewFileSink() = %v", err)
+		}
+		if err := sink.Write(&Record{TransactionID: id}); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close() = %v", err)
+		}
+		if err := sink.Write(&Record{TransactionID: id}); err == nil {
+			t.Errorf("Write() after Close() expected an error")
+		}
+	}
+	if records := readRecords(t, filename); len(records) != 2 {
+		t.Errorf("got %d records, want 2", len(records))
+	}
+}
+
+func TestMakeLogger(t *testing.T) {
+	received := make(chan Record, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var record Record
+		if err := json.Unmarshal(body, &record); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received <- record
+	}))
+	defer server.Close()
+
+	filename := filepath.Join(t.TempDir(), "audit.log")
+	logger, err := MakeLogger(Config{Filename: filename, Webhook: server.URL})
+	if err != nil {
+		t.Fatalf("MakeLogger() = %v", err)
+	}
+	defer logger.Close()
+
+	record := &Record{
+		Time:          time.Now(),
+		Kind:          KindCommand,
+		TransactionID: "abc",
+		Identity: CertificateIdentity(&ca.CertificateName{
+			Agent:   "smith",
+			Name:    "ls",
+			Purpose: ca.CertificatePurposeRemoteCommand,
+		}),
+		Arguments: []string{"-l"},
+	}
+	record.SetExitCode(0)
+	logger.Log(record)
+
+	select {
+	case got := <-received:
+		if got.ExitCode == nil || *got.ExitCode != 0 {
+			t.Errorf("webhook exit code = %v, want 0", got.ExitCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	records := readRecords(t, filename)
+	if len(records) != 1 || records[0].Identity.Certificate == nil || records[0].Identity.Certificate.Agent != "smith" {
+		t.Errorf("file has %#v", records)
+	}
+}
+
+func TestWebhookSink_dropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan string, 10)
+	var active, maxActive int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		var record Record
+		json.NewDecoder(r.Body).Decode(&record)
+		<-release
+		received <- record.TransactionID
+	}))
+	defer server.Close()
+
+	sink := newWebhookSink(server.URL, 2, 5*time.Second)
+	defer sink.Close()
+
+	written := []string{}
+	for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+		if err := sink.Write(&Record{TransactionID: id}); err == nil {
+			written = append(written, id)
+		}
+		// Let the worker pick up the first record.
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(written) == 6 {
+		t.Fatalf("Write() never reported a full queue")
+	}
+	close(release)
+
+	for _, want := range written {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("received %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("webhook not called for %s", want)
+		}
+	}
+	if m := atomic.LoadInt32(&maxActive); m != 1 {
+		t.Errorf("%d concurrent webhook requests, want 1", m)
+	}
+}
+
+func TestWebhookSink_timeout(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var record Record
+		json.NewDecoder(r.Body).Decode(&record)
+		if record.TransactionID == "slow" {
+			<-release
+		}
+		received <- record.TransactionID
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := newWebhookSink(server.URL, 10, 100*time.Millisecond)
+	defer sink.Close()
+	for _, id := range []string{"slow", "fast"} {
+		if err := sink.Write(&Record{TransactionID: id}); err != nil {
+			t.Fatalf("Write() = %v", err)
+		}
+	}
+
+	select {
+	case got := <-received:
+		if got != "fast" {
+			t.Errorf("received %s, want fast", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a hung webhook blocked later records")
+	}
+}
+
+func TestMakeLogger_invalid(t *testing.T) {
+	if _, err := MakeLogger(Config{MaxBackups: -1}); err == nil {
+		t.Errorf("MakeLogger() expected an error")
+	}
+}
+
+func TestTokenClaimsIdentity(t *testing.T) {
+	identity := TokenClaimsIdentity(&jwtutil.ServiceClaims{ID: "xyz", Agent: "smith"})
+	if identity.Source != IdentitySourceToken || identity.Token.ID != "xyz" {
+		t.Errorf("TokenClaimsIdentity() = %#v", identity)
+	}
+	if identity.Token.IssuedAt != nil || identity.Token.Expiry != nil {
+		t.Errorf("zero times should be omitted")
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+//
+// FileSink writes records to a file as JSON lines.  When the file would
+// grow past maxBytes it is renamed to filename.1, any older files are
+// shifted up by one, and those past maxBackups are removed.
+//
+type FileSink struct {
+	sync.Mutex
+	filename   string
+	maxBytes   int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+//
+// NewFileSink opens the file for appending, creating it if needed.  If
+// maxBytes is zero, the file is never rotated.
+//
+func NewFileSink(filename string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		filename:   filename,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open audit log: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to open audit log: %v", err)
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+//
+// Write appends the record to the file, rotating it first if needed.  If
+// rotation fails, the record is still written to the current file, the
+// error is returned, and rotation is tried again on the next write.
+//
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.Lock()
+	defer s.Unlock()
+	if s.f == nil {
+		return fmt.Errorf("audit log %s is closed", s.filename)
+	}
+	var rotateErr error
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		rotateErr = s.rotate()
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+//
+// rotate must be called with the lock held.  The current file stays open
+// until the new one is, so if rotation fails we keep writing to it.
+//
+func (s *FileSink) rotate() error {
+	if s.maxBackups == 0 {
+		if err := s.f.Truncate(0); err != nil {
+			return fmt.Errorf("unable to rotate audit log: %v", err)
+		}
+		s.size = 0
+		return nil
+	}
+	os.Remove(s.backupName(s.maxBackups))
+	for i := s.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(s.backupName(i), s.backupName(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to rotate audit log: %v", err)
+		}
+	}
+	if err := os.Rename(s.filename, s.backupName(1)); err != nil {
+		return fmt.Errorf("unable to rotate audit log: %v", err)
+	}
+	old := s.f
+	if err := s.open(); err != nil {
+		// Put the file back, so we keep writing to it under its own name.
+		if err := os.Rename(s.backupName(1), s.filename); err != nil {
+			log.Printf("Unable to restore audit log %s: %v", s.filename, err)
+		}
+		return err
+	}
+	old.Close()
+	return nil
+}
+
+func (s *FileSink) backupName(n int) string {
+	return fmt.Sprintf("%s.%d", s.filename, n)
+}
+
+// Close closes the file.  Later writes return an error.
+func (s *FileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
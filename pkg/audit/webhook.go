@@ -0,0 +1,114 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	webhookQueueSize = 1000
+	webhookTimeout   = 10 * time.Second
+)
+
+//
+// WebhookSink POSTs each record as JSON to a URL.  Records are queued and
+// sent one at a time, so a slow webhook cannot hold up requests or start
+// an unbounded number of connections.  When the queue is full, records
+// are dropped.  Delivery is not retried, and failures are only logged.
+//
+type WebhookSink struct {
+	sync.Mutex
+	url    string
+	client *http.Client
+	queue  chan []byte
+	closed bool
+}
+
+// NewWebhookSink starts a worker sending records to the URL.
+func NewWebhookSink(url string) *WebhookSink {
+	return newWebhookSink(url, webhookQueueSize, webhookTimeout)
+}
+
+func newWebhookSink(url string, queueSize int, timeout time.Duration) *WebhookSink {
+	s := &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan []byte, queueSize),
+	}
+	go s.run()
+	return s
+}
+
+// Write queues the record to be sent, or returns an error if the queue
+// is full.
+func (s *WebhookSink) Write(record *Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return fmt.Errorf("audit webhook is closed")
+	}
+	select {
+	case s.queue <- body:
+		return nil
+	default:
+		return fmt.Errorf("audit webhook queue is full, record dropped")
+	}
+}
+
+func (s *WebhookSink) run() {
+	for body := range s.queue {
+		s.post(body)
+	}
+}
+
+func (s *WebhookSink) post(body []byte) {
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Unable to send audit record to webhook: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+	// Read some of the body, so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("Audit webhook returned %s", resp.Status)
+	}
+}
+
+// Close stops accepting records.  Those already queued are still sent.
+func (s *WebhookSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	return nil
+}
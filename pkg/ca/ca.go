@@ -21,8 +21,8 @@ package ca
 
 import (
 	"bytes"
+	"crypto"
 	crand "crypto/rand"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -30,7 +30,9 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"sync"
 	"time"
 )
 
@@ -57,19 +59,69 @@ type CertPoolGenerator interface {
 // CA holds the state for the certificate authority.
 //
 type CA struct {
-	config *Config
-	caCert tls.Certificate
+	sync.RWMutex
+	config      *Config
+	caCert      tls.Certificate
+	caLeaf      *x509.Certificate
+	chain       [][]byte
+	trusted     []*x509.Certificate
+	certPool    *x509.CertPool
+	loaded      *loadedFiles
+	revocations *RevocationList
+}
+
+// loadedFiles holds the file contents the CA was last loaded from, so
+// Reload can tell if anything changed.
+type loadedFiles struct {
+	cert   []byte
+	key    []byte
+	bundle []byte
 }
 
 //
 // Config holds the filenames for a CA, and has mappings for loading from
 // YAML or JSON.
 //
+// CACertFile may hold an intermediate CA certificate followed by the
+// certificates it chains to, so the root key can be kept offline.  The
+// key is loaded from CAKeyFile unless Signer selects another signer type,
+// in which case CAKeyFile is not used.
+//
 type Config struct {
-	CACertFile string `yaml:"caCertFile,omitempty" json:"caCertFile,omitempty"`
-	CAKeyFile  string `yaml:"caKeyFile,omitempty" json:"caKeyFile,omitempty"`
+	CACertFile string       `yaml:"caCertFile,omitempty" json:"caCertFile,omitempty"`
+	CAKeyFile  string       `yaml:"caKeyFile,omitempty" json:"caKeyFile,omitempty"`
+	Signer     SignerConfig `yaml:"signer,omitempty" json:"signer,omitempty"`
+
+	// RevocationListFile is where revoked certificate serial numbers are
+	// stored.  If empty, revocations are lost when the controller restarts.
+	// Each controller keeps its own list, and sends it to its peers, so
+	// a revocation on any replica is applied on all of them.
+	RevocationListFile string `yaml:"revocationListFile,omitempty" json:"revocationListFile,omitempty"`
+
+	// TrustBundleFile holds PEM encoded CA certificates which are trusted
+	// in addition to the CA in CACertFile.  The controller sends these,
+	// with the CA, to agents when they connect and whenever they change.
+	// To roll over to a new CA, add it here, wait for agents to pick up the
+	// new bundle, then swap it with the CA in CACertFile and keep the old
+	// CA here until every certificate it signed has been replaced.
+	TrustBundleFile string `yaml:"trustBundleFile,omitempty" json:"trustBundleFile,omitempty"`
+
+	// KeyType is the type of key generated for new certificates, one of
+	// "rsa" (the default), "ecdsa", or "ed25519".  KeyBits is the RSA key
+	// size, 2048 by default, or the ECDSA curve size, 256 by default.
+	KeyType string `yaml:"keyType,omitempty" json:"keyType,omitempty"`
+	KeyBits int    `yaml:"keyBits,omitempty" json:"keyBits,omitempty"`
+
+	// CertificateLifetimeSeconds is the default, and the maximum, lifetime
+	// of issued certificates.  ServerCertificateLifetimeSeconds is the
+	// lifetime of the controller's own server certificate.  Both default
+	// to one year.
+	CertificateLifetimeSeconds       int64 `yaml:"certificateLifetimeSeconds,omitempty" json:"certificateLifetimeSeconds,omitempty"`
+	ServerCertificateLifetimeSeconds int64 `yaml:"serverCertificateLifetimeSeconds,omitempty" json:"serverCertificateLifetimeSeconds,omitempty"`
 }
 
+const defaultCertificateLifetime = 365 * 24 * time.Hour
+
 func (c *Config) applyDefaults() {
 	if len(c.CACertFile) == 0 {
 		c.CACertFile = defaultTLSCertificatePath
@@ -77,28 +129,249 @@ func (c *Config) applyDefaults() {
 	if len(c.CAKeyFile) == 0 {
 		c.CAKeyFile = defaultTLSKeyPath
 	}
+	c.applyIssueDefaults()
+}
+
+// applyIssueDefaults sets the defaults for issuing certificates, which
+// also apply to a CA made from data rather than files.
+func (c *Config) applyIssueDefaults() {
+	if len(c.KeyType) == 0 {
+		c.KeyType = KeyTypeRSA
+	}
+	if c.CertificateLifetimeSeconds == 0 {
+		c.CertificateLifetimeSeconds = int64(defaultCertificateLifetime / time.Second)
+	}
+	if c.ServerCertificateLifetimeSeconds == 0 {
+		c.ServerCertificateLifetimeSeconds = int64(defaultCertificateLifetime / time.Second)
+	}
+}
+
+func (c *Config) validate() error {
+	if !c.Signer.isFile() {
+		if _, err := findSignerType(c.Signer.Type); err != nil {
+			return err
+		}
+	}
+	if err := CheckKeyType(c.KeyType, c.KeyBits); err != nil {
+		return err
+	}
+	if c.CertificateLifetimeSeconds < 0 {
+		return fmt.Errorf("certificateLifetimeSeconds must be positive")
+	}
+	if c.ServerCertificateLifetimeSeconds < 0 {
+		return fmt.Errorf("serverCertificateLifetimeSeconds must be positive")
+	}
+	return nil
 }
 
-func (c *CA) loadCertificate() error {
-	caCert, err := tls.LoadX509KeyPair(c.config.CACertFile, c.config.CAKeyFile)
+func (c *Config) certificateLifetime() time.Duration {
+	return time.Duration(c.CertificateLifetimeSeconds) * time.Second
+}
+
+func (c *Config) serverCertificateLifetime() time.Duration {
+	return time.Duration(c.ServerCertificateLifetimeSeconds) * time.Second
+}
+
+//
+// Reload reads the CA certificate, key, and trust bundle files again, and
+// switches to them if any have changed.  It returns true if the CA was
+// replaced.  On error, the CA in use is kept.  If a signer other than the
+// key file is used, it is set up again when the certificate changes.
+//
+func (c *CA) Reload() (bool, error) {
+	if len(c.config.CACertFile) == 0 {
+		return false, nil
+	}
+
+	files := &loadedFiles{}
+	var err error
+	files.cert, err = ioutil.ReadFile(c.config.CACertFile)
 	if err != nil {
-		return fmt.Errorf("unable to load CA cetificate or key: %v", err)
+		return false, fmt.Errorf("unable to load CA cetificate or key: %v", err)
+	}
+	if c.config.Signer.isFile() {
+		files.key, err = ioutil.ReadFile(c.config.CAKeyFile)
+		if err != nil {
+			return false, fmt.Errorf("unable to load CA cetificate or key: %v", err)
+		}
+	}
+	if len(c.config.TrustBundleFile) > 0 {
+		files.bundle, err = ioutil.ReadFile(c.config.TrustBundleFile)
+		if err != nil {
+			return false, fmt.Errorf("unable to load CA trust bundle: %v", err)
+		}
+	}
+
+	c.RLock()
+	unchanged := c.loaded != nil &&
+		bytes.Equal(c.loaded.cert, files.cert) &&
+		bytes.Equal(c.loaded.key, files.key) &&
+		bytes.Equal(c.loaded.bundle, files.bundle)
+	c.RUnlock()
+	if unchanged {
+		return false, nil
 	}
+
+	caCert, err := c.loadKeyPair(files)
+	if err != nil {
+		return false, err
+	}
+	bundle, err := parseCertificates(files.bundle)
+	if err != nil {
+		return false, fmt.Errorf("unable to load CA trust bundle %s: %v", c.config.TrustBundleFile, err)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if err := c.setCertificate(caCert, bundle); err != nil {
+		return false, err
+	}
+	c.loaded = files
+	return true, nil
+}
+
+// loadKeyPair returns the CA certificate chain with either the key from
+// the key file, or the configured signer.
+func (c *CA) loadKeyPair(files *loadedFiles) (tls.Certificate, error) {
+	if c.config.Signer.isFile() {
+		caCert, err := tls.X509KeyPair(files.cert, files.key)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to load CA cetificate or key: %v", err)
+		}
+		return caCert, nil
+	}
+
+	certs, err := parseCertificates(files.cert)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to load CA certificate: %v", err)
+	}
+	if len(certs) == 0 {
+		return tls.Certificate{}, fmt.Errorf("no CA certificate found in %s", c.config.CACertFile)
+	}
+	signer, err := makeSigner(c.config.Signer, certs[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	caCert := tls.Certificate{PrivateKey: signer}
+	for _, cert := range certs {
+		caCert.Certificate = append(caCert.Certificate, cert.Raw)
+	}
+	return caCert, nil
+}
+
+// setCertificate must be called with the lock held, or before the CA is
+// in use.  The trusted list holds the signing CA first, then the rest of
+// its chain, followed by any others from the bundle.  Issued certificates
+// are followed by the intermediate CAs in the chain, so clients which
+// trust only the root can verify them.
+func (c *CA) setCertificate(caCert tls.Certificate, bundle []*x509.Certificate) error {
+	chain := [][]byte{}
+	trusted := []*x509.Certificate{}
+	pool := x509.NewCertPool()
+	var previous *x509.Certificate
+	for _, der := range caCert.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("unable to parse CA certificate: %v", err)
+		}
+		if !cert.IsCA {
+			return fmt.Errorf("certificate %s is not a CA", cert.Subject)
+		}
+		if previous != nil {
+			if err := previous.CheckSignatureFrom(cert); err != nil {
+				return fmt.Errorf("CA certificate chain is out of order: %v", err)
+			}
+		}
+		if !isSelfSigned(cert) {
+			chain = append(chain, der)
+		}
+		trusted = append(trusted, cert)
+		pool.AddCert(cert)
+		previous = cert
+	}
+	if len(trusted) == 0 {
+		return fmt.Errorf("no CA certificate found")
+	}
+	leaf := trusted[0]
+	for _, cert := range bundle {
+		if containsCertificate(trusted, cert) {
+			continue
+		}
+		trusted = append(trusted, cert)
+		pool.AddCert(cert)
+	}
+
 	c.caCert = caCert
+	c.caLeaf = leaf
+	c.chain = chain
+	c.trusted = trusted
+	c.certPool = pool
 	return nil
 }
 
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCertificates returns the CA certificates in the PEM data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if !cert.IsCA {
+			return nil, fmt.Errorf("certificate %s is not a CA", cert.Subject)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// signer returns the certificate and key new certificates are signed
+// with, and the intermediate certificates which should follow them.
+func (c *CA) signer() (*x509.Certificate, crypto.PrivateKey, [][]byte) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.caLeaf, c.caCert.PrivateKey, c.chain
+}
+
 //
 // LoadCAFromFile will load an existing authority.
 //
 func LoadCAFromFile(c Config) (*CA, error) {
 	c.applyDefaults()
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
 
 	ca := &CA{
 		config: &c,
 	}
 
-	err := ca.loadCertificate()
+	_, err := ca.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	ca.revocations, err = LoadRevocationList(c.RevocationListFile)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +387,16 @@ func MakeCAFromData(certPEM []byte, certPrivKeyPEM []byte) (*CA, error) {
 	if err != nil {
 		return nil, err
 	}
-	ca := &CA{caCert: caCert}
+	revocations, err := LoadRevocationList("")
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	config.applyIssueDefaults()
+	ca := &CA{config: config, revocations: revocations}
+	if err := ca.setCertificate(caCert, nil); err != nil {
+		return nil, err
+	}
 	return ca, nil
 }
 
@@ -122,9 +404,18 @@ func MakeCAFromData(certPEM []byte, certPrivKeyPEM []byte) (*CA, error) {
 // GetCACertificate returns the public certificate for the CA.
 //
 func (c *CA) GetCACertificate() []byte {
+	c.RLock()
+	defer c.RUnlock()
 	return c.caCert.Certificate[0]
 }
 
+//
+// randomSerialNumber returns a random 128 bit certificate serial number.
+//
+func randomSerialNumber() (*big.Int, error) {
+	return crand.Int(crand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
 func toPEM(data []byte, t string) ([]byte, error) {
 	p := &bytes.Buffer{}
 	err := pem.Encode(p, &pem.Block{
@@ -139,17 +430,23 @@ func toPEM(data []byte, t string) ([]byte, error) {
 
 //
 // MakeCertificateAuthority generates a new certificate authority key, and self-signs it.
+// The key type and size are as for Config.KeyType and Config.KeyBits, except
+// that RSA keys default to 4096 bits.
 //
-func MakeCertificateAuthority() ([]byte, []byte, error) {
+func MakeCertificateAuthority(keyType string, keyBits int, lifetime time.Duration) ([]byte, []byte, error) {
 	now := time.Now().UTC()
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
 	rootTemplate := &x509.Certificate{
-		SerialNumber: big.NewInt(now.UnixNano()),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			Organization: []string{"OpsMX API Forwarder CA"},
 			Country:      []string{"DF"},
 		},
 		NotBefore:             now.Add(-10 * time.Second),
-		NotAfter:              now.AddDate(10, 0, 0),
+		NotAfter:              now.Add(lifetime),
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 		BasicConstraintsValid: true,
 		IsCA:                  true,
@@ -157,13 +454,13 @@ func MakeCertificateAuthority() ([]byte, []byte, error) {
 	}
 	empty := []byte{}
 
-	priv, err := rsa.GenerateKey(crand.Reader, 4096)
+	priv, err := generateKey(keyType, keyBits, defaultRSACABits)
 	if err != nil {
 		return empty, empty, err
 	}
 
 	// Self-sign the CA key.
-	certBytes, err := x509.CreateCertificate(crand.Reader, rootTemplate, rootTemplate, &priv.PublicKey, priv)
+	certBytes, err := x509.CreateCertificate(crand.Reader, rootTemplate, rootTemplate, priv.Public(), priv)
 	if err != nil {
 		return empty, empty, err
 	}
@@ -173,7 +470,7 @@ func MakeCertificateAuthority() ([]byte, []byte, error) {
 		return []byte{}, []byte{}, err
 	}
 
-	certPrivKeyPEM, err := toPEM(x509.MarshalPKCS1PrivateKey(priv), "RSA PRIVATE KEY")
+	certPrivKeyPEM, err := keyToPEM(priv)
 	if err != nil {
 		return []byte{}, []byte{}, err
 	}
@@ -181,47 +478,136 @@ func MakeCertificateAuthority() ([]byte, []byte, error) {
 	return certPEM, certPrivKeyPEM, nil
 }
 
+//
+// MakeIntermediateCertificateAuthority generates a new CA key and
+// certificate, signed by the parent CA.  The parent certificate PEM may
+// include its own chain, and the returned certificate PEM holds the new
+// certificate followed by that chain, ready to be used as Config.CACertFile.
+// The intermediate may issue certificates, but not further CAs, and does
+// not outlive its parent.
+//
+func MakeIntermediateCertificateAuthority(parentCertPEM []byte, parentKeyPEM []byte, keyType string, keyBits int, lifetime time.Duration) ([]byte, []byte, error) {
+	parentChain, err := parseCertificates(parentCertPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse parent CA certificate: %v", err)
+	}
+	if len(parentChain) == 0 {
+		return nil, nil, fmt.Errorf("no parent CA certificate found")
+	}
+	parent := parentChain[0]
+	parentKey, err := ParsePrivateKey(parentKeyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse parent CA key: %v", err)
+	}
+
+	now := time.Now().UTC()
+	notAfter := now.Add(lifetime)
+	if notAfter.After(parent.NotAfter) {
+		notAfter = parent.NotAfter
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"OpsMX API Forwarder Intermediate CA"},
+			Country:      []string{"DF"},
+		},
+		NotBefore:             now.Add(-10 * time.Second),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	priv, err := generateKey(keyType, keyBits, defaultRSACABits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBytes, err := x509.CreateCertificate(crand.Reader, template, parent, priv.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chain := [][]byte{}
+	for _, cert := range parentChain {
+		chain = append(chain, cert.Raw)
+	}
+	certPEM, err := chainToPEM(certBytes, chain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPrivKeyPEM, err := keyToPEM(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certPEM, certPrivKeyPEM, nil
+}
+
+// chainToPEM encodes the certificate followed by its chain.
+func chainToPEM(cert []byte, chain [][]byte) ([]byte, error) {
+	p, err := toPEM(cert, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	for _, der := range chain {
+		certPEM, err := toPEM(der, "CERTIFICATE")
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, certPEM...)
+	}
+	return p, nil
+}
+
 //
 // MakeServerCert will generate a new server certificate, signed with the authority,
-// with a validity period of 1 year.  The DNS names will be applied.
+// with the configured server certificate lifetime.  The DNS names will be applied.
 //
 func (c *CA) MakeServerCert(names []string) (*tls.Certificate, error) {
 	now := time.Now().UTC()
 
-	caCert, err := x509.ParseCertificate(c.caCert.Certificate[0])
+	caCert, caKey, chain := c.signer()
+
+	certPrivKey, err := generateKey(c.config.KeyType, c.config.KeyBits, defaultRSABits)
 	if err != nil {
 		return nil, err
 	}
 
-	certPrivKey, err := rsa.GenerateKey(crand.Reader, 2048)
+	serial, err := randomSerialNumber()
 	if err != nil {
 		return nil, err
 	}
-
 	certTemplate := &x509.Certificate{
-		SerialNumber: big.NewInt(now.UnixNano()),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			Organization: []string{"OpsMX API Forwarder"},
 			Country:      []string{"DF"},
 		},
 		NotBefore:   now.Add(-10 * time.Second),
-		NotAfter:    now.AddDate(1, 0, 0),
+		NotAfter:    now.Add(c.config.serverCertificateLifetime()),
 		KeyUsage:    x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		DNSNames:    names,
 	}
 
-	certBytes, err := x509.CreateCertificate(crand.Reader, certTemplate, caCert, &certPrivKey.PublicKey, c.caCert.PrivateKey)
+	certBytes, err := createCertificate(certTemplate, caCert, certPrivKey.Public(), caKey)
 	if err != nil {
 		return nil, err
 	}
 
-	certPEM, err := toPEM(certBytes, "CERTIFICATE")
+	certPEM, err := chainToPEM(certBytes, chain)
 	if err != nil {
 		return nil, err
 	}
 
-	certPrivKeyPEM, err := toPEM(x509.MarshalPKCS1PrivateKey(certPrivKey), "RSA PRIVATE KEY")
+	certPrivKeyPEM, err := keyToPEM(certPrivKey)
 	if err != nil {
 		return nil, err
 	}
@@ -230,6 +616,10 @@ func (c *CA) MakeServerCert(names []string) (*tls.Certificate, error) {
 	if err != nil {
 		return nil, err
 	}
+	serverCert.Leaf, err = x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, err
+	}
 
 	return &serverCert, nil
 }
@@ -238,11 +628,19 @@ func (c *CA) MakeServerCert(names []string) (*tls.Certificate, error) {
 // CertificateName holds the items we will encode in the certificate, so we can determine what
 // endpoint is being requested.
 //
+// KeyType, KeyBits, and Lifetime are not encoded, and if set, override the
+// CA's configured key type and certificate lifetime when issuing.  The
+// lifetime may be shortened, but not lengthened.
+//
 type CertificateName struct {
 	Name    string `json:"name,omitempty"`
 	Type    string `json:"type,omitempty"`
 	Agent   string `json:"agent,omitempty"`
 	Purpose string `json:"purpose,omitempty"`
+
+	KeyType  string        `json:"-"`
+	KeyBits  int           `json:"-"`
+	Lifetime time.Duration `json:"-"`
 }
 
 // Certificate purposes, intended to be on CertificateName.Purpose
@@ -251,6 +649,7 @@ const (
 	CertificatePurposeAgent         = "agent"
 	CertificatePurposeService       = "service"
 	CertificatePurposeRemoteCommand = "remote-command"
+	CertificatePurposeController    = "controller"
 )
 
 // GetCertificateNameFromCert extracts the CertificateName from the certificate, or returns
@@ -278,86 +677,251 @@ func GetCertificateNameFromCert(cert *x509.Certificate) (*CertificateName, error
 // string for the certificate, key, and authority certificate.
 //
 func (c *CA) GenerateCertificate(name CertificateName) (string, string, string, error) {
-	now := time.Now().UTC()
-	jsonName, err := json.Marshal(name)
+	keyType, keyBits := c.config.KeyType, c.config.KeyBits
+	if len(name.KeyType) > 0 {
+		keyType, keyBits = name.KeyType, name.KeyBits
+	}
+
+	template, err := c.certificateTemplate(name)
 	if err != nil {
 		return "", "", "", err
 	}
-	cert := &x509.Certificate{
-		SerialNumber: big.NewInt(now.UnixNano()),
-		Subject: pkix.Name{
-			ExtraNames: []pkix.AttributeTypeAndValue{
-				{
-					Type:  []int{2, 5, 4, OpsMxOIDValue},
-					Value: string(jsonName),
-				},
-			},
-		},
-		NotBefore:   now,
-		NotAfter:    now.AddDate(1, 0, 0),
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageDigitalSignature,
-	}
-	certPrivKey, err := rsa.GenerateKey(crand.Reader, 2048)
+	certPrivKey, err := generateKey(keyType, keyBits, defaultRSABits)
 	if err != nil {
 		return "", "", "", err
 	}
 
 	// we now have a certificate and private key.  Now, sign the cert with the CA.
 
-	caCert, err := x509.ParseCertificate(c.caCert.Certificate[0])
+	certPEM, err := c.signTemplate(template, certPrivKey.Public())
 	if err != nil {
 		return "", "", "", err
 	}
+	cert64 := base64.StdEncoding.EncodeToString(certPEM)
 
-	certBytes, err := x509.CreateCertificate(crand.Reader, cert, caCert, &certPrivKey.PublicKey, c.caCert.PrivateKey)
+	ca64, err := c.GetCACert()
 	if err != nil {
 		return "", "", "", err
 	}
 
-	ca64, err := c.GetCACert()
+	certPrivKeyPEM, err := keyToPEM(certPrivKey)
 	if err != nil {
 		return "", "", "", err
 	}
+	certPrivKey64 := base64.StdEncoding.EncodeToString(certPrivKeyPEM)
+
+	return ca64, cert64, certPrivKey64, nil
+}
 
-	cert64, err := bytesTo64("CERTIFICATE", certBytes)
+//
+// SignCertificateRequest issues a certificate for the PEM encoded
+// certificate signing request, so the private key never leaves the
+// requester.  The subject in the request is ignored; the certificate
+// is issued for name.  The PEM encoded certificate and its chain are
+// returned.
+//
+func (c *CA) SignCertificateRequest(name CertificateName, csrPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no PEM encoded certificate request found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
 	if err != nil {
-		return "", "", "", err
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("certificate request signature: %v", err)
+	}
+	if err := checkPublicKey(csr.PublicKey); err != nil {
+		return nil, err
 	}
 
-	certPrivKey64, err := bytesTo64("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(certPrivKey))
+	template, err := c.certificateTemplate(name)
 	if err != nil {
-		return "", "", "", err
+		return nil, err
 	}
-
-	return ca64, cert64, certPrivKey64, nil
+	return c.signTemplate(template, csr.PublicKey)
 }
 
-// GetCACert returns the authority certificate encoded as base64.
-func (c *CA) GetCACert() (string, error) {
-	return bytesTo64("CERTIFICATE", c.caCert.Certificate[0])
+// certificateTemplate checks the requested lifetime and makes the template
+// for a client certificate carrying name.
+func (c *CA) certificateTemplate(name CertificateName) (*x509.Certificate, error) {
+	now := time.Now().UTC()
+
+	lifetime := c.config.certificateLifetime()
+	if name.Lifetime < 0 {
+		return nil, fmt.Errorf("certificate lifetime must be positive")
+	}
+	if name.Lifetime > lifetime {
+		return nil, fmt.Errorf("certificate lifetime may not be more than %d seconds", int64(lifetime/time.Second))
+	}
+	if name.Lifetime > 0 {
+		lifetime = name.Lifetime
+	}
+
+	jsonName, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{
+					Type:  []int{2, 5, 4, OpsMxOIDValue},
+					Value: string(jsonName),
+				},
+			},
+		},
+		NotBefore:   now,
+		NotAfter:    now.Add(lifetime),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}, nil
 }
 
-func bytesTo64(prefix string, data []byte) (string, error) {
-	p, err := toPEM(data, prefix)
+// signTemplate signs the template for the public key, returning the PEM encoded
+// certificate followed by the authority's chain.
+func (c *CA) signTemplate(template *x509.Certificate, pub crypto.PublicKey) ([]byte, error) {
+	caCert, caKey, chain := c.signer()
+
+	certBytes, err := createCertificate(template, caCert, pub, caKey)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return chainToPEM(certBytes, chain)
+}
+
+// createCertificate signs the template with the CA key.  Signers which log
+// what they sign are told which certificate it is.
+func createCertificate(template *x509.Certificate, parent *x509.Certificate, pub crypto.PublicKey, key crypto.PrivateKey) ([]byte, error) {
+	if s, ok := key.(certificateSigner); ok {
+		key = s.forCertificate(template)
+	}
+	return x509.CreateCertificate(crand.Reader, template, parent, pub, key)
+}
+
+// GetCACert returns the authority certificate and its chain, followed by
+// any others in the trust bundle, PEM encoded and then encoded as base64.
+func (c *CA) GetCACert() (string, error) {
+	c.RLock()
+	defer c.RUnlock()
+	p := []byte{}
+	for _, cert := range c.trusted {
+		certPEM, err := toPEM(cert.Raw, "CERTIFICATE")
+		if err != nil {
+			return "", err
+		}
+		p = append(p, certPEM...)
 	}
 	return base64.StdEncoding.EncodeToString(p), nil
 }
 
 //
-// MakeCertPool will return a certificate pool with our CA installed.
+// MakeCertPool will return a certificate pool with our CA and its chain,
+// and any others in the trust bundle, installed.  The pool must not be
+// modified, as it is shared until the CA is next reloaded.
 //
 func (c *CA) MakeCertPool() (*x509.CertPool, error) {
-	caCertPool := x509.NewCertPool()
-	for _, cert := range c.caCert.Certificate {
-		x, err := x509.ParseCertificate(cert)
+	c.RLock()
+	defer c.RUnlock()
+	return c.certPool, nil
+}
+
+//
+// ServerTLSConfig returns a tls.Config for a listener which verifies client
+// certificates against our trusted CAs.  The server certificate and the
+// client CA pool are looked up for each connection, so both may be
+// replaced while the listener is running.  Callers which wrap the config,
+// such as gRPC and net/http, set NextProtos only on their own copy, so
+// the protocols to negotiate must be passed in.
+//
+func (c *CA) ServerTLSConfig(serverCert *RenewableCertificate, clientAuth tls.ClientAuthType, minVersion uint16, nextProtos []string) *tls.Config {
+	config := &tls.Config{
+		ClientAuth:            clientAuth,
+		GetCertificate:        serverCert.GetCertificate,
+		MinVersion:            minVersion,
+		NextProtos:            nextProtos,
+		VerifyPeerCertificate: c.VerifyPeerCertificate,
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		certPool, err := c.MakeCertPool()
 		if err != nil {
-			return nil, fmt.Errorf("unable to parse certificate: %v", err)
+			return nil, err
 		}
-		caCertPool.AddCert(x)
-		caCertPool.AppendCertsFromPEM(cert)
+		connConfig := config.Clone()
+		connConfig.GetConfigForClient = nil
+		connConfig.ClientCAs = certPool
+		return connConfig, nil
+	}
+	return config
+}
+
+//
+// RevokeCertificate adds the certificate's serial number to the revocation list.
+// The name is optional, and is recorded only to make the list easier to read.
+// The certificate was issued no later than now, so it is kept on the list
+// for the longest lifetime we issue.
+//
+func (c *CA) RevokeCertificate(serial *big.Int, name *CertificateName) error {
+	lifetime := c.config.certificateLifetime()
+	if server := c.config.serverCertificateLifetime(); server > lifetime {
+		lifetime = server
 	}
-	return caCertPool, nil
+	return c.revocations.Revoke(serial, name, time.Now().Add(lifetime))
+}
+
+//
+// IsRevoked returns true if the certificate serial number has been revoked.
+//
+func (c *CA) IsRevoked(serial *big.Int) bool {
+	return c.revocations.IsRevoked(serial)
+}
+
+//
+// RevokedCertificates returns every revoked certificate, oldest first.
+//
+func (c *CA) RevokedCertificates() []RevokedCertificate {
+	return c.revocations.List()
+}
+
+//
+// MergeRevokedCertificates adds certificates revoked on another controller.
+//
+func (c *CA) MergeRevokedCertificates(items []RevokedCertificate) error {
+	return c.revocations.Merge(items)
+}
+
+//
+// SubscribeRevocations returns a channel which receives a value whenever
+// a certificate is revoked.  Call UnsubscribeRevocations when done.
+//
+func (c *CA) SubscribeRevocations() chan struct{} {
+	return c.revocations.Subscribe()
+}
+
+//
+// UnsubscribeRevocations removes a channel returned by SubscribeRevocations.
+//
+func (c *CA) UnsubscribeRevocations(ch chan struct{}) {
+	c.revocations.Unsubscribe(ch)
+}
+
+//
+// OnRevoke registers a function to be called each time a certificate is revoked.
+//
+func (c *CA) OnRevoke(listener RevocationListener) {
+	c.revocations.OnRevoke(listener)
+}
+
+//
+// VerifyPeerCertificate rejects revoked certificates, and should be set on
+// the tls.Config of every listener which accepts client certificates.
+//
+func (c *CA) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return c.revocations.VerifyPeerCertificate(rawCerts, verifiedChains)
 }
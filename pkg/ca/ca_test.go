@@ -0,0 +1,224 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCA(t *testing.T, certFile string, keyFile string) []byte {
+	certPEM, keyPEM, err := MakeCertificateAuthority(KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeCertificateAuthority() = %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPEM
+}
+
+func issue(t *testing.T, c *CA) *x509.Certificate {
+	cert, err := c.MakeServerCert([]string{"controller.local"})
+	if err != nil {
+		t.Fatalf("MakeServerCert() = %v", err)
+	}
+	return cert.Leaf
+}
+
+func verifies(c *CA, cert *x509.Certificate) bool {
+	pool, err := c.MakeCertPool()
+	if err != nil {
+		return false
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+func countCACerts(t *testing.T, c *CA) int {
+	ca64, err := c.GetCACert()
+	if err != nil {
+		t.Fatalf("GetCACert() = %v", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(ca64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return count
+		}
+		count++
+	}
+}
+
+func TestCA_rollover(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{
+		CACertFile:      filepath.Join(dir, "tls.crt"),
+		CAKeyFile:       filepath.Join(dir, "tls.key"),
+		TrustBundleFile: filepath.Join(dir, "bundle.pem"),
+	}
+	oldCA := writeCA(t, config.CACertFile, config.CAKeyFile)
+	if err := ioutil.WriteFile(config.TrustBundleFile, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadCAFromFile(config)
+	if err != nil {
+		t.Fatalf("LoadCAFromFile() = %v", err)
+	}
+	oldCert := issue(t, c)
+	if n := countCACerts(t, c); n != 1 {
+		t.Errorf("GetCACert() has %d certificates, want 1", n)
+	}
+	if changed, err := c.Reload(); changed || err != nil {
+		t.Errorf("Reload() with no changes = %v, %v", changed, err)
+	}
+
+	// Introduce a new CA into the bundle, then switch to signing with it.
+	newCAFile := filepath.Join(dir, "new.crt")
+	newCAKey := filepath.Join(dir, "new.key")
+	newCA := writeCA(t, newCAFile, newCAKey)
+	if err := ioutil.WriteFile(config.TrustBundleFile, newCA, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := c.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() after adding to bundle = %v, %v", changed, err)
+	}
+	if n := countCACerts(t, c); n != 2 {
+		t.Errorf("GetCACert() has %d certificates, want 2", n)
+	}
+
+	if err := ioutil.WriteFile(config.CACertFile, newCA, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config.CAKeyFile, mustRead(t, newCAKey), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(config.TrustBundleFile, oldCA, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := c.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() after switching CA = %v, %v", changed, err)
+	}
+	newCert := issue(t, c)
+	if newCert.CheckSignatureFrom(mustParse(t, newCA)) != nil {
+		t.Errorf("certificate not signed by the new CA")
+	}
+	if !verifies(c, oldCert) {
+		t.Errorf("certificate from the old CA no longer verifies")
+	}
+	if !verifies(c, newCert) {
+		t.Errorf("certificate from the new CA does not verify")
+	}
+
+	// Retire the old CA.
+	if err := ioutil.WriteFile(config.TrustBundleFile, []byte{}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := c.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() after retiring old CA = %v, %v", changed, err)
+	}
+	if verifies(c, oldCert) {
+		t.Errorf("certificate from the retired CA still verifies")
+	}
+	if !verifies(c, newCert) {
+		t.Errorf("certificate from the new CA does not verify")
+	}
+}
+
+func TestCA_Reload_keepsCAOnError(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{
+		CACertFile: filepath.Join(dir, "tls.crt"),
+		CAKeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	writeCA(t, config.CACertFile, config.CAKeyFile)
+	c, err := LoadCAFromFile(config)
+	if err != nil {
+		t.Fatalf("LoadCAFromFile() = %v", err)
+	}
+	cert := issue(t, c)
+
+	if err := ioutil.WriteFile(config.CAKeyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Reload(); err == nil {
+		t.Errorf("Reload() with a bad key expected an error")
+	}
+	if !verifies(c, cert) {
+		t.Errorf("CA was replaced after a failed reload")
+	}
+}
+
+func mustRead(t *testing.T, filename string) []byte {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func mustParse(t *testing.T, certPEM []byte) *x509.Certificate {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("no PEM data")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCA_serialNumbers(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "ca-cert.pem")
+	keyFile := filepath.Join(dir, "ca-key.pem")
+	writeCA(t, certFile, keyFile)
+	c, err := LoadCAFromFile(Config{CACertFile: certFile, CAKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("LoadCAFromFile() = %v", err)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		serial := issue(t, c).SerialNumber
+		if serial.Sign() <= 0 || serial.BitLen() > 128 {
+			t.Errorf("serial %s is not a positive 128 bit number", serial)
+		}
+		if seen[serial.String()] {
+			t.Errorf("serial %s issued twice", serial)
+		}
+		seen[serial.String()] = true
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// Key types which may be used for Config.KeyType and CertificateName.KeyType.
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+const (
+	defaultRSABits   = 2048
+	defaultRSACABits = 4096
+	minRSABits       = 2048
+)
+
+//
+// CheckKeyType returns an error if the key type and size could not be
+// generated.  A zero size means the default for the type, and an empty
+// type means RSA.
+//
+func CheckKeyType(keyType string, keyBits int) error {
+	switch keyType {
+	case "", KeyTypeRSA:
+		if keyBits != 0 && keyBits < minRSABits {
+			return fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+	case KeyTypeECDSA:
+		if _, err := ecdsaCurve(keyBits); err != nil {
+			return err
+		}
+	case KeyTypeEd25519:
+		if keyBits != 0 {
+			return fmt.Errorf("ed25519 keys do not have a selectable size")
+		}
+	default:
+		return fmt.Errorf("unknown key type '%s'", keyType)
+	}
+	return nil
+}
+
+func ecdsaCurve(keyBits int) (elliptic.Curve, error) {
+	switch keyBits {
+	case 0, 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("ECDSA keys must be 256, 384, or 521 bits")
+	}
+}
+
+//
+// generateKey makes a new private key.  rsaBits is used for RSA keys if
+// keyBits is zero.
+//
+func generateKey(keyType string, keyBits int, rsaBits int) (crypto.Signer, error) {
+	if err := CheckKeyType(keyType, keyBits); err != nil {
+		return nil, err
+	}
+	switch keyType {
+	case KeyTypeECDSA:
+		curve, err := ecdsaCurve(keyBits)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.GenerateKey(curve, crand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(crand.Reader)
+		return key, err
+	default:
+		if keyBits == 0 {
+			keyBits = rsaBits
+		}
+		return rsa.GenerateKey(crand.Reader, keyBits)
+	}
+}
+
+// checkPublicKey returns an error unless the key is one we would generate.
+func checkPublicKey(pub crypto.PublicKey) error {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA keys must be at least %d bits", minRSABits)
+		}
+	case *ecdsa.PublicKey:
+		if _, err := ecdsaCurve(key.Curve.Params().BitSize); err != nil {
+			return err
+		}
+	case ed25519.PublicKey:
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
+
+// keyToPEM encodes the private key as a PKCS#8 PEM block.
+func keyToPEM(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return toPEM(der, "PRIVATE KEY")
+}
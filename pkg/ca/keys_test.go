@@ -0,0 +1,208 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestCheckKeyType(t *testing.T) {
+	tests := []struct {
+		keyType string
+		keyBits int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{KeyTypeRSA, 0, false},
+		{KeyTypeRSA, 3072, false},
+		{KeyTypeRSA, 1024, true},
+		{KeyTypeECDSA, 0, false},
+		{KeyTypeECDSA, 384, false},
+		{KeyTypeECDSA, 512, true},
+		{KeyTypeEd25519, 0, false},
+		{KeyTypeEd25519, 256, true},
+		{"dsa", 0, true},
+	}
+	for _, tt := range tests {
+		if err := CheckKeyType(tt.keyType, tt.keyBits); (err != nil) != tt.wantErr {
+			t.Errorf("CheckKeyType(%q, %d) error = %v, wantErr %v", tt.keyType, tt.keyBits, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCA_GenerateCertificate_options(t *testing.T) {
+	certPEM, keyPEM, err := MakeCertificateAuthority(KeyTypeEd25519, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeCertificateAuthority() = %v", err)
+	}
+	c, err := MakeCAFromData(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("MakeCAFromData() = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		keyType  string
+		keyBits  int
+		lifetime time.Duration
+		check    func(interface{}) bool
+		wantErr  bool
+	}{
+		{"default", "", 0, 0, isRSA, false},
+		{"ecdsa", KeyTypeECDSA, 384, time.Hour, isECDSA, false},
+		{"ed25519", KeyTypeEd25519, 0, time.Hour, isEd25519, false},
+		{"too long", KeyTypeECDSA, 0, 2 * defaultCertificateLifetime, nil, true},
+		{"bad size", KeyTypeECDSA, 100, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := CertificateName{
+				Agent:    "smith",
+				Purpose:  CertificatePurposeAgent,
+				KeyType:  tt.keyType,
+				KeyBits:  tt.keyBits,
+				Lifetime: tt.lifetime,
+			}
+			_, cert64, key64, err := c.GenerateCertificate(name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			certPEM, _ := base64.StdEncoding.DecodeString(cert64)
+			keyPEM, _ := base64.StdEncoding.DecodeString(key64)
+			if block, _ := pem.Decode(keyPEM); block == nil || block.Type != "PRIVATE KEY" {
+				t.Errorf("key is not PKCS#8 PEM")
+			}
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatalf("X509KeyPair() = %v", err)
+			}
+			if !tt.check(cert.PrivateKey) {
+				t.Errorf("key is %T", cert.PrivateKey)
+			}
+			leaf := cert.Leaf
+			if tt.lifetime > 0 && leaf != nil && leaf.NotAfter.Sub(leaf.NotBefore) != tt.lifetime {
+				t.Errorf("lifetime = %v, want %v", leaf.NotAfter.Sub(leaf.NotBefore), tt.lifetime)
+			}
+		})
+	}
+}
+
+func TestCA_SignCertificateRequest(t *testing.T) {
+	certPEM, keyPEM, err := MakeCertificateAuthority(KeyTypeEd25519, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeCertificateAuthority() = %v", err)
+	}
+	c, err := MakeCAFromData(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("MakeCAFromData() = %v", err)
+	}
+
+	makeCSR := func(key crypto.Signer) []byte {
+		template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "someone-else"}}
+		der, err := x509.CreateCertificateRequest(crand.Reader, template, key)
+		if err != nil {
+			t.Fatalf("CreateCertificateRequest() = %v", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	}
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	_, ed25519Key, _ := ed25519.GenerateKey(crand.Reader)
+	smallRSAKey, _ := rsa.GenerateKey(crand.Reader, 1024)
+	tampered := makeCSR(ecdsaKey)
+	block, _ := pem.Decode(tampered)
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	tampered = pem.EncodeToMemory(block)
+
+	tests := []struct {
+		name    string
+		csr     []byte
+		key     crypto.Signer
+		wantErr bool
+	}{
+		{"ecdsa", makeCSR(ecdsaKey), ecdsaKey, false},
+		{"ed25519", makeCSR(ed25519Key), ed25519Key, false},
+		{"small rsa", makeCSR(smallRSAKey), nil, true},
+		{"bad signature", tampered, nil, true},
+		{"not pem", []byte("garbage"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := CertificateName{Agent: "smith", Purpose: CertificatePurposeAgent}
+			got, err := c.SignCertificateRequest(name, tt.csr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SignCertificateRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			keyPEM, err := keyToPEM(tt.key)
+			if err != nil {
+				t.Fatalf("keyToPEM() = %v", err)
+			}
+			cert, err := tls.X509KeyPair(got, keyPEM)
+			if err != nil {
+				t.Fatalf("X509KeyPair() = %v", err)
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatalf("ParseCertificate() = %v", err)
+			}
+			if leaf.Subject.CommonName != "" {
+				t.Errorf("subject from the request was used: %v", leaf.Subject)
+			}
+			gotName, err := GetCertificateNameFromCert(leaf)
+			if err != nil {
+				t.Fatalf("GetCertificateNameFromCert() = %v", err)
+			}
+			if gotName.Agent != "smith" || gotName.Purpose != CertificatePurposeAgent {
+				t.Errorf("name = %#v", gotName)
+			}
+		})
+	}
+}
+
+func isRSA(key interface{}) bool {
+	_, ok := key.(*rsa.PrivateKey)
+	return ok
+}
+
+func isECDSA(key interface{}) bool {
+	k, ok := key.(*ecdsa.PrivateKey)
+	return ok && k.Curve.Params().BitSize == 384
+}
+
+func isEd25519(key interface{}) bool {
+	_, ok := key.(ed25519.PrivateKey)
+	return ok
+}
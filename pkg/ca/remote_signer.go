@@ -0,0 +1,269 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Paths served by a remote signer.
+const (
+	RemoteSignerPublicKeyPath = "/v1/publicKey"
+	RemoteSignerSignPath      = "/v1/sign"
+)
+
+const remoteSignerTimeout = 10 * time.Second
+
+// RemoteSignerPublicKeyResponse holds the signer's PKIX encoded public key.
+type RemoteSignerPublicKeyResponse struct {
+	PublicKey []byte `json:"publicKey"`
+}
+
+//
+// RemoteSignerSignRequest asks for a digest to be signed.  Hash is the
+// crypto.Hash used to make the digest, or zero for Ed25519, where the
+// digest is the whole message.  PSSSaltLength is set only for RSA-PSS.
+// Subject and Serial describe the certificate being signed, and are
+// only logged by the signer.
+//
+type RemoteSignerSignRequest struct {
+	Digest        []byte      `json:"digest"`
+	Hash          crypto.Hash `json:"hash"`
+	PSSSaltLength *int        `json:"pssSaltLength,omitempty"`
+	Subject       string      `json:"subject,omitempty"`
+	Serial        string      `json:"serial,omitempty"`
+}
+
+// RemoteSignerSignResponse holds the signature.
+type RemoteSignerSignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+//
+// remoteSigner implements crypto.Signer by sending each digest to a
+// separate process over a Unix socket, so the CA key is never loaded by
+// the controller.
+//
+type remoteSigner struct {
+	client *http.Client
+	public crypto.PublicKey
+}
+
+func newRemoteSigner(config SignerConfig) (crypto.Signer, error) {
+	if len(config.Address) == 0 {
+		return nil, fmt.Errorf("address is not set")
+	}
+	s := &remoteSigner{
+		client: &http.Client{
+			Timeout: remoteSignerTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", config.Address)
+				},
+			},
+		},
+	}
+
+	var resp RemoteSignerPublicKeyResponse
+	if err := s.call("GET", RemoteSignerPublicKeyPath, nil, &resp); err != nil {
+		return nil, err
+	}
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key: %v", err)
+	}
+	s.public = public
+	return s, nil
+}
+
+func (s *remoteSigner) call(method string, path string, request interface{}, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		content, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+	// The host is ignored, as we always dial the socket.
+	req, err := http.NewRequest(method, "http://signer"+path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("signer returned %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *remoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.sign(RemoteSignerSignRequest{Digest: digest}, opts)
+}
+
+// forCertificate returns a signer which also sends the certificate's
+// subject and serial, so the remote signer can log what it signs.
+func (s *remoteSigner) forCertificate(cert *x509.Certificate) crypto.Signer {
+	return &remoteCertificateSigner{
+		remoteSigner: s,
+		subject:      describeSubject(cert),
+		serial:       cert.SerialNumber.Text(16),
+	}
+}
+
+func (s *remoteSigner) sign(req RemoteSignerSignRequest, opts crypto.SignerOpts) ([]byte, error) {
+	req.Hash = opts.HashFunc()
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		saltLength := pss.SaltLength
+		req.PSSSaltLength = &saltLength
+	}
+	var resp RemoteSignerSignResponse
+	if err := s.call("POST", RemoteSignerSignPath, req, &resp); err != nil {
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	return resp.Signature, nil
+}
+
+type remoteCertificateSigner struct {
+	*remoteSigner
+	subject string
+	serial  string
+}
+
+func (s *remoteCertificateSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.sign(RemoteSignerSignRequest{Digest: digest, Subject: s.subject, Serial: s.serial}, opts)
+}
+
+// describeSubject returns our JSON name from the certificate's subject if
+// it has one, or the subject as a string.
+func describeSubject(cert *x509.Certificate) string {
+	for _, atv := range cert.Subject.ExtraNames {
+		if value, ok := atv.Value.(string); ok && atv.Type.Equal([]int{2, 5, 4, OpsMxOIDValue}) {
+			return value
+		}
+	}
+	return cert.Subject.String()
+}
+
+type remoteSignerPeerKey struct{}
+
+//
+// WithRemoteSignerPeer returns a context which records a description of the
+// process connected to the signer, such as its credentials on the Unix
+// socket.  A signer process uses it as its http.Server's ConnContext, and
+// the description is logged with each signing request.
+//
+func WithRemoteSignerPeer(ctx context.Context, peer string) context.Context {
+	return context.WithValue(ctx, remoteSignerPeerKey{}, peer)
+}
+
+func remoteSignerPeer(r *http.Request) string {
+	if peer, ok := r.Context().Value(remoteSignerPeerKey{}).(string); ok {
+		return peer
+	}
+	if len(r.RemoteAddr) > 0 {
+		return r.RemoteAddr
+	}
+	return "unknown peer"
+}
+
+//
+// NewRemoteSignerHandler returns the HTTP handler a remote signer process
+// serves on its Unix socket.  Access is controlled by the socket's file
+// permissions.  Each signing request is logged with the peer and the
+// certificate it is for.
+//
+func NewRemoteSignerHandler(signer crypto.Signer) (http.Handler, error) {
+	public, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(RemoteSignerPublicKeyPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeSignerResponse(w, RemoteSignerPublicKeyResponse{PublicKey: public})
+	})
+	mux.HandleFunc(RemoteSignerSignPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req RemoteSignerSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var opts crypto.SignerOpts = req.Hash
+		if req.PSSSaltLength != nil {
+			opts = &rsa.PSSOptions{SaltLength: *req.PSSSaltLength, Hash: req.Hash}
+		}
+		subject, serial := req.Subject, req.Serial
+		if len(subject) == 0 {
+			subject = "unknown"
+		}
+		if len(serial) == 0 {
+			serial = "unknown"
+		}
+		log.Printf("Signing for %s: subject %s, serial %s", remoteSignerPeer(r), subject, serial)
+		signature, err := signer.Sign(crand.Reader, req.Digest, opts)
+		if err != nil {
+			log.Printf("Unable to sign for %s: %v", remoteSignerPeer(r), err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeSignerResponse(w, RemoteSignerSignResponse{Signature: signature})
+	})
+	return mux, nil
+}
+
+func writeSignerResponse(w http.ResponseWriter, response interface{}) {
+	content, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	if _, err := w.Write(content); err != nil {
+		log.Printf("Unable to write response: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//
+// RenewableCertificate holds a certificate which can be replaced while
+// it is in use by a tls.Config, using the GetCertificate or
+// GetClientCertificate callbacks.
+//
+type RenewableCertificate struct {
+	sync.RWMutex
+	issue func() (*tls.Certificate, error)
+	cert  *tls.Certificate
+	leaf  *x509.Certificate
+}
+
+//
+// NewRenewableCertificate calls issue to get the first certificate, and
+// again each time the certificate is renewed.
+//
+func NewRenewableCertificate(issue func() (*tls.Certificate, error)) (*RenewableCertificate, error) {
+	r := &RenewableCertificate{issue: issue}
+	if err := r.Renew(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//
+// Renew issues a new certificate and switches to it.  On error, the
+// current certificate is kept.
+//
+func (r *RenewableCertificate) Renew() error {
+	cert, err := r.issue()
+	if err != nil {
+		return err
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		if len(cert.Certificate) == 0 {
+			return fmt.Errorf("issued certificate is empty")
+		}
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.cert = cert
+	r.leaf = leaf
+	return nil
+}
+
+//
+// NeedsRenewal returns true once two thirds of the certificate's validity
+// period has passed, leaving time to retry if renewing fails.
+//
+func (r *RenewableCertificate) NeedsRenewal(now time.Time) bool {
+	r.RLock()
+	defer r.RUnlock()
+	lifetime := r.leaf.NotAfter.Sub(r.leaf.NotBefore)
+	return !now.Before(r.leaf.NotBefore.Add(lifetime / 3 * 2))
+}
+
+//
+// NotAfter returns the expiry time of the current certificate.
+//
+func (r *RenewableCertificate) NotAfter() time.Time {
+	r.RLock()
+	defer r.RUnlock()
+	return r.leaf.NotAfter
+}
+
+//
+// GetCertificate returns the current certificate, and may be used as the
+// GetCertificate function in a server's tls.Config.
+//
+func (r *RenewableCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
+
+//
+// GetClientCertificate returns the current certificate, and may be used as
+// the GetClientCertificate function in a client's tls.Config.
+//
+func (r *RenewableCertificate) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
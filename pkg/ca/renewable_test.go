@@ -0,0 +1,81 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRenewableCertificate(t *testing.T) {
+	notBefore := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	issued := 0
+	var issueErr error
+	r, err := NewRenewableCertificate(func() (*tls.Certificate, error) {
+		if issueErr != nil {
+			return nil, issueErr
+		}
+		issued++
+		return &tls.Certificate{
+			Leaf: &x509.Certificate{
+				NotBefore: notBefore,
+				NotAfter:  notBefore.Add(300 * time.Hour),
+			},
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewRenewableCertificate() = %v", err)
+	}
+	first, _ := r.GetCertificate(nil)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"new", notBefore, false},
+		{"before two thirds", notBefore.Add(199 * time.Hour), false},
+		{"at two thirds", notBefore.Add(200 * time.Hour), true},
+		{"expired", notBefore.Add(400 * time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.NeedsRenewal(tt.now); got != tt.want {
+				t.Errorf("NeedsRenewal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := r.Renew(); err != nil {
+		t.Fatalf("Renew() = %v", err)
+	}
+	second, _ := r.GetClientCertificate(nil)
+	if issued != 2 || second == first {
+		t.Errorf("Renew() did not replace the certificate")
+	}
+
+	issueErr = fmt.Errorf("CA unavailable")
+	if err := r.Renew(); err == nil {
+		t.Errorf("Renew() expected an error")
+	}
+	if current, _ := r.GetCertificate(nil); current != second {
+		t.Errorf("certificate replaced after a failed renewal")
+	}
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/util"
+)
+
+// CertificateRevoker implements revoking certificates, and checking
+// presented certificates against the revoked list.
+type CertificateRevoker interface {
+	RevokeCertificate(serial *big.Int, name *CertificateName) error
+	VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+}
+
+// RevokedCertificate records a single revoked certificate.  ExpiresAt
+// is the latest the certificate can expire, after which it is dropped
+// from the list.
+type RevokedCertificate struct {
+	Serial    string           `json:"serial"`
+	Name      *CertificateName `json:"name,omitempty"`
+	RevokedAt time.Time        `json:"revokedAt"`
+	ExpiresAt time.Time        `json:"expiresAt"`
+}
+
+// RevokedKey implements util.Revoked.
+func (r RevokedCertificate) RevokedKey() string { return r.Serial }
+
+// RevokedTime implements util.Revoked.
+func (r RevokedCertificate) RevokedTime() time.Time { return r.RevokedAt }
+
+// ExpiryTime implements util.Revoked.
+func (r RevokedCertificate) ExpiryTime() time.Time { return r.ExpiresAt }
+
+// RevocationListener is called after a certificate is revoked.
+type RevocationListener func(RevokedCertificate)
+
+//
+// RevocationList holds the serial numbers of revoked certificates.  If
+// a filename is set, the list is loaded from it and every change is
+// written back to it.
+//
+type RevocationList struct {
+	sync.RWMutex
+	revoked     *util.RevokedSet
+	listeners   []RevocationListener
+	subscribers []chan struct{}
+}
+
+//
+// LoadRevocationList loads a revocation list from the file.  A missing
+// file is an empty list, and will be created on the first revocation.
+// If filename is empty, the list is kept only in memory.
+//
+func LoadRevocationList(filename string) (*RevocationList, error) {
+	revoked, err := util.LoadRevokedSet(filename, "revocation list", func(content []byte) ([]util.Revoked, error) {
+		var list []RevokedCertificate
+		if err := json.Unmarshal(content, &list); err != nil {
+			return nil, err
+		}
+		ret := make([]util.Revoked, len(list))
+		for i, item := range list {
+			ret[i] = item
+		}
+		return ret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RevocationList{revoked: revoked}, nil
+}
+
+//
+// Revoke adds the serial number to the list, saves the list, and then
+// notifies any listeners.  expiresAt is the latest the certificate can
+// expire, or zero to keep it forever.  Revoking an already revoked
+// certificate is not an error, and listeners are called again.
+//
+func (r *RevocationList) Revoke(serial *big.Int, name *CertificateName, expiresAt time.Time) error {
+	r.Lock()
+	item, found := r.revoked.Get(serial.String())
+	if !found {
+		item = RevokedCertificate{
+			Serial:    serial.String(),
+			Name:      name,
+			RevokedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		}
+		if _, err := r.revoked.Add(item); err != nil {
+			r.Unlock()
+			return err
+		}
+		r.notifySubscribers()
+	}
+	listeners := r.listeners
+	r.Unlock()
+
+	for _, listener := range listeners {
+		listener(item.(RevokedCertificate))
+	}
+	return nil
+}
+
+//
+// Merge adds certificates revoked elsewhere, such as on a peer controller,
+// keeping their original revocation time.  Only certificates not already
+// revoked are added and passed to listeners, so revocations passed back
+// and forth between controllers settle once every list is the same.
+//
+func (r *RevocationList) Merge(items []RevokedCertificate) error {
+	r.Lock()
+	list := make([]util.Revoked, len(items))
+	for i, item := range items {
+		list[i] = item
+	}
+	added, err := r.revoked.Add(list...)
+	if err != nil {
+		r.Unlock()
+		return err
+	}
+	if len(added) > 0 {
+		r.notifySubscribers()
+	}
+	listeners := r.listeners
+	r.Unlock()
+
+	for _, item := range added {
+		for _, listener := range listeners {
+			listener(item.(RevokedCertificate))
+		}
+	}
+	return nil
+}
+
+//
+// IsRevoked returns true if the serial number has been revoked.
+//
+func (r *RevocationList) IsRevoked(serial *big.Int) bool {
+	r.RLock()
+	defer r.RUnlock()
+	_, found := r.revoked.Get(serial.String())
+	return found
+}
+
+//
+// List returns all revoked certificates, oldest first.
+//
+func (r *RevocationList) List() []RevokedCertificate {
+	r.RLock()
+	defer r.RUnlock()
+	list := r.revoked.List()
+	ret := make([]RevokedCertificate, len(list))
+	for i, item := range list {
+		ret[i] = item.(RevokedCertificate)
+	}
+	return ret
+}
+
+//
+// OnRevoke adds a listener which is called each time a certificate is revoked.
+//
+func (r *RevocationList) OnRevoke(listener RevocationListener) {
+	r.Lock()
+	defer r.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+//
+// Subscribe returns a channel which will receive a value whenever the list
+// changes.  Notifications are coalesced, so a slow reader will see only
+// one pending notification.  Call Unsubscribe when done.
+//
+func (r *RevocationList) Subscribe() chan struct{} {
+	r.Lock()
+	defer r.Unlock()
+	c := make(chan struct{}, 1)
+	r.subscribers = append(r.subscribers, c)
+	return c
+}
+
+//
+// Unsubscribe removes a channel returned by Subscribe.
+//
+func (r *RevocationList) Unsubscribe(c chan struct{}) {
+	r.Lock()
+	defer r.Unlock()
+	for i, s := range r.subscribers {
+		if s == c {
+			r.subscribers = append(r.subscribers[:i], r.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifySubscribers must be called with the lock held.
+func (r *RevocationList) notifySubscribers() {
+	for _, c := range r.subscribers {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//
+// VerifyPeerCertificate rejects any verified chain whose leaf certificate
+// has been revoked.  It is intended to be used as the VerifyPeerCertificate
+// function in a tls.Config, which is called after the normal chain
+// verification.
+//
+func (r *RevocationList) VerifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		if r.IsRevoked(chain[0].SerialNumber) {
+			return fmt.Errorf("certificate serial %s has been revoked", chain[0].SerialNumber)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevocationList_persists(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "revoked.json")
+
+	r, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatalf("LoadRevocationList() on a missing file: %v", err)
+	}
+	notified := []string{}
+	r.OnRevoke(func(item RevokedCertificate) {
+		notified = append(notified, item.Serial)
+	})
+
+	name := &CertificateName{Agent: "smith", Purpose: CertificatePurposeAgent}
+	if err := r.Revoke(big.NewInt(42), name, time.Time{}); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if err := r.Revoke(big.NewInt(42), nil, time.Time{}); err != nil {
+		t.Fatalf("Revoke() again = %v", err)
+	}
+	if len(notified) != 2 || notified[0] != "42" {
+		t.Errorf("listener called with %v, want [42 42]", notified)
+	}
+
+	r2, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatalf("LoadRevocationList() = %v", err)
+	}
+	if !r2.IsRevoked(big.NewInt(42)) {
+		t.Errorf("IsRevoked(42) = false after reload")
+	}
+	if r2.IsRevoked(big.NewInt(43)) {
+		t.Errorf("IsRevoked(43) = true")
+	}
+	list := r2.List()
+	if len(list) != 1 || list[0].Name == nil || list[0].Name.Agent != "smith" {
+		t.Errorf("List() = %#v", list)
+	}
+}
+
+func TestRevocationList_Merge(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "revoked.json")
+	r, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revoke(big.NewInt(1), nil, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	notified := []string{}
+	r.OnRevoke(func(item RevokedCertificate) {
+		notified = append(notified, item.Serial)
+	})
+	changed := r.Subscribe()
+	defer r.Unsubscribe(changed)
+
+	revokedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	merged := []RevokedCertificate{
+		{Serial: "1", RevokedAt: revokedAt},
+		{Serial: "2", RevokedAt: revokedAt},
+	}
+	if err := r.Merge(merged); err != nil {
+		t.Fatalf("Merge() = %v", err)
+	}
+	if len(notified) != 1 || notified[0] != "2" {
+		t.Errorf("listener called with %v, want [2]", notified)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Errorf("subscriber not notified")
+	}
+
+	// merging the same list again changes nothing.
+	if err := r.Merge(merged); err != nil {
+		t.Fatalf("Merge() again = %v", err)
+	}
+	if len(notified) != 1 {
+		t.Errorf("listener called again with %v", notified)
+	}
+	select {
+	case <-changed:
+		t.Errorf("subscriber notified of no change")
+	default:
+	}
+
+	r2, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := r2.List()
+	if len(list) != 2 || list[0].Serial != "2" || !list[0].RevokedAt.Equal(revokedAt) {
+		t.Errorf("List() = %#v", list)
+	}
+}
+
+func TestRevocationList_prunesExpired(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "revoked.json")
+	r, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revoke(big.NewInt(1), nil, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	err = r.Merge([]RevokedCertificate{
+		{Serial: "2", RevokedAt: time.Now().Add(-2 * time.Hour), ExpiresAt: time.Now().Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.IsRevoked(big.NewInt(2)) {
+		t.Errorf("IsRevoked(2) = true for an expired certificate")
+	}
+
+	r2, err := LoadRevocationList(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := r2.List(); len(list) != 1 || list[0].Serial != "1" {
+		t.Errorf("List() = %#v", list)
+	}
+}
+
+func TestLoadRevocationList_invalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "revoked.json")
+	if err := ioutil.WriteFile(filename, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRevocationList(filename); err == nil {
+		t.Errorf("LoadRevocationList() expected an error")
+	}
+}
+
+func TestRevocationList_VerifyPeerCertificate(t *testing.T) {
+	r, err := LoadRevocationList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revoke(big.NewInt(42), nil, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := func(serial int64) [][]*x509.Certificate {
+		return [][]*x509.Certificate{{{SerialNumber: big.NewInt(serial)}}}
+	}
+
+	tests := []struct {
+		name    string
+		chains  [][]*x509.Certificate
+		wantErr bool
+	}{
+		{"no chains", nil, false},
+		{"valid", chain(1), false},
+		{"revoked", chain(42), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.VerifyPeerCertificate(nil, tt.chains); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPeerCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
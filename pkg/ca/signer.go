@@ -0,0 +1,146 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+)
+
+// Signer types which may be used for SignerConfig.Type.
+const (
+	// SignerTypeFile loads the CA key from Config.CAKeyFile.
+	SignerTypeFile = "file"
+
+	// SignerTypeRemote asks a separate process, such as the ca-signer
+	// command, to sign over a Unix socket.
+	SignerTypeRemote = "remote"
+)
+
+//
+// SignerConfig selects where the CA private key is held.  Address and
+// Options are interpreted by the signer type.
+//
+type SignerConfig struct {
+	Type    string            `yaml:"type,omitempty" json:"type,omitempty"`
+	Address string            `yaml:"address,omitempty" json:"address,omitempty"`
+	Options map[string]string `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+func (c *SignerConfig) isFile() bool {
+	return len(c.Type) == 0 || c.Type == SignerTypeFile
+}
+
+//
+// SignerFactory makes a signer from its configuration.  The signer's
+// public key must match the CA certificate, which is checked when the
+// CA is loaded.  Signers for PKCS#11 tokens or cloud KMS keys implement
+// crypto.Signer, and are added with RegisterSignerType.
+//
+type SignerFactory func(SignerConfig) (crypto.Signer, error)
+
+// certificateSigner is implemented by signers which are told which
+// certificate they are signing, such as the remote signer, which logs it.
+type certificateSigner interface {
+	forCertificate(cert *x509.Certificate) crypto.Signer
+}
+
+var (
+	signerTypesLock sync.Mutex
+	signerTypes     = map[string]SignerFactory{
+		SignerTypeRemote: newRemoteSigner,
+	}
+)
+
+//
+// RegisterSignerType adds a signer type, which may then be selected with
+// SignerConfig.Type.  It is intended to be called from an init function.
+//
+func RegisterSignerType(name string, factory SignerFactory) {
+	signerTypesLock.Lock()
+	defer signerTypesLock.Unlock()
+	signerTypes[name] = factory
+}
+
+func findSignerType(name string) (SignerFactory, error) {
+	signerTypesLock.Lock()
+	defer signerTypesLock.Unlock()
+	factory, found := signerTypes[name]
+	if !found {
+		return nil, fmt.Errorf("unknown signer type '%s'", name)
+	}
+	return factory, nil
+}
+
+// makeSigner returns the configured signer, checking it holds the key
+// for the CA certificate.
+func makeSigner(config SignerConfig, caCert *x509.Certificate) (crypto.Signer, error) {
+	factory, err := findSignerType(config.Type)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to use %s signer: %v", config.Type, err)
+	}
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(caCert.PublicKey) {
+		return nil, fmt.Errorf("%s signer's key does not match the CA certificate", config.Type)
+	}
+	return signer, nil
+}
+
+//
+// ParsePrivateKey returns the first private key in the PEM data, which
+// may be PKCS#8, PKCS#1 RSA, or SEC 1 EC encoded.
+//
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			switch k := key.(type) {
+			case *rsa.PrivateKey:
+				return k, nil
+			case *ecdsa.PrivateKey:
+				return k, nil
+			case ed25519.PrivateKey:
+				return k, nil
+			default:
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMakeIntermediateCertificateAuthority(t *testing.T) {
+	rootPEM, rootKeyPEM, err := MakeCertificateAuthority(KeyTypeECDSA, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeCertificateAuthority() = %v", err)
+	}
+	certPEM, keyPEM, err := MakeIntermediateCertificateAuthority(rootPEM, rootKeyPEM, KeyTypeECDSA, 0, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("MakeIntermediateCertificateAuthority() = %v", err)
+	}
+	root := mustParse(t, rootPEM)
+	intermediate := mustParse(t, certPEM)
+	if !intermediate.NotAfter.Equal(root.NotAfter) {
+		t.Errorf("intermediate expires %v, want it capped at %v", intermediate.NotAfter, root.NotAfter)
+	}
+
+	c, err := MakeCAFromData(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("MakeCAFromData() = %v", err)
+	}
+	if n := countCACerts(t, c); n != 2 {
+		t.Errorf("GetCACert() has %d certificates, want 2", n)
+	}
+
+	// A client which trusts only the root must be able to verify a
+	// certificate using the chain sent with it.
+	_, certOut, _, err := c.GenerateCertificate(CertificateName{Agent: "smith", Purpose: CertificatePurposeAgent})
+	if err != nil {
+		t.Fatalf("GenerateCertificate() = %v", err)
+	}
+	leafPEM, err := base64.StdEncoding.DecodeString(certOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, leafPEM = pem.Decode(leafPEM)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("issued certificate has %d certificates in its chain, want 2", len(certs))
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(certs[1])
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		t.Errorf("issued certificate does not verify against the root: %v", err)
+	}
+	if !verifies(c, certs[0]) {
+		t.Errorf("issued certificate does not verify against MakeCertPool()")
+	}
+}
+
+func TestCA_remoteSigner(t *testing.T) {
+	dir, err := os.MkdirTemp("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCA(t, certFile, keyFile)
+
+	key, err := ParsePrivateKey(mustRead(t, keyFile))
+	if err != nil {
+		t.Fatalf("ParsePrivateKey() = %v", err)
+	}
+	handler, err := NewRemoteSignerHandler(key)
+	if err != nil {
+		t.Fatalf("NewRemoteSignerHandler() = %v", err)
+	}
+	socket := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler: handler,
+		ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
+			return WithRemoteSignerPeer(ctx, "test-peer")
+		},
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	c, err := LoadCAFromFile(Config{
+		CACertFile: certFile,
+		Signer:     SignerConfig{Type: SignerTypeRemote, Address: socket},
+	})
+	if err != nil {
+		t.Fatalf("LoadCAFromFile() = %v", err)
+	}
+	cert := issue(t, c)
+	if !verifies(c, cert) {
+		t.Errorf("certificate signed remotely does not verify")
+	}
+	want := "Signing for test-peer: subject " + cert.Subject.String() + ", serial " + cert.SerialNumber.Text(16)
+	if !strings.Contains(logged.String(), want) {
+		t.Errorf("signing request not logged, want %q in:\n%s", want, logged.String())
+	}
+
+	// A signer holding a different key must be rejected.
+	otherCert := filepath.Join(dir, "other.crt")
+	writeCA(t, otherCert, filepath.Join(dir, "other.key"))
+	_, err = LoadCAFromFile(Config{
+		CACertFile: otherCert,
+		Signer:     SignerConfig{Type: SignerTypeRemote, Address: socket},
+	})
+	if err == nil {
+		t.Errorf("LoadCAFromFile() with a mismatched signer expected an error")
+	}
+}
+
+func TestLoadCAFromFile_unknownSigner(t *testing.T) {
+	_, err := LoadCAFromFile(Config{
+		CACertFile: "/nonexistent",
+		Signer:     SignerConfig{Type: "pkcs11"},
+	})
+	if err == nil {
+		t.Errorf("LoadCAFromFile() with an unknown signer type expected an error")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8 := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"pkcs1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), false},
+		{"ec", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}), false},
+		{"pkcs8 rsa", pkcs8(rsaKey), false},
+		{"pkcs8 ecdsa", pkcs8(ecKey), false},
+		{"pkcs8 ed25519", pkcs8(edKey), false},
+		{"not pem", []byte("garbage"), true},
+		{"wrong type", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePrivateKey(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -20,6 +20,8 @@
 //
 package fwdapi
 
+import "time"
+
 // Endpoint paths
 const (
 	KubeconfigEndpoint = "/api/v1/generateKubectlComponents"
@@ -27,14 +29,34 @@ const (
 	ServiceEndpoint    = "/api/v1/generateServiceCredentials"
 	StatisticsEndpoint = "/api/v1/getAgentStatistics"
 	ControlEndpoint    = "/api/v1/generateControlCredentials"
+	RevokeEndpoint     = "/api/v1/revokeCertificate"
+	CommandEndpoint    = "/api/v1/generateRemoteCommandCredentials"
+
+	RevokeServiceTokenEndpoint   = "/api/v1/revokeServiceToken"
+	RevokedServiceTokensEndpoint = "/api/v1/getRevokedServiceTokens"
 )
 
+//
+// CertificateOptions may be included in any request which issues a
+// certificate.  KeyType is one of "rsa", "ecdsa", or "ed25519", and
+// KeyBits is the RSA key size or ECDSA curve size.  If unset, the
+// controller's configured defaults are used.  LifetimeSeconds may shorten
+// the certificate's lifetime from the controller's default, but not
+// lengthen it.
+//
+type CertificateOptions struct {
+	KeyType         string `json:"keyType,omitempty"`
+	KeyBits         int    `json:"keyBits,omitempty"`
+	LifetimeSeconds int64  `json:"lifetimeSeconds,omitempty"`
+}
+
 //
 // KubeConfigRequest defines the request for the KubeconfigEndpoint
 //
 type KubeConfigRequest struct {
 	AgentName string `json:"agentName,omitempty"`
 	Name      string `json:"name,omitempty"`
+	CertificateOptions
 }
 
 //
@@ -54,6 +76,7 @@ type KubeConfigResponse struct {
 //
 type ManifestRequest struct {
 	AgentName string `json:"agentName,omitempty"`
+	CertificateOptions
 }
 
 //
@@ -84,6 +107,16 @@ type ServiceCredentialRequest struct {
 	AgentName string `json:"agentName,omitempty"`
 	Type      string `json:"Type,omitempty"`
 	Name      string `json:"Name,omitempty"`
+
+	// LifetimeSeconds may shorten the token's lifetime from the
+	// controller's configured default, but not lengthen it.
+	LifetimeSeconds int64 `json:"lifetimeSeconds,omitempty"`
+
+	// Methods, if set, are the only HTTP methods the token may be used with.
+	Methods []string `json:"methods,omitempty"`
+
+	// PathPrefix, if set, limits the token to URI paths at or below it.
+	PathPrefix string `json:"pathPrefix,omitempty"`
 }
 
 //
@@ -99,6 +132,8 @@ type ServiceCredentialResponse struct {
 	Credential     interface{} `json:"credential,omitempty"`
 	URL            string      `json:"url,omitempty"`
 	CACert         string      `json:"caCert,omitempty"`
+	TokenID        string      `json:"tokenId,omitempty"`
+	ExpiresAt      int64       `json:"expiresAt,omitempty"` // unix seconds
 }
 
 // BasicCredentialResponse is the "http basic auth" configuration.
@@ -118,6 +153,7 @@ type AwsCredentialResponse struct {
 //
 type ControlCredentialsRequest struct {
 	Name string `json:"name,omitempty"`
+	CertificateOptions
 }
 
 //
@@ -130,3 +166,73 @@ type ControlCredentialsResponse struct {
 	Key         string `json:"userKey,omitempty"`
 	CACert      string `json:"caCert,omitempty"`
 }
+
+//
+// RemoteCommandRequest defines the request for the CommandEndpoint
+//
+type RemoteCommandRequest struct {
+	AgentName string `json:"agentName,omitempty"`
+	Name      string `json:"name,omitempty"`
+	CertificateOptions
+}
+
+//
+// RemoteCommandResponse defines the response for the CommandEndpoint.
+// ServerAddress is the host:port of the controller's remote-command listener.
+//
+type RemoteCommandResponse struct {
+	AgentName       string `json:"agentName,omitempty"`
+	Name            string `json:"name,omitempty"`
+	ServerAddress   string `json:"serverAddress,omitempty"`
+	UserCertificate string `json:"userCertificate,omitempty"`
+	UserKey         string `json:"userKey,omitempty"`
+	CACert          string `json:"caCert,omitempty"`
+}
+
+//
+// RevokeCertificateRequest defines the request for the RevokeEndpoint.
+// Exactly one of SerialNumber or Certificate must be set.  SerialNumber
+// is decimal, or hexadecimal with a 0x prefix.  Certificate is the
+// base64 encoded PEM certificate, as returned by the other endpoints.
+//
+type RevokeCertificateRequest struct {
+	SerialNumber string `json:"serialNumber,omitempty"`
+	Certificate  string `json:"certificate,omitempty"`
+}
+
+//
+// RevokeCertificateResponse defines the response for the RevokeEndpoint
+//
+type RevokeCertificateResponse struct {
+	SerialNumber string `json:"serialNumber,omitempty"`
+	Purpose      string `json:"purpose,omitempty"`
+	AgentName    string `json:"agentName,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+//
+// RevokeServiceTokenRequest defines the request for the RevokeServiceTokenEndpoint
+//
+type RevokeServiceTokenRequest struct {
+	TokenID string `json:"tokenId,omitempty"`
+}
+
+//
+// RevokeServiceTokenResponse defines the response for the RevokeServiceTokenEndpoint
+//
+type RevokeServiceTokenResponse struct {
+	TokenID string `json:"tokenId,omitempty"`
+}
+
+// RevokedServiceToken is a single revoked service token ID.
+type RevokedServiceToken struct {
+	TokenID   string    `json:"tokenId,omitempty"`
+	RevokedAt time.Time `json:"revokedAt,omitempty"`
+}
+
+//
+// RevokedServiceTokensResponse defines the response for the RevokedServiceTokensEndpoint
+//
+type RevokedServiceTokensResponse struct {
+	Tokens []RevokedServiceToken `json:"tokens"`
+}
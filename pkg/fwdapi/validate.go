@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
 )
 
 // NamePresent ensures the string is not null.
@@ -38,6 +39,16 @@ func typeValid(n string) bool {
 	return matched
 }
 
+// methodValid ensures an HTTP method is uppercase alpha only
+func methodValid(n string) bool {
+	matched, err := regexp.MatchString("^[A-Z]+$", n)
+	if err != nil {
+		log.Printf("matching method: %v", err)
+		return false
+	}
+	return matched
+}
+
 // Validate ensures that the required fields are set to reasonable values, usually just non-empty strings.
 func (req *ServiceCredentialRequest) Validate() error {
 	if !namePresent(req.AgentName) {
@@ -52,6 +63,47 @@ func (req *ServiceCredentialRequest) Validate() error {
 		return fmt.Errorf("'type' is invalid")
 	}
 
+	if req.LifetimeSeconds < 0 {
+		return fmt.Errorf("'lifetimeSeconds' is invalid")
+	}
+
+	for _, method := range req.Methods {
+		if !methodValid(method) {
+			return fmt.Errorf("'methods' is invalid")
+		}
+	}
+
+	if req.PathPrefix != "" && !strings.HasPrefix(req.PathPrefix, "/") {
+		return fmt.Errorf("'pathPrefix' is invalid")
+	}
+
+	return nil
+}
+
+// keyTypeValid ensures the key type is empty, or one the CA can generate.
+func keyTypeValid(n string) bool {
+	switch n {
+	case "", "rsa", "ecdsa", "ed25519":
+		return true
+	}
+	return false
+}
+
+// validate checks the options are in range.  The CA checks the key size
+// is valid for the key type.
+func (o *CertificateOptions) validate() error {
+	if !keyTypeValid(o.KeyType) {
+		return fmt.Errorf("'keyType' is invalid")
+	}
+
+	if o.KeyBits < 0 || (o.KeyBits > 0 && o.KeyType == "") {
+		return fmt.Errorf("'keyBits' is invalid")
+	}
+
+	if o.LifetimeSeconds < 0 {
+		return fmt.Errorf("'lifetimeSeconds' is invalid")
+	}
+
 	return nil
 }
 
@@ -65,7 +117,7 @@ func (req *KubeConfigRequest) Validate() error {
 		return fmt.Errorf("'name' is invalid")
 	}
 
-	return nil
+	return req.CertificateOptions.validate()
 }
 
 // Validate ensures that the required fields are set to reasonable values, usually just non-empty strings.
@@ -74,7 +126,7 @@ func (req *ManifestRequest) Validate() error {
 		return fmt.Errorf("'agentName' is invalid")
 	}
 
-	return nil
+	return req.CertificateOptions.validate()
 }
 
 // Validate ensures that the required fields are set to reasonable values, usually just non-empty strings.
@@ -83,5 +135,36 @@ func (req *ControlCredentialsRequest) Validate() error {
 		return fmt.Errorf("'name' is invalid")
 	}
 
+	return req.CertificateOptions.validate()
+}
+
+// Validate ensures that the required fields are set to reasonable values, usually just non-empty strings.
+func (req *RemoteCommandRequest) Validate() error {
+	if !namePresent(req.AgentName) {
+		return fmt.Errorf("'agentName' is invalid")
+	}
+
+	if !namePresent(req.Name) {
+		return fmt.Errorf("'name' is invalid")
+	}
+
+	return req.CertificateOptions.validate()
+}
+
+// Validate ensures that exactly one way of identifying the certificate is set.
+func (req *RevokeCertificateRequest) Validate() error {
+	if namePresent(req.SerialNumber) == namePresent(req.Certificate) {
+		return fmt.Errorf("exactly one of 'serialNumber' or 'certificate' must be set")
+	}
+
+	return nil
+}
+
+// Validate ensures that the required fields are set to reasonable values, usually just non-empty strings.
+func (req *RevokeServiceTokenRequest) Validate() error {
+	if !namePresent(req.TokenID) {
+		return fmt.Errorf("'tokenId' is invalid")
+	}
+
 	return nil
 }
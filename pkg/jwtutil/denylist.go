@@ -0,0 +1,111 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtutil
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/opsmx/oes-birger/pkg/util"
+)
+
+// DeniedToken records a single revoked token ID.
+type DeniedToken struct {
+	ID        string    `json:"id"`
+	RevokedAt time.Time `json:"revokedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RevokedKey implements util.Revoked.
+func (t DeniedToken) RevokedKey() string { return t.ID }
+
+// RevokedTime implements util.Revoked.
+func (t DeniedToken) RevokedTime() time.Time { return t.RevokedAt }
+
+// ExpiryTime implements util.Revoked.
+func (t DeniedToken) ExpiryTime() time.Time { return t.ExpiresAt }
+
+//
+// Denylist holds the IDs of revoked tokens.  If a filename is set, the
+// list is loaded from it and every change is written back to it.
+// Entries are dropped once the token would have expired.
+//
+type Denylist struct {
+	sync.RWMutex
+	ids *util.RevokedSet
+}
+
+//
+// LoadDenylist loads a denylist from the file.  A missing file is an
+// empty list, and will be created on the first revocation.  If filename
+// is empty, the list is kept only in memory.
+//
+func LoadDenylist(filename string) (*Denylist, error) {
+	ids, err := util.LoadRevokedSet(filename, "token denylist", func(content []byte) ([]util.Revoked, error) {
+		var list []DeniedToken
+		if err := json.Unmarshal(content, &list); err != nil {
+			return nil, err
+		}
+		ret := make([]util.Revoked, len(list))
+		for i, item := range list {
+			ret[i] = item
+		}
+		return ret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Denylist{ids: ids}, nil
+}
+
+//
+// Revoke adds the token ID to the list and saves it.  expiresAt is the
+// latest the token can expire, after which it need not be remembered;
+// if zero, it is kept forever.  Revoking an already revoked ID is not
+// an error.
+//
+func (d *Denylist) Revoke(id string, expiresAt time.Time) error {
+	d.Lock()
+	defer d.Unlock()
+	_, err := d.ids.Add(DeniedToken{ID: id, RevokedAt: time.Now().UTC(), ExpiresAt: expiresAt})
+	return err
+}
+
+//
+// IsRevoked returns true if the token ID has been revoked.
+//
+func (d *Denylist) IsRevoked(id string) bool {
+	d.RLock()
+	defer d.RUnlock()
+	_, found := d.ids.Get(id)
+	return found
+}
+
+//
+// List returns all revoked token IDs, oldest first.
+//
+func (d *Denylist) List() []DeniedToken {
+	d.RLock()
+	defer d.RUnlock()
+	list := d.ids.List()
+	ret := make([]DeniedToken, len(list))
+	for i, item := range list {
+		ret[i] = item.(DeniedToken)
+	}
+	return ret
+}
@@ -0,0 +1,81 @@
+package jwtutil
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDenylist_persists(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "denylist.json")
+
+	d, err := LoadDenylist(filename)
+	if err != nil {
+		t.Fatalf("LoadDenylist() on a missing file: %v", err)
+	}
+	if err := d.Revoke("token1", time.Time{}); err != nil {
+		t.Fatalf("Revoke() = %v", err)
+	}
+	if err := d.Revoke("token1", time.Time{}); err != nil {
+		t.Fatalf("Revoke() again = %v", err)
+	}
+
+	d2, err := LoadDenylist(filename)
+	if err != nil {
+		t.Fatalf("LoadDenylist() = %v", err)
+	}
+	if !d2.IsRevoked("token1") {
+		t.Errorf("IsRevoked(token1) = false after reload")
+	}
+	if d2.IsRevoked("token2") {
+		t.Errorf("IsRevoked(token2) = true")
+	}
+	if list := d2.List(); len(list) != 1 || list[0].ID != "token1" {
+		t.Errorf("List() = %#v", list)
+	}
+}
+
+func TestDenylist_prunesExpired(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "denylist.json")
+
+	d, err := LoadDenylist(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Revoke("expiring", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Revoke("expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if d.IsRevoked("expired") {
+		t.Errorf("IsRevoked(expired) = true for an already expired token")
+	}
+	if !d.IsRevoked("expiring") {
+		t.Errorf("IsRevoked(expiring) = false")
+	}
+
+	d2, err := LoadDenylist(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := d2.List(); len(list) != 1 || list[0].ID != "expiring" {
+		t.Errorf("List() = %#v", list)
+	}
+}
@@ -20,7 +20,13 @@
 package jwtutil
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
@@ -31,10 +37,43 @@ const (
 	jwtEndpointTypeKey = "t"
 	jwtEndpointNameKey = "n"
 	jwtAgentKey        = "a"
+	jwtMethodsKey      = "m"
+	jwtPathPrefixKey   = "p"
+
+	// clockSkew is how far our clock may differ from the one which issued a token.
+	clockSkew = 30 * time.Second
 )
 
+// ServiceClaims holds the claims in a service token.  Only the type, name,
+// and agent are required.  Tokens issued before expiry and restrictions
+// were added have only those, and never expire.
+type ServiceClaims struct {
+	Type       string
+	Name       string
+	Agent      string
+	ID         string    // a unique token ID, which can be used to revoke it
+	IssuedAt   time.Time // not set if zero
+	Expiry     time.Time // never expires if zero
+	Methods    []string  // allowed HTTP methods, all if empty
+	PathPrefix string    // allowed URI path prefix, all if empty
+}
+
+// NewTokenID returns a random, unique token ID.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 // MakeJWT will return a token with provided type, name, and agent name embedded in the claims.
 func MakeJWT(key jwk.Key, epType string, epName string, agent string) (string, error) {
+	return MakeServiceJWT(key, &ServiceClaims{Type: epType, Name: epName, Agent: agent})
+}
+
+// MakeServiceJWT will return a signed token holding the claims.
+func MakeServiceJWT(key jwk.Key, claims *ServiceClaims) (string, error) {
 	t := jwt.New()
 
 	err := t.Set(jwt.IssuerKey, "opsmx")
@@ -42,21 +81,51 @@ func MakeJWT(key jwk.Key, epType string, epName string, agent string) (string, e
 		return "", err
 	}
 
-	err = t.Set(jwtEndpointTypeKey, epType)
+	err = t.Set(jwtEndpointTypeKey, claims.Type)
 	if err != nil {
 		return "", err
 	}
 
-	err = t.Set(jwtEndpointNameKey, epName)
+	err = t.Set(jwtEndpointNameKey, claims.Name)
 	if err != nil {
 		return "", err
 	}
 
-	err = t.Set(jwtAgentKey, agent)
+	err = t.Set(jwtAgentKey, claims.Agent)
 	if err != nil {
 		return "", err
 	}
 
+	if len(claims.ID) > 0 {
+		if err := t.Set(jwt.JwtIDKey, claims.ID); err != nil {
+			return "", err
+		}
+	}
+
+	if !claims.IssuedAt.IsZero() {
+		if err := t.Set(jwt.IssuedAtKey, claims.IssuedAt); err != nil {
+			return "", err
+		}
+	}
+
+	if !claims.Expiry.IsZero() {
+		if err := t.Set(jwt.ExpirationKey, claims.Expiry); err != nil {
+			return "", err
+		}
+	}
+
+	if len(claims.Methods) > 0 {
+		if err := t.Set(jwtMethodsKey, claims.Methods); err != nil {
+			return "", err
+		}
+	}
+
+	if len(claims.PathPrefix) > 0 {
+		if err := t.Set(jwtPathPrefixKey, claims.PathPrefix); err != nil {
+			return "", err
+		}
+	}
+
 	signed, err := jwt.Sign(t, jwa.HS256, key)
 	if err != nil {
 		return "", err
@@ -66,29 +135,115 @@ func MakeJWT(key jwk.Key, epType string, epName string, agent string) (string, e
 
 func getField(token jwt.Token, name string) (string, error) {
 	if i, ok := token.Get(name); ok {
-		return i.(string), nil
+		if s, ok := i.(string); ok {
+			return s, nil
+		}
+		return "", fmt.Errorf("%s is not a string", name)
 	}
 	return "", fmt.Errorf("missing %s", name)
 }
 
+func getStringList(token jwt.Token, name string) ([]string, error) {
+	i, ok := token.Get(name)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := i.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", name)
+	}
+	ret := make([]string, len(list))
+	for n, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a list of strings", name)
+		}
+		ret[n] = s
+	}
+	return ret, nil
+}
+
 // ValidateJWT will validate and return the enbedded claims.
 func ValidateJWT(keyset jwk.Set, tokenString string) (epType string, epName string, agent string, err error) {
+	claims, err := ValidateServiceJWT(keyset, tokenString)
+	if err != nil {
+		return "", "", "", err
+	}
+	return claims.Type, claims.Name, claims.Agent, nil
+}
+
+// ValidateServiceJWT will validate the token's signature and lifetime,
+// and return its claims.  Checking the method and path restrictions, and
+// whether the token ID has been revoked, is up to the caller.
+func ValidateServiceJWT(keyset jwk.Set, tokenString string) (*ServiceClaims, error) {
 	token, err := jwt.Parse(
 		[]byte(tokenString),
 		jwt.WithValidate(true),
 		jwt.WithKeySet(keyset),
+		jwt.WithAcceptableSkew(clockSkew),
 	)
 	if err != nil {
-		return
+		return nil, err
 	}
-	if epType, err = getField(token, jwtEndpointTypeKey); err != nil {
-		return "", "", "", err
+	claims := &ServiceClaims{
+		ID:       token.JwtID(),
+		IssuedAt: token.IssuedAt(),
+		Expiry:   token.Expiration(),
 	}
-	if epName, err = getField(token, jwtEndpointNameKey); err != nil {
-		return "", "", "", err
+	if claims.Type, err = getField(token, jwtEndpointTypeKey); err != nil {
+		return nil, err
 	}
-	if agent, err = getField(token, jwtAgentKey); err != nil {
-		return "", "", "", err
+	if claims.Name, err = getField(token, jwtEndpointNameKey); err != nil {
+		return nil, err
+	}
+	if claims.Agent, err = getField(token, jwtAgentKey); err != nil {
+		return nil, err
+	}
+	if claims.Methods, err = getStringList(token, jwtMethodsKey); err != nil {
+		return nil, err
+	}
+	if _, found := token.Get(jwtPathPrefixKey); found {
+		if claims.PathPrefix, err = getField(token, jwtPathPrefixKey); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// IsLegacy returns true if the token has no expiry or no ID, as tokens
+// issued before those were added do.  Such a token cannot be revoked, and
+// never expires.
+func (c *ServiceClaims) IsLegacy() bool {
+	return c.Expiry.IsZero() || len(c.ID) == 0
+}
+
+// Allows returns an error if the token's restrictions do not permit the request.
+func (c *ServiceClaims) Allows(r *http.Request) error {
+	if len(c.Methods) > 0 {
+		allowed := false
+		for _, method := range c.Methods {
+			if strings.EqualFold(method, r.Method) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("token does not allow method %s", r.Method)
+		}
+	}
+	if len(c.PathPrefix) > 0 && !pathHasPrefix(r.URL.Path, c.PathPrefix) {
+		return fmt.Errorf("token does not allow path %s", r.URL.Path)
+	}
+	return nil
+}
+
+// pathHasPrefix returns true if the cleaned path is the prefix, or is below
+// it.  A prefix of "/api" allows "/api/x" but not "/apix" or "/api/../x".
+func pathHasPrefix(p string, prefix string) bool {
+	p = path.Clean("/" + p)
+	prefix = path.Clean("/" + prefix)
+	if prefix == "/" || p == prefix {
+		return true
 	}
-	return
+	return strings.HasPrefix(p, prefix+"/")
 }
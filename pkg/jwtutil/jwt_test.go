@@ -17,8 +17,10 @@ package jwtutil
  */
 
 import (
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
@@ -150,3 +152,110 @@ func TestValidateJWT(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceJWT_roundTrip(t *testing.T) {
+	keyset := loadkeys(t)
+	key, _ := keyset.LookupKeyID("key1")
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		claims  ServiceClaims
+		wantErr bool
+	}{
+		{
+			"no restrictions",
+			ServiceClaims{Type: "jenkins", Name: "bob", Agent: "agent1"},
+			false,
+		},
+		{
+			"all claims",
+			ServiceClaims{
+				Type:       "jenkins",
+				Name:       "bob",
+				Agent:      "agent1",
+				ID:         "token1",
+				IssuedAt:   now,
+				Expiry:     now.Add(time.Hour),
+				Methods:    []string{"GET", "HEAD"},
+				PathPrefix: "/api",
+			},
+			false,
+		},
+		{
+			"expired",
+			ServiceClaims{Type: "jenkins", Name: "bob", Agent: "agent1", IssuedAt: now.Add(-2 * time.Hour), Expiry: now.Add(-time.Hour)},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := MakeServiceJWT(key, &tt.claims)
+			if err != nil {
+				t.Fatalf("MakeServiceJWT() error = %v", err)
+			}
+			got, err := ValidateServiceJWT(keyset, token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateServiceJWT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.ID != tt.claims.ID || !got.Expiry.Equal(tt.claims.Expiry) || !got.IssuedAt.Equal(tt.claims.IssuedAt) ||
+				!reflect.DeepEqual(got.Methods, tt.claims.Methods) || got.PathPrefix != tt.claims.PathPrefix {
+				t.Errorf("ValidateServiceJWT() = %#v, want %#v", got, tt.claims)
+			}
+		})
+	}
+}
+
+func TestServiceClaims_Allows(t *testing.T) {
+	claims := &ServiceClaims{Methods: []string{"GET"}, PathPrefix: "/api"}
+	tests := []struct {
+		method  string
+		path    string
+		wantErr bool
+	}{
+		{"GET", "/api", false},
+		{"GET", "/api/v1/x", false},
+		{"get", "/api/v1/x", false},
+		{"POST", "/api/v1/x", true},
+		{"GET", "/apix", true},
+		{"GET", "/api/../secret", true},
+		{"GET", "/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "https://localhost/", nil)
+			r.URL.Path = tt.path
+			if err := claims.Allows(r); (err != nil) != tt.wantErr {
+				t.Errorf("Allows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := (&ServiceClaims{}).Allows(httptest.NewRequest("DELETE", "https://localhost/x", nil)); err != nil {
+		t.Errorf("Allows() with no restrictions = %v", err)
+	}
+}
+
+func TestServiceClaims_IsLegacy(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		claims ServiceClaims
+		want   bool
+	}{
+		{"expiry and ID", ServiceClaims{ID: "id", Expiry: now}, false},
+		{"no expiry", ServiceClaims{ID: "id"}, true},
+		{"no ID", ServiceClaims{Expiry: now}, true},
+		{"neither", ServiceClaims{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.claims.IsLegacy(); got != tt.want {
+				t.Errorf("IsLegacy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
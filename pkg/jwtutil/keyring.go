@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwtutil
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+type retiredKey struct {
+	key   jwk.Key
+	until time.Time
+}
+
+//
+// Keyring holds the keys used to sign and verify tokens, and allows them
+// to be replaced while in use.  Keys which are removed stay valid for
+// verification, but not signing, for an overlap period so tokens signed
+// with them keep working while clients move to new ones.
+//
+type Keyring struct {
+	sync.RWMutex
+	keyset     jwk.Set
+	currentKey string
+	active     map[string]jwk.Key
+	retired    map[string]retiredKey
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keyset:  jwk.NewSet(),
+		active:  map[string]jwk.Key{},
+		retired: map[string]retiredKey{},
+	}
+}
+
+//
+// Update replaces the active keys, which are keyed by their key ID, and
+// the name of the key used for signing.  Previously active keys which are
+// no longer present are retired until the overlap has passed.  An error is
+// returned, and nothing is changed, if the current key is not active.
+//
+func (k *Keyring) Update(keys map[string]jwk.Key, currentKey string, overlap time.Duration, now time.Time) error {
+	if _, found := keys[currentKey]; !found {
+		return fmt.Errorf("current key %s not found", currentKey)
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	for name, key := range k.active {
+		if _, found := keys[name]; !found {
+			log.Printf("Service key %s removed, valid for verification until %s", name, now.Add(overlap).Format(time.RFC3339))
+			k.retired[name] = retiredKey{key: key, until: now.Add(overlap)}
+		}
+	}
+	for name := range keys {
+		if _, found := k.active[name]; !found {
+			log.Printf("Service key %s added", name)
+		}
+		delete(k.retired, name)
+	}
+	if k.currentKey != currentKey {
+		log.Printf("Service tokens will be signed with key %s", currentKey)
+	}
+
+	keyset := jwk.NewSet()
+	for _, key := range keys {
+		keyset.Add(key)
+	}
+	for name, retired := range k.retired {
+		if !now.Before(retired.until) {
+			log.Printf("Service key %s is no longer valid", name)
+			delete(k.retired, name)
+			continue
+		}
+		keyset.Add(retired.key)
+	}
+
+	k.active = keys
+	k.currentKey = currentKey
+	k.keyset = keyset
+	return nil
+}
+
+//
+// Keyset returns the keys which may be used to verify a token.  The set
+// returned is not modified by later updates.
+//
+func (k *Keyring) Keyset() jwk.Set {
+	k.RLock()
+	defer k.RUnlock()
+	return k.keyset
+}
+
+//
+// CurrentKey returns the key new tokens should be signed with.
+//
+func (k *Keyring) CurrentKey() (jwk.Key, error) {
+	k.RLock()
+	defer k.RUnlock()
+	key, found := k.active[k.currentKey]
+	if !found {
+		return nil, fmt.Errorf("unable to find service key '%s'", k.currentKey)
+	}
+	return key, nil
+}
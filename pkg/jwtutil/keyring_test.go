@@ -0,0 +1,68 @@
+package jwtutil
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+func TestKeyring_Update(t *testing.T) {
+	key1 := makekey(t, "key1", "this is a key")
+	key2 := makekey(t, "key2", "this is a key2")
+	now := time.Now()
+	overlap := time.Hour
+
+	k := NewKeyring()
+	if _, err := k.CurrentKey(); err == nil {
+		t.Errorf("CurrentKey() on an empty keyring expected an error")
+	}
+
+	if err := k.Update(map[string]jwk.Key{"key1": key1}, "key2", overlap, now); err == nil {
+		t.Errorf("Update() with a missing current key expected an error")
+	}
+
+	if err := k.Update(map[string]jwk.Key{"key1": key1}, "key1", overlap, now); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	token, err := MakeJWT(key1, "jenkins", "bob", "agent1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate to key2, removing key1.  key1 tokens still verify.
+	if err := k.Update(map[string]jwk.Key{"key2": key2}, "key2", overlap, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	current, err := k.CurrentKey()
+	if err != nil || current != key2 {
+		t.Errorf("CurrentKey() = %v, %v, want key2", current, err)
+	}
+	if _, _, _, err := ValidateJWT(k.Keyset(), token); err != nil {
+		t.Errorf("ValidateJWT() during overlap = %v", err)
+	}
+
+	// After the overlap, key1 is gone.
+	if err := k.Update(map[string]jwk.Key{"key2": key2}, "key2", overlap, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Update() = %v", err)
+	}
+	if _, _, _, err := ValidateJWT(k.Keyset(), token); err == nil {
+		t.Errorf("ValidateJWT() after overlap expected an error")
+	}
+}
@@ -20,3 +20,8 @@ package secrets
 type SecretLoader interface {
 	GetSecret(string) (*map[string][]byte, error)
 }
+
+// SecretUpdater is an interface to ensure UpdateSecret() exists.
+type SecretUpdater interface {
+	UpdateSecret(string, map[string][]byte) error
+}
@@ -86,3 +86,22 @@ func (s *KubernetesSecretLoader) GetSecret(name string) (*map[string][]byte, err
 	}
 	return &secret.Data, nil
 }
+
+// UpdateSecret will set the given keys in an existing Kubernetes secret.
+// Other keys in the secret are left unchanged.
+func (s *KubernetesSecretLoader) UpdateSecret(name string, data map[string][]byte) error {
+	secretsClient := s.clientset.CoreV1().Secrets(s.namespace)
+
+	secret, err := secretsClient.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range data {
+		secret.Data[k] = v
+	}
+	_, err = secretsClient.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	return err
+}
@@ -93,3 +93,60 @@ func TestKubernetesSecretLoader_GetSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestKubernetesSecretLoader_UpdateSecret(t *testing.T) {
+	var tests = []struct {
+		description string
+		secretName  string
+		expected    *map[string][]byte
+		objs        []runtime.Object
+		wantErr     bool
+	}{
+		{
+			"no secrets", "foo", nil, nil, true,
+		},
+		{
+			"matching secret",
+			"secret1",
+			&map[string][]byte{
+				"key1": []byte("key1 content"),
+				"key2": []byte("new key2 content"),
+				"key3": []byte("key3 content"),
+			},
+			[]runtime.Object{secret("secret1")},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			client := fake.NewSimpleClientset(test.objs...)
+			loader := MakeKubernetesSecretLoaderFromClientset("ns1", client)
+
+			err := loader.UpdateSecret(test.secretName, map[string][]byte{
+				"key2": []byte("new key2 content"),
+				"key3": []byte("key3 content"),
+			})
+			if (err != nil) && !test.wantErr {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+			if (err == nil) && test.wantErr {
+				t.Errorf("Expected an error, did not get one")
+				return
+			}
+			if test.wantErr {
+				return
+			}
+			actual, err := loader.GetSecret(test.secretName)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err)
+				return
+			}
+			if diff := cmp.Diff(actual, test.expected); diff != "" {
+				t.Errorf("%T differ (-got, +want): %s", test.expected, diff)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config describes where spans are sent.  If Endpoint is empty, tracing
+// is disabled, and only trace context received from upstream is passed on.
+type Config struct {
+	// Endpoint is the host:port of an OTLP/HTTP collector.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// URLPath defaults to /v1/traces.
+	URLPath string `yaml:"urlPath,omitempty"`
+	// Insecure uses http rather than https to reach the collector.
+	Insecure bool `yaml:"insecure,omitempty"`
+	// SampleRatio is the fraction of new traces recorded, defaulting to 1.
+	// Traces continued from upstream follow the caller's decision.
+	SampleRatio *float64 `yaml:"sampleRatio,omitempty"`
+}
+
+func (c Config) exporterOptions() []otlptracehttp.Option {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if len(c.URLPath) > 0 {
+		opts = append(opts, otlptracehttp.WithURLPath(c.URLPath))
+	}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return opts
+}
+
+// Setup starts exporting spans for the named service.  The returned
+// function flushes any queued spans and stops the exporter, and should
+// be called before the process exits.
+func Setup(ctx context.Context, serviceName string, config Config) (func(context.Context) error, error) {
+	if len(config.Endpoint) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+	ratio := 1.0
+	if config.SampleRatio != nil {
+		ratio = *config.SampleRatio
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("tracing sampleRatio %v must be between 0 and 1", ratio)
+		}
+	}
+
+	exporter, err := otlptracehttp.New(ctx, config.exporterOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	tracer := provider.Tracer(instrumentation)
+	globalLock.Lock()
+	globalTracer = tracer
+	globalLock.Unlock()
+	log.Printf("Exporting trace spans to %s", config.Endpoint)
+
+	return func(ctx context.Context) error {
+		globalLock.Lock()
+		if globalTracer == tracer {
+			globalTracer = nil
+		}
+		globalLock.Unlock()
+		return provider.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+//
+// Package tracing creates trace spans with the OpenTelemetry SDK, carries
+// W3C trace context across HTTP and the tunnel between the controller and
+// agents, and exports spans to an OpenTelemetry collector using OTLP/HTTP.
+//
+// If no collector is configured, spans are not recorded, but trace
+// context received from upstream is still passed along unchanged so a
+// trace started by a caller continues downstream.
+//
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracestateHeader = "tracestate"
+	instrumentation  = "github.com/opsmx/oes-birger/pkg/tracing"
+)
+
+// SpanKind is the OTLP span kind.
+type SpanKind int
+
+// The span kinds, with their OTLP values.
+const (
+	SpanKindInternal SpanKind = SpanKind(trace.SpanKindInternal)
+	SpanKindServer   SpanKind = SpanKind(trace.SpanKindServer)
+	SpanKindClient   SpanKind = SpanKind(trace.SpanKindClient)
+	SpanKindProducer SpanKind = SpanKind(trace.SpanKindProducer)
+	SpanKindConsumer SpanKind = SpanKind(trace.SpanKindConsumer)
+)
+
+// Attribute is a key and value recorded on a span.
+type Attribute = attribute.KeyValue
+
+// String returns a string attribute.
+func String(key string, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+//
+// Span is a single timed operation.  Start returns a nil span when spans
+// are not being exported, and all methods are safe to call on a nil span.
+//
+type Span struct {
+	span trace.Span
+}
+
+// SpanContext returns the span's identity.
+func (s *Span) SpanContext() trace.SpanContext {
+	if s == nil {
+		return trace.SpanContext{}
+	}
+	return s.span.SpanContext()
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attributes...)
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.span.SetStatus(codes.Error, message)
+}
+
+// End records the span's end time and queues it for export.  Only the
+// first call has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+var (
+	globalLock   sync.RWMutex
+	globalTracer trace.Tracer
+
+	propagator = propagation.TraceContext{}
+)
+
+//
+// Start begins a span as a child of the current span in ctx, and returns
+// a context with the new span as current.  If spans are not being
+// exported, the returned context carries the parent unchanged.
+//
+func Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	globalLock.RLock()
+	tracer := globalTracer
+	globalLock.RUnlock()
+
+	if tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKind(kind)),
+		trace.WithAttributes(attributes...))
+	return ctx, &Span{span: span}
+}
+
+//
+// Inject returns the trace context of ctx, suitable for the traceContext
+// field of tunnel messages.  It returns nil if there is no trace.
+//
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx with the trace context from a tunnel message.
+func Extract(ctx context.Context, traceContext map[string]string) context.Context {
+	return propagator.Extract(ctx, propagation.MapCarrier(traceContext))
+}
+
+// ExtractHTTP returns ctx with the trace context from HTTP headers.
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectHTTP sets the trace context headers of an outgoing request,
+// replacing any already present.
+func InjectHTTP(ctx context.Context, header http.Header) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	header.Del(tracestateHeader)
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
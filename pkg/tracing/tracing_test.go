@@ -0,0 +1,163 @@
+package tracing
+
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"valid", testTraceparent, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"extra fields", testTraceparent + "-extra", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Extract(context.Background(), map[string]string{"traceparent": tt.value})
+			if got := trace.SpanContextFromContext(ctx).IsValid(); got != tt.want {
+				t.Errorf("Extract(%q) valid = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	ctx := Extract(context.Background(), map[string]string{"traceparent": testTraceparent})
+	if got := Inject(ctx)["traceparent"]; got != testTraceparent {
+		t.Errorf("Inject() traceparent = %s, want %s", got, testTraceparent)
+	}
+}
+
+func TestPropagation_disabled(t *testing.T) {
+	if got := Inject(context.Background()); got != nil {
+		t.Errorf("Inject() with no trace = %v, want nil", got)
+	}
+
+	header := http.Header{}
+	header.Set("traceparent", testTraceparent)
+	header.Set("tracestate", "vendor=value")
+	ctx, span := Start(ExtractHTTP(context.Background(), header), "test", SpanKindServer)
+	span.SetAttributes(String("key", "value"))
+	span.SetError("failed")
+	span.End()
+
+	got := Inject(ctx)
+	if got["traceparent"] != testTraceparent || got["tracestate"] != "vendor=value" {
+		t.Errorf("Inject() = %v, want the upstream trace context passed through", got)
+	}
+
+	out := http.Header{}
+	out.Set("tracestate", "stale=value")
+	InjectHTTP(Extract(context.Background(), map[string]string{"traceparent": testTraceparent}), out)
+	if out.Get("traceparent") != testTraceparent {
+		t.Errorf("InjectHTTP() traceparent = %s, want %s", out.Get("traceparent"), testTraceparent)
+	}
+	if out.Get("tracestate") != "" {
+		t.Errorf("InjectHTTP() kept tracestate %q", out.Get("tracestate"))
+	}
+}
+
+func TestSetup_exports(t *testing.T) {
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("collector called on %s", r.URL.Path)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	shutdown, err := Setup(context.Background(), "test-service", Config{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Insecure: true,
+	})
+	if err != nil {
+		t.Fatalf("Setup() = %v", err)
+	}
+
+	ctx, parent := Start(Extract(context.Background(), map[string]string{"traceparent": testTraceparent}), "parent", SpanKindServer)
+	_, child := Start(ctx, "child", SpanKindClient, Int("http.status_code", 502))
+	child.SetError("bad gateway")
+	child.End()
+	parent.End()
+
+	childContext := Extract(context.Background(), Inject(ctx))
+	if sc := trace.SpanContextFromContext(childContext); sc.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Inject() did not carry the new span")
+	}
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() = %v", err)
+	}
+
+	var request coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(<-bodies, &request); err != nil {
+		t.Fatalf("collector received an invalid request: %v", err)
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %v", &request)
+	}
+	resource := request.ResourceSpans[0].Resource
+	if len(resource.Attributes) != 1 || resource.Attributes[0].Value.GetStringValue() != "test-service" {
+		t.Errorf("unexpected resource %v", resource)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	if spans[0].Name != "child" || hex.EncodeToString(spans[0].ParentSpanId) != hex.EncodeToString(spans[1].SpanId) ||
+		spans[0].Status.Code != tracepb.Status_STATUS_CODE_ERROR || spans[0].Kind != tracepb.Span_SPAN_KIND_CLIENT {
+		t.Errorf("unexpected child span %v", spans[0])
+	}
+	if hex.EncodeToString(spans[1].TraceId) != "4bf92f3577b34da6a3ce929d0e0e4736" || hex.EncodeToString(spans[1].ParentSpanId) != "00f067aa0ba902b7" {
+		t.Errorf("parent span did not continue the upstream trace: %v", spans[1])
+	}
+
+	if _, span := Start(context.Background(), "after", SpanKindInternal); span != nil {
+		t.Errorf("Start() after shutdown returned a recording span")
+	}
+}
+
+func TestSetup_invalidSampleRatio(t *testing.T) {
+	ratio := 2.0
+	if _, err := Setup(context.Background(), "test", Config{Endpoint: "localhost:4318", SampleRatio: &ratio}); err == nil {
+		t.Errorf("Setup() expected an error")
+	}
+}
@@ -262,6 +262,20 @@ type HttpRequest struct {
 	URI     string        `protobuf:"bytes,5,opt,name=URI,proto3" json:"URI,omitempty"`
 	Headers []*HttpHeader `protobuf:"bytes,6,rep,name=headers,proto3" json:"headers,omitempty"`
 	Body    []byte        `protobuf:"bytes,7,opt,name=body,proto3" json:"body,omitempty"`
+	// W3C trace context (traceparent, tracestate) for the request.
+	TraceContext map[string]string `protobuf:"bytes,8,rep,name=traceContext,proto3" json:"traceContext,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// If set, the agent may send at most this many bytes of response
+	// body before waiting for WindowUpdate messages.
+	Window int64 `protobuf:"varint,9,opt,name=window,proto3" json:"window,omitempty"`
+	// If set, body is empty, and the request body follows in
+	// HttpRequestBody messages.  contentLength is -1 if unknown.
+	BodyStreamed  bool  `protobuf:"varint,10,opt,name=bodyStreamed,proto3" json:"bodyStreamed,omitempty"`
+	ContentLength int64 `protobuf:"varint,11,opt,name=contentLength,proto3" json:"contentLength,omitempty"`
+	// If set, the request asks to upgrade the connection, and is sent only
+	// to agents which set tunnelsUpgrades in their AgentHello.  If the
+	// endpoint switches protocols, the HttpResponse has status 101 and the
+	// connection's bytes follow in ConnectionData messages.
+	Upgrade bool `protobuf:"varint,12,opt,name=upgrade,proto3" json:"upgrade,omitempty"`
 }
 
 func (x *HttpRequest) Reset() {
@@ -345,6 +359,100 @@ func (x *HttpRequest) GetBody() []byte {
 	return nil
 }
 
+func (x *HttpRequest) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
+	}
+	return nil
+}
+
+func (x *HttpRequest) GetWindow() int64 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+func (x *HttpRequest) GetBodyStreamed() bool {
+	if x != nil {
+		return x.BodyStreamed
+	}
+	return false
+}
+
+func (x *HttpRequest) GetContentLength() int64 {
+	if x != nil {
+		return x.ContentLength
+	}
+	return 0
+}
+
+func (x *HttpRequest) GetUpgrade() bool {
+	if x != nil {
+		return x.Upgrade
+	}
+	return false
+}
+
+// Part of a request body, sent only to agents which set
+// streamsRequestBodies in their AgentHello.  A zero length body means EOF.
+// The controller may send RequestBodyWindow bytes of each body before
+// waiting for WindowUpdate messages from the agent.
+type HttpRequestBody struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id   string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Body []byte `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+}
+
+func (x *HttpRequestBody) Reset() {
+	*x = HttpRequestBody{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HttpRequestBody) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HttpRequestBody) ProtoMessage() {}
+
+func (x *HttpRequestBody) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HttpRequestBody.ProtoReflect.Descriptor instead.
+func (*HttpRequestBody) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *HttpRequestBody) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *HttpRequestBody) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
 type CancelRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -356,7 +464,7 @@ type CancelRequest struct {
 func (x *CancelRequest) Reset() {
 	*x = CancelRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[4]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -369,7 +477,7 @@ func (x *CancelRequest) String() string {
 func (*CancelRequest) ProtoMessage() {}
 
 func (x *CancelRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[4]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -382,7 +490,7 @@ func (x *CancelRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CancelRequest.ProtoReflect.Descriptor instead.
 func (*CancelRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{4}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *CancelRequest) GetId() string {
@@ -409,7 +517,7 @@ type HttpResponse struct {
 func (x *HttpResponse) Reset() {
 	*x = HttpResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[5]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -422,7 +530,7 @@ func (x *HttpResponse) String() string {
 func (*HttpResponse) ProtoMessage() {}
 
 func (x *HttpResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[5]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -435,7 +543,7 @@ func (x *HttpResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HttpResponse.ProtoReflect.Descriptor instead.
 func (*HttpResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{5}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *HttpResponse) GetId() string {
@@ -478,7 +586,7 @@ type HttpChunkedResponse struct {
 func (x *HttpChunkedResponse) Reset() {
 	*x = HttpChunkedResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[6]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -491,7 +599,7 @@ func (x *HttpChunkedResponse) String() string {
 func (*HttpChunkedResponse) ProtoMessage() {}
 
 func (x *HttpChunkedResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[6]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -504,7 +612,7 @@ func (x *HttpChunkedResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HttpChunkedResponse.ProtoReflect.Descriptor instead.
 func (*HttpChunkedResponse) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{6}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *HttpChunkedResponse) GetId() string {
@@ -521,34 +629,38 @@ func (x *HttpChunkedResponse) GetBody() []byte {
 	return nil
 }
 
-type CommandRequest struct {
+// Bytes read from one end of an upgraded HTTP connection or a forwarded
+// TCP connection, sent in both directions.  When either end closes,
+// closed is set and the connection is shut down.  Each direction is paced
+// with WindowUpdate messages, the controller sending at most
+// RequestBodyWindow bytes before waiting.
+type ConnectionData struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id          string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Name        string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Arguments   []string `protobuf:"bytes,3,rep,name=arguments,proto3" json:"arguments,omitempty"`
-	Environment []string `protobuf:"bytes,4,rep,name=environment,proto3" json:"environment,omitempty"`
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Closed bool   `protobuf:"varint,3,opt,name=closed,proto3" json:"closed,omitempty"`
 }
 
-func (x *CommandRequest) Reset() {
-	*x = CommandRequest{}
+func (x *ConnectionData) Reset() {
+	*x = ConnectionData{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[7]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CommandRequest) String() string {
+func (x *ConnectionData) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CommandRequest) ProtoMessage() {}
+func (*ConnectionData) ProtoMessage() {}
 
-func (x *CommandRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[7]
+func (x *ConnectionData) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -559,69 +671,69 @@ func (x *CommandRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CommandRequest.ProtoReflect.Descriptor instead.
-func (*CommandRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use ConnectionData.ProtoReflect.Descriptor instead.
+func (*ConnectionData) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *CommandRequest) GetId() string {
+func (x *ConnectionData) GetId() string {
 	if x != nil {
 		return x.Id
 	}
 	return ""
 }
 
-func (x *CommandRequest) GetName() string {
-	if x != nil {
-		return x.Name
-	}
-	return ""
-}
-
-func (x *CommandRequest) GetArguments() []string {
+func (x *ConnectionData) GetData() []byte {
 	if x != nil {
-		return x.Arguments
+		return x.Data
 	}
 	return nil
 }
 
-func (x *CommandRequest) GetEnvironment() []string {
+func (x *ConnectionData) GetClosed() bool {
 	if x != nil {
-		return x.Environment
+		return x.Closed
 	}
-	return nil
+	return false
 }
 
-// A simplified message, used for command-tool <-> controller communication.
-// This does not have the "id" or "target" field, as these are set by
-// the controller based on authentication used.
-type CmdToolCommandRequest struct {
+// Asks the agent to open a TCP connection through a "tcp" endpoint.  If
+// address (host:port) is empty, the endpoint's default is used, and
+// otherwise it must be one the endpoint allows.  The agent replies with a
+// ConnectionOpened, and the connection's bytes then follow in
+// ConnectionData messages.  The agent may send at most window bytes
+// before waiting for WindowUpdate messages.
+type OpenConnection struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name        string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Arguments   []string `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty"`
-	Environment []string `protobuf:"bytes,3,rep,name=environment,proto3" json:"environment,omitempty"`
+	Id      string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name    string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Type    string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Address string `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	// W3C trace context (traceparent, tracestate) for the connection.
+	TraceContext map[string]string `protobuf:"bytes,5,rep,name=traceContext,proto3" json:"traceContext,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Window       int64             `protobuf:"varint,6,opt,name=window,proto3" json:"window,omitempty"`
 }
 
-func (x *CmdToolCommandRequest) Reset() {
-	*x = CmdToolCommandRequest{}
+func (x *OpenConnection) Reset() {
+	*x = OpenConnection{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[8]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CmdToolCommandRequest) String() string {
+func (x *OpenConnection) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CmdToolCommandRequest) ProtoMessage() {}
+func (*OpenConnection) ProtoMessage() {}
 
-func (x *CmdToolCommandRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[8]
+func (x *OpenConnection) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -632,60 +744,81 @@ func (x *CmdToolCommandRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CmdToolCommandRequest.ProtoReflect.Descriptor instead.
-func (*CmdToolCommandRequest) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use OpenConnection.ProtoReflect.Descriptor instead.
+func (*OpenConnection) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *CmdToolCommandRequest) GetName() string {
+func (x *OpenConnection) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *OpenConnection) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *CmdToolCommandRequest) GetArguments() []string {
+func (x *OpenConnection) GetType() string {
 	if x != nil {
-		return x.Arguments
+		return x.Type
 	}
-	return nil
+	return ""
 }
 
-func (x *CmdToolCommandRequest) GetEnvironment() []string {
+func (x *OpenConnection) GetAddress() string {
 	if x != nil {
-		return x.Environment
+		return x.Address
+	}
+	return ""
+}
+
+func (x *OpenConnection) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
 	}
 	return nil
 }
 
-type CommandData struct {
+func (x *OpenConnection) GetWindow() int64 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+// If error is set, the connection could not be made, and no more
+// messages follow for it.
+type ConnectionOpened struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id      string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Body    []byte           `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
-	Channel ChannelDirection `protobuf:"varint,3,opt,name=channel,proto3,enum=tunnel.ChannelDirection" json:"channel,omitempty"`
-	Closed  bool             `protobuf:"varint,4,opt,name=Closed,proto3" json:"Closed,omitempty"`
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
 }
 
-func (x *CommandData) Reset() {
-	*x = CommandData{}
+func (x *ConnectionOpened) Reset() {
+	*x = ConnectionOpened{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[9]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CommandData) String() string {
+func (x *ConnectionOpened) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CommandData) ProtoMessage() {}
+func (*ConnectionOpened) ProtoMessage() {}
 
-func (x *CommandData) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[9]
+func (x *ConnectionOpened) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -696,69 +829,51 @@ func (x *CommandData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CommandData.ProtoReflect.Descriptor instead.
-func (*CommandData) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use ConnectionOpened.ProtoReflect.Descriptor instead.
+func (*ConnectionOpened) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *CommandData) GetId() string {
+func (x *ConnectionOpened) GetId() string {
 	if x != nil {
 		return x.Id
 	}
 	return ""
 }
 
-func (x *CommandData) GetBody() []byte {
-	if x != nil {
-		return x.Body
-	}
-	return nil
-}
-
-func (x *CommandData) GetChannel() ChannelDirection {
-	if x != nil {
-		return x.Channel
-	}
-	return ChannelDirection_STDIN
-}
-
-func (x *CommandData) GetClosed() bool {
+func (x *ConnectionOpened) GetError() string {
 	if x != nil {
-		return x.Closed
+		return x.Error
 	}
-	return false
+	return ""
 }
 
-// A simplified message, used for command-tool <-> controller communication.
-// This does not have the "id" or "target" field, as these are set by
-// the controller based on authentication used.
-type CmdToolCommandData struct {
+type TerminalSize struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Body    []byte           `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
-	Channel ChannelDirection `protobuf:"varint,2,opt,name=channel,proto3,enum=tunnel.ChannelDirection" json:"channel,omitempty"`
-	Closed  bool             `protobuf:"varint,3,opt,name=Closed,proto3" json:"Closed,omitempty"`
+	Rows    uint32 `protobuf:"varint,1,opt,name=rows,proto3" json:"rows,omitempty"`
+	Columns uint32 `protobuf:"varint,2,opt,name=columns,proto3" json:"columns,omitempty"`
 }
 
-func (x *CmdToolCommandData) Reset() {
-	*x = CmdToolCommandData{}
+func (x *TerminalSize) Reset() {
+	*x = TerminalSize{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[10]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CmdToolCommandData) String() string {
+func (x *TerminalSize) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CmdToolCommandData) ProtoMessage() {}
+func (*TerminalSize) ProtoMessage() {}
 
-func (x *CmdToolCommandData) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[10]
+func (x *TerminalSize) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -769,59 +884,69 @@ func (x *CmdToolCommandData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CmdToolCommandData.ProtoReflect.Descriptor instead.
-func (*CmdToolCommandData) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{10}
-}
-
-func (x *CmdToolCommandData) GetBody() []byte {
-	if x != nil {
-		return x.Body
-	}
-	return nil
+// Deprecated: Use TerminalSize.ProtoReflect.Descriptor instead.
+func (*TerminalSize) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{11}
 }
 
-func (x *CmdToolCommandData) GetChannel() ChannelDirection {
+func (x *TerminalSize) GetRows() uint32 {
 	if x != nil {
-		return x.Channel
+		return x.Rows
 	}
-	return ChannelDirection_STDIN
+	return 0
 }
 
-func (x *CmdToolCommandData) GetClosed() bool {
+func (x *TerminalSize) GetColumns() uint32 {
 	if x != nil {
-		return x.Closed
+		return x.Columns
 	}
-	return false
+	return 0
 }
 
-type CommandTermination struct {
+// If stdin is set, the command's standard input will be fed from
+// CommandData messages on the STDIN channel until one is marked Closed.
+// Otherwise, the command's standard input is empty.  For agents which set
+// pacesCommandInput in their AgentHello, the controller sends at most
+// RequestBodyWindow bytes of input before waiting for WindowUpdate messages.
+//
+// If tty is set, the command is run on a pseudo-terminal of the given
+// size, and all output is sent on the STDOUT channel.
+type CommandRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	ExitCode int32  `protobuf:"varint,2,opt,name=exitCode,proto3" json:"exitCode,omitempty"`
-	Message  string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Id           string        `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name         string        `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Arguments    []string      `protobuf:"bytes,3,rep,name=arguments,proto3" json:"arguments,omitempty"`
+	Environment  []string      `protobuf:"bytes,4,rep,name=environment,proto3" json:"environment,omitempty"`
+	Stdin        bool          `protobuf:"varint,5,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Tty          bool          `protobuf:"varint,6,opt,name=tty,proto3" json:"tty,omitempty"`
+	TerminalSize *TerminalSize `protobuf:"bytes,7,opt,name=terminalSize,proto3" json:"terminalSize,omitempty"`
+	// W3C trace context (traceparent, tracestate) for the command.
+	TraceContext map[string]string `protobuf:"bytes,8,rep,name=traceContext,proto3" json:"traceContext,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// If set, the agent may send at most this many bytes of output
+	// before waiting for WindowUpdate messages.
+	Window int64 `protobuf:"varint,9,opt,name=window,proto3" json:"window,omitempty"`
 }
 
-func (x *CommandTermination) Reset() {
-	*x = CommandTermination{}
+func (x *CommandRequest) Reset() {
+	*x = CommandRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[11]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CommandTermination) String() string {
+func (x *CommandRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CommandTermination) ProtoMessage() {}
+func (*CommandRequest) ProtoMessage() {}
 
-func (x *CommandTermination) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[11]
+func (x *CommandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -832,61 +957,1289 @@ func (x *CommandTermination) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CommandTermination.ProtoReflect.Descriptor instead.
-func (*CommandTermination) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{11}
-}
-
+// Deprecated: Use CommandRequest.ProtoReflect.Descriptor instead.
+func (*CommandRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CommandRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CommandRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CommandRequest) GetArguments() []string {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+func (x *CommandRequest) GetEnvironment() []string {
+	if x != nil {
+		return x.Environment
+	}
+	return nil
+}
+
+func (x *CommandRequest) GetStdin() bool {
+	if x != nil {
+		return x.Stdin
+	}
+	return false
+}
+
+func (x *CommandRequest) GetTty() bool {
+	if x != nil {
+		return x.Tty
+	}
+	return false
+}
+
+func (x *CommandRequest) GetTerminalSize() *TerminalSize {
+	if x != nil {
+		return x.TerminalSize
+	}
+	return nil
+}
+
+func (x *CommandRequest) GetTraceContext() map[string]string {
+	if x != nil {
+		return x.TraceContext
+	}
+	return nil
+}
+
+func (x *CommandRequest) GetWindow() int64 {
+	if x != nil {
+		return x.Window
+	}
+	return 0
+}
+
+// Sent by the controller as it passes a response body, command output or
+// connection data on to its client, allowing the agent to send
+// that many more bytes for the request.  Sent by the agent as it passes a
+// streamed request body, command input or connection data on to the endpoint,
+// allowing the controller to send more.  Each request is paced separately, so a slow client does
+// not hold up other requests on the same tunnel.
+type WindowUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Bytes int64  `protobuf:"varint,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+}
+
+func (x *WindowUpdate) Reset() {
+	*x = WindowUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WindowUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowUpdate) ProtoMessage() {}
+
+func (x *WindowUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowUpdate.ProtoReflect.Descriptor instead.
+func (*WindowUpdate) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *WindowUpdate) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WindowUpdate) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+// Sent when the command-tool's terminal window changes size.
+type CommandResize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string        `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TerminalSize *TerminalSize `protobuf:"bytes,2,opt,name=terminalSize,proto3" json:"terminalSize,omitempty"`
+}
+
+func (x *CommandResize) Reset() {
+	*x = CommandResize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommandResize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandResize) ProtoMessage() {}
+
+func (x *CommandResize) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandResize.ProtoReflect.Descriptor instead.
+func (*CommandResize) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CommandResize) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CommandResize) GetTerminalSize() *TerminalSize {
+	if x != nil {
+		return x.TerminalSize
+	}
+	return nil
+}
+
+// A simplified message, used for command-tool <-> controller communication.
+// This does not have the "id" or "target" field, as these are set by
+// the controller based on authentication used.
+type CmdToolCommandRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name         string        `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Arguments    []string      `protobuf:"bytes,2,rep,name=arguments,proto3" json:"arguments,omitempty"`
+	Environment  []string      `protobuf:"bytes,3,rep,name=environment,proto3" json:"environment,omitempty"`
+	Stdin        bool          `protobuf:"varint,4,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	Tty          bool          `protobuf:"varint,5,opt,name=tty,proto3" json:"tty,omitempty"`
+	TerminalSize *TerminalSize `protobuf:"bytes,6,opt,name=terminalSize,proto3" json:"terminalSize,omitempty"`
+}
+
+func (x *CmdToolCommandRequest) Reset() {
+	*x = CmdToolCommandRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CmdToolCommandRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CmdToolCommandRequest) ProtoMessage() {}
+
+func (x *CmdToolCommandRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CmdToolCommandRequest.ProtoReflect.Descriptor instead.
+func (*CmdToolCommandRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CmdToolCommandRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CmdToolCommandRequest) GetArguments() []string {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+func (x *CmdToolCommandRequest) GetEnvironment() []string {
+	if x != nil {
+		return x.Environment
+	}
+	return nil
+}
+
+func (x *CmdToolCommandRequest) GetStdin() bool {
+	if x != nil {
+		return x.Stdin
+	}
+	return false
+}
+
+func (x *CmdToolCommandRequest) GetTty() bool {
+	if x != nil {
+		return x.Tty
+	}
+	return false
+}
+
+func (x *CmdToolCommandRequest) GetTerminalSize() *TerminalSize {
+	if x != nil {
+		return x.TerminalSize
+	}
+	return nil
+}
+
+// A simplified message, used for command-tool <-> controller communication.
+type CmdToolCommandResize struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TerminalSize *TerminalSize `protobuf:"bytes,1,opt,name=terminalSize,proto3" json:"terminalSize,omitempty"`
+}
+
+func (x *CmdToolCommandResize) Reset() {
+	*x = CmdToolCommandResize{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CmdToolCommandResize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CmdToolCommandResize) ProtoMessage() {}
+
+func (x *CmdToolCommandResize) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CmdToolCommandResize.ProtoReflect.Descriptor instead.
+func (*CmdToolCommandResize) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CmdToolCommandResize) GetTerminalSize() *TerminalSize {
+	if x != nil {
+		return x.TerminalSize
+	}
+	return nil
+}
+
+type CommandData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id      string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Body    []byte           `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	Channel ChannelDirection `protobuf:"varint,3,opt,name=channel,proto3,enum=tunnel.ChannelDirection" json:"channel,omitempty"`
+	Closed  bool             `protobuf:"varint,4,opt,name=Closed,proto3" json:"Closed,omitempty"`
+}
+
+func (x *CommandData) Reset() {
+	*x = CommandData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommandData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandData) ProtoMessage() {}
+
+func (x *CommandData) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandData.ProtoReflect.Descriptor instead.
+func (*CommandData) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *CommandData) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CommandData) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *CommandData) GetChannel() ChannelDirection {
+	if x != nil {
+		return x.Channel
+	}
+	return ChannelDirection_STDIN
+}
+
+func (x *CommandData) GetClosed() bool {
+	if x != nil {
+		return x.Closed
+	}
+	return false
+}
+
+// A simplified message, used for command-tool <-> controller communication.
+// This does not have the "id" or "target" field, as these are set by
+// the controller based on authentication used.  The command-tool may send
+// RequestBodyWindow bytes of stdin before waiting for CmdToolWindowUpdate
+// messages.
+type CmdToolCommandData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Body    []byte           `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Channel ChannelDirection `protobuf:"varint,2,opt,name=channel,proto3,enum=tunnel.ChannelDirection" json:"channel,omitempty"`
+	Closed  bool             `protobuf:"varint,3,opt,name=Closed,proto3" json:"Closed,omitempty"`
+}
+
+func (x *CmdToolCommandData) Reset() {
+	*x = CmdToolCommandData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CmdToolCommandData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CmdToolCommandData) ProtoMessage() {}
+
+func (x *CmdToolCommandData) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CmdToolCommandData.ProtoReflect.Descriptor instead.
+func (*CmdToolCommandData) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *CmdToolCommandData) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *CmdToolCommandData) GetChannel() ChannelDirection {
+	if x != nil {
+		return x.Channel
+	}
+	return ChannelDirection_STDIN
+}
+
+func (x *CmdToolCommandData) GetClosed() bool {
+	if x != nil {
+		return x.Closed
+	}
+	return false
+}
+
+type CommandTermination struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ExitCode int32  `protobuf:"varint,2,opt,name=exitCode,proto3" json:"exitCode,omitempty"`
+	Message  string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *CommandTermination) Reset() {
+	*x = CommandTermination{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommandTermination) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandTermination) ProtoMessage() {}
+
+func (x *CommandTermination) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandTermination.ProtoReflect.Descriptor instead.
+func (*CommandTermination) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{19}
+}
+
 func (x *CommandTermination) GetId() string {
 	if x != nil {
-		return x.Id
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CommandTermination) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *CommandTermination) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// A simplified message, used for command-tool <-> controller communication.
+// This does not have the "id" or "target" field, as these are set by
+// the controller based on authentication used.
+type CmdToolCommandTermination struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ExitCode int32  `protobuf:"varint,1,opt,name=exitCode,proto3" json:"exitCode,omitempty"`
+	Message  string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *CmdToolCommandTermination) Reset() {
+	*x = CmdToolCommandTermination{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CmdToolCommandTermination) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CmdToolCommandTermination) ProtoMessage() {}
+
+func (x *CmdToolCommandTermination) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CmdToolCommandTermination.ProtoReflect.Descriptor instead.
+func (*CmdToolCommandTermination) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *CmdToolCommandTermination) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *CmdToolCommandTermination) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// Sent by the controller as it passes stdin on to the agent, allowing the
+// command-tool to send that many more bytes.
+type CmdToolWindowUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Bytes int64 `protobuf:"varint,1,opt,name=bytes,proto3" json:"bytes,omitempty"`
+}
+
+func (x *CmdToolWindowUpdate) Reset() {
+	*x = CmdToolWindowUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CmdToolWindowUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CmdToolWindowUpdate) ProtoMessage() {}
+
+func (x *CmdToolWindowUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CmdToolWindowUpdate.ProtoReflect.Descriptor instead.
+func (*CmdToolWindowUpdate) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *CmdToolWindowUpdate) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+type EndpointHealth struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name       string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type       string   `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Configured bool     `protobuf:"varint,3,opt,name=configured,proto3" json:"configured,omitempty"`
+	Namespaces []string `protobuf:"bytes,4,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+}
+
+func (x *EndpointHealth) Reset() {
+	*x = EndpointHealth{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EndpointHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointHealth) ProtoMessage() {}
+
+func (x *EndpointHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointHealth.ProtoReflect.Descriptor instead.
+func (*EndpointHealth) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *EndpointHealth) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *EndpointHealth) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *EndpointHealth) GetConfigured() bool {
+	if x != nil {
+		return x.Configured
+	}
+	return false
+}
+
+func (x *EndpointHealth) GetNamespaces() []string {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+// os and arch are as reported by the Go runtime, and binaryHash is the
+// agent's updater.HashSelf() value.  streamsRequestBodies is set by agents
+// which accept HttpRequestBody messages, tunnelsUpgrades by those which
+// handle HttpRequest.upgrade, and pacesCommandInput by those which return
+// WindowUpdate messages for command input.
+type AgentHello struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Endpoints            []*EndpointHealth `protobuf:"bytes,1,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
+	Version              string            `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Hostname             string            `protobuf:"bytes,3,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Os                   string            `protobuf:"bytes,4,opt,name=os,proto3" json:"os,omitempty"`
+	Arch                 string            `protobuf:"bytes,5,opt,name=arch,proto3" json:"arch,omitempty"`
+	BinaryHash           string            `protobuf:"bytes,6,opt,name=binaryHash,proto3" json:"binaryHash,omitempty"`
+	StreamsRequestBodies bool              `protobuf:"varint,7,opt,name=streamsRequestBodies,proto3" json:"streamsRequestBodies,omitempty"`
+	TunnelsUpgrades      bool              `protobuf:"varint,8,opt,name=tunnelsUpgrades,proto3" json:"tunnelsUpgrades,omitempty"`
+	PacesCommandInput    bool              `protobuf:"varint,9,opt,name=pacesCommandInput,proto3" json:"pacesCommandInput,omitempty"`
+}
+
+func (x *AgentHello) Reset() {
+	*x = AgentHello{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentHello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentHello) ProtoMessage() {}
+
+func (x *AgentHello) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentHello.ProtoReflect.Descriptor instead.
+func (*AgentHello) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *AgentHello) GetEndpoints() []*EndpointHealth {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *AgentHello) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *AgentHello) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *AgentHello) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *AgentHello) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *AgentHello) GetBinaryHash() string {
+	if x != nil {
+		return x.BinaryHash
+	}
+	return ""
+}
+
+func (x *AgentHello) GetStreamsRequestBodies() bool {
+	if x != nil {
+		return x.StreamsRequestBodies
+	}
+	return false
+}
+
+func (x *AgentHello) GetTunnelsUpgrades() bool {
+	if x != nil {
+		return x.TunnelsUpgrades
+	}
+	return false
+}
+
+func (x *AgentHello) GetPacesCommandInput() bool {
+	if x != nil {
+		return x.PacesCommandInput
+	}
+	return false
+}
+
+// Sent by the controller after the AgentHello, if it has an agent binary
+// for the agent's OS and architecture.  hash is in the same format
+// as AgentHello.binaryHash.
+type AgentBinaryInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash string `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Size int64  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+}
+
+func (x *AgentBinaryInfo) Reset() {
+	*x = AgentBinaryInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentBinaryInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentBinaryInfo) ProtoMessage() {}
+
+func (x *AgentBinaryInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentBinaryInfo.ProtoReflect.Descriptor instead.
+func (*AgentBinaryInfo) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *AgentBinaryInfo) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *AgentBinaryInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+// Sent by an agent which wants to update itself to the binary with the
+// given hash.
+type AgentBinaryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash string `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (x *AgentBinaryRequest) Reset() {
+	*x = AgentBinaryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentBinaryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentBinaryRequest) ProtoMessage() {}
+
+func (x *AgentBinaryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentBinaryRequest.ProtoReflect.Descriptor instead.
+func (*AgentBinaryRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *AgentBinaryRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+// The agent binary is sent in a series of these, with a zero length body
+// meaning EOF.  If error is set, the download has failed.
+type AgentBinaryChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Body  []byte `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *AgentBinaryChunk) Reset() {
+	*x = AgentBinaryChunk{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentBinaryChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentBinaryChunk) ProtoMessage() {}
+
+func (x *AgentBinaryChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentBinaryChunk.ProtoReflect.Descriptor instead.
+func (*AgentBinaryChunk) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *AgentBinaryChunk) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *AgentBinaryChunk) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// Sent by an agent whose certificate is nearing expiry, asking for a new
+// one for the same agent name.  The agent generates a new key, and csr is
+// a PEM encoded certificate signing request for it.  The subject in the
+// request is ignored.
+type AgentCertificateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Csr []byte `protobuf:"bytes,1,opt,name=csr,proto3" json:"csr,omitempty"`
+}
+
+func (x *AgentCertificateRequest) Reset() {
+	*x = AgentCertificateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentCertificateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentCertificateRequest) ProtoMessage() {}
+
+func (x *AgentCertificateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentCertificateRequest.ProtoReflect.Descriptor instead.
+func (*AgentCertificateRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *AgentCertificateRequest) GetCsr() []byte {
+	if x != nil {
+		return x.Csr
+	}
+	return nil
+}
+
+// The controller's reply to an AgentCertificateRequest.  The certificate
+// is PEM encoded, followed by its chain.  If error is set, no certificate
+// was issued.
+type AgentCertificate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Error       string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *AgentCertificate) Reset() {
+	*x = AgentCertificate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentCertificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentCertificate) ProtoMessage() {}
+
+func (x *AgentCertificate) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentCertificate.ProtoReflect.Descriptor instead.
+func (*AgentCertificate) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AgentCertificate) GetCertificate() []byte {
+	if x != nil {
+		return x.Certificate
+	}
+	return nil
+}
+
+func (x *AgentCertificate) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// The CA certificates the controller's server certificate may be signed
+// by, PEM encoded.  This is sent after the agent's hello, and again when
+// the controller's CA changes, so agents trust a new CA before the
+// controller's certificate is signed by it.
+type AgentTrustBundle struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CaCertificates []byte `protobuf:"bytes,1,opt,name=caCertificates,proto3" json:"caCertificates,omitempty"`
+}
+
+func (x *AgentTrustBundle) Reset() {
+	*x = AgentTrustBundle{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentTrustBundle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentTrustBundle) ProtoMessage() {}
+
+func (x *AgentTrustBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentTrustBundle.ProtoReflect.Descriptor instead.
+func (*AgentTrustBundle) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *AgentTrustBundle) GetCaCertificates() []byte {
+	if x != nil {
+		return x.CaCertificates
+	}
+	return nil
+}
+
+// Describes a directly connected agent, as advertised by one controller
+// to its peers.
+type PeerAgentInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name                 string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Session              string            `protobuf:"bytes,2,opt,name=session,proto3" json:"session,omitempty"`
+	Endpoints            []*EndpointHealth `protobuf:"bytes,3,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
+	Version              string            `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	Hostname             string            `protobuf:"bytes,5,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	ConnectedAt          uint64            `protobuf:"varint,6,opt,name=connectedAt,proto3" json:"connectedAt,omitempty"`
+	StreamsRequestBodies bool              `protobuf:"varint,7,opt,name=streamsRequestBodies,proto3" json:"streamsRequestBodies,omitempty"`
+	TunnelsUpgrades      bool              `protobuf:"varint,8,opt,name=tunnelsUpgrades,proto3" json:"tunnelsUpgrades,omitempty"`
+	PacesCommandInput    bool              `protobuf:"varint,9,opt,name=pacesCommandInput,proto3" json:"pacesCommandInput,omitempty"`
+}
+
+func (x *PeerAgentInfo) Reset() {
+	*x = PeerAgentInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerAgentInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerAgentInfo) ProtoMessage() {}
+
+func (x *PeerAgentInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerAgentInfo.ProtoReflect.Descriptor instead.
+func (*PeerAgentInfo) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *PeerAgentInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PeerAgentInfo) GetSession() string {
+	if x != nil {
+		return x.Session
+	}
+	return ""
+}
+
+func (x *PeerAgentInfo) GetEndpoints() []*EndpointHealth {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *PeerAgentInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
 	}
 	return ""
 }
 
-func (x *CommandTermination) GetExitCode() int32 {
+func (x *PeerAgentInfo) GetHostname() string {
 	if x != nil {
-		return x.ExitCode
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *PeerAgentInfo) GetConnectedAt() uint64 {
+	if x != nil {
+		return x.ConnectedAt
 	}
 	return 0
 }
 
-func (x *CommandTermination) GetMessage() string {
+func (x *PeerAgentInfo) GetStreamsRequestBodies() bool {
 	if x != nil {
-		return x.Message
+		return x.StreamsRequestBodies
 	}
-	return ""
+	return false
 }
 
-// A simplified message, used for command-tool <-> controller communication.
-// This does not have the "id" or "target" field, as these are set by
-// the controller based on authentication used.
-type CmdToolCommandTermination struct {
+func (x *PeerAgentInfo) GetTunnelsUpgrades() bool {
+	if x != nil {
+		return x.TunnelsUpgrades
+	}
+	return false
+}
+
+func (x *PeerAgentInfo) GetPacesCommandInput() bool {
+	if x != nil {
+		return x.PacesCommandInput
+	}
+	return false
+}
+
+// Sent by a controller when it first connects to a peer.
+type PeerHello struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	ExitCode int32  `protobuf:"varint,1,opt,name=exitCode,proto3" json:"exitCode,omitempty"`
-	Message  string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	ControllerId string `protobuf:"bytes,1,opt,name=controllerId,proto3" json:"controllerId,omitempty"`
+	Version      string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
 }
 
-func (x *CmdToolCommandTermination) Reset() {
-	*x = CmdToolCommandTermination{}
+func (x *PeerHello) Reset() {
+	*x = PeerHello{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[12]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[31]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *CmdToolCommandTermination) String() string {
+func (x *PeerHello) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CmdToolCommandTermination) ProtoMessage() {}
+func (*PeerHello) ProtoMessage() {}
 
-func (x *CmdToolCommandTermination) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[12]
+func (x *PeerHello) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[31]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -897,53 +2250,52 @@ func (x *CmdToolCommandTermination) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CmdToolCommandTermination.ProtoReflect.Descriptor instead.
-func (*CmdToolCommandTermination) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use PeerHello.ProtoReflect.Descriptor instead.
+func (*PeerHello) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *CmdToolCommandTermination) GetExitCode() int32 {
+func (x *PeerHello) GetControllerId() string {
 	if x != nil {
-		return x.ExitCode
+		return x.ControllerId
 	}
-	return 0
+	return ""
 }
 
-func (x *CmdToolCommandTermination) GetMessage() string {
+func (x *PeerHello) GetVersion() string {
 	if x != nil {
-		return x.Message
+		return x.Version
 	}
 	return ""
 }
 
-type EndpointHealth struct {
+// The full list of agents directly connected to the sending controller.
+// This is sent after the PeerHello, and again each time the list changes.
+type PeerAgentList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Name       string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Type       string   `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
-	Configured bool     `protobuf:"varint,3,opt,name=configured,proto3" json:"configured,omitempty"`
-	Namespaces []string `protobuf:"bytes,4,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	Agents []*PeerAgentInfo `protobuf:"bytes,1,rep,name=agents,proto3" json:"agents,omitempty"`
 }
 
-func (x *EndpointHealth) Reset() {
-	*x = EndpointHealth{}
+func (x *PeerAgentList) Reset() {
+	*x = PeerAgentList{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[13]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[32]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *EndpointHealth) String() string {
+func (x *PeerAgentList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*EndpointHealth) ProtoMessage() {}
+func (*PeerAgentList) ProtoMessage() {}
 
-func (x *EndpointHealth) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[13]
+func (x *PeerAgentList) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[32]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -954,66 +2306,120 @@ func (x *EndpointHealth) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use EndpointHealth.ProtoReflect.Descriptor instead.
-func (*EndpointHealth) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use PeerAgentList.ProtoReflect.Descriptor instead.
+func (*PeerAgentList) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *EndpointHealth) GetName() string {
+func (x *PeerAgentList) GetAgents() []*PeerAgentInfo {
 	if x != nil {
-		return x.Name
+		return x.Agents
+	}
+	return nil
+}
+
+// A certificate revoked on a controller.  name is the JSON encoded
+// certificate name, if known.  revokedAt and expiresAt are in
+// milliseconds, and expiresAt is zero if the entry never expires.
+type PeerRevokedCertificate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Serial    string `protobuf:"bytes,1,opt,name=serial,proto3" json:"serial,omitempty"`
+	Name      string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	RevokedAt uint64 `protobuf:"varint,3,opt,name=revokedAt,proto3" json:"revokedAt,omitempty"`
+	ExpiresAt uint64 `protobuf:"varint,4,opt,name=expiresAt,proto3" json:"expiresAt,omitempty"`
+}
+
+func (x *PeerRevokedCertificate) Reset() {
+	*x = PeerRevokedCertificate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerRevokedCertificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerRevokedCertificate) ProtoMessage() {}
+
+func (x *PeerRevokedCertificate) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerRevokedCertificate.ProtoReflect.Descriptor instead.
+func (*PeerRevokedCertificate) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *PeerRevokedCertificate) GetSerial() string {
+	if x != nil {
+		return x.Serial
 	}
 	return ""
 }
 
-func (x *EndpointHealth) GetType() string {
+func (x *PeerRevokedCertificate) GetName() string {
 	if x != nil {
-		return x.Type
+		return x.Name
 	}
 	return ""
 }
 
-func (x *EndpointHealth) GetConfigured() bool {
+func (x *PeerRevokedCertificate) GetRevokedAt() uint64 {
 	if x != nil {
-		return x.Configured
+		return x.RevokedAt
 	}
-	return false
+	return 0
 }
 
-func (x *EndpointHealth) GetNamespaces() []string {
+func (x *PeerRevokedCertificate) GetExpiresAt() uint64 {
 	if x != nil {
-		return x.Namespaces
+		return x.ExpiresAt
 	}
-	return nil
+	return 0
 }
 
-type AgentHello struct {
+// The full list of certificates revoked on the sending controller, so
+// every controller refuses them.  This is sent after the PeerHello,
+// and again each time a certificate is revoked.
+type PeerRevocationList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Endpoints []*EndpointHealth `protobuf:"bytes,1,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
-	Version   string            `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
-	Hostname  string            `protobuf:"bytes,3,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Certificates []*PeerRevokedCertificate `protobuf:"bytes,1,rep,name=certificates,proto3" json:"certificates,omitempty"`
 }
 
-func (x *AgentHello) Reset() {
-	*x = AgentHello{}
+func (x *PeerRevocationList) Reset() {
+	*x = PeerRevocationList{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[14]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[34]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *AgentHello) String() string {
+func (x *PeerRevocationList) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*AgentHello) ProtoMessage() {}
+func (*PeerRevocationList) ProtoMessage() {}
 
-func (x *AgentHello) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[14]
+func (x *PeerRevocationList) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[34]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1024,32 +2430,83 @@ func (x *AgentHello) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use AgentHello.ProtoReflect.Descriptor instead.
-func (*AgentHello) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{14}
+// Deprecated: Use PeerRevocationList.ProtoReflect.Descriptor instead.
+func (*PeerRevocationList) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *AgentHello) GetEndpoints() []*EndpointHealth {
+func (x *PeerRevocationList) GetCertificates() []*PeerRevokedCertificate {
 	if x != nil {
-		return x.Endpoints
+		return x.Certificates
 	}
 	return nil
 }
 
-func (x *AgentHello) GetVersion() string {
+// A request sent from one controller to a peer, which will forward it
+// to the directly connected agent identified by name and session.
+type PeerAgentRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	AgentName    string                    `protobuf:"bytes,1,opt,name=agentName,proto3" json:"agentName,omitempty"`
+	AgentSession string                    `protobuf:"bytes,2,opt,name=agentSession,proto3" json:"agentSession,omitempty"`
+	Message      *ControllerToAgentWrapper `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *PeerAgentRequest) Reset() {
+	*x = PeerAgentRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerAgentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerAgentRequest) ProtoMessage() {}
+
+func (x *PeerAgentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerAgentRequest.ProtoReflect.Descriptor instead.
+func (*PeerAgentRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *PeerAgentRequest) GetAgentName() string {
 	if x != nil {
-		return x.Version
+		return x.AgentName
 	}
 	return ""
 }
 
-func (x *AgentHello) GetHostname() string {
+func (x *PeerAgentRequest) GetAgentSession() string {
 	if x != nil {
-		return x.Hostname
+		return x.AgentSession
 	}
 	return ""
 }
 
+func (x *PeerAgentRequest) GetMessage() *ControllerToAgentWrapper {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
 // Messages sent from server to agent
 type ControllerToAgentWrapper struct {
 	state         protoimpl.MessageState
@@ -1062,13 +2519,22 @@ type ControllerToAgentWrapper struct {
 	//	*ControllerToAgentWrapper_CancelRequest
 	//	*ControllerToAgentWrapper_CommandRequest
 	//	*ControllerToAgentWrapper_CommandData
+	//	*ControllerToAgentWrapper_CommandResize
+	//	*ControllerToAgentWrapper_AgentBinaryInfo
+	//	*ControllerToAgentWrapper_AgentBinaryChunk
+	//	*ControllerToAgentWrapper_AgentCertificate
+	//	*ControllerToAgentWrapper_WindowUpdate
+	//	*ControllerToAgentWrapper_HttpRequestBody
+	//	*ControllerToAgentWrapper_ConnectionData
+	//	*ControllerToAgentWrapper_OpenConnection
+	//	*ControllerToAgentWrapper_AgentTrustBundle
 	Event isControllerToAgentWrapper_Event `protobuf_oneof:"event"`
 }
 
 func (x *ControllerToAgentWrapper) Reset() {
 	*x = ControllerToAgentWrapper{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[15]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[36]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1081,7 +2547,7 @@ func (x *ControllerToAgentWrapper) String() string {
 func (*ControllerToAgentWrapper) ProtoMessage() {}
 
 func (x *ControllerToAgentWrapper) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[15]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[36]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1094,7 +2560,7 @@ func (x *ControllerToAgentWrapper) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ControllerToAgentWrapper.ProtoReflect.Descriptor instead.
 func (*ControllerToAgentWrapper) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{15}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{36}
 }
 
 func (m *ControllerToAgentWrapper) GetEvent() isControllerToAgentWrapper_Event {
@@ -1139,6 +2605,69 @@ func (x *ControllerToAgentWrapper) GetCommandData() *CommandData {
 	return nil
 }
 
+func (x *ControllerToAgentWrapper) GetCommandResize() *CommandResize {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_CommandResize); ok {
+		return x.CommandResize
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetAgentBinaryInfo() *AgentBinaryInfo {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_AgentBinaryInfo); ok {
+		return x.AgentBinaryInfo
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetAgentBinaryChunk() *AgentBinaryChunk {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_AgentBinaryChunk); ok {
+		return x.AgentBinaryChunk
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetAgentCertificate() *AgentCertificate {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_AgentCertificate); ok {
+		return x.AgentCertificate
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetWindowUpdate() *WindowUpdate {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_WindowUpdate); ok {
+		return x.WindowUpdate
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetHttpRequestBody() *HttpRequestBody {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_HttpRequestBody); ok {
+		return x.HttpRequestBody
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetConnectionData() *ConnectionData {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_ConnectionData); ok {
+		return x.ConnectionData
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetOpenConnection() *OpenConnection {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_OpenConnection); ok {
+		return x.OpenConnection
+	}
+	return nil
+}
+
+func (x *ControllerToAgentWrapper) GetAgentTrustBundle() *AgentTrustBundle {
+	if x, ok := x.GetEvent().(*ControllerToAgentWrapper_AgentTrustBundle); ok {
+		return x.AgentTrustBundle
+	}
+	return nil
+}
+
 type isControllerToAgentWrapper_Event interface {
 	isControllerToAgentWrapper_Event()
 }
@@ -1163,6 +2692,42 @@ type ControllerToAgentWrapper_CommandData struct {
 	CommandData *CommandData `protobuf:"bytes,5,opt,name=commandData,proto3,oneof"`
 }
 
+type ControllerToAgentWrapper_CommandResize struct {
+	CommandResize *CommandResize `protobuf:"bytes,6,opt,name=commandResize,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_AgentBinaryInfo struct {
+	AgentBinaryInfo *AgentBinaryInfo `protobuf:"bytes,7,opt,name=agentBinaryInfo,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_AgentBinaryChunk struct {
+	AgentBinaryChunk *AgentBinaryChunk `protobuf:"bytes,8,opt,name=agentBinaryChunk,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_AgentCertificate struct {
+	AgentCertificate *AgentCertificate `protobuf:"bytes,9,opt,name=agentCertificate,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_WindowUpdate struct {
+	WindowUpdate *WindowUpdate `protobuf:"bytes,10,opt,name=windowUpdate,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_HttpRequestBody struct {
+	HttpRequestBody *HttpRequestBody `protobuf:"bytes,11,opt,name=httpRequestBody,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_ConnectionData struct {
+	ConnectionData *ConnectionData `protobuf:"bytes,12,opt,name=connectionData,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_OpenConnection struct {
+	OpenConnection *OpenConnection `protobuf:"bytes,13,opt,name=openConnection,proto3,oneof"`
+}
+
+type ControllerToAgentWrapper_AgentTrustBundle struct {
+	AgentTrustBundle *AgentTrustBundle `protobuf:"bytes,14,opt,name=agentTrustBundle,proto3,oneof"`
+}
+
 func (*ControllerToAgentWrapper_PingResponse) isControllerToAgentWrapper_Event() {}
 
 func (*ControllerToAgentWrapper_HttpRequest) isControllerToAgentWrapper_Event() {}
@@ -1173,6 +2738,24 @@ func (*ControllerToAgentWrapper_CommandRequest) isControllerToAgentWrapper_Event
 
 func (*ControllerToAgentWrapper_CommandData) isControllerToAgentWrapper_Event() {}
 
+func (*ControllerToAgentWrapper_CommandResize) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_AgentBinaryInfo) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_AgentBinaryChunk) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_AgentCertificate) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_WindowUpdate) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_HttpRequestBody) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_ConnectionData) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_OpenConnection) isControllerToAgentWrapper_Event() {}
+
+func (*ControllerToAgentWrapper_AgentTrustBundle) isControllerToAgentWrapper_Event() {}
+
 // Messages sent from agent to server
 type AgentToControllerWrapper struct {
 	state         protoimpl.MessageState
@@ -1186,13 +2769,18 @@ type AgentToControllerWrapper struct {
 	//	*AgentToControllerWrapper_AgentHello
 	//	*AgentToControllerWrapper_CommandData
 	//	*AgentToControllerWrapper_CommandTermination
+	//	*AgentToControllerWrapper_AgentBinaryRequest
+	//	*AgentToControllerWrapper_AgentCertificateRequest
+	//	*AgentToControllerWrapper_WindowUpdate
+	//	*AgentToControllerWrapper_ConnectionData
+	//	*AgentToControllerWrapper_ConnectionOpened
 	Event isAgentToControllerWrapper_Event `protobuf_oneof:"event"`
 }
 
 func (x *AgentToControllerWrapper) Reset() {
 	*x = AgentToControllerWrapper{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[16]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[37]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1205,7 +2793,7 @@ func (x *AgentToControllerWrapper) String() string {
 func (*AgentToControllerWrapper) ProtoMessage() {}
 
 func (x *AgentToControllerWrapper) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[16]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[37]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1218,7 +2806,7 @@ func (x *AgentToControllerWrapper) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AgentToControllerWrapper.ProtoReflect.Descriptor instead.
 func (*AgentToControllerWrapper) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{16}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{37}
 }
 
 func (m *AgentToControllerWrapper) GetEvent() isAgentToControllerWrapper_Event {
@@ -1270,6 +2858,41 @@ func (x *AgentToControllerWrapper) GetCommandTermination() *CommandTermination {
 	return nil
 }
 
+func (x *AgentToControllerWrapper) GetAgentBinaryRequest() *AgentBinaryRequest {
+	if x, ok := x.GetEvent().(*AgentToControllerWrapper_AgentBinaryRequest); ok {
+		return x.AgentBinaryRequest
+	}
+	return nil
+}
+
+func (x *AgentToControllerWrapper) GetAgentCertificateRequest() *AgentCertificateRequest {
+	if x, ok := x.GetEvent().(*AgentToControllerWrapper_AgentCertificateRequest); ok {
+		return x.AgentCertificateRequest
+	}
+	return nil
+}
+
+func (x *AgentToControllerWrapper) GetWindowUpdate() *WindowUpdate {
+	if x, ok := x.GetEvent().(*AgentToControllerWrapper_WindowUpdate); ok {
+		return x.WindowUpdate
+	}
+	return nil
+}
+
+func (x *AgentToControllerWrapper) GetConnectionData() *ConnectionData {
+	if x, ok := x.GetEvent().(*AgentToControllerWrapper_ConnectionData); ok {
+		return x.ConnectionData
+	}
+	return nil
+}
+
+func (x *AgentToControllerWrapper) GetConnectionOpened() *ConnectionOpened {
+	if x, ok := x.GetEvent().(*AgentToControllerWrapper_ConnectionOpened); ok {
+		return x.ConnectionOpened
+	}
+	return nil
+}
+
 type isAgentToControllerWrapper_Event interface {
 	isAgentToControllerWrapper_Event()
 }
@@ -1298,6 +2921,26 @@ type AgentToControllerWrapper_CommandTermination struct {
 	CommandTermination *CommandTermination `protobuf:"bytes,6,opt,name=commandTermination,proto3,oneof"`
 }
 
+type AgentToControllerWrapper_AgentBinaryRequest struct {
+	AgentBinaryRequest *AgentBinaryRequest `protobuf:"bytes,7,opt,name=agentBinaryRequest,proto3,oneof"`
+}
+
+type AgentToControllerWrapper_AgentCertificateRequest struct {
+	AgentCertificateRequest *AgentCertificateRequest `protobuf:"bytes,8,opt,name=agentCertificateRequest,proto3,oneof"`
+}
+
+type AgentToControllerWrapper_WindowUpdate struct {
+	WindowUpdate *WindowUpdate `protobuf:"bytes,9,opt,name=windowUpdate,proto3,oneof"`
+}
+
+type AgentToControllerWrapper_ConnectionData struct {
+	ConnectionData *ConnectionData `protobuf:"bytes,10,opt,name=connectionData,proto3,oneof"`
+}
+
+type AgentToControllerWrapper_ConnectionOpened struct {
+	ConnectionOpened *ConnectionOpened `protobuf:"bytes,11,opt,name=connectionOpened,proto3,oneof"`
+}
+
 func (*AgentToControllerWrapper_PingRequest) isAgentToControllerWrapper_Event() {}
 
 func (*AgentToControllerWrapper_HttpResponse) isAgentToControllerWrapper_Event() {}
@@ -1310,6 +2953,16 @@ func (*AgentToControllerWrapper_CommandData) isAgentToControllerWrapper_Event()
 
 func (*AgentToControllerWrapper_CommandTermination) isAgentToControllerWrapper_Event() {}
 
+func (*AgentToControllerWrapper_AgentBinaryRequest) isAgentToControllerWrapper_Event() {}
+
+func (*AgentToControllerWrapper_AgentCertificateRequest) isAgentToControllerWrapper_Event() {}
+
+func (*AgentToControllerWrapper_WindowUpdate) isAgentToControllerWrapper_Event() {}
+
+func (*AgentToControllerWrapper_ConnectionData) isAgentToControllerWrapper_Event() {}
+
+func (*AgentToControllerWrapper_ConnectionOpened) isAgentToControllerWrapper_Event() {}
+
 // Messages sent from command-tool to controller
 type CmdToolToControllerWrapper struct {
 	state         protoimpl.MessageState
@@ -1319,13 +2972,14 @@ type CmdToolToControllerWrapper struct {
 	// Types that are assignable to Event:
 	//	*CmdToolToControllerWrapper_CommandRequest
 	//	*CmdToolToControllerWrapper_CommandData
+	//	*CmdToolToControllerWrapper_CommandResize
 	Event isCmdToolToControllerWrapper_Event `protobuf_oneof:"event"`
 }
 
 func (x *CmdToolToControllerWrapper) Reset() {
 	*x = CmdToolToControllerWrapper{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[17]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[38]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1338,7 +2992,7 @@ func (x *CmdToolToControllerWrapper) String() string {
 func (*CmdToolToControllerWrapper) ProtoMessage() {}
 
 func (x *CmdToolToControllerWrapper) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[17]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[38]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1351,7 +3005,7 @@ func (x *CmdToolToControllerWrapper) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CmdToolToControllerWrapper.ProtoReflect.Descriptor instead.
 func (*CmdToolToControllerWrapper) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{17}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{38}
 }
 
 func (m *CmdToolToControllerWrapper) GetEvent() isCmdToolToControllerWrapper_Event {
@@ -1375,6 +3029,13 @@ func (x *CmdToolToControllerWrapper) GetCommandData() *CmdToolCommandData {
 	return nil
 }
 
+func (x *CmdToolToControllerWrapper) GetCommandResize() *CmdToolCommandResize {
+	if x, ok := x.GetEvent().(*CmdToolToControllerWrapper_CommandResize); ok {
+		return x.CommandResize
+	}
+	return nil
+}
+
 type isCmdToolToControllerWrapper_Event interface {
 	isCmdToolToControllerWrapper_Event()
 }
@@ -1387,10 +3048,16 @@ type CmdToolToControllerWrapper_CommandData struct {
 	CommandData *CmdToolCommandData `protobuf:"bytes,2,opt,name=commandData,proto3,oneof"`
 }
 
+type CmdToolToControllerWrapper_CommandResize struct {
+	CommandResize *CmdToolCommandResize `protobuf:"bytes,3,opt,name=commandResize,proto3,oneof"`
+}
+
 func (*CmdToolToControllerWrapper_CommandRequest) isCmdToolToControllerWrapper_Event() {}
 
 func (*CmdToolToControllerWrapper_CommandData) isCmdToolToControllerWrapper_Event() {}
 
+func (*CmdToolToControllerWrapper_CommandResize) isCmdToolToControllerWrapper_Event() {}
+
 // Messages sent from the controller to the command-tool
 type ControllerToCmdToolWrapper struct {
 	state         protoimpl.MessageState
@@ -1400,13 +3067,14 @@ type ControllerToCmdToolWrapper struct {
 	// Types that are assignable to Event:
 	//	*ControllerToCmdToolWrapper_CommandTermination
 	//	*ControllerToCmdToolWrapper_CommandData
+	//	*ControllerToCmdToolWrapper_WindowUpdate
 	Event isControllerToCmdToolWrapper_Event `protobuf_oneof:"event"`
 }
 
 func (x *ControllerToCmdToolWrapper) Reset() {
 	*x = ControllerToCmdToolWrapper{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[18]
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[39]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1419,7 +3087,7 @@ func (x *ControllerToCmdToolWrapper) String() string {
 func (*ControllerToCmdToolWrapper) ProtoMessage() {}
 
 func (x *ControllerToCmdToolWrapper) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[18]
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[39]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1432,7 +3100,7 @@ func (x *ControllerToCmdToolWrapper) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ControllerToCmdToolWrapper.ProtoReflect.Descriptor instead.
 func (*ControllerToCmdToolWrapper) Descriptor() ([]byte, []int) {
-	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{18}
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{39}
 }
 
 func (m *ControllerToCmdToolWrapper) GetEvent() isControllerToCmdToolWrapper_Event {
@@ -1456,6 +3124,13 @@ func (x *ControllerToCmdToolWrapper) GetCommandData() *CmdToolCommandData {
 	return nil
 }
 
+func (x *ControllerToCmdToolWrapper) GetWindowUpdate() *CmdToolWindowUpdate {
+	if x, ok := x.GetEvent().(*ControllerToCmdToolWrapper_WindowUpdate); ok {
+		return x.WindowUpdate
+	}
+	return nil
+}
+
 type isControllerToCmdToolWrapper_Event interface {
 	isControllerToCmdToolWrapper_Event()
 }
@@ -1468,10 +3143,208 @@ type ControllerToCmdToolWrapper_CommandData struct {
 	CommandData *CmdToolCommandData `protobuf:"bytes,2,opt,name=commandData,proto3,oneof"`
 }
 
+type ControllerToCmdToolWrapper_WindowUpdate struct {
+	WindowUpdate *CmdToolWindowUpdate `protobuf:"bytes,3,opt,name=windowUpdate,proto3,oneof"`
+}
+
 func (*ControllerToCmdToolWrapper_CommandTermination) isControllerToCmdToolWrapper_Event() {}
 
 func (*ControllerToCmdToolWrapper_CommandData) isControllerToCmdToolWrapper_Event() {}
 
+func (*ControllerToCmdToolWrapper_WindowUpdate) isControllerToCmdToolWrapper_Event() {}
+
+// Messages sent from a controller to the peer it has connected to.
+// Responses from agents are relayed as-is in agentMessage.
+// requestClosed indicates the agent went away before the request completed.
+type PeerToControllerWrapper struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*PeerToControllerWrapper_PeerHello
+	//	*PeerToControllerWrapper_AgentList
+	//	*PeerToControllerWrapper_AgentMessage
+	//	*PeerToControllerWrapper_RequestClosed
+	//	*PeerToControllerWrapper_Revocations
+	Event isPeerToControllerWrapper_Event `protobuf_oneof:"event"`
+}
+
+func (x *PeerToControllerWrapper) Reset() {
+	*x = PeerToControllerWrapper{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PeerToControllerWrapper) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerToControllerWrapper) ProtoMessage() {}
+
+func (x *PeerToControllerWrapper) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerToControllerWrapper.ProtoReflect.Descriptor instead.
+func (*PeerToControllerWrapper) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{40}
+}
+
+func (m *PeerToControllerWrapper) GetEvent() isPeerToControllerWrapper_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *PeerToControllerWrapper) GetPeerHello() *PeerHello {
+	if x, ok := x.GetEvent().(*PeerToControllerWrapper_PeerHello); ok {
+		return x.PeerHello
+	}
+	return nil
+}
+
+func (x *PeerToControllerWrapper) GetAgentList() *PeerAgentList {
+	if x, ok := x.GetEvent().(*PeerToControllerWrapper_AgentList); ok {
+		return x.AgentList
+	}
+	return nil
+}
+
+func (x *PeerToControllerWrapper) GetAgentMessage() *AgentToControllerWrapper {
+	if x, ok := x.GetEvent().(*PeerToControllerWrapper_AgentMessage); ok {
+		return x.AgentMessage
+	}
+	return nil
+}
+
+func (x *PeerToControllerWrapper) GetRequestClosed() *CancelRequest {
+	if x, ok := x.GetEvent().(*PeerToControllerWrapper_RequestClosed); ok {
+		return x.RequestClosed
+	}
+	return nil
+}
+
+func (x *PeerToControllerWrapper) GetRevocations() *PeerRevocationList {
+	if x, ok := x.GetEvent().(*PeerToControllerWrapper_Revocations); ok {
+		return x.Revocations
+	}
+	return nil
+}
+
+type isPeerToControllerWrapper_Event interface {
+	isPeerToControllerWrapper_Event()
+}
+
+type PeerToControllerWrapper_PeerHello struct {
+	PeerHello *PeerHello `protobuf:"bytes,1,opt,name=peerHello,proto3,oneof"`
+}
+
+type PeerToControllerWrapper_AgentList struct {
+	AgentList *PeerAgentList `protobuf:"bytes,2,opt,name=agentList,proto3,oneof"`
+}
+
+type PeerToControllerWrapper_AgentMessage struct {
+	AgentMessage *AgentToControllerWrapper `protobuf:"bytes,3,opt,name=agentMessage,proto3,oneof"`
+}
+
+type PeerToControllerWrapper_RequestClosed struct {
+	RequestClosed *CancelRequest `protobuf:"bytes,4,opt,name=requestClosed,proto3,oneof"`
+}
+
+type PeerToControllerWrapper_Revocations struct {
+	Revocations *PeerRevocationList `protobuf:"bytes,5,opt,name=revocations,proto3,oneof"`
+}
+
+func (*PeerToControllerWrapper_PeerHello) isPeerToControllerWrapper_Event() {}
+
+func (*PeerToControllerWrapper_AgentList) isPeerToControllerWrapper_Event() {}
+
+func (*PeerToControllerWrapper_AgentMessage) isPeerToControllerWrapper_Event() {}
+
+func (*PeerToControllerWrapper_RequestClosed) isPeerToControllerWrapper_Event() {}
+
+func (*PeerToControllerWrapper_Revocations) isPeerToControllerWrapper_Event() {}
+
+// Messages sent from a controller to a connected peer controller
+type ControllerToPeerWrapper struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Event:
+	//	*ControllerToPeerWrapper_AgentRequest
+	Event isControllerToPeerWrapper_Event `protobuf_oneof:"event"`
+}
+
+func (x *ControllerToPeerWrapper) Reset() {
+	*x = ControllerToPeerWrapper{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pkg_tunnel_tunnel_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ControllerToPeerWrapper) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ControllerToPeerWrapper) ProtoMessage() {}
+
+func (x *ControllerToPeerWrapper) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_tunnel_tunnel_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ControllerToPeerWrapper.ProtoReflect.Descriptor instead.
+func (*ControllerToPeerWrapper) Descriptor() ([]byte, []int) {
+	return file_pkg_tunnel_tunnel_proto_rawDescGZIP(), []int{41}
+}
+
+func (m *ControllerToPeerWrapper) GetEvent() isControllerToPeerWrapper_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *ControllerToPeerWrapper) GetAgentRequest() *PeerAgentRequest {
+	if x, ok := x.GetEvent().(*ControllerToPeerWrapper_AgentRequest); ok {
+		return x.AgentRequest
+	}
+	return nil
+}
+
+type isControllerToPeerWrapper_Event interface {
+	isControllerToPeerWrapper_Event()
+}
+
+type ControllerToPeerWrapper_AgentRequest struct {
+	AgentRequest *PeerAgentRequest `protobuf:"bytes,1,opt,name=agentRequest,proto3,oneof"`
+}
+
+func (*ControllerToPeerWrapper_AgentRequest) isControllerToPeerWrapper_Event() {}
+
 var File_pkg_tunnel_tunnel_proto protoreflect.FileDescriptor
 
 var file_pkg_tunnel_tunnel_proto_rawDesc = []byte{
@@ -1486,7 +3359,7 @@ var file_pkg_tunnel_tunnel_proto_rawDesc = []byte{
 	0x48, 0x74, 0x74, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
 	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16,
 	0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0xb1, 0x01, 0x0a, 0x0b, 0x48, 0x74, 0x74, 0x70, 0x52,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0xb9, 0x03, 0x0a, 0x0b, 0x48, 0x74, 0x74, 0x70, 0x52,
 	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02,
 	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
@@ -1497,167 +3370,448 @@ var file_pkg_tunnel_tunnel_proto_rawDesc = []byte{
 	0x65, 0x72, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
 	0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x68,
 	0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x07,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x22, 0x1f, 0x0a, 0x0d, 0x43, 0x61,
-	0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69,
-	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x8a, 0x01, 0x0a, 0x0c,
-	0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02,
-	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06,
-	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x12, 0x2c, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18,
-	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48,
-	0x74, 0x74, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65,
-	0x72, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x4c, 0x65, 0x6e,
-	0x67, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65,
-	0x6e, 0x74, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x22, 0x39, 0x0a, 0x13, 0x48, 0x74, 0x74, 0x70,
-	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
-	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
-	0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62,
-	0x6f, 0x64, 0x79, 0x22, 0x74, 0x0a, 0x0e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x72, 0x67,
-	0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x61, 0x72,
-	0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x65, 0x6e, 0x76, 0x69, 0x72,
-	0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x65, 0x6e,
-	0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x6b, 0x0a, 0x15, 0x43, 0x6d, 0x64,
-	0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65,
-	0x6e, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d,
-	0x65, 0x6e, 0x74, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x65, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d,
-	0x65, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x65, 0x6e, 0x76, 0x69, 0x72,
-	0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0x7d, 0x0a, 0x0b, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
-	0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x49, 0x0a, 0x0c, 0x74, 0x72,
+	0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x25, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65,
+	0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0c, 0x74, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f,
+	0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x22, 0x0a,
+	0x0c, 0x62, 0x6f, 0x64, 0x79, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x65, 0x64, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0c, 0x62, 0x6f, 0x64, 0x79, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x65,
+	0x64, 0x12, 0x24, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x4c, 0x65, 0x6e, 0x67,
+	0x74, 0x68, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x75, 0x70, 0x67, 0x72, 0x61,
+	0x64, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x75, 0x70, 0x67, 0x72, 0x61, 0x64,
+	0x65, 0x1a, 0x3f, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78,
+	0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x22, 0x35, 0x0a, 0x0f, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x32, 0x0a, 0x07, 0x63, 0x68, 0x61,
-	0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e,
-	0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x44, 0x69, 0x72, 0x65, 0x63,
-	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x16, 0x0a,
-	0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x43,
-	0x6c, 0x6f, 0x73, 0x65, 0x64, 0x22, 0x74, 0x0a, 0x12, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c,
-	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x12, 0x0a, 0x04, 0x62,
-	0x6f, 0x64, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12,
-	0x32, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
-	0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65,
-	0x6c, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e,
-	0x6e, 0x65, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x18, 0x03, 0x20,
-	0x01, 0x28, 0x08, 0x52, 0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x22, 0x5a, 0x0a, 0x12, 0x43,
-	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f,
-	0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69,
-	0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x05, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a,
-	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
-	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x51, 0x0a, 0x19, 0x43, 0x6d, 0x64, 0x54, 0x6f,
-	0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65,
-	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x78, 0x0a, 0x0e, 0x45, 0x6e,
-	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04,
-	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x74, 0x79, 0x70, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72,
-	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67,
-	0x75, 0x72, 0x65, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
-	0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70,
-	0x61, 0x63, 0x65, 0x73, 0x22, 0x78, 0x0a, 0x0a, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c,
-	0x6c, 0x6f, 0x12, 0x34, 0x0a, 0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x45,
-	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x65,
-	0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73,
-	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xd2,
-	0x02, 0x0a, 0x18, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x41,
-	0x67, 0x65, 0x6e, 0x74, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x3a, 0x0a, 0x0c, 0x70,
-	0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x70, 0x69, 0x6e, 0x67, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a, 0x0b, 0x68, 0x74, 0x74, 0x70, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74,
-	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x48, 0x00, 0x52, 0x0b, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x12, 0x3d, 0x0a, 0x0d, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
-	0x2e, 0x43, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00,
-	0x52, 0x0d, 0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
-	0x40, 0x0a, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
-	0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48,
-	0x00, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x37, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e,
-	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63,
-	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76,
-	0x65, 0x6e, 0x74, 0x22, 0xa6, 0x03, 0x0a, 0x18, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x43,
-	0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72,
-	0x12, 0x37, 0x0a, 0x0b, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50,
-	0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0b, 0x70, 0x69,
-	0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x0c, 0x68, 0x74, 0x74,
-	0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4f, 0x0a, 0x13, 0x68, 0x74, 0x74, 0x70, 0x43, 0x68, 0x75,
-	0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70,
-	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48,
-	0x00, 0x52, 0x13, 0x68, 0x74, 0x74, 0x70, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x34, 0x0a, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x48,
-	0x65, 0x6c, 0x6c, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e,
-	0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x48, 0x00,
-	0x52, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x37, 0x0a, 0x0b,
-	0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
-	0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
-	0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x4c, 0x0a, 0x12, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
-	0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
-	0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52,
-	0x12, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74,
-	0x69, 0x6f, 0x6e, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0xae, 0x01, 0x0a,
-	0x1a, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
-	0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x47, 0x0a, 0x0e, 0x63,
-	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64,
-	0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x48, 0x00, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44,
-	0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
+	0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x22, 0x1f, 0x0a, 0x0d, 0x43, 0x61, 0x6e,
+	0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x8a, 0x01, 0x0a, 0x0c, 0x48,
+	0x74, 0x74, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x12, 0x2c, 0x0a, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74,
+	0x74, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x73, 0x12, 0x24, 0x0a, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x4c, 0x65, 0x6e, 0x67,
+	0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x22, 0x39, 0x0a, 0x13, 0x48, 0x74, 0x74, 0x70, 0x43,
+	0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12,
+	0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f,
+	0x64, 0x79, 0x22, 0x4c, 0x0a, 0x0e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x44, 0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6c, 0x6f, 0x73,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x64,
+	0x22, 0x89, 0x02, 0x0a, 0x0e, 0x4f, 0x70, 0x65, 0x6e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x4c, 0x0a, 0x0c, 0x74, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f,
+	0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x74, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x4f, 0x70, 0x65, 0x6e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0c, 0x74, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74,
+	0x65, 0x78, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x1a, 0x3f, 0x0a, 0x11, 0x54,
+	0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x38, 0x0a, 0x10,
+	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x70, 0x65, 0x6e, 0x65, 0x64,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x3c, 0x0a, 0x0c, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e,
+	0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x72, 0x6f, 0x77, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f,
+	0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x63, 0x6f, 0x6c,
+	0x75, 0x6d, 0x6e, 0x73, 0x22, 0xfd, 0x02, 0x0a, 0x0e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x61,
+	0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09,
+	0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x65, 0x6e, 0x76,
+	0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b,
+	0x65, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x74, 0x64, 0x69, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x64, 0x69,
+	0x6e, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03,
+	0x74, 0x74, 0x79, 0x12, 0x38, 0x0a, 0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53,
+	0x69, 0x7a, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
+	0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x52,
+	0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x4c, 0x0a,
+	0x0c, 0x74, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x08, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x72, 0x61, 0x63,
+	0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0c, 0x74,
+	0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x1a, 0x3f, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x63, 0x65, 0x43, 0x6f, 0x6e, 0x74,
+	0x65, 0x78, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x22, 0x34, 0x0a, 0x0c, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x22, 0x59, 0x0a, 0x0d, 0x43, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x38, 0x0a, 0x0c, 0x74,
+	0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69,
+	0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6c, 0x53, 0x69, 0x7a, 0x65, 0x22, 0xcd, 0x01, 0x0a, 0x15, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f,
+	0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x12, 0x20, 0x0a, 0x0b, 0x65, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d, 0x65, 0x6e, 0x74,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x65, 0x6e, 0x76, 0x69, 0x72, 0x6f, 0x6e, 0x6d,
+	0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x74, 0x79,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x03, 0x74, 0x74, 0x79, 0x12, 0x38, 0x0a, 0x0c, 0x74,
+	0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72, 0x6d, 0x69,
+	0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6c, 0x53, 0x69, 0x7a, 0x65, 0x22, 0x50, 0x0a, 0x14, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c,
+	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x38, 0x0a,
+	0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x54, 0x65, 0x72,
+	0x6d, 0x69, 0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x52, 0x0c, 0x74, 0x65, 0x72, 0x6d, 0x69,
+	0x6e, 0x61, 0x6c, 0x53, 0x69, 0x7a, 0x65, 0x22, 0x7d, 0x0a, 0x0b, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x32, 0x0a, 0x07, 0x63, 0x68,
+	0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x74, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x44, 0x69, 0x72, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x16,
+	0x0a, 0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06,
+	0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x22, 0x74, 0x0a, 0x12, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f,
+	0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x12, 0x0a, 0x04,
+	0x62, 0x6f, 0x64, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79,
+	0x12, 0x32, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x63, 0x68, 0x61,
+	0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x22, 0x5a, 0x0a, 0x12,
+	0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x69, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x51, 0x0a, 0x19, 0x43, 0x6d, 0x64, 0x54,
+	0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x2b, 0x0a, 0x13, 0x43,
+	0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x05, 0x62, 0x79, 0x74, 0x65, 0x73, 0x22, 0x78, 0x0a, 0x0e, 0x45, 0x6e, 0x64, 0x70,
+	0x6f, 0x69, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x65, 0x64,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72,
+	0x65, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63, 0x65, 0x73,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x70, 0x61, 0x63,
+	0x65, 0x73, 0x22, 0xc8, 0x02, 0x0a, 0x0a, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c,
+	0x6f, 0x12, 0x34, 0x0a, 0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x45, 0x6e,
+	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x52, 0x09, 0x65, 0x6e,
+	0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x0e, 0x0a,
+	0x02, 0x6f, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x6f, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x61, 0x72, 0x63, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x72, 0x63,
+	0x68, 0x12, 0x1e, 0x0a, 0x0a, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x48, 0x61, 0x73, 0x68, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x48, 0x61, 0x73,
+	0x68, 0x12, 0x32, 0x0a, 0x14, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x69, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x14, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42,
+	0x6f, 0x64, 0x69, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x0f, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x73,
+	0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x73, 0x12,
+	0x2c, 0x0a, 0x11, 0x70, 0x61, 0x63, 0x65, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49,
+	0x6e, 0x70, 0x75, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x70, 0x61, 0x63, 0x65,
+	0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x22, 0x39, 0x0a,
+	0x0f, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x49, 0x6e, 0x66, 0x6f,
+	0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x68, 0x61, 0x73, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x28, 0x0a, 0x12, 0x41, 0x67, 0x65, 0x6e,
+	0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x61,
+	0x73, 0x68, 0x22, 0x3c, 0x0a, 0x10, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72,
+	0x79, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72,
+	0x22, 0x2b, 0x0a, 0x17, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63,
+	0x73, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x63, 0x73, 0x72, 0x22, 0x4a, 0x0a,
+	0x10, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0b, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x3a, 0x0a, 0x10, 0x41, 0x67, 0x65,
+	0x6e, 0x74, 0x54, 0x72, 0x75, 0x73, 0x74, 0x42, 0x75, 0x6e, 0x64, 0x6c, 0x65, 0x12, 0x26, 0x0a,
+	0x0e, 0x63, 0x61, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e, 0x63, 0x61, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x73, 0x22, 0xd7, 0x02, 0x0a, 0x0d, 0x50, 0x65, 0x65, 0x72, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68,
+	0x52, 0x09, 0x65, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x64, 0x41, 0x74,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65,
+	0x64, 0x41, 0x74, 0x12, 0x32, 0x0a, 0x14, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x69, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x14, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x42, 0x6f, 0x64, 0x69, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x0f, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x73, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0f, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x73, 0x55, 0x70, 0x67, 0x72, 0x61, 0x64, 0x65,
+	0x73, 0x12, 0x2c, 0x0a, 0x11, 0x70, 0x61, 0x63, 0x65, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x11, 0x70, 0x61,
+	0x63, 0x65, 0x73, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x22,
+	0x49, 0x0a, 0x09, 0x50, 0x65, 0x65, 0x72, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x22, 0x0a, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x49, 0x64,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x3e, 0x0a, 0x0d, 0x50, 0x65,
+	0x65, 0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x06, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x49, 0x6e,
+	0x66, 0x6f, 0x52, 0x06, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x73, 0x22, 0x80, 0x01, 0x0a, 0x16, 0x50,
+	0x65, 0x65, 0x72, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x41, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x41, 0x74, 0x12,
+	0x1c, 0x0a, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x09, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x73, 0x41, 0x74, 0x22, 0x58, 0x0a,
+	0x12, 0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c,
+	0x69, 0x73, 0x74, 0x12, 0x42, 0x0a, 0x0c, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
+	0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x52, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x0c, 0x63, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x73, 0x22, 0x90, 0x01, 0x0a, 0x10, 0x50, 0x65, 0x65, 0x72,
+	0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09,
+	0x61, 0x67, 0x65, 0x6e, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x61, 0x67,
+	0x65, 0x6e, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x3a,
+	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c,
+	0x6c, 0x65, 0x72, 0x54, 0x6f, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65,
+	0x72, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xb3, 0x07, 0x0a, 0x18, 0x43,
+	0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x3a, 0x0a, 0x0c, 0x70, 0x69, 0x6e, 0x67, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x37, 0x0a, 0x0b, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
+	0x0b, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3d, 0x0a, 0x0d,
+	0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x61, 0x6e,
+	0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0d, 0x63, 0x61,
+	0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x40, 0x0a, 0x0e, 0x63,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0e, 0x63,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x37, 0x0a,
+	0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x3d, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65,
+	0x73, 0x69, 0x7a, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52,
+	0x65, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x43, 0x0a, 0x0f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69,
+	0x6e, 0x61, 0x72, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e,
+	0x61, 0x72, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x48, 0x00, 0x52, 0x0f, 0x61, 0x67, 0x65, 0x6e, 0x74,
+	0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x46, 0x0a, 0x10, 0x61, 0x67,
+	0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x48, 0x00,
+	0x52, 0x10, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x12, 0x46, 0x0a, 0x10, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x10, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x43,
+	0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x3a, 0x0a, 0x0c, 0x77, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x43, 0x0a, 0x0f, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x17, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x79, 0x48, 0x00, 0x52, 0x0f, 0x68, 0x74, 0x74, 0x70,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x40, 0x0a, 0x0e, 0x63,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x18, 0x0c, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0e, 0x63,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x12, 0x40, 0x0a,
+	0x0e, 0x6f, 0x70, 0x65, 0x6e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x4f,
+	0x70, 0x65, 0x6e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52,
+	0x0e, 0x6f, 0x70, 0x65, 0x6e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x46, 0x0a, 0x10, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x72, 0x75, 0x73, 0x74, 0x42, 0x75, 0x6e,
+	0x64, 0x6c, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
+	0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x72, 0x75, 0x73, 0x74, 0x42, 0x75, 0x6e,
+	0x64, 0x6c, 0x65, 0x48, 0x00, 0x52, 0x10, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x72, 0x75, 0x73,
+	0x74, 0x42, 0x75, 0x6e, 0x64, 0x6c, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74,
+	0x22, 0x97, 0x06, 0x0a, 0x18, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74,
+	0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x37, 0x0a,
+	0x0b, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x69, 0x6e, 0x67,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0b, 0x70, 0x69, 0x6e, 0x67, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x0c, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x68, 0x74, 0x74, 0x70, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x4f, 0x0a, 0x13, 0x68, 0x74, 0x74, 0x70, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65,
+	0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1b, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x13,
+	0x68, 0x74, 0x74, 0x70, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x34, 0x0a, 0x0a, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c,
+	0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
+	0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x48, 0x00, 0x52, 0x0a, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x12, 0x37, 0x0a, 0x0b, 0x63, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13,
+	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44,
+	0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x4c, 0x0a, 0x12, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72,
+	0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54,
+	0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x12, 0x63, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x4c, 0x0a, 0x12, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x42, 0x69, 0x6e, 0x61, 0x72,
+	0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x12, 0x61, 0x67, 0x65, 0x6e,
+	0x74, 0x42, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x5b,
+	0x0a, 0x17, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1f, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65,
+	0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x00, 0x52, 0x17, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3a, 0x0a, 0x0c, 0x77,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x77, 0x69, 0x6e, 0x64, 0x6f,
+	0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x40, 0x0a, 0x0e, 0x63, 0x6f, 0x6e, 0x6e, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0e, 0x63, 0x6f, 0x6e, 0x6e, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x12, 0x46, 0x0a, 0x10, 0x63, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x70, 0x65, 0x6e, 0x65, 0x64, 0x18, 0x0b, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x70, 0x65, 0x6e, 0x65, 0x64, 0x48, 0x00, 0x52,
+	0x10, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x70, 0x65, 0x6e, 0x65,
+	0x64, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0xf4, 0x01, 0x0a, 0x1a, 0x43,
+	0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c,
+	0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x47, 0x0a, 0x0e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f,
+	0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x00, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74,
+	0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
+	0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44,
+	0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x44, 0x0a, 0x0d, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73,
+	0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
 	0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
-	0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
-	0x44, 0x61, 0x74, 0x61, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0xba, 0x01,
-	0x0a, 0x1a, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x43, 0x6d,
-	0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x53, 0x0a, 0x12,
-	0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
-	0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
-	0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x12, 0x63,
-	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f,
-	0x6e, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74, 0x61,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e,
-	0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61,
-	0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x44, 0x61, 0x74,
-	0x61, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2a, 0x35, 0x0a, 0x10, 0x43, 0x68,
-	0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x09,
-	0x0a, 0x05, 0x53, 0x54, 0x44, 0x49, 0x4e, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x54, 0x44,
-	0x4f, 0x55, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x54, 0x44, 0x45, 0x52, 0x52, 0x10,
-	0x02, 0x32, 0x6d, 0x0a, 0x12, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
-	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x57, 0x0a, 0x0b, 0x45, 0x76, 0x65, 0x6e, 0x74,
-	0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e,
-	0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65,
-	0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x1a, 0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
-	0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x41, 0x67,
-	0x65, 0x6e, 0x74, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01,
-	0x32, 0x73, 0x0a, 0x14, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x75, 0x6e, 0x6e, 0x65,
-	0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5b, 0x0a, 0x0b, 0x45, 0x76, 0x65, 0x6e,
-	0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x22, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
-	0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
-	0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x1a, 0x22, 0x2e, 0x74, 0x75,
+	0x64, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x48, 0x00, 0x52, 0x0d, 0x63, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x52, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e,
+	0x74, 0x22, 0xfd, 0x01, 0x0a, 0x1a, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72,
+	0x54, 0x6f, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72,
+	0x12, 0x53, 0x0a, 0x12, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69,
+	0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48,
+	0x00, 0x52, 0x12, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x54, 0x65, 0x72, 0x6d, 0x69, 0x6e,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3e, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
+	0x44, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x43, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e,
+	0x64, 0x44, 0x61, 0x74, 0x61, 0x12, 0x41, 0x0a, 0x0c, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x74, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x69, 0x6e, 0x64,
+	0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0c, 0x77, 0x69, 0x6e, 0x64,
+	0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e,
+	0x74, 0x22, 0xd3, 0x02, 0x0a, 0x17, 0x50, 0x65, 0x65, 0x72, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74,
+	0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x12, 0x31, 0x0a,
+	0x09, 0x70, 0x65, 0x65, 0x72, 0x48, 0x65, 0x6c, 0x6c, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x11, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x48, 0x65,
+	0x6c, 0x6c, 0x6f, 0x48, 0x00, 0x52, 0x09, 0x70, 0x65, 0x65, 0x72, 0x48, 0x65, 0x6c, 0x6c, 0x6f,
+	0x12, 0x35, 0x0a, 0x09, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65,
+	0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x48, 0x00, 0x52, 0x09, 0x61, 0x67,
+	0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x46, 0x0a, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e,
+	0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x43, 0x6f,
+	0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x48,
+	0x00, 0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12,
+	0x3d, 0x0a, 0x0d, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e,
+	0x43, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52,
+	0x0d, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x64, 0x12, 0x3e,
+	0x0a, 0x0b, 0x72, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65,
+	0x72, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x69, 0x73, 0x74, 0x48,
+	0x00, 0x52, 0x0b, 0x72, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x42, 0x07,
+	0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x62, 0x0a, 0x17, 0x43, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x50, 0x65, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70,
+	0x65, 0x72, 0x12, 0x3e, 0x0a, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x48, 0x00, 0x52, 0x0c, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2a, 0x35, 0x0a, 0x10, 0x43,
+	0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x09, 0x0a, 0x05, 0x53, 0x54, 0x44, 0x49, 0x4e, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x54,
+	0x44, 0x4f, 0x55, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x54, 0x44, 0x45, 0x52, 0x52,
+	0x10, 0x02, 0x32, 0x6d, 0x0a, 0x12, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x57, 0x0a, 0x0b, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c,
+	0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c,
+	0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x1a, 0x20, 0x2e, 0x74, 0x75, 0x6e, 0x6e,
+	0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54, 0x6f, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x22, 0x00, 0x28, 0x01, 0x30,
+	0x01, 0x32, 0x73, 0x0a, 0x14, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x75, 0x6e, 0x6e,
+	0x65, 0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5b, 0x0a, 0x0b, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x22, 0x2e, 0x74, 0x75, 0x6e, 0x6e, 0x65,
+	0x6c, 0x2e, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72,
+	0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x1a, 0x22, 0x2e, 0x74,
+	0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72,
+	0x54, 0x6f, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72,
+	0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x32, 0x6a, 0x0a, 0x11, 0x50, 0x65, 0x65, 0x72, 0x54, 0x75,
+	0x6e, 0x6e, 0x65, 0x6c, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x55, 0x0a, 0x0b, 0x45,
+	0x76, 0x65, 0x6e, 0x74, 0x54, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x12, 0x1f, 0x2e, 0x74, 0x75, 0x6e,
+	0x6e, 0x65, 0x6c, 0x2e, 0x50, 0x65, 0x65, 0x72, 0x54, 0x6f, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f,
+	0x6c, 0x6c, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x1a, 0x1f, 0x2e, 0x74, 0x75,
 	0x6e, 0x6e, 0x65, 0x6c, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x6c, 0x65, 0x72, 0x54,
-	0x6f, 0x43, 0x6d, 0x64, 0x54, 0x6f, 0x6f, 0x6c, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x22,
-	0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x0b, 0x5a, 0x09, 0x2e, 0x2f, 0x3b, 0x74, 0x75, 0x6e, 0x6e,
-	0x65, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6f, 0x50, 0x65, 0x65, 0x72, 0x57, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x22, 0x00, 0x28, 0x01,
+	0x30, 0x01, 0x42, 0x0b, 0x5a, 0x09, 0x2e, 0x2f, 0x3b, 0x74, 0x75, 0x6e, 0x6e, 0x65, 0x6c, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1673,59 +3827,120 @@ func file_pkg_tunnel_tunnel_proto_rawDescGZIP() []byte {
 }
 
 var file_pkg_tunnel_tunnel_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_pkg_tunnel_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_pkg_tunnel_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 45)
 var file_pkg_tunnel_tunnel_proto_goTypes = []interface{}{
 	(ChannelDirection)(0),              // 0: tunnel.ChannelDirection
 	(*PingRequest)(nil),                // 1: tunnel.PingRequest
 	(*PingResponse)(nil),               // 2: tunnel.PingResponse
 	(*HttpHeader)(nil),                 // 3: tunnel.HttpHeader
 	(*HttpRequest)(nil),                // 4: tunnel.HttpRequest
-	(*CancelRequest)(nil),              // 5: tunnel.CancelRequest
-	(*HttpResponse)(nil),               // 6: tunnel.HttpResponse
-	(*HttpChunkedResponse)(nil),        // 7: tunnel.HttpChunkedResponse
-	(*CommandRequest)(nil),             // 8: tunnel.CommandRequest
-	(*CmdToolCommandRequest)(nil),      // 9: tunnel.CmdToolCommandRequest
-	(*CommandData)(nil),                // 10: tunnel.CommandData
-	(*CmdToolCommandData)(nil),         // 11: tunnel.CmdToolCommandData
-	(*CommandTermination)(nil),         // 12: tunnel.CommandTermination
-	(*CmdToolCommandTermination)(nil),  // 13: tunnel.CmdToolCommandTermination
-	(*EndpointHealth)(nil),             // 14: tunnel.EndpointHealth
-	(*AgentHello)(nil),                 // 15: tunnel.AgentHello
-	(*ControllerToAgentWrapper)(nil),   // 16: tunnel.ControllerToAgentWrapper
-	(*AgentToControllerWrapper)(nil),   // 17: tunnel.AgentToControllerWrapper
-	(*CmdToolToControllerWrapper)(nil), // 18: tunnel.CmdToolToControllerWrapper
-	(*ControllerToCmdToolWrapper)(nil), // 19: tunnel.ControllerToCmdToolWrapper
+	(*HttpRequestBody)(nil),            // 5: tunnel.HttpRequestBody
+	(*CancelRequest)(nil),              // 6: tunnel.CancelRequest
+	(*HttpResponse)(nil),               // 7: tunnel.HttpResponse
+	(*HttpChunkedResponse)(nil),        // 8: tunnel.HttpChunkedResponse
+	(*ConnectionData)(nil),             // 9: tunnel.ConnectionData
+	(*OpenConnection)(nil),             // 10: tunnel.OpenConnection
+	(*ConnectionOpened)(nil),           // 11: tunnel.ConnectionOpened
+	(*TerminalSize)(nil),               // 12: tunnel.TerminalSize
+	(*CommandRequest)(nil),             // 13: tunnel.CommandRequest
+	(*WindowUpdate)(nil),               // 14: tunnel.WindowUpdate
+	(*CommandResize)(nil),              // 15: tunnel.CommandResize
+	(*CmdToolCommandRequest)(nil),      // 16: tunnel.CmdToolCommandRequest
+	(*CmdToolCommandResize)(nil),       // 17: tunnel.CmdToolCommandResize
+	(*CommandData)(nil),                // 18: tunnel.CommandData
+	(*CmdToolCommandData)(nil),         // 19: tunnel.CmdToolCommandData
+	(*CommandTermination)(nil),         // 20: tunnel.CommandTermination
+	(*CmdToolCommandTermination)(nil),  // 21: tunnel.CmdToolCommandTermination
+	(*CmdToolWindowUpdate)(nil),        // 22: tunnel.CmdToolWindowUpdate
+	(*EndpointHealth)(nil),             // 23: tunnel.EndpointHealth
+	(*AgentHello)(nil),                 // 24: tunnel.AgentHello
+	(*AgentBinaryInfo)(nil),            // 25: tunnel.AgentBinaryInfo
+	(*AgentBinaryRequest)(nil),         // 26: tunnel.AgentBinaryRequest
+	(*AgentBinaryChunk)(nil),           // 27: tunnel.AgentBinaryChunk
+	(*AgentCertificateRequest)(nil),    // 28: tunnel.AgentCertificateRequest
+	(*AgentCertificate)(nil),           // 29: tunnel.AgentCertificate
+	(*AgentTrustBundle)(nil),           // 30: tunnel.AgentTrustBundle
+	(*PeerAgentInfo)(nil),              // 31: tunnel.PeerAgentInfo
+	(*PeerHello)(nil),                  // 32: tunnel.PeerHello
+	(*PeerAgentList)(nil),              // 33: tunnel.PeerAgentList
+	(*PeerRevokedCertificate)(nil),     // 34: tunnel.PeerRevokedCertificate
+	(*PeerRevocationList)(nil),         // 35: tunnel.PeerRevocationList
+	(*PeerAgentRequest)(nil),           // 36: tunnel.PeerAgentRequest
+	(*ControllerToAgentWrapper)(nil),   // 37: tunnel.ControllerToAgentWrapper
+	(*AgentToControllerWrapper)(nil),   // 38: tunnel.AgentToControllerWrapper
+	(*CmdToolToControllerWrapper)(nil), // 39: tunnel.CmdToolToControllerWrapper
+	(*ControllerToCmdToolWrapper)(nil), // 40: tunnel.ControllerToCmdToolWrapper
+	(*PeerToControllerWrapper)(nil),    // 41: tunnel.PeerToControllerWrapper
+	(*ControllerToPeerWrapper)(nil),    // 42: tunnel.ControllerToPeerWrapper
+	nil,                                // 43: tunnel.HttpRequest.TraceContextEntry
+	nil,                                // 44: tunnel.OpenConnection.TraceContextEntry
+	nil,                                // 45: tunnel.CommandRequest.TraceContextEntry
 }
 var file_pkg_tunnel_tunnel_proto_depIdxs = []int32{
 	3,  // 0: tunnel.HttpRequest.headers:type_name -> tunnel.HttpHeader
-	3,  // 1: tunnel.HttpResponse.headers:type_name -> tunnel.HttpHeader
-	0,  // 2: tunnel.CommandData.channel:type_name -> tunnel.ChannelDirection
-	0,  // 3: tunnel.CmdToolCommandData.channel:type_name -> tunnel.ChannelDirection
-	14, // 4: tunnel.AgentHello.endpoints:type_name -> tunnel.EndpointHealth
-	2,  // 5: tunnel.ControllerToAgentWrapper.pingResponse:type_name -> tunnel.PingResponse
-	4,  // 6: tunnel.ControllerToAgentWrapper.httpRequest:type_name -> tunnel.HttpRequest
-	5,  // 7: tunnel.ControllerToAgentWrapper.cancelRequest:type_name -> tunnel.CancelRequest
-	8,  // 8: tunnel.ControllerToAgentWrapper.commandRequest:type_name -> tunnel.CommandRequest
-	10, // 9: tunnel.ControllerToAgentWrapper.commandData:type_name -> tunnel.CommandData
-	1,  // 10: tunnel.AgentToControllerWrapper.pingRequest:type_name -> tunnel.PingRequest
-	6,  // 11: tunnel.AgentToControllerWrapper.httpResponse:type_name -> tunnel.HttpResponse
-	7,  // 12: tunnel.AgentToControllerWrapper.httpChunkedResponse:type_name -> tunnel.HttpChunkedResponse
-	15, // 13: tunnel.AgentToControllerWrapper.agentHello:type_name -> tunnel.AgentHello
-	10, // 14: tunnel.AgentToControllerWrapper.commandData:type_name -> tunnel.CommandData
-	12, // 15: tunnel.AgentToControllerWrapper.commandTermination:type_name -> tunnel.CommandTermination
-	9,  // 16: tunnel.CmdToolToControllerWrapper.commandRequest:type_name -> tunnel.CmdToolCommandRequest
-	11, // 17: tunnel.CmdToolToControllerWrapper.commandData:type_name -> tunnel.CmdToolCommandData
-	13, // 18: tunnel.ControllerToCmdToolWrapper.commandTermination:type_name -> tunnel.CmdToolCommandTermination
-	11, // 19: tunnel.ControllerToCmdToolWrapper.commandData:type_name -> tunnel.CmdToolCommandData
-	17, // 20: tunnel.AgentTunnelService.EventTunnel:input_type -> tunnel.AgentToControllerWrapper
-	18, // 21: tunnel.CmdToolTunnelService.EventTunnel:input_type -> tunnel.CmdToolToControllerWrapper
-	16, // 22: tunnel.AgentTunnelService.EventTunnel:output_type -> tunnel.ControllerToAgentWrapper
-	19, // 23: tunnel.CmdToolTunnelService.EventTunnel:output_type -> tunnel.ControllerToCmdToolWrapper
-	22, // [22:24] is the sub-list for method output_type
-	20, // [20:22] is the sub-list for method input_type
-	20, // [20:20] is the sub-list for extension type_name
-	20, // [20:20] is the sub-list for extension extendee
-	0,  // [0:20] is the sub-list for field type_name
+	43, // 1: tunnel.HttpRequest.traceContext:type_name -> tunnel.HttpRequest.TraceContextEntry
+	3,  // 2: tunnel.HttpResponse.headers:type_name -> tunnel.HttpHeader
+	44, // 3: tunnel.OpenConnection.traceContext:type_name -> tunnel.OpenConnection.TraceContextEntry
+	12, // 4: tunnel.CommandRequest.terminalSize:type_name -> tunnel.TerminalSize
+	45, // 5: tunnel.CommandRequest.traceContext:type_name -> tunnel.CommandRequest.TraceContextEntry
+	12, // 6: tunnel.CommandResize.terminalSize:type_name -> tunnel.TerminalSize
+	12, // 7: tunnel.CmdToolCommandRequest.terminalSize:type_name -> tunnel.TerminalSize
+	12, // 8: tunnel.CmdToolCommandResize.terminalSize:type_name -> tunnel.TerminalSize
+	0,  // 9: tunnel.CommandData.channel:type_name -> tunnel.ChannelDirection
+	0,  // 10: tunnel.CmdToolCommandData.channel:type_name -> tunnel.ChannelDirection
+	23, // 11: tunnel.AgentHello.endpoints:type_name -> tunnel.EndpointHealth
+	23, // 12: tunnel.PeerAgentInfo.endpoints:type_name -> tunnel.EndpointHealth
+	31, // 13: tunnel.PeerAgentList.agents:type_name -> tunnel.PeerAgentInfo
+	34, // 14: tunnel.PeerRevocationList.certificates:type_name -> tunnel.PeerRevokedCertificate
+	37, // 15: tunnel.PeerAgentRequest.message:type_name -> tunnel.ControllerToAgentWrapper
+	2,  // 16: tunnel.ControllerToAgentWrapper.pingResponse:type_name -> tunnel.PingResponse
+	4,  // 17: tunnel.ControllerToAgentWrapper.httpRequest:type_name -> tunnel.HttpRequest
+	6,  // 18: tunnel.ControllerToAgentWrapper.cancelRequest:type_name -> tunnel.CancelRequest
+	13, // 19: tunnel.ControllerToAgentWrapper.commandRequest:type_name -> tunnel.CommandRequest
+	18, // 20: tunnel.ControllerToAgentWrapper.commandData:type_name -> tunnel.CommandData
+	15, // 21: tunnel.ControllerToAgentWrapper.commandResize:type_name -> tunnel.CommandResize
+	25, // 22: tunnel.ControllerToAgentWrapper.agentBinaryInfo:type_name -> tunnel.AgentBinaryInfo
+	27, // 23: tunnel.ControllerToAgentWrapper.agentBinaryChunk:type_name -> tunnel.AgentBinaryChunk
+	29, // 24: tunnel.ControllerToAgentWrapper.agentCertificate:type_name -> tunnel.AgentCertificate
+	14, // 25: tunnel.ControllerToAgentWrapper.windowUpdate:type_name -> tunnel.WindowUpdate
+	5,  // 26: tunnel.ControllerToAgentWrapper.httpRequestBody:type_name -> tunnel.HttpRequestBody
+	9,  // 27: tunnel.ControllerToAgentWrapper.connectionData:type_name -> tunnel.ConnectionData
+	10, // 28: tunnel.ControllerToAgentWrapper.openConnection:type_name -> tunnel.OpenConnection
+	30, // 29: tunnel.ControllerToAgentWrapper.agentTrustBundle:type_name -> tunnel.AgentTrustBundle
+	1,  // 30: tunnel.AgentToControllerWrapper.pingRequest:type_name -> tunnel.PingRequest
+	7,  // 31: tunnel.AgentToControllerWrapper.httpResponse:type_name -> tunnel.HttpResponse
+	8,  // 32: tunnel.AgentToControllerWrapper.httpChunkedResponse:type_name -> tunnel.HttpChunkedResponse
+	24, // 33: tunnel.AgentToControllerWrapper.agentHello:type_name -> tunnel.AgentHello
+	18, // 34: tunnel.AgentToControllerWrapper.commandData:type_name -> tunnel.CommandData
+	20, // 35: tunnel.AgentToControllerWrapper.commandTermination:type_name -> tunnel.CommandTermination
+	26, // 36: tunnel.AgentToControllerWrapper.agentBinaryRequest:type_name -> tunnel.AgentBinaryRequest
+	28, // 37: tunnel.AgentToControllerWrapper.agentCertificateRequest:type_name -> tunnel.AgentCertificateRequest
+	14, // 38: tunnel.AgentToControllerWrapper.windowUpdate:type_name -> tunnel.WindowUpdate
+	9,  // 39: tunnel.AgentToControllerWrapper.connectionData:type_name -> tunnel.ConnectionData
+	11, // 40: tunnel.AgentToControllerWrapper.connectionOpened:type_name -> tunnel.ConnectionOpened
+	16, // 41: tunnel.CmdToolToControllerWrapper.commandRequest:type_name -> tunnel.CmdToolCommandRequest
+	19, // 42: tunnel.CmdToolToControllerWrapper.commandData:type_name -> tunnel.CmdToolCommandData
+	17, // 43: tunnel.CmdToolToControllerWrapper.commandResize:type_name -> tunnel.CmdToolCommandResize
+	21, // 44: tunnel.ControllerToCmdToolWrapper.commandTermination:type_name -> tunnel.CmdToolCommandTermination
+	19, // 45: tunnel.ControllerToCmdToolWrapper.commandData:type_name -> tunnel.CmdToolCommandData
+	22, // 46: tunnel.ControllerToCmdToolWrapper.windowUpdate:type_name -> tunnel.CmdToolWindowUpdate
+	32, // 47: tunnel.PeerToControllerWrapper.peerHello:type_name -> tunnel.PeerHello
+	33, // 48: tunnel.PeerToControllerWrapper.agentList:type_name -> tunnel.PeerAgentList
+	38, // 49: tunnel.PeerToControllerWrapper.agentMessage:type_name -> tunnel.AgentToControllerWrapper
+	6,  // 50: tunnel.PeerToControllerWrapper.requestClosed:type_name -> tunnel.CancelRequest
+	35, // 51: tunnel.PeerToControllerWrapper.revocations:type_name -> tunnel.PeerRevocationList
+	36, // 52: tunnel.ControllerToPeerWrapper.agentRequest:type_name -> tunnel.PeerAgentRequest
+	38, // 53: tunnel.AgentTunnelService.EventTunnel:input_type -> tunnel.AgentToControllerWrapper
+	39, // 54: tunnel.CmdToolTunnelService.EventTunnel:input_type -> tunnel.CmdToolToControllerWrapper
+	41, // 55: tunnel.PeerTunnelService.EventTunnel:input_type -> tunnel.PeerToControllerWrapper
+	37, // 56: tunnel.AgentTunnelService.EventTunnel:output_type -> tunnel.ControllerToAgentWrapper
+	40, // 57: tunnel.CmdToolTunnelService.EventTunnel:output_type -> tunnel.ControllerToCmdToolWrapper
+	42, // 58: tunnel.PeerTunnelService.EventTunnel:output_type -> tunnel.ControllerToPeerWrapper
+	56, // [56:59] is the sub-list for method output_type
+	53, // [53:56] is the sub-list for method input_type
+	53, // [53:53] is the sub-list for extension type_name
+	53, // [53:53] is the sub-list for extension extendee
+	0,  // [0:53] is the sub-list for field type_name
 }
 
 func init() { file_pkg_tunnel_tunnel_proto_init() }
@@ -1746,8 +3961,116 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PingResponse); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PingResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HttpHeader); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HttpRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HttpRequestBody); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CancelRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HttpResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HttpChunkedResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConnectionData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OpenConnection); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConnectionOpened); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1758,8 +4081,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HttpHeader); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TerminalSize); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1770,8 +4093,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HttpRequest); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommandRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1782,8 +4105,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CancelRequest); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WindowUpdate); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1794,8 +4117,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HttpResponse); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommandResize); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1806,8 +4129,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*HttpChunkedResponse); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CmdToolCommandRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1818,8 +4141,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CommandRequest); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CmdToolCommandResize); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1830,8 +4153,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CmdToolCommandRequest); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommandData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1842,8 +4165,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CommandData); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CmdToolCommandData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1854,8 +4177,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CmdToolCommandData); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommandTermination); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1866,8 +4189,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CommandTermination); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CmdToolCommandTermination); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1878,8 +4201,8 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CmdToolCommandTermination); i {
+		file_pkg_tunnel_tunnel_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CmdToolWindowUpdate); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1890,7 +4213,7 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*EndpointHealth); i {
 			case 0:
 				return &v.state
@@ -1902,7 +4225,7 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*AgentHello); i {
 			case 0:
 				return &v.state
@@ -1914,7 +4237,151 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentBinaryInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentBinaryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentBinaryChunk); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentCertificateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentCertificate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentTrustBundle); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerAgentInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerHello); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerAgentList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerRevokedCertificate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerRevocationList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerAgentRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ControllerToAgentWrapper); i {
 			case 0:
 				return &v.state
@@ -1926,7 +4393,7 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*AgentToControllerWrapper); i {
 			case 0:
 				return &v.state
@@ -1938,7 +4405,7 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*CmdToolToControllerWrapper); i {
 			case 0:
 				return &v.state
@@ -1950,7 +4417,7 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
-		file_pkg_tunnel_tunnel_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+		file_pkg_tunnel_tunnel_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*ControllerToCmdToolWrapper); i {
 			case 0:
 				return &v.state
@@ -1962,29 +4429,79 @@ func file_pkg_tunnel_tunnel_proto_init() {
 				return nil
 			}
 		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PeerToControllerWrapper); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pkg_tunnel_tunnel_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ControllerToPeerWrapper); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
-	file_pkg_tunnel_tunnel_proto_msgTypes[15].OneofWrappers = []interface{}{
+	file_pkg_tunnel_tunnel_proto_msgTypes[36].OneofWrappers = []interface{}{
 		(*ControllerToAgentWrapper_PingResponse)(nil),
 		(*ControllerToAgentWrapper_HttpRequest)(nil),
 		(*ControllerToAgentWrapper_CancelRequest)(nil),
 		(*ControllerToAgentWrapper_CommandRequest)(nil),
 		(*ControllerToAgentWrapper_CommandData)(nil),
+		(*ControllerToAgentWrapper_CommandResize)(nil),
+		(*ControllerToAgentWrapper_AgentBinaryInfo)(nil),
+		(*ControllerToAgentWrapper_AgentBinaryChunk)(nil),
+		(*ControllerToAgentWrapper_AgentCertificate)(nil),
+		(*ControllerToAgentWrapper_WindowUpdate)(nil),
+		(*ControllerToAgentWrapper_HttpRequestBody)(nil),
+		(*ControllerToAgentWrapper_ConnectionData)(nil),
+		(*ControllerToAgentWrapper_OpenConnection)(nil),
+		(*ControllerToAgentWrapper_AgentTrustBundle)(nil),
 	}
-	file_pkg_tunnel_tunnel_proto_msgTypes[16].OneofWrappers = []interface{}{
+	file_pkg_tunnel_tunnel_proto_msgTypes[37].OneofWrappers = []interface{}{
 		(*AgentToControllerWrapper_PingRequest)(nil),
 		(*AgentToControllerWrapper_HttpResponse)(nil),
 		(*AgentToControllerWrapper_HttpChunkedResponse)(nil),
 		(*AgentToControllerWrapper_AgentHello)(nil),
 		(*AgentToControllerWrapper_CommandData)(nil),
 		(*AgentToControllerWrapper_CommandTermination)(nil),
+		(*AgentToControllerWrapper_AgentBinaryRequest)(nil),
+		(*AgentToControllerWrapper_AgentCertificateRequest)(nil),
+		(*AgentToControllerWrapper_WindowUpdate)(nil),
+		(*AgentToControllerWrapper_ConnectionData)(nil),
+		(*AgentToControllerWrapper_ConnectionOpened)(nil),
 	}
-	file_pkg_tunnel_tunnel_proto_msgTypes[17].OneofWrappers = []interface{}{
+	file_pkg_tunnel_tunnel_proto_msgTypes[38].OneofWrappers = []interface{}{
 		(*CmdToolToControllerWrapper_CommandRequest)(nil),
 		(*CmdToolToControllerWrapper_CommandData)(nil),
+		(*CmdToolToControllerWrapper_CommandResize)(nil),
 	}
-	file_pkg_tunnel_tunnel_proto_msgTypes[18].OneofWrappers = []interface{}{
+	file_pkg_tunnel_tunnel_proto_msgTypes[39].OneofWrappers = []interface{}{
 		(*ControllerToCmdToolWrapper_CommandTermination)(nil),
 		(*ControllerToCmdToolWrapper_CommandData)(nil),
+		(*ControllerToCmdToolWrapper_WindowUpdate)(nil),
+	}
+	file_pkg_tunnel_tunnel_proto_msgTypes[40].OneofWrappers = []interface{}{
+		(*PeerToControllerWrapper_PeerHello)(nil),
+		(*PeerToControllerWrapper_AgentList)(nil),
+		(*PeerToControllerWrapper_AgentMessage)(nil),
+		(*PeerToControllerWrapper_RequestClosed)(nil),
+		(*PeerToControllerWrapper_Revocations)(nil),
+	}
+	file_pkg_tunnel_tunnel_proto_msgTypes[41].OneofWrappers = []interface{}{
+		(*ControllerToPeerWrapper_AgentRequest)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1992,9 +4509,9 @@ func file_pkg_tunnel_tunnel_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pkg_tunnel_tunnel_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   19,
+			NumMessages:   45,
 			NumExtensions: 0,
-			NumServices:   2,
+			NumServices:   3,
 		},
 		GoTypes:           file_pkg_tunnel_tunnel_proto_goTypes,
 		DependencyIndexes: file_pkg_tunnel_tunnel_proto_depIdxs,
@@ -2222,3 +4739,107 @@ var _CmdToolTunnelService_serviceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "pkg/tunnel/tunnel.proto",
 }
+
+// PeerTunnelServiceClient is the client API for PeerTunnelService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PeerTunnelServiceClient interface {
+	EventTunnel(ctx context.Context, opts ...grpc.CallOption) (PeerTunnelService_EventTunnelClient, error)
+}
+
+type peerTunnelServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPeerTunnelServiceClient(cc grpc.ClientConnInterface) PeerTunnelServiceClient {
+	return &peerTunnelServiceClient{cc}
+}
+
+func (c *peerTunnelServiceClient) EventTunnel(ctx context.Context, opts ...grpc.CallOption) (PeerTunnelService_EventTunnelClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_PeerTunnelService_serviceDesc.Streams[0], "/tunnel.PeerTunnelService/EventTunnel", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &peerTunnelServiceEventTunnelClient{stream}
+	return x, nil
+}
+
+type PeerTunnelService_EventTunnelClient interface {
+	Send(*PeerToControllerWrapper) error
+	Recv() (*ControllerToPeerWrapper, error)
+	grpc.ClientStream
+}
+
+type peerTunnelServiceEventTunnelClient struct {
+	grpc.ClientStream
+}
+
+func (x *peerTunnelServiceEventTunnelClient) Send(m *PeerToControllerWrapper) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *peerTunnelServiceEventTunnelClient) Recv() (*ControllerToPeerWrapper, error) {
+	m := new(ControllerToPeerWrapper)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PeerTunnelServiceServer is the server API for PeerTunnelService service.
+type PeerTunnelServiceServer interface {
+	EventTunnel(PeerTunnelService_EventTunnelServer) error
+}
+
+// UnimplementedPeerTunnelServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedPeerTunnelServiceServer struct {
+}
+
+func (*UnimplementedPeerTunnelServiceServer) EventTunnel(PeerTunnelService_EventTunnelServer) error {
+	return status.Errorf(codes.Unimplemented, "method EventTunnel not implemented")
+}
+
+func RegisterPeerTunnelServiceServer(s *grpc.Server, srv PeerTunnelServiceServer) {
+	s.RegisterService(&_PeerTunnelService_serviceDesc, srv)
+}
+
+func _PeerTunnelService_EventTunnel_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PeerTunnelServiceServer).EventTunnel(&peerTunnelServiceEventTunnelServer{stream})
+}
+
+type PeerTunnelService_EventTunnelServer interface {
+	Send(*ControllerToPeerWrapper) error
+	Recv() (*PeerToControllerWrapper, error)
+	grpc.ServerStream
+}
+
+type peerTunnelServiceEventTunnelServer struct {
+	grpc.ServerStream
+}
+
+func (x *peerTunnelServiceEventTunnelServer) Send(m *ControllerToPeerWrapper) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *peerTunnelServiceEventTunnelServer) Recv() (*PeerToControllerWrapper, error) {
+	m := new(PeerToControllerWrapper)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _PeerTunnelService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tunnel.PeerTunnelService",
+	HandlerType: (*PeerTunnelServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EventTunnel",
+			Handler:       _PeerTunnelService_EventTunnel_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/tunnel/tunnel.proto",
+}
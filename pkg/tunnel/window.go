@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tunnel
+
+import (
+	"context"
+	"math"
+	"sync"
+)
+
+//
+// RequestBodyWindow is how many bytes of a streamed request body the
+// controller may send before waiting for a WindowUpdate from the agent.
+//
+const RequestBodyWindow = 256 * 1024
+
+//
+// SendWindow holds the credit the receiving side has given for one
+// stream.  The sender may send while any credit remains, so a single
+// chunk can take it below zero, and then waits for WindowUpdate messages
+// as the receiver passes the data on.
+//
+// A nil SendWindow is for a receiver which does not do flow control,
+// and never waits.
+//
+type SendWindow struct {
+	sync.Mutex
+	available int64
+	granted   chan struct{}
+}
+
+// NewSendWindow returns a window with the initial credit.
+func NewSendWindow(size int64) *SendWindow {
+	return &SendWindow{
+		available: size,
+		granted:   make(chan struct{}, 1),
+	}
+}
+
+// Grant adds credit from a WindowUpdate.
+func (w *SendWindow) Grant(n int64) {
+	if w == nil || n <= 0 {
+		return
+	}
+	w.Lock()
+	w.available += n
+	w.Unlock()
+	select {
+	case w.granted <- struct{}{}:
+	default:
+	}
+}
+
+//
+// Wait blocks until there is credit to send, and returns how much there
+// is.  An error is returned if the context is done first.
+//
+func (w *SendWindow) Wait(ctx context.Context) (int64, error) {
+	if w == nil {
+		return math.MaxInt64, nil
+	}
+	for {
+		w.Lock()
+		available := w.available
+		w.Unlock()
+		if available > 0 {
+			return available, nil
+		}
+		select {
+		case <-w.granted:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// Consume records that n bytes have been sent.
+func (w *SendWindow) Consume(n int) {
+	if w == nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+	w.available -= int64(n)
+}
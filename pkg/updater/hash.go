@@ -24,8 +24,10 @@ package updater
 
 import (
 	"encoding/base64"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"golang.org/x/crypto/sha3"
 )
@@ -43,6 +45,15 @@ func (h *Hash) String() string {
 	return h.Name + ":" + h.Hash
 }
 
+// ParseHash parses a hash in the format returned by String().
+func ParseHash(s string) (*Hash, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return nil, fmt.Errorf("invalid hash format: %s", s)
+	}
+	return &Hash{Name: parts[0], Hash: parts[1]}, nil
+}
+
 // Equals will compare one hash to another, returning true if so.
 func (h *Hash) Equals(b *Hash) bool {
 	return h.Name == b.Name && h.Hash == b.Hash
@@ -58,3 +58,22 @@ func TestEqualsHashDiffers(t *testing.T) {
 		t.Fatalf("Expected %s to not equal %s", h1, h2)
 	}
 }
+
+func TestParseHash(t *testing.T) {
+	h1 := &Hash{Name: "sha3-512", Hash: "abc+/="}
+	h2, err := ParseHash(h1.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h1.Equals(h2) {
+		t.Fatalf("Expected %s to equal %s", h1, h2)
+	}
+}
+
+func TestParseHashInvalid(t *testing.T) {
+	for _, s := range []string{"", "foo", ":bar", "foo:"} {
+		if _, err := ParseHash(s); err == nil {
+			t.Errorf("Expected error parsing %q", s)
+		}
+	}
+}
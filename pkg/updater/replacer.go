@@ -17,14 +17,31 @@
 package updater
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"syscall"
 )
 
+// selfPath is the running binary, found once at startup.  It is resolved
+// from os.Executable() rather than os.Args[0], which need not be a path to
+// the binary, and through any symlinks, so an update replaces the binary
+// itself rather than the link to it.
+var selfPath, selfPathErr = findSelf()
+
+func findSelf() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
 // HashSelf will generate a hash of the currently running binary.
 func HashSelf() (string, error) {
-	selfPath := os.Args[0]
-
+	if selfPathErr != nil {
+		return "", selfPathErr
+	}
 	hash, err := HashFile(selfPath)
 	if err != nil {
 		return "", err
@@ -32,10 +49,40 @@ func HashSelf() (string, error) {
 	return hash.String(), nil
 }
 
-// RestartSelf will replace the currently running binary with the specified binary.
-func RestartSelf(path string) error {
-	if err := syscall.Exec(os.Args[0], os.Args, os.Environ()); err != nil {
+// ReplaceSelf will atomically replace the currently running binary with
+// the file at path, which must be on the same filesystem.  The file is
+// only used if its hash matches the expected hash.
+func ReplaceSelf(path string, expected *Hash) error {
+	if selfPathErr != nil {
+		return selfPathErr
+	}
+	hash, err := HashFile(path)
+	if err != nil {
 		return err
 	}
-	return nil
+	if !hash.Equals(expected) {
+		return fmt.Errorf("hash mismatch: expected %s, got %s", expected, hash)
+	}
+	if err := os.Chmod(path, 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, selfPath)
+}
+
+// TempFileForSelf creates a new temporary file in the same directory as
+// the currently running binary, suitable for passing to ReplaceSelf.
+func TempFileForSelf() (*os.File, error) {
+	if selfPathErr != nil {
+		return nil, selfPathErr
+	}
+	return os.CreateTemp(filepath.Dir(selfPath), ".update-*")
+}
+
+// RestartSelf will run the currently running binary again, with the
+// same arguments, in place of this process.
+func RestartSelf() error {
+	if selfPathErr != nil {
+		return selfPathErr
+	}
+	return syscall.Exec(selfPath, os.Args, os.Environ())
 }
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfPath(t *testing.T) {
+	if selfPathErr != nil {
+		t.Fatal(selfPathErr)
+	}
+	if !filepath.IsAbs(selfPath) {
+		t.Errorf("selfPath %q is not absolute", selfPath)
+	}
+	resolved, err := filepath.EvalSymlinks(selfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != selfPath {
+		t.Errorf("selfPath %q is a symlink to %q", selfPath, resolved)
+	}
+}
+
+func TestTempFileForSelf(t *testing.T) {
+	f, err := TempFileForSelf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if filepath.Dir(f.Name()) != filepath.Dir(selfPath) {
+		t.Errorf("temporary file %s is not beside %s", f.Name(), selfPath)
+	}
+}
@@ -61,3 +61,12 @@ func FailRequest(w http.ResponseWriter, err error, code int) {
 		log.Printf("failed to write entire message in FailRequest: %d of %d bytes written", n, len(errmsg))
 	}
 }
+
+// HTTPStatusClass returns "2xx" for status codes 200 through 299, and so
+// on, for use as a low-cardinality metric label.
+func HTTPStatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import "testing"
+
+func TestHTTPStatusClass(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{200, "2xx"},
+		{204, "2xx"},
+		{302, "3xx"},
+		{404, "4xx"},
+		{502, "5xx"},
+		{0, "unknown"},
+		{600, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := HTTPStatusClass(tt.status); got != tt.want {
+			t.Errorf("HTTPStatusClass(%d) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+//
+// Revoked is an entry in a RevokedSet.
+//
+type Revoked interface {
+	// RevokedKey is the token ID, serial number or similar which was revoked.
+	RevokedKey() string
+	// RevokedTime is when the entry was revoked.
+	RevokedTime() time.Time
+	// ExpiryTime is when whatever was revoked would have expired anyway,
+	// after which the entry is no longer needed.  If zero, it is kept forever.
+	ExpiryTime() time.Time
+}
+
+//
+// RevokedSet holds revoked entries by key, and writes them to a JSON file
+// if one is set.  Entries are dropped once they have expired.  It is not
+// safe for concurrent use, and callers are expected to hold their own lock.
+//
+type RevokedSet struct {
+	filename string
+	what     string
+	items    map[string]Revoked
+}
+
+//
+// LoadRevokedSet loads a set from the file, using decode to parse its
+// contents.  A missing file is an empty set, and will be created when
+// the first entry is added.  If filename is empty, the set is kept only
+// in memory.  what names the set in error messages.
+//
+func LoadRevokedSet(filename string, what string, decode func([]byte) ([]Revoked, error)) (*RevokedSet, error) {
+	s := &RevokedSet{
+		filename: filename,
+		what:     what,
+		items:    map[string]Revoked{},
+	}
+	if len(filename) == 0 {
+		return s, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", what, err)
+	}
+	list, err := decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s %s: %v", what, filename, err)
+	}
+	for _, item := range list {
+		s.items[item.RevokedKey()] = item
+	}
+	s.prune(time.Now())
+	return s, nil
+}
+
+//
+// Get returns the entry for key, if it has been revoked.
+//
+func (s *RevokedSet) Get(key string) (Revoked, bool) {
+	item, found := s.items[key]
+	return item, found
+}
+
+//
+// Add adds the entries whose keys are not already present, drops any
+// which have expired, and saves the set.  The entries added are returned.
+// If the set cannot be saved, it is left as it was.
+//
+func (s *RevokedSet) Add(items ...Revoked) ([]Revoked, error) {
+	now := time.Now()
+	added := []Revoked{}
+	for _, item := range items {
+		if _, found := s.items[item.RevokedKey()]; found || expired(item, now) {
+			continue
+		}
+		s.items[item.RevokedKey()] = item
+		added = append(added, item)
+	}
+	pruned := s.prune(now)
+	if len(added) == 0 && len(pruned) == 0 {
+		return added, nil
+	}
+	if err := s.save(); err != nil {
+		for _, item := range added {
+			delete(s.items, item.RevokedKey())
+		}
+		for _, item := range pruned {
+			s.items[item.RevokedKey()] = item
+		}
+		return nil, err
+	}
+	return added, nil
+}
+
+//
+// List returns all entries, oldest first.
+//
+func (s *RevokedSet) List() []Revoked {
+	ret := make([]Revoked, 0, len(s.items))
+	for _, item := range s.items {
+		ret = append(ret, item)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].RevokedTime().Equal(ret[j].RevokedTime()) {
+			return ret[i].RevokedKey() < ret[j].RevokedKey()
+		}
+		return ret[i].RevokedTime().Before(ret[j].RevokedTime())
+	})
+	return ret
+}
+
+func expired(item Revoked, now time.Time) bool {
+	expiry := item.ExpiryTime()
+	return !expiry.IsZero() && !now.Before(expiry)
+}
+
+// prune removes expired entries, and returns them.
+func (s *RevokedSet) prune(now time.Time) []Revoked {
+	pruned := []Revoked{}
+	for key, item := range s.items {
+		if expired(item, now) {
+			delete(s.items, key)
+			pruned = append(pruned, item)
+		}
+	}
+	return pruned
+}
+
+// save writes the set to a temporary file which is then renamed, so a
+// crash cannot leave a partially written file behind.
+func (s *RevokedSet) save() error {
+	if len(s.filename) == 0 {
+		return nil
+	}
+	content, err := json.MarshalIndent(s.List(), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.filename), "."+filepath.Base(s.filename)+"-*")
+	if err != nil {
+		return fmt.Errorf("unable to save %s: %v", s.what, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to save %s: %v", s.what, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to save %s: %v", s.what, err)
+	}
+	if err := os.Rename(f.Name(), s.filename); err != nil {
+		return fmt.Errorf("unable to save %s: %v", s.what, err)
+	}
+	return nil
+}
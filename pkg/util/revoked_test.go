@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 OpsMx, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License")
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testRevoked struct {
+	Key     string    `json:"key"`
+	At      time.Time `json:"at"`
+	Expires time.Time `json:"expires"`
+}
+
+func (r testRevoked) RevokedKey() string     { return r.Key }
+func (r testRevoked) RevokedTime() time.Time { return r.At }
+func (r testRevoked) ExpiryTime() time.Time  { return r.Expires }
+
+func loadTestRevokedSet(t *testing.T, filename string) *RevokedSet {
+	s, err := LoadRevokedSet(filename, "test set", func(content []byte) ([]Revoked, error) {
+		var list []testRevoked
+		if err := json.Unmarshal(content, &list); err != nil {
+			return nil, err
+		}
+		ret := make([]Revoked, len(list))
+		for i, item := range list {
+			ret[i] = item
+		}
+		return ret, nil
+	})
+	if err != nil {
+		t.Fatalf("LoadRevokedSet() = %v", err)
+	}
+	return s
+}
+
+func revokedKeys(list []Revoked) []string {
+	ret := []string{}
+	for _, item := range list {
+		ret = append(ret, item.RevokedKey())
+	}
+	return ret
+}
+
+func TestRevokedSet(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "set.json")
+	base := time.Now().Add(-time.Hour)
+
+	s := loadTestRevokedSet(t, filename)
+	added, err := s.Add(
+		testRevoked{Key: "b", At: base},
+		testRevoked{Key: "a", At: base},
+		testRevoked{Key: "c", At: base.Add(-time.Minute), Expires: time.Now().Add(time.Hour)},
+		testRevoked{Key: "expired", At: base, Expires: time.Now().Add(-time.Minute)},
+	)
+	if err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	if got := revokedKeys(added); len(got) != 3 {
+		t.Errorf("Add() added %v, want 3 entries", got)
+	}
+	if added, _ := s.Add(testRevoked{Key: "a", At: time.Now()}); len(added) != 0 {
+		t.Errorf("Add() of an existing key added %v", revokedKeys(added))
+	}
+	if item, found := s.Get("a"); !found || !item.RevokedTime().Equal(base) {
+		t.Errorf("Get(a) = %v, %v", item, found)
+	}
+
+	s2 := loadTestRevokedSet(t, filename)
+	want := "c,a,b"
+	got := revokedKeys(s2.List())
+	if len(got) != 3 || got[0]+","+got[1]+","+got[2] != want {
+		t.Errorf("List() = %v, want %s", got, want)
+	}
+}
+
+func TestRevokedSet_saveFailure(t *testing.T) {
+	s := loadTestRevokedSet(t, filepath.Join(t.TempDir(), "missing", "set.json"))
+	if _, err := s.Add(testRevoked{Key: "a", At: time.Now()}); err == nil {
+		t.Fatal("Add() expected an error")
+	}
+	if _, found := s.Get("a"); found {
+		t.Errorf("entry kept after failing to save")
+	}
+}
+
+func TestRevokedSet_memoryOnly(t *testing.T) {
+	s := loadTestRevokedSet(t, "")
+	if _, err := s.Add(testRevoked{Key: "a", At: time.Now()}); err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+	if _, found := s.Get("a"); !found {
+		t.Errorf("Get(a) not found")
+	}
+}